@@ -10,7 +10,6 @@ import (
 
 	"github.com/G-Node/gin-cli/ginclient/log"
 	"github.com/G-Node/gin-cli/gincmd/ginerrors"
-	"github.com/fatih/color"
 	"github.com/shibukawa/configdir"
 	"github.com/spf13/viper"
 )
@@ -21,7 +20,6 @@ const (
 
 var (
 	configDirs = configdir.New("g-node", "gin")
-	yellow     = color.New(color.FgYellow).SprintFunc()
 
 	ginDefaultServer = ServerCfg{
 		WebCfg{
@@ -47,11 +45,33 @@ var (
 		"annex.minsize": "10M",
 		"servers.gin":   ginDefaultServer,
 		"defaultserver": "gin",
+
+		// Version command
+		"version.maxcount": 10,
+		"version.select":   VersionSelectPrompt,
+
+		// Commit signing
+		"commit.sign":    false,
+		"commit.signkey": "",
+
+		// Upload checks
+		"upload.sizelimit": "",
+
+		// Clone pack settings
+		"clone.compression": -1,
+		"clone.packthreads": 0,
 	}
 
 	// configuration cache: used to avoid rereading during a single command invocation
 	configuration GinCliCfg
 	set           = false
+
+	// warnings found while reading the configuration
+	warnings []string
+
+	// repoConfigKeys lists the options that can be set in a repository configuration file.
+	// Repository configuration files are shared with everyone who has a copy of the repository, so options that specify programs to run (bin, hooks) or where to send data and credentials (servers) are never read from them.
+	repoConfigKeys = []string{"annex.exclude", "annex.minsize", "annex.noannex", "defaultserver"}
 )
 
 // Types
@@ -61,6 +81,12 @@ type WebCfg struct {
 	Protocol string
 	Host     string
 	Port     uint16
+
+	// AuthUser and AuthPassword are the credentials for an HTTP basic authentication layer in front of the server (e.g., a reverse proxy), not for the user's account.
+	AuthUser     string
+	AuthPassword string
+	// Netrc enables reading the credentials for the HTTP authentication layer from the user's netrc file if AuthUser is not set.
+	Netrc bool
 }
 
 // AddressStr constructs a full address string from the configuration.
@@ -75,6 +101,10 @@ type GitCfg struct {
 	Host    string
 	Port    uint16
 	HostKey string
+	// KeyFile is the path to an existing private key to use for the server instead of the key generated on login.
+	KeyFile string
+	// HTTPS enables cloning repositories over the web server's HTTPS address instead of ssh.
+	HTTPS bool
 }
 
 // AddressStr constructs a full address string from the configuration.
@@ -101,6 +131,54 @@ type BinCfg struct {
 type AnnexCfg struct {
 	Exclude []string
 	MinSize string
+	// NoAnnex disables git-annex: all files are added to git and new repositories are initialised without an annex.
+	NoAnnex bool
+}
+
+// Values for VersionCfg.Select
+const (
+	// VersionSelectPrompt prompts for the version to return to.
+	VersionSelectPrompt = "prompt"
+	// VersionSelectLatest returns to the most recent version without prompting.
+	VersionSelectLatest = "latest"
+)
+
+// CommitCfg holds the options for the commits made by the client.
+type CommitCfg struct {
+	// Sign enables signing commits with GPG.
+	Sign bool
+	// SignKey is the ID of the GPG key to sign with (default key of the user if empty).
+	SignKey string
+}
+
+// UploadCfg holds the options for uploads.
+type UploadCfg struct {
+	// SizeLimit is the maximum size of a repository on the server (e.g., 10GB); uploads that would exceed it are refused. No limit is checked if empty.
+	SizeLimit string
+}
+
+// CloneCfg holds the defaults for the pack that is received when a repository is cloned.
+type CloneCfg struct {
+	// Compression is the zlib compression level (0-9) of the objects written during the clone (-1 uses the default of git).
+	Compression int
+	// PackThreads is the number of threads used to process the received pack (0 uses one thread per CPU).
+	PackThreads int
+}
+
+// VersionCfg holds the defaults for the version command.
+type VersionCfg struct {
+	// MaxCount is the number of versions listed when no --max-count is given (0 means all).
+	MaxCount uint
+	// Select is the behaviour when no version ID is given: VersionSelectPrompt or VersionSelectLatest.
+	Select string
+}
+
+// HooksCfg holds shell commands that are run after successful operations.
+// Hooks are only read from the user configuration file, never from a repository configuration file.
+type HooksCfg struct {
+	Upload   string
+	Download string
+	Get      string
 }
 
 // GinCliCfg holds the client configuration values.
@@ -109,6 +187,14 @@ type GinCliCfg struct {
 	DefaultServer string
 	Bin           BinCfg
 	Annex         AnnexCfg
+	Hooks         HooksCfg
+	Commit        CommitCfg
+	Upload        UploadCfg
+	Clone         CloneCfg
+	Version       VersionCfg
+	LogFile       string
+	// CleanupStaleKeys enables removing keys left over from earlier logins on the same host when logging in.
+	CleanupStaleKeys bool
 }
 
 // Read loads in the configuration from the config file(s), merges any defined values into the default configuration, and returns a populated GinConfiguration struct.
@@ -137,7 +223,17 @@ func Read() GinCliCfg {
 
 	removeInvalidServerConfs()
 
-	// configuration file in the repository root (annex excludes and size threshold only)
+	if sel := configuration.Version.Select; sel != VersionSelectPrompt && sel != VersionSelectLatest {
+		warn("invalid value '%s' for version.select (valid values: %s, %s): using '%s'", sel, VersionSelectPrompt, VersionSelectLatest, VersionSelectPrompt)
+		configuration.Version.Select = VersionSelectPrompt
+	}
+
+	// NOTE: Anything read after this point may be set by a configuration file
+	// in the repository; only the keys listed in repoConfigKeys may be read
+	// after this point
+
+	// configuration file in the repository root
+	// Only the options listed in repoConfigKeys are read from this file
 	reporoot, err := findreporoot(".")
 	if err == nil {
 		confpath := filepath.Join(reporoot, defaultFileName)
@@ -145,10 +241,19 @@ func Read() GinCliCfg {
 		cerr = viper.MergeInConfig()
 		if cerr == nil {
 			log.Write("Found config file %s", confpath)
+			checkRepoConfigKeys(confpath)
 		}
 	}
 	configuration.Annex.Exclude = viper.GetStringSlice("annex.exclude")
 	configuration.Annex.MinSize = viper.GetString("annex.minsize")
+	configuration.Annex.NoAnnex = viper.GetBool("annex.noannex")
+	if defserver := viper.GetString("defaultserver"); defserver != configuration.DefaultServer {
+		if _, ok := configuration.Servers[defserver]; ok {
+			configuration.DefaultServer = defserver
+		} else {
+			warn("default server '%s' set in repository configuration does not exist: using '%s'", defserver, configuration.DefaultServer)
+		}
+	}
 
 	// if Bin.GitAnnex is set but Bin.GitAnnexPath is not, set the path
 	if configuration.Bin.GitAnnexPath == "" && configuration.Bin.GitAnnex != "" {
@@ -160,11 +265,32 @@ func Read() GinCliCfg {
 	return configuration
 }
 
+// checkRepoConfigKeys warns about any options in the repository configuration file at confpath that are not allowed in repository configurations (see repoConfigKeys).
+func checkRepoConfigKeys(confpath string) {
+	v := viper.New()
+	v.SetConfigFile(confpath)
+	if v.ReadInConfig() != nil {
+		return
+	}
+	for _, key := range v.AllKeys() {
+		allowed := false
+		for _, rkey := range repoConfigKeys {
+			if key == rkey {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			warn("option '%s' is not allowed in repository configuration file %s: option ignored", key, confpath)
+		}
+	}
+}
+
 func removeInvalidServerConfs() {
 	// Check server configurations for invalid names and port numbers
 	for alias := range viper.GetStringMap("servers") {
 		if alias == "dir" {
-			fmt.Fprintf(color.Error, "%s server alias '%s' is not allowed (reserved word): server configuration ignored\n", yellow("[warning]"), alias)
+			warn("server alias '%s' is not allowed (reserved word): server configuration ignored", alias)
 			delete(configuration.Servers, alias)
 			continue
 		}
@@ -172,16 +298,31 @@ func removeInvalidServerConfs() {
 		gitport := viper.GetInt(fmt.Sprintf("servers.%s.git.port", alias))
 		if webport < 0 || webport > 65535 || gitport < 0 || gitport > 65535 {
 			if alias == "gin" {
-				fmt.Fprintf(color.Error, "%s invalid value found in configuration for '%s': using default\n", yellow("[warning]"), alias)
+				warn("invalid value found in configuration for '%s': using default", alias)
 				configuration.Servers["gin"] = ginDefaultServer
 			} else {
-				fmt.Fprintf(color.Error, "%s invalid value found in configuration for '%s': server configuration ignored\n", yellow("[warning]"), alias)
+				warn("invalid value found in configuration for '%s': server configuration ignored", alias)
 				delete(configuration.Servers, alias)
 			}
 		}
 	}
 }
 
+// warn logs a warning about the configuration and keeps it for retrieval with Warnings.
+func warn(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	log.Write("Configuration warning: %s", msg)
+	warnings = append(warnings, msg)
+}
+
+// Warnings returns the warnings that were found while reading the configuration and clears them.
+// The configuration package does not print warnings itself; it is up to the caller to display them.
+func Warnings() []string {
+	w := warnings
+	warnings = nil
+	return w
+}
+
 // SetConfig appends a key-value to the configuration file.  A useful
 // utility function that loads the configuration only from the file, adds the
 // new key-value pair, and saves it back, without loading the built-in
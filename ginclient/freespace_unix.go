@@ -0,0 +1,15 @@
+//go:build !windows
+// +build !windows
+
+package ginclient
+
+import "syscall"
+
+// freeSpace returns the number of bytes available to the user on the filesystem that contains path.
+func freeSpace(path string) (uint64, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), nil
+}
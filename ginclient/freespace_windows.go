@@ -0,0 +1,27 @@
+package ginclient
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+var procGetDiskFreeSpaceEx = syscall.NewLazyDLL("kernel32.dll").NewProc("GetDiskFreeSpaceExW")
+
+// freeSpace returns the number of bytes available to the user on the volume that contains path.
+func freeSpace(path string) (uint64, error) {
+	pathptr, err := syscall.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, err
+	}
+	var available, total, free uint64
+	ret, _, err := procGetDiskFreeSpaceEx.Call(
+		uintptr(unsafe.Pointer(pathptr)),
+		uintptr(unsafe.Pointer(&available)),
+		uintptr(unsafe.Pointer(&total)),
+		uintptr(unsafe.Pointer(&free)),
+	)
+	if ret == 0 {
+		return 0, err
+	}
+	return available, nil
+}
@@ -7,6 +7,8 @@ import (
 	"os"
 
 	"net/http"
+	"net/url"
+	"strings"
 
 	"github.com/G-Node/gin-cli/ginclient/config"
 	"github.com/G-Node/gin-cli/ginclient/log"
@@ -40,7 +42,29 @@ func New(alias string) *Client {
 	if !ok {
 		return &Client{Client: web.New(""), srvalias: ""}
 	}
-	return &Client{Client: web.New(srvcfg.Web.AddressStr()), srvalias: alias}
+	webcl := web.New(srvcfg.Web.AddressStr())
+	webcl.HTTPAuth = httpAuth(srvcfg.Web)
+	return &Client{Client: webcl, srvalias: alias}
+}
+
+// httpAuth returns the credentials for the HTTP authentication layer of a server, if it has one.
+// Credentials in the server configuration take precedence over the user's netrc file, which is only read if enabled for the server.
+func httpAuth(webcfg config.WebCfg) *web.HTTPAuth {
+	if webcfg.AuthUser != "" {
+		return &web.HTTPAuth{Username: webcfg.AuthUser, Password: webcfg.AuthPassword}
+	}
+	if !webcfg.Netrc {
+		return nil
+	}
+	auth, err := web.NetrcAuth(webcfg.Host)
+	if err != nil {
+		log.Write("Failed to read HTTP credentials for %s: %v", webcfg.Host, err)
+		return nil
+	}
+	if auth == nil {
+		log.Write("No netrc entry found for %s", webcfg.Host)
+	}
+	return auth
 }
 
 // AccessToken represents a API access token.
@@ -53,6 +77,8 @@ type AccessToken struct {
 type Client struct {
 	*web.Client
 	srvalias string
+	// keyfile is the private key file set with SetKeyFile, which is stored in the configuration on login
+	keyfile string
 }
 
 // GitAddress returns the full address string for the configured git server
@@ -63,6 +89,49 @@ func (gincl *Client) GitAddress() string {
 	return config.Read().Servers[gincl.srvalias].Git.AddressStr()
 }
 
+// HTTPSAddress returns the HTTPS address for cloning the repository at 'repopath' from the configured web server.
+// The address does not include any credentials: git commands authenticate with the user's access token (see ServerCredentials), so the user must be logged in.
+// Repositories cloned from this address can be used without ssh.
+func (gincl *Client) HTTPSAddress(repopath string) (string, error) {
+	if gincl.Username == "" || gincl.Token == "" {
+		return "", fmt.Errorf("you must be logged in to use HTTPS addresses")
+	}
+	webcfg := config.Read().Servers[gincl.srvalias].Web
+	return fmt.Sprintf("%s/%s.git", httpsBase(webcfg), repopath), nil
+}
+
+// httpsBase returns the address of a web server (scheme://host:port) that is the prefix of the HTTPS addresses of its repositories.
+func httpsBase(webcfg config.WebCfg) string {
+	u := url.URL{
+		Scheme: webcfg.Protocol,
+		Host:   fmt.Sprintf("%s:%d", webcfg.Host, webcfg.Port),
+	}
+	return u.String()
+}
+
+// ServerCredentials returns the credentials for the HTTPS addresses of all configured servers that the user is logged in to.
+// They should be passed to git (see git.HTTPCredentials), which uses them for repositories cloned from an HTTPS address (see HTTPSAddress).
+func ServerCredentials() []git.HTTPCredential {
+	var creds []git.HTTPCredential
+	for alias, srvcfg := range config.Read().Servers {
+		ut := web.UserToken{}
+		if ut.LoadToken(alias) != nil || ut.Token == "" {
+			continue
+		}
+		creds = append(creds, git.HTTPCredential{URL: httpsBase(srvcfg.Web), Username: ut.Username, Password: ut.Token})
+	}
+	return creds
+}
+
+// RemoteAddress returns the address for cloning the repository at 'repopath'.
+// This is the ssh address of the configured git server, unless HTTPS is enabled in the server's configuration (servers.<alias>.git.https), in which case the HTTPS address is returned (see HTTPSAddress).
+func (gincl *Client) RemoteAddress(repopath string) (string, error) {
+	if gincl.srvalias != "" && config.Read().Servers[gincl.srvalias].Git.HTTPS {
+		return gincl.HTTPSAddress(repopath)
+	}
+	return fmt.Sprintf("%s/%s", gincl.GitAddress(), repopath), nil
+}
+
 // WebAddress returns the full address string for the configured web server
 func (gincl *Client) WebAddress() string {
 	return config.Read().Servers[gincl.srvalias].Web.AddressStr()
@@ -70,12 +139,27 @@ func (gincl *Client) WebAddress() string {
 
 // GetUserKeys fetches the public keys that the user has added to the auth server.
 func (gincl *Client) GetUserKeys() ([]gogs.PublicKey, error) {
-	fn := "GetUserKeys()"
-	var keys []gogs.PublicKey
 	res, err := gincl.Get("/api/v1/user/keys")
 	if err != nil {
 		return nil, err // return error from Get() directly
 	}
+	defer web.CloseRes(res.Body)
+	return readUserKeys(res, "GetUserKeys()")
+}
+
+// GetUserKeysBasicAuth is like GetUserKeys but authenticates with the user's password instead of the stored token.
+func (gincl *Client) GetUserKeysBasicAuth(username, password string) ([]gogs.PublicKey, error) {
+	res, err := gincl.GetBasicAuth("/api/v1/user/keys", username, password)
+	if err != nil {
+		return nil, err // return error from GetBasicAuth directly
+	}
+	defer web.CloseRes(res.Body)
+	return readUserKeys(res, "GetUserKeysBasicAuth()")
+}
+
+// readUserKeys checks the response of a key listing request and returns the keys it contains.
+func readUserKeys(res *http.Response, fn string) ([]gogs.PublicKey, error) {
+	var keys []gogs.PublicKey
 	switch code := res.StatusCode; {
 	case code == http.StatusUnauthorized:
 		return nil, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
@@ -85,8 +169,6 @@ func (gincl *Client) GetUserKeys() ([]gogs.PublicKey, error) {
 		return nil, ginerror{UError: res.Status, Origin: fn} // Unexpected error
 	}
 
-	defer web.CloseRes(res.Body)
-
 	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		return nil, ginerror{UError: err.Error(), Origin: fn, Description: "failed to read response body"}
@@ -106,6 +188,7 @@ func (gincl *Client) RequestAccount(name string) (gogs.User, error) {
 	if err != nil {
 		return acc, err // return error from Get() directly
 	}
+	defer web.CloseRes(res.Body)
 	switch code := res.StatusCode; {
 	case code == http.StatusNotFound:
 		return acc, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("requested user '%s' does not exist", name)}
@@ -117,7 +200,35 @@ func (gincl *Client) RequestAccount(name string) (gogs.User, error) {
 		return acc, ginerror{UError: res.Status, Origin: fn} // Unexpected error
 	}
 
+	b, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return acc, ginerror{UError: err.Error(), Origin: fn, Description: "failed to read response body"}
+	}
+	err = json.Unmarshal(b, &acc)
+	if err != nil {
+		err = ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+	}
+	return acc, err
+}
+
+// CurrentUser requests the account of the user that owns the Client's token.
+// It can be used to check that a token is valid.
+func (gincl *Client) CurrentUser() (gogs.User, error) {
+	fn := "CurrentUser()"
+	var acc gogs.User
+	res, err := gincl.Get("/api/v1/user")
+	if err != nil {
+		return acc, err // return error from Get() directly
+	}
 	defer web.CloseRes(res.Body)
+	switch code := res.StatusCode; {
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		return acc, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed: invalid token"}
+	case code == http.StatusInternalServerError:
+		return acc, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+	case code != http.StatusOK:
+		return acc, ginerror{UError: res.Status, Origin: fn} // Unexpected error
+	}
 
 	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
@@ -130,6 +241,20 @@ func (gincl *Client) RequestAccount(name string) (gogs.User, error) {
 	return acc, err
 }
 
+// Ping checks that the server is reachable and that it accepts the Client's token.
+// It returns an error describing the problem if the server cannot be reached, the user is not logged in, or the token has expired or been revoked.
+func (gincl *Client) Ping() error {
+	fn := "Ping()"
+	if gincl.Token == "" {
+		return ginerror{Origin: fn, Description: "you are not logged in"}
+	}
+	_, err := gincl.CurrentUser()
+	if gerr, ok := err.(ginerror); ok && (strings.HasPrefix(gerr.UError, "401") || strings.HasPrefix(gerr.UError, "403")) {
+		return ginerror{UError: gerr.UError, Origin: fn, Description: "your login session has expired or is no longer valid"}
+	}
+	return err
+}
+
 // AddKey adds the given key to the current user's authorised keys.
 // If force is enabled, any key which matches the new key's description will be overwritten.
 func (gincl *Client) AddKey(key, description string, force bool) error {
@@ -144,6 +269,7 @@ func (gincl *Client) AddKey(key, description string, force bool) error {
 	if err != nil {
 		return err // return error from Post() directly
 	}
+	defer web.CloseRes(res.Body)
 	switch code := res.StatusCode; {
 	case code == http.StatusUnprocessableEntity:
 		return ginerror{UError: res.Status, Origin: fn, Description: "invalid key or key with same name already exists"}
@@ -154,7 +280,6 @@ func (gincl *Client) AddKey(key, description string, force bool) error {
 	case code != http.StatusCreated:
 		return ginerror{UError: res.Status, Origin: fn} // Unexpected error
 	}
-	web.CloseRes(res.Body)
 	return nil
 }
 
@@ -164,10 +289,10 @@ func (gincl *Client) DeletePubKey(id int64) error {
 
 	address := fmt.Sprintf("/api/v1/user/keys/%d", id)
 	res, err := gincl.Delete(address)
-	defer web.CloseRes(res.Body)
 	if err != nil {
 		return err // Return error from Delete() directly
 	}
+	defer web.CloseRes(res.Body)
 	switch code := res.StatusCode; {
 	case code == http.StatusInternalServerError:
 		return ginerror{UError: res.Status, Origin: fn, Description: "server error"}
@@ -258,7 +383,35 @@ func (gincl *Client) Login(username, password, clientID string) error {
 	}
 
 	// Make keys
-	return gincl.MakeSessionKey()
+	return gincl.registerKey()
+}
+
+// LoginWithToken validates a pre-generated access token with the server and stores it along with the name of the user that owns it.
+// If 'username' is not empty, it must match the owner of the token.
+// A key pair for use in git commands is generated only if 'makekey' is true.
+// (See also Login)
+func (gincl *Client) LoginWithToken(username, token string, makekey bool) error {
+	gincl.UserToken.Username = ""
+	gincl.UserToken.Token = token
+	acc, err := gincl.CurrentUser()
+	if err != nil {
+		return err
+	}
+	if username != "" && acc.UserName != username {
+		return fmt.Errorf("token belongs to user '%s', not '%s'", acc.UserName, username)
+	}
+	gincl.UserToken.Username = acc.UserName
+	log.Write("Token valid. Username: %s", acc.UserName)
+
+	err = gincl.StoreToken(gincl.srvalias)
+	if err != nil {
+		return fmt.Errorf("Error while storing token: %s", err.Error())
+	}
+
+	if !makekey {
+		return nil
+	}
+	return gincl.registerKey()
 }
 
 // GetTokens returns all the user's active access tokens from the GIN server.
@@ -269,6 +422,7 @@ func (gincl *Client) GetTokens(username, password string) ([]AccessToken, error)
 	if err != nil {
 		return nil, err // return error from GetBasicAuth directly
 	}
+	defer web.CloseRes(res.Body)
 	switch code := res.StatusCode; {
 	case code == http.StatusInternalServerError:
 		return nil, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
@@ -300,6 +454,7 @@ func (gincl *Client) NewToken(username, password, clientID string) error {
 	if err != nil {
 		return err // return error from PostBasicAuth directly
 	}
+	defer web.CloseRes(res.Body)
 	switch code := res.StatusCode; {
 	case code == http.StatusInternalServerError:
 		return ginerror{UError: res.Status, Origin: fn, Description: "server error"}
@@ -330,26 +485,21 @@ func (gincl *Client) LoadToken() error {
 
 // Logout logs out the currently logged in user in 3 steps:
 // 1. Remove the public key matching the current hostname from the server.
-// 2. Delete the private key file from the local machine.
+// 2. Delete the private key file from the local machine (unless it is a key file configured by the user).
 // 3. Delete the user token.
 func (gincl *Client) Logout() {
 	// 1. Delete public key
-	hostname, err := os.Hostname()
-	if err != nil {
-		log.Write("Could not retrieve hostname")
-		hostname = unknownhostname
-	}
-
-	currentkeyname := fmt.Sprintf("GIN Client: %s@%s", gincl.Username, hostname)
-	err = gincl.DeletePubKeyByTitle(currentkeyname)
+	currentkeyname := gincl.keyTitle()
+	err := gincl.DeletePubKeyByTitle(currentkeyname)
 	if err != nil {
 		log.Write(err.Error())
 	}
 
-	// 2. Delete private key
-	privKeyFiles := git.PrivKeyPath()
-	err = os.Remove(privKeyFiles[gincl.srvalias])
-	if err != nil {
+	// 2. Delete private key (only if it was generated on login)
+	sessionkey, _ := git.SessionKeyPath(gincl.srvalias)
+	if config.Read().Servers[gincl.srvalias].Git.KeyFile != "" {
+		log.Write("Using configured key file; not deleting")
+	} else if err = os.Remove(sessionkey); err != nil {
 		log.Write("Error deleting key file")
 	} else {
 		log.Write("Private key file deleted")
@@ -379,6 +529,7 @@ func SetDefaultServer(alias string) error {
 }
 
 // RemoveServer removes a server from the user configuration.
+// The stored login token for the server, if any, is deleted as well, so that a new server added with the same alias does not inherit it.
 // Returns with error if no server with the given alias exists.
 func RemoveServer(alias string) error {
 	conf := config.Read()
@@ -386,5 +537,8 @@ func RemoveServer(alias string) error {
 		return fmt.Errorf("server with alias '%s' does not exist", alias)
 	}
 	config.RmServerConf(alias)
+	if (&web.UserToken{}).LoadToken(alias) == nil {
+		web.DeleteToken(alias)
+	}
 	return nil
 }
@@ -6,41 +6,103 @@ import (
 	"log"
 	"os"
 	"path"
+	"path/filepath"
+	"sync"
 
 	"github.com/shibukawa/configdir"
 )
 
-var logfile *os.File
+var logfile *rotatingFile
 var logger *log.Logger
 
+// mu protects logfile and logger from being replaced while in use
+var mu sync.RWMutex
+
 var configDirs = configdir.New("g-node", "gin")
 
 const loglimit = 1048576 // 1 MiB
 
-// trim reduces the size of a file to `loglimit`.
-// It reads the contents and writes them back, removing the initial bytes to fit the limit.
-// If any error occurs, it returns silently.
-func trim(file *os.File) {
-	filestat, err := file.Stat()
-	if err != nil {
-		return
+// logkeep is the number of rotated log files that are kept in addition to the active one.
+const logkeep = 3
+
+// rotatingFile is a log file that is rotated when its size exceeds loglimit.
+// When rotated, the file is renamed by appending '.1' to its name (shifting existing rotated files to '.2', '.3', etc.) and a new, empty file is created in its place.
+// Only 'logkeep' rotated files are kept.
+// All operations are safe for concurrent use.
+type rotatingFile struct {
+	mu   sync.Mutex
+	name string
+	file *os.File
+	size int64
+}
+
+// openRotatingFile opens (or creates) the log file at the given path for appending.
+func openRotatingFile(name string) (*rotatingFile, error) {
+	rf := &rotatingFile{name: name}
+	if err := rf.open(); err != nil {
+		return nil, err
 	}
-	fsize := filestat.Size()
-	if fsize < loglimit {
-		return
+	return rf, nil
+}
+
+// open opens the file for appending and records its current size.
+// The caller must hold the lock (or have exclusive access to rf).
+func (rf *rotatingFile) open() error {
+	file, err := os.OpenFile(rf.name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("Error creating file %s", rf.name)
 	}
-	contents := make([]byte, loglimit)
-	offset := fsize - loglimit
-	_, err = file.ReadAt(contents, offset)
+	info, err := file.Stat()
 	if err != nil {
-		return
+		file.Close()
+		return err
+	}
+	rf.file = file
+	rf.size = info.Size()
+	return nil
+}
+
+// rotate closes the file, shifts the rotated files, removing the oldest, and reopens an empty file.
+// The caller must hold the lock.
+func (rf *rotatingFile) rotate() error {
+	rf.file.Close()
+	os.Remove(fmt.Sprintf("%s.%d", rf.name, logkeep))
+	for idx := logkeep - 1; idx > 0; idx-- {
+		os.Rename(fmt.Sprintf("%s.%d", rf.name, idx), fmt.Sprintf("%s.%d", rf.name, idx+1))
+	}
+	// if renaming fails, the existing file is reopened and appended to
+	os.Rename(rf.name, rf.name+".1")
+	return rf.open()
+}
+
+// Write appends p to the file, rotating it first if the write would exceed the size limit.
+func (rf *rotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		return 0, os.ErrClosed
 	}
-	// Close logfile, recreate (empty), and write the trimmed contents
-	file.Close()
-	file, err = os.Create(file.Name())
-	if err == nil {
-		file.Write(contents)
+	if rf.size > 0 && rf.size+int64(len(p)) > loglimit {
+		if err := rf.rotate(); err != nil {
+			rf.file = nil
+			return 0, err
+		}
 	}
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+// Close closes the file.
+func (rf *rotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
 }
 
 // Init initialises the log file and logger.
@@ -50,9 +112,9 @@ func Init() error {
 		return err
 	}
 	logpath := path.Join(cachepath, "gin.log")
-	logfile, err = os.OpenFile(logpath, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	logfile, err = openRotatingFile(logpath)
 	if err != nil {
-		return fmt.Errorf("Error creating file %s", logpath)
+		return err
 	}
 
 	flags := log.Ldate | log.Ltime | log.LUTC
@@ -63,6 +125,42 @@ func Init() error {
 	return nil
 }
 
+// SetFile switches logging to the file at the given path.
+// The directory containing the file is created if necessary.
+// If the new file can't be opened, logging continues in the current file.
+func SetFile(logpath string) error {
+	if err := os.MkdirAll(filepath.Dir(logpath), 0755); err != nil {
+		return fmt.Errorf("could not create log directory %s", filepath.Dir(logpath))
+	}
+	newfile, err := openRotatingFile(logpath)
+	if err != nil {
+		return err
+	}
+	flags := log.Ldate | log.Ltime | log.LUTC
+	newlogger := log.New(newfile, "", flags)
+	mu.Lock()
+	defer mu.Unlock()
+	if logger != nil {
+		logger.Printf("Switching log file to %s", logpath)
+		logfile.Close()
+	}
+	logfile = newfile
+	logger = newlogger
+	logger.Print("=== LOGINIT ===")
+	return nil
+}
+
+// Path returns the path of the active log file.
+// It returns an empty string if the log file is not initialised.
+func Path() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	if logfile == nil {
+		return ""
+	}
+	return logfile.name
+}
+
 // mklogdir returns the path where gin cache files (logs) should be stored.
 func mklogdir(create bool) (string, error) {
 	var err error
@@ -81,7 +179,10 @@ func mklogdir(create bool) (string, error) {
 
 // Write writes a string to the log file. Nothing happens if the log file is not initialised (see LogInit).
 // Depending on the number of arguments passed, Write either behaves as a Print or a Printf. The first argument must always be a string. If more than one argument is given, the function behaves as Printf.
+// Write is safe for concurrent use.
 func Write(fmtstr string, args ...interface{}) {
+	mu.RLock()
+	defer mu.RUnlock()
 	if logger == nil {
 		return
 	}
@@ -98,7 +199,11 @@ func WriteError(err error) {
 
 // Close closes the log file.
 func Close() {
-	Write("=== LOGEND ===")
-	trim(logfile)
+	mu.RLock()
+	defer mu.RUnlock()
+	if logfile == nil {
+		return
+	}
+	logger.Print("=== LOGEND ===")
 	_ = logfile.Close()
 }
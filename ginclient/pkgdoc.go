@@ -1,4 +1,26 @@
 /*
 Package ginclient augments the web package with functions to interact with GIN Gogs (https://github.com/G-Node/gogs) specifically.
+
+The package can be used as a library for building GIN clients.
+It never prints to the standard output or exits the program.
+All failures are returned to the caller, either as error values or as the Err field of the status messages sent on channels.
+
+# Clients
+
+A Client is created with New for a configured server alias.
+Client methods that communicate with the server's web API (e.g., Login, ListRepos, CreateRepo) require a valid token, which is loaded with LoadToken after a successful Login.
+
+# Repository operations
+
+Functions and methods that operate on a local repository (e.g., Upload, GetContent, LockContent, ListFiles) act on the repository in the current working directory.
+Long running operations report their progress by sending git.RepoFileStatus messages on a channel provided by the caller.
+These functions are meant to be run in a goroutine; they close the channel when they return, so the caller can range over it until the operation completes.
+Operations that accept a context.Context stop the underlying git and git-annex commands when the context is cancelled.
+
+# Configuration and logging
+
+The configuration is read using the config subpackage.
+Warnings about invalid configuration values are available from config.Warnings() and are left to the caller to display.
+Diagnostic information is written to the log file managed by the log subpackage.
 */
 package ginclient
@@ -1,21 +1,28 @@
 package ginclient
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"os/user"
 	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/G-Node/gin-cli/ginclient/config"
 	"github.com/G-Node/gin-cli/ginclient/log"
 	"github.com/G-Node/gin-cli/git"
 	"github.com/G-Node/gin-cli/web"
+	humanize "github.com/dustin/go-humanize"
 	gogs "github.com/gogits/go-gogs-client"
 )
 
@@ -34,6 +41,85 @@ type FileCheckoutStatus struct {
 	Err         error
 }
 
+// ProgressReporter receives the status updates of operations that report
+// their progress on a status channel (e.g., Upload, GetContent, LockContent).
+// Applications embedding the client can implement it to render progress in
+// their own user interface.
+type ProgressReporter interface {
+	// Report is called for each status update, in order.
+	Report(status git.RepoFileStatus)
+	// Done is called once, after the last status update.
+	Done()
+}
+
+// ProgressFunc is an adapter that allows the use of an ordinary function as a
+// ProgressReporter. Its Done method does nothing.
+type ProgressFunc func(status git.RepoFileStatus)
+
+// Report calls f(status).
+func (f ProgressFunc) Report(status git.RepoFileStatus) {
+	f(status)
+}
+
+// Done does nothing.
+func (f ProgressFunc) Done() {}
+
+// ReportProgress passes each status update received on statuschan to the
+// reporter until the channel is closed and then calls the reporter's Done
+// method.
+func ReportProgress(statuschan <-chan git.RepoFileStatus, reporter ProgressReporter) {
+	for status := range statuschan {
+		reporter.Report(status)
+	}
+	reporter.Done()
+}
+
+// RunWithProgress runs an operation that reports its progress on a status
+// channel and passes its status updates to the reporter.
+// The operation must close the channel when it finishes, as all client
+// functions that take a status channel do, e.g.:
+//
+//	RunWithProgress(func(c chan<- git.RepoFileStatus) { gincl.LockContent(paths, c) }, reporter)
+//
+// The function returns when the operation has finished and returns the first
+// error reported by the operation, if any.
+func RunWithProgress(operation func(chan<- git.RepoFileStatus), reporter ProgressReporter) error {
+	statuschan := make(chan git.RepoFileStatus)
+	go operation(statuschan)
+	var firsterr error
+	ReportProgress(statuschan, ProgressFunc(func(status git.RepoFileStatus) {
+		if status.Err != nil && firsterr == nil {
+			firsterr = status.Err
+		}
+		reporter.Report(status)
+	}))
+	reporter.Done()
+	return firsterr
+}
+
+// ImportOptions holds the options for importing a directory tree into a repository.
+type ImportOptions struct {
+	// Move the files into the repository instead of copying them.
+	Move bool
+	// Overwrite files that already exist in the destination instead of skipping them.
+	Overwrite bool
+}
+
+// FileImportStatus is used to report the status of an ImportFiles() operation.
+type FileImportStatus struct {
+	Filename    string
+	Destination string
+	Skipped     bool
+	Err         error
+}
+
+// GetContentOptions holds the options for retrieving the content of annexed files.
+type GetContentOptions struct {
+	// Verify the integrity of the content that is already available locally.
+	// Content that fails verification is downloaded again.
+	Verify bool
+}
+
 // FileStatus represents the state a file is in with respect to local and remote changes.
 type FileStatus uint8
 
@@ -46,6 +132,8 @@ const (
 	Modified
 	// LocalChanges indicates that a file has local, committed modifications that have not been pushed
 	LocalChanges
+	// NewFile indicates that a file has been added and committed locally and does not exist in the upstream branch yet
+	NewFile
 	// RemoteChanges indicates that a file has remote modifications that have not been pulled
 	RemoteChanges
 	// Unlocked indicates that a file is being tracked and is unlocked for editing
@@ -76,12 +164,186 @@ func (fsSlice FileStatusSlice) Less(i, j int) bool {
 	return fsSlice[i] < fsSlice[j]
 }
 
-//isAnnexPath returns true if a given string represents the path to an annex object.
+// FileStatusMap maps file names to their sync status (see ListFiles).
+type FileStatusMap map[string]FileStatus
+
+// Filter returns a new map with the files that have one of the given statuses.
+func (fsMap FileStatusMap) Filter(statuses ...FileStatus) FileStatusMap {
+	keep := make(map[FileStatus]bool, len(statuses))
+	for _, status := range statuses {
+		keep[status] = true
+	}
+	filtered := make(FileStatusMap)
+	for fname, status := range fsMap {
+		if keep[status] {
+			filtered[fname] = status
+		}
+	}
+	return filtered
+}
+
+// Changed returns a new map with the files whose state differs from the recorded and uploaded state, i.e., all files except those that are Synced or have NoContent.
+func (fsMap FileStatusMap) Changed() FileStatusMap {
+	changed := make(FileStatusMap)
+	for fname, status := range fsMap {
+		if status != Synced && status != NoContent {
+			changed[fname] = status
+		}
+	}
+	return changed
+}
+
+// Counts returns the number of files with each status.
+// Statuses without files are not included.
+func (fsMap FileStatusMap) Counts() map[FileStatus]int {
+	counts := make(map[FileStatus]int)
+	for _, status := range fsMap {
+		counts[status]++
+	}
+	return counts
+}
+
+// SortedFiles returns the file names sorted by status (in the order of FileStatusSlice) and by name within each status.
+func (fsMap FileStatusMap) SortedFiles() []string {
+	files := make([]string, 0, len(fsMap))
+	for fname := range fsMap {
+		files = append(files, fname)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		si, sj := fsMap[files[i]], fsMap[files[j]]
+		if si != sj {
+			return si < sj
+		}
+		return files[i] < files[j]
+	})
+	return files
+}
+
+// StatusTree is a node in a hierarchical view of a FileStatusMap (see Tree).
+// A node is either a file with a Status or a directory with Children.
+type StatusTree struct {
+	Name     string
+	Status   FileStatus
+	Children []*StatusTree
+	// Counts is the number of files below a directory with each status.
+	Counts map[FileStatus]int
+}
+
+// IsDir returns true if the node is a directory.
+func (node *StatusTree) IsDir() bool {
+	return node.Counts != nil
+}
+
+// Summary returns a description of the aggregate status of the files below a directory, or the description of the status of a file.
+func (node *StatusTree) Summary() string {
+	if !node.IsDir() {
+		return node.Status.Description()
+	}
+	nocontent := false
+	for status := range node.Counts {
+		switch status {
+		case Synced:
+		case NoContent:
+			nocontent = true
+		default:
+			return "contains unsynced changes"
+		}
+	}
+	if nocontent {
+		return "contains files without local content"
+	}
+	return "synced"
+}
+
+// Tree returns the files of the map as a directory tree.
+// The root node is the working directory (".") and the children of each directory are sorted by name, with directories first.
+func (fsMap FileStatusMap) Tree() *StatusTree {
+	root := &StatusTree{Name: ".", Counts: make(map[FileStatus]int)}
+	for fname, status := range fsMap {
+		parts := strings.Split(filepath.ToSlash(filepath.Clean(fname)), "/")
+		node := root
+		for _, dirname := range parts[:len(parts)-1] {
+			node.Counts[status]++
+			var child *StatusTree
+			for _, c := range node.Children {
+				if c.Name == dirname && c.IsDir() {
+					child = c
+					break
+				}
+			}
+			if child == nil {
+				child = &StatusTree{Name: dirname, Counts: make(map[FileStatus]int)}
+				node.Children = append(node.Children, child)
+			}
+			node = child
+		}
+		node.Counts[status]++
+		node.Children = append(node.Children, &StatusTree{Name: parts[len(parts)-1], Status: status})
+	}
+	root.sort()
+	return root
+}
+
+// sort sorts the children of the node and of all its subdirectories.
+func (node *StatusTree) sort() {
+	sort.Slice(node.Children, func(i, j int) bool {
+		ci, cj := node.Children[i], node.Children[j]
+		if ci.IsDir() != cj.IsDir() {
+			return ci.IsDir()
+		}
+		return ci.Name < cj.Name
+	})
+	for _, child := range node.Children {
+		if child.IsDir() {
+			child.sort()
+		}
+	}
+}
+
+// isAnnexPath returns true if a given string represents the path to an annex object.
 func isAnnexPath(path string) bool {
 	// TODO: Check paths on Windows
 	return strings.Contains(path, "/annex/objects")
 }
 
+// ContentSize returns the size of the content of a file in bytes.
+// For annexed files, the size of the annexed content is returned, even if the content is not available locally (placeholder files).
+// The size of a file that does not exist is 0.
+func ContentSize(path string) (int64, error) {
+	info, err := os.Lstat(path)
+	if os.IsNotExist(err) {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		// locked annexed file: the key is the name of the link target
+		target, err := os.Readlink(path)
+		if err != nil {
+			return 0, err
+		}
+		if isAnnexPath(filepath.ToSlash(target)) {
+			return git.KeySize(target), nil
+		}
+		info, err = os.Stat(path)
+		if err != nil {
+			return 0, err
+		}
+		return info.Size(), nil
+	}
+	if info.Mode().IsRegular() && info.Size() < 1024 {
+		// unlocked annexed placeholders contain the path to the annexed object
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return 0, err
+		}
+		if line := strings.TrimSpace(string(content)); strings.HasPrefix(line, "/annex/objects/") {
+			return git.KeySize(line), nil
+		}
+	}
+	return info.Size(), nil
+}
+
 // MakeSessionKey creates a private+public key pair.
 // The private key is saved in the user's configuration directory, to be used for git commands.
 // The public key is added to the GIN server for the current logged in user.
@@ -91,29 +353,128 @@ func (gincl *Client) MakeSessionKey() error {
 		return err
 	}
 
-	hostname, err := os.Hostname()
-	if err != nil {
-		log.Write("Could not retrieve hostname")
-		hostname = unknownhostname
-	}
-	description := fmt.Sprintf("GIN Client: %s@%s", gincl.Username, hostname)
+	description := gincl.keyTitle()
 	pubkey := fmt.Sprintf("%s %s", strings.TrimSpace(keyPair.Public), description)
 	err = gincl.AddKey(pubkey, description, true)
 	if err != nil {
 		return err
 	}
 
-	configpath, err := config.Path(true)
-	if err != nil {
+	if _, err = config.Path(true); err != nil {
 		log.Write("Could not create config directory for private key")
 		return err
 	}
-	keyfilepath := filepath.Join(configpath, fmt.Sprintf("%s.key", gincl.srvalias))
+	keyfilepath, err := git.SessionKeyPath(gincl.srvalias)
+	if err != nil {
+		return err
+	}
 	ioutil.WriteFile(keyfilepath, []byte(keyPair.Private), 0600)
 
 	return nil
 }
 
+// AddKeyFile adds the public key that matches an existing private key file to the GIN server for the current logged in user.
+// The public key is read from the file with the same name as the private key and the extension '.pub'.
+// The key files are not modified.
+func (gincl *Client) AddKeyFile(privkeyfile string) error {
+	pubkey, err := git.ReadPublicKeyFile(privkeyfile)
+	if err != nil {
+		return err
+	}
+	description := gincl.keyTitle()
+	return gincl.AddKey(fmt.Sprintf("%s %s", pubkey, description), description, true)
+}
+
+// SetKeyFile configures an existing private key file to be used for git commands with the Client's server instead of a generated key.
+// The file and its matching public key file must exist.
+// The key file is registered and stored in the configuration on the next successful login with the Client (see Login and LoginWithToken).
+func (gincl *Client) SetKeyFile(privkeyfile string) error {
+	privkeyfile, err := filepath.Abs(privkeyfile)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(privkeyfile); err != nil {
+		return fmt.Errorf("could not read private key file '%s': %s", privkeyfile, err)
+	}
+	if _, err := git.ReadPublicKeyFile(privkeyfile); err != nil {
+		return err
+	}
+	gincl.keyfile = privkeyfile
+	return nil
+}
+
+// registerKey adds the key used for git commands to the GIN server for the current logged in user.
+// If a key file is set (see SetKeyFile) or configured for the server, its public key is added; otherwise a new session key pair is generated.
+// A key file set with SetKeyFile is stored in the configuration once its key has been added.
+// If enabled in the configuration (cleanupstalekeys), keys left over from earlier logins on the same host are removed afterwards (see CleanupStaleKeys).
+func (gincl *Client) registerKey() error {
+	var err error
+	if gincl.keyfile != "" {
+		if err = gincl.AddKeyFile(gincl.keyfile); err == nil {
+			err = config.SetConfig(fmt.Sprintf("servers.%s.git.keyfile", gincl.srvalias), gincl.keyfile)
+		}
+	} else if keyfile := config.Read().Servers[gincl.srvalias].Git.KeyFile; keyfile != "" {
+		err = gincl.AddKeyFile(keyfile)
+	} else {
+		err = gincl.MakeSessionKey()
+	}
+	if err != nil {
+		return err
+	}
+	if config.Read().CleanupStaleKeys {
+		if _, cerr := gincl.CleanupStaleKeys(); cerr != nil {
+			// not fatal: the new key has been registered
+			log.Write("Failed to clean up stale keys: %v", cerr)
+		}
+	}
+	return nil
+}
+
+// keyTitle returns the title of the key that the client registers for the current user and host.
+func (gincl *Client) keyTitle() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		log.Write("Could not retrieve hostname")
+		hostname = unknownhostname
+	}
+	return fmt.Sprintf("GIN Client: %s@%s", gincl.Username, hostname)
+}
+
+// CleanupStaleKeys removes the keys that the client registered for the current user and host on earlier logins, keeping only the most recently added one.
+// Only keys with the exact title used by the client for the host (see MakeSessionKey) are considered; keys added by the user or by logins on other hosts are never removed.
+// It returns the number of removed keys.
+func (gincl *Client) CleanupStaleKeys() (int, error) {
+	keys, err := gincl.GetUserKeys()
+	if err != nil {
+		return 0, err
+	}
+	title := gincl.keyTitle()
+	var hostkeys []gogs.PublicKey
+	var newest int64
+	for _, key := range keys {
+		if key.Title != title {
+			continue
+		}
+		hostkeys = append(hostkeys, key)
+		// key IDs increase, so the current key has the highest ID
+		if key.ID > newest {
+			newest = key.ID
+		}
+	}
+	removed := 0
+	for _, key := range hostkeys {
+		if key.ID == newest {
+			continue
+		}
+		if err = gincl.DeletePubKey(key.ID); err != nil {
+			return removed, err
+		}
+		log.Write("Removed stale key %d (%s)", key.ID, key.Title)
+		removed++
+	}
+	return removed, nil
+}
+
 // GetRepo retrieves the information of a repository.
 func (gincl *Client) GetRepo(repoPath string) (gogs.Repository, error) {
 	fn := fmt.Sprintf("GetRepo(%s)", repoPath)
@@ -124,6 +485,7 @@ func (gincl *Client) GetRepo(repoPath string) (gogs.Repository, error) {
 	if err != nil {
 		return repo, err // return error from Get() directly
 	}
+	defer web.CloseRes(res.Body)
 	switch code := res.StatusCode; {
 	case code == http.StatusNotFound:
 		return repo, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("repository '%s' does not exist", repoPath)}
@@ -134,7 +496,6 @@ func (gincl *Client) GetRepo(repoPath string) (gogs.Repository, error) {
 	case code != http.StatusOK:
 		return repo, ginerror{UError: res.Status, Origin: fn} // Unexpected error
 	}
-	defer web.CloseRes(res.Body)
 	b, err := ioutil.ReadAll(res.Body) // ignore potential read error on res.Body; catch later when trying to unmarshal
 	if err != nil {
 		return repo, ginerror{UError: err.Error(), Origin: fn, Description: "failed to read response body"}
@@ -146,20 +507,196 @@ func (gincl *Client) GetRepo(repoPath string) (gogs.Repository, error) {
 	return repo, nil
 }
 
-// ListRepos gets a list of repositories (public or user specific)
+// RemoteCommit is a commit of a repository on the server (see GetCommit).
+type RemoteCommit struct {
+	git.GinCommit
+	// Parents holds the hashes of the parent commits.
+	Parents []string `json:"parents"`
+}
+
+// apiCommit is the commit object returned by the commits API of the server.
+// The changed files are only reported by some servers.
+type apiCommit struct {
+	gogs.Commit
+	Files []struct {
+		Filename string `json:"filename"`
+		Status   string `json:"status"`
+	} `json:"files"`
+}
+
+// remoteCommit converts a commit returned by the server to a RemoteCommit.
+func (c apiCommit) remoteCommit() RemoteCommit {
+	var commit RemoteCommit
+	if c.CommitMeta != nil {
+		commit.Hash = c.SHA
+	}
+	commit.AbbreviatedHash = commit.Hash
+	if abbrevlen := 7; len(commit.Hash) > abbrevlen {
+		if git.AbbrevLength > abbrevlen {
+			abbrevlen = git.AbbrevLength
+		}
+		if abbrevlen < len(commit.Hash) {
+			commit.AbbreviatedHash = commit.Hash[:abbrevlen]
+		}
+	}
+	if rc := c.RepoCommit; rc != nil {
+		parts := strings.SplitN(strings.TrimSpace(rc.Message), "\n", 2)
+		commit.Subject = parts[0]
+		if len(parts) > 1 {
+			commit.Body = strings.TrimSpace(parts[1])
+		}
+		if rc.Author != nil {
+			commit.AuthorName = rc.Author.Name
+			commit.AuthorEmail = rc.Author.Email
+			if date, err := time.Parse(time.RFC3339, rc.Author.Date); err == nil {
+				commit.Date = date
+			}
+		}
+	}
+	for _, parent := range c.Parents {
+		if parent != nil {
+			commit.Parents = append(commit.Parents, parent.SHA)
+		}
+	}
+	for _, file := range c.Files {
+		switch file.Status {
+		case "added":
+			commit.FileStats.NewFiles = append(commit.FileStats.NewFiles, file.Filename)
+		case "removed", "deleted":
+			commit.FileStats.DeletedFiles = append(commit.FileStats.DeletedFiles, file.Filename)
+		default:
+			commit.FileStats.ModifiedFiles = append(commit.FileStats.ModifiedFiles, file.Filename)
+		}
+	}
+	return commit
+}
+
+// GetCommit retrieves the metadata of a commit of a repository on the server: the author, date, message, and parents, as well as the changed files if the server reports them.
+// The ref can be a commit hash, a branch, or a tag.
+func (gincl *Client) GetCommit(repoPath, ref string) (RemoteCommit, error) {
+	fn := fmt.Sprintf("GetCommit(%s, %s)", repoPath, ref)
+	log.Write("GetCommit")
+	res, err := gincl.Get(fmt.Sprintf("/api/v1/repos/%s/commits/%s", repoPath, url.PathEscape(ref)))
+	if err != nil {
+		return RemoteCommit{}, err // return error from Get() directly
+	}
+	defer web.CloseRes(res.Body)
+	switch code := res.StatusCode; {
+	case code == http.StatusNotFound:
+		return RemoteCommit{}, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("repository '%s' or version '%s' does not exist", repoPath, ref)}
+	case code == http.StatusUnauthorized:
+		return RemoteCommit{}, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+	case code == http.StatusInternalServerError:
+		return RemoteCommit{}, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+	case code != http.StatusOK:
+		return RemoteCommit{}, ginerror{UError: res.Status, Origin: fn} // Unexpected error
+	}
+	var commit apiCommit
+	if err = json.NewDecoder(res.Body).Decode(&commit); err != nil {
+		return RemoteCommit{}, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
+	}
+	return commit.remoteCommit(), nil
+}
+
+// RemoteLog retrieves the history of a repository on the server, starting at the given ref and following the first parent of each commit, without cloning the repository.
+// If the ref is empty, the default branch of the repository is used.
+// At most 'count' commits are returned; if count is 0, the entire history is retrieved, which requires one request per commit.
+func (gincl *Client) RemoteLog(repoPath, ref string, count uint) ([]RemoteCommit, error) {
+	if ref == "" {
+		repo, err := gincl.GetRepo(repoPath)
+		if err != nil {
+			return nil, err
+		}
+		ref = repo.DefaultBranch
+		if ref == "" {
+			ref = "master"
+		}
+	}
+	var commits []RemoteCommit
+	for count == 0 || uint(len(commits)) < count {
+		commit, err := gincl.GetCommit(repoPath, ref)
+		if err != nil {
+			return nil, err
+		}
+		commits = append(commits, commit)
+		if len(commit.Parents) == 0 {
+			break
+		}
+		ref = commit.Parents[0]
+	}
+	return commits, nil
+}
+
+// ListRepos gets a list of repositories (public or user specific).
+// For the logged in user, this includes the repositories shared with the user.
 func (gincl *Client) ListRepos(user string) ([]gogs.Repository, error) {
-	fn := fmt.Sprintf("ListRepos(%s)", user)
 	log.Write("Retrieving repo list")
+	fn := fmt.Sprintf("ListRepos(%s)", user)
+	return gincl.listReposPaged(fn, fmt.Sprintf("/api/v1/users/%s/repos", user), fmt.Sprintf("user '%s' does not exist", user), false)
+}
+
+// ListMyRepos gets the list of repositories the logged in user owns or collaborates on.
+func (gincl *Client) ListMyRepos() ([]gogs.Repository, error) {
+	log.Write("Retrieving repo list for logged in user")
+	return gincl.listReposPaged("ListMyRepos()", "/api/v1/user/repos", "", false)
+}
+
+// SearchRepos gets the list of repositories whose name contains the query string.
+// An empty query matches all repositories.
+// Only public repositories and repositories accessible to the logged in user are found.
+func (gincl *Client) SearchRepos(query string) ([]gogs.Repository, error) {
+	log.Write("Searching repositories")
+	fn := fmt.Sprintf("SearchRepos(%s)", query)
+	return gincl.listReposPaged(fn, fmt.Sprintf("/api/v1/repos/search?q=%s", url.QueryEscape(query)), "", true)
+}
+
+// repoListPageSize is the number of repositories requested per page when listing repositories.
+const repoListPageSize = 50
+
+// listReposPaged requests a list of repositories one page at a time until all repositories have been retrieved.
+// Servers that do not support pagination return the full list with the first page.
+// 'notfound' is the error description for a missing resource.
+// With 'search', the response is parsed as the result of a repository search.
+func (gincl *Client) listReposPaged(fn, address, notfound string, search bool) ([]gogs.Repository, error) {
 	var repoList []gogs.Repository
-	var res *http.Response
-	var err error
-	res, err = gincl.Get(fmt.Sprintf("/api/v1/users/%s/repos", user))
+	seen := make(map[int64]bool)
+	for page := 1; ; page++ {
+		pageList, err := gincl.reposPage(fn, address, notfound, page, search)
+		if err != nil {
+			return nil, err
+		}
+		newrepos := 0
+		for _, repo := range pageList {
+			if seen[repo.ID] {
+				continue
+			}
+			seen[repo.ID] = true
+			repoList = append(repoList, repo)
+			newrepos++
+		}
+		// a short page is the last one; a page without new repositories means
+		// the server ignored the page parameter
+		if len(pageList) < repoListPageSize || newrepos == 0 {
+			break
+		}
+	}
+	return repoList, nil
+}
+
+// reposPage gets one page of a list of repositories (see listReposPaged).
+func (gincl *Client) reposPage(fn, address, notfound string, page int, search bool) ([]gogs.Repository, error) {
+	sep := "?"
+	if strings.Contains(address, "?") {
+		sep = "&"
+	}
+	res, err := gincl.Get(fmt.Sprintf("%s%spage=%d&limit=%d", address, sep, page, repoListPageSize))
 	if err != nil {
 		return nil, err // return error from Get() directly
 	}
+	defer web.CloseRes(res.Body)
 	switch code := res.StatusCode; {
 	case code == http.StatusNotFound:
-		return nil, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("user '%s' does not exist", user)}
+		return nil, ginerror{UError: res.Status, Origin: fn, Description: notfound}
 	case code == http.StatusUnauthorized:
 		return nil, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
 	case code == http.StatusInternalServerError:
@@ -167,12 +704,20 @@ func (gincl *Client) ListRepos(user string) ([]gogs.Repository, error) {
 	case code != http.StatusOK:
 		return nil, ginerror{UError: res.Status, Origin: fn} // Unexpected error
 	}
-	defer web.CloseRes(res.Body)
 	b, err := ioutil.ReadAll(res.Body)
 	if err != nil {
 		return nil, ginerror{UError: err.Error(), Origin: fn, Description: "failed to read response body"}
 	}
-	err = json.Unmarshal(b, &repoList)
+	var repoList []gogs.Repository
+	if search {
+		var result struct {
+			Data []gogs.Repository `json:"data"`
+		}
+		err = json.Unmarshal(b, &result)
+		repoList = result.Data
+	} else {
+		err = json.Unmarshal(b, &repoList)
+	}
 	if err != nil {
 		return nil, ginerror{UError: err.Error(), Origin: fn, Description: "failed to parse response body"}
 	}
@@ -189,6 +734,7 @@ func (gincl *Client) CreateRepo(name, description string) error {
 	if err != nil {
 		return err // return error from Post() directly
 	}
+	defer web.CloseRes(res.Body)
 	switch code := res.StatusCode; {
 	case code == http.StatusUnprocessableEntity:
 		return ginerror{UError: res.Status, Origin: fn, Description: "invalid repository name or repository with the same name already exists"}
@@ -199,7 +745,6 @@ func (gincl *Client) CreateRepo(name, description string) error {
 	case code != http.StatusCreated:
 		return ginerror{UError: res.Status, Origin: fn} // Unexpected error
 	}
-	web.CloseRes(res.Body)
 	log.Write("Repository created")
 	return nil
 }
@@ -212,6 +757,7 @@ func (gincl *Client) DelRepo(name string) error {
 	if err != nil {
 		return err // return error from Post() directly
 	}
+	defer web.CloseRes(res.Body)
 	switch code := res.StatusCode; {
 	case code == http.StatusForbidden:
 		return ginerror{UError: res.Status, Origin: fn, Description: "failed to delete repository (forbidden)"}
@@ -224,12 +770,51 @@ func (gincl *Client) DelRepo(name string) error {
 	case code != http.StatusNoContent:
 		return ginerror{UError: res.Status, Origin: fn} // Unexpected error
 	}
-	web.CloseRes(res.Body)
 	log.Write("Repository deleted")
 	return nil
 }
 
+// transferRepoOption holds the request body for a repository transfer.
+type transferRepoOption struct {
+	NewOwner string `json:"new_owner"`
+}
+
+// TransferRepo transfers the repository 'owner/name' to the user or organisation 'newOwner'.
+// If the server requires the new owner to accept the transfer, the transfer is pending until it is accepted and 'pending' is true.
+// The repository keeps its name.
+func (gincl *Client) TransferRepo(owner, name, newOwner string) (pending bool, err error) {
+	repopath := fmt.Sprintf("%s/%s", owner, name)
+	fn := fmt.Sprintf("TransferRepo(%s, %s)", repopath, newOwner)
+	log.Write("Transferring repository %s to %s", repopath, newOwner)
+	res, err := gincl.Post(fmt.Sprintf("/api/v1/repos/%s/transfer", repopath), transferRepoOption{NewOwner: newOwner})
+	if err != nil {
+		return false, err // return error from Post() directly
+	}
+	defer web.CloseRes(res.Body)
+	switch code := res.StatusCode; {
+	case code == http.StatusAccepted:
+		log.Write("Repository transfer pending")
+		return true, nil
+	case code == http.StatusOK || code == http.StatusCreated:
+		log.Write("Repository transferred")
+		return false, nil
+	case code == http.StatusForbidden:
+		return false, ginerror{UError: res.Status, Origin: fn, Description: "failed to transfer repository (forbidden): only the owner of a repository can transfer it"}
+	case code == http.StatusNotFound:
+		return false, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("repository '%s' or user '%s' does not exist, or the server does not support transferring repositories", repopath, newOwner)}
+	case code == http.StatusUnprocessableEntity:
+		return false, ginerror{UError: res.Status, Origin: fn, Description: fmt.Sprintf("repository cannot be transferred: '%s' may already have a repository named '%s'", newOwner, name)}
+	case code == http.StatusUnauthorized:
+		return false, ginerror{UError: res.Status, Origin: fn, Description: "authorisation failed"}
+	case code == http.StatusInternalServerError:
+		return false, ginerror{UError: res.Status, Origin: fn, Description: "server error"}
+	default:
+		return false, ginerror{UError: res.Status, Origin: fn} // Unexpected error
+	}
+}
+
 // Add updates the index with the changes in the files specified by 'paths'.
+// If git-annex is disabled for the repository (see git.NoAnnex), all files are added to git.
 // The status channel 'addchan' is closed when this function returns.
 func Add(paths []string, addchan chan<- git.RepoFileStatus) {
 	defer close(addchan)
@@ -239,6 +824,15 @@ func Add(paths []string, addchan chan<- git.RepoFileStatus) {
 		return
 	}
 
+	if len(paths) > 0 && git.NoAnnex() {
+		gitaddchan := make(chan git.RepoFileStatus)
+		go git.Add(paths, gitaddchan)
+		for addstat := range gitaddchan {
+			addchan <- addstat
+		}
+		return
+	}
+
 	if len(paths) > 0 {
 		gitaddpaths := make([]string, 0) // most times, this wont be used, so start with 0
 		statuschan := make(chan git.AnnexStatusRes)
@@ -272,9 +866,69 @@ func Add(paths []string, addchan chan<- git.RepoFileStatus) {
 	}
 }
 
+// FilterTracked returns the files under the given paths that are already tracked by the repository.
+// Untracked (new) files are omitted, so passing the result to Add records changes to existing files only.
+// Files that were tracked and have since been deleted are included, so their removal is recorded.
+func FilterTracked(paths []string) ([]string, error) {
+	paths, err := expandglobs(paths, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(paths) == 0 {
+		return paths, nil
+	}
+	lschan := make(chan string)
+	go git.LsFiles(append([]string{"--cached", "--"}, paths...), lschan)
+	tracked := make([]string, 0)
+	for fname := range lschan {
+		tracked = append(tracked, fname)
+	}
+	return tracked, nil
+}
+
+// RepoRootPaths returns the absolute path to the root of the repository containing the working directory, along with the given paths rewritten to be relative to the root.
+// Paths are interpreted relative to the working directory, so that they remain valid after changing to the repository root.
+// If no paths are given, the working directory itself (relative to the root) is returned as the only path.
+// An error is returned if the working directory is not in a repository or if any of the paths is outside the repository.
+func RepoRootPaths(paths []string) (string, []string, error) {
+	root, err := git.FindRepoRoot(".")
+	if err != nil {
+		return "", nil, err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return "", nil, err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", nil, err
+	}
+	// resolve symlinks in the working directory only; the paths themselves
+	// may be symlinks (locked annexed files) and must not be followed
+	if cwd, err = filepath.EvalSymlinks(cwd); err != nil {
+		return "", nil, err
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	relpaths := make([]string, len(paths))
+	for idx, p := range paths {
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(cwd, p)
+		}
+		rel, err := filepath.Rel(root, filepath.Clean(p))
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", nil, fmt.Errorf("path '%s' is outside repository", paths[idx])
+		}
+		relpaths[idx] = rel
+	}
+	return root, relpaths, nil
+}
+
 // Upload transfers locally recorded changes to a remote.
+// If git-annex is disabled for the repository (see git.NoAnnex), only the git branches are pushed.
+// The running git and git-annex commands are stopped if ctx is cancelled.
 // The status channel 'uploadchan' is closed when this function returns.
-func (gincl *Client) Upload(paths []string, remotes []string, uploadchan chan<- git.RepoFileStatus) {
+func (gincl *Client) Upload(ctx context.Context, paths []string, remotes []string, uploadchan chan<- git.RepoFileStatus) {
 	// TODO: Does this need to be a Client method?
 	defer close(uploadchan)
 	log.Write("Upload")
@@ -306,44 +960,246 @@ func (gincl *Client) Upload(paths []string, remotes []string, uploadchan chan<-
 		}
 
 		gitpushchan := make(chan git.RepoFileStatus)
-		go git.Push(remote, gitpushchan)
-		for stat := range gitpushchan {
-			uploadchan <- stat
+		go git.Push(ctx, remote, gitpushchan)
+		if !forward(ctx, gitpushchan, uploadchan) {
+			return
+		}
+		if git.NoAnnex() {
+			// all files are in git
+			continue
 		}
 
 		annexpushchan := make(chan git.RepoFileStatus)
-		go git.AnnexPush(paths, remote, annexpushchan)
-		for stat := range annexpushchan {
-			uploadchan <- stat
+		go git.AnnexPush(ctx, paths, remote, annexpushchan)
+		if !forward(ctx, annexpushchan, uploadchan) {
+			return
 		}
 	}
 	return
 }
 
-// GetContent downloads the contents of placeholder files in a checked out repository.
-// The status channel 'getcontchan' is closed when this function returns.
-func (gincl *Client) GetContent(paths []string, getcontchan chan<- git.RepoFileStatus) {
-	defer close(getcontchan)
-	log.Write("GetContent")
-
-	paths, err := expandglobs(paths, true)
-
-	if err != nil {
-		getcontchan <- git.RepoFileStatus{Err: err}
+// CommitAndUpload records the changes to the files specified by 'paths' with the given commit message and uploads all recorded changes to the remotes.
+// This performs the same steps as the 'upload' command: the changes are added (see Add), committed, and uploaded (see Upload).
+// If there are no changes to record, the changes that were recorded previously are uploaded.
+// If no remotes are specified, the changes are uploaded to the default remote.
+// The running git and git-annex commands are stopped if ctx is cancelled.
+// The status channel 'statuschan' is closed when this function returns.
+func (gincl *Client) CommitAndUpload(ctx context.Context, paths []string, message string, remotes []string, statuschan chan<- git.RepoFileStatus) {
+	log.Write("CommitAndUpload")
+	if message == "" {
+		statuschan <- git.RepoFileStatus{Err: fmt.Errorf("commit message must not be empty")}
+		close(statuschan)
 		return
 	}
-
-	annexgetchan := make(chan git.RepoFileStatus)
-	go git.AnnexGet(paths, annexgetchan)
-	for stat := range annexgetchan {
-		getcontchan <- stat
+	if len(paths) > 0 {
+		addchan := make(chan git.RepoFileStatus)
+		go Add(paths, addchan)
+		if !forward(ctx, addchan, statuschan) {
+			close(statuschan)
+			return
+		}
+	}
+	if err := git.Commit(message); err != nil && err.Error() != "Nothing to commit" {
+		statuschan <- git.RepoFileStatus{Err: err}
+		close(statuschan)
+		return
+	}
+	// Upload closes the channel
+	gincl.Upload(ctx, paths, remotes, statuschan)
+}
+
+// PendingChanges returns true if the files specified by 'paths' (or any file in the working tree, if none are specified) have changes that have not been committed, including new untracked files.
+// This can be used to check whether changes were made to files while they were being uploaded (see CommitAndUpload).
+func PendingChanges(paths ...string) (bool, error) {
+	fn := fmt.Sprintf("PendingChanges(%v)", paths)
+	cmdargs := append([]string{"status", "--porcelain", "-z", "--untracked-files=all", "--"}, paths...)
+	cmd := git.Command(cmdargs...)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during status: %s", string(stderr))
+		return false, ginerror{UError: string(stderr), Origin: fn, Description: "failed to check the working tree for changes"}
+	}
+	return len(stdout) > 0, nil
+}
+
+// forward relays status messages from 'src' to 'dst' until 'src' is closed.
+// If ctx is cancelled, the remaining messages are discarded so that the sending function can finish and the cancellation error is sent instead.
+// Returns false if the context was cancelled.
+func forward(ctx context.Context, src <-chan git.RepoFileStatus, dst chan<- git.RepoFileStatus) bool {
+	for stat := range src {
+		if ctx.Err() != nil {
+			continue
+		}
+		dst <- stat
+	}
+	if err := ctx.Err(); err != nil {
+		dst <- git.RepoFileStatus{Err: err}
+		return false
+	}
+	return true
+}
+
+// MissingContentSize returns the total size of the annexed content of the given files that is not available locally.
+// Content shared by multiple files is counted once.
+func MissingContentSize(paths []string) (int64, error) {
+	missing, err := git.AnnexFindMatching([]string{"--not", "--in=here"}, paths)
+	if err != nil {
+		return 0, err
+	}
+	return contentSize(missing), nil
+}
+
+// UploadSize returns the total size of the annexed content of the given files that is available locally but not in the remote, which is the content that Upload transfers to the remote.
+// Content shared by multiple files is counted once.
+func UploadSize(paths []string, remote string) (int64, error) {
+	pending, err := git.AnnexFindMatching([]string{"--in=here", "--not", "--in=" + remote}, paths)
+	if err != nil {
+		return 0, err
+	}
+	return contentSize(pending), nil
+}
+
+// contentSize returns the total size of the content of the files found by git.AnnexFindMatching.
+// Content shared by multiple files is counted once.
+func contentSize(found []git.AnnexFindRes) int64 {
+	var total int64
+	counted := make(map[string]bool, len(found))
+	for _, item := range found {
+		if counted[item.Key] {
+			continue
+		}
+		counted[item.Key] = true
+		size, err := strconv.ParseInt(item.Bytesize, 10, 64)
+		if err != nil {
+			size = git.KeySize(item.Key)
+		}
+		total += size
+	}
+	return total
+}
+
+// CheckFreeSpace returns an error if the content of the given files that is not available locally does not fit in the free space of the filesystem that contains the current working directory.
+// If the free space cannot be determined, no error is returned.
+func CheckFreeSpace(paths []string) error {
+	required, err := MissingContentSize(paths)
+	if err != nil {
+		return err
+	}
+	if required == 0 {
+		return nil
+	}
+	available, err := freeSpace(".")
+	if err != nil {
+		log.Write("Failed to determine free disk space: %v", err)
+		return nil
+	}
+	if uint64(required) > available {
+		return fmt.Errorf("not enough free disk space: the content needs %s, only %s available", humanize.IBytes(uint64(required)), humanize.IBytes(available))
+	}
+	return nil
+}
+
+// CheckRepoSizeLimit returns an error if uploading the content of the given files to the remote would make the repository on the server larger than 'limit' bytes.
+// The current size of the repository is the size reported by the server (see GetRepo) and the size of the upload is determined with UploadSize.
+// Remotes that are not repositories on a server (e.g., directory remotes) are not checked.
+func (gincl *Client) CheckRepoSizeLimit(paths []string, remote string, limit int64) error {
+	fn := fmt.Sprintf("CheckRepoSizeLimit(%s, %d)", remote, limit)
+	remotes, err := git.RemoteShow()
+	if err != nil {
+		return err
+	}
+	remoteurl, ok := remotes[remote]
+	if !ok {
+		return fmt.Errorf("unknown remote name '%s'", remote)
+	}
+	repopath, err := RepoPathFromURL(remoteurl)
+	if err != nil {
+		log.Write("Not checking size limit for remote %s: %v", remote, err)
+		return nil
+	}
+	repo, err := gincl.GetRepo(repopath)
+	if err != nil {
+		return err
+	}
+	pending, err := UploadSize(paths, remote)
+	if err != nil {
+		return err
+	}
+	if total := repo.Size + pending; total > limit {
+		return ginerror{Origin: fn, Description: fmt.Sprintf("uploading %s to '%s' would increase the size of repository '%s' to %s, which exceeds the limit of %s", humanize.IBytes(uint64(pending)), remote, repopath, humanize.IBytes(uint64(total)), humanize.IBytes(uint64(limit)))}
+	}
+	return nil
+}
+
+// GetContent downloads the contents of placeholder files in a checked out repository.
+// The running git-annex command is stopped if ctx is cancelled.
+// The status channel 'getcontchan' is closed when this function returns.
+func (gincl *Client) GetContent(ctx context.Context, paths []string, opts GetContentOptions, getcontchan chan<- git.RepoFileStatus) {
+	defer close(getcontchan)
+	log.Write("GetContent")
+
+	paths, err := expandglobs(paths, true)
+
+	if err != nil {
+		getcontchan <- git.RepoFileStatus{Err: err}
+		return
+	}
+
+	// git-annex silently skips files whose content is already available;
+	// report them as skipped so that the output accounts for every file
+	present, err := git.AnnexFindMatching(nil, paths)
+	if err != nil {
+		log.Write("Failed to list files with local content: %v", err)
+	}
+	checked := make(map[string]bool)
+	if opts.Verify && len(present) > 0 {
+		presentpaths := make([]string, len(present))
+		for idx, afr := range present {
+			presentpaths[idx] = afr.File
+		}
+		verifychan := make(chan git.RepoFileStatus)
+		go git.AnnexVerify(ctx, presentpaths, verifychan)
+		for stat := range verifychan {
+			checked[stat.FileName] = true
+			if stat.Err != nil {
+				// the bad content has been removed and is downloaded below
+				log.WriteError(stat.Err)
+				stat.State = "Verification failed; downloading again"
+				stat.Err = nil
+			} else {
+				stat.State = "Content verified, skipped"
+				stat.Skipped = true
+			}
+			getcontchan <- stat
+		}
+		if err := ctx.Err(); err != nil {
+			getcontchan <- git.RepoFileStatus{Err: err}
+			return
+		}
+	}
+	for _, afr := range present {
+		if checked[afr.File] {
+			continue
+		}
+		getcontchan <- git.RepoFileStatus{
+			FileName: afr.File,
+			State:    "Content present, skipped",
+			Progress: "100%",
+			Size:     git.KeySize(afr.Key),
+			Skipped:  true,
+		}
 	}
+
+	annexgetchan := make(chan git.RepoFileStatus)
+	go git.AnnexGet(ctx, paths, annexgetchan)
+	forward(ctx, annexgetchan, getcontchan)
 	return
 }
 
 // RemoveContent removes the contents of local files, turning them into placeholders but only if the content is available on a remote.
+// If 'force' is true, the content is removed even if no copy can be verified on a remote, in which case it may be lost.
 // The status channel 'rmcchan' is closed when this function returns.
-func (gincl *Client) RemoveContent(paths []string, rmcchan chan<- git.RepoFileStatus) {
+func (gincl *Client) RemoveContent(paths []string, force bool, rmcchan chan<- git.RepoFileStatus) {
 	defer close(rmcchan)
 	log.Write("RemoveContent")
 
@@ -354,13 +1210,75 @@ func (gincl *Client) RemoveContent(paths []string, rmcchan chan<- git.RepoFileSt
 	}
 
 	dropchan := make(chan git.RepoFileStatus)
-	go git.AnnexDrop(paths, dropchan)
+	go git.AnnexDrop(paths, force, dropchan)
 	for stat := range dropchan {
 		rmcchan <- stat
 	}
 	return
 }
 
+// MoveContent moves the content of files from one remote to another.
+// Both remotes must be configured in the local repository.
+// The running git-annex command is stopped if ctx is cancelled.
+// The status channel 'movechan' is closed when this function returns.
+func (gincl *Client) MoveContent(ctx context.Context, paths []string, src, dst string, movechan chan<- git.RepoFileStatus) {
+	log.Write("MoveContent")
+	if err := checkMoveRemotes(src, dst); err != nil {
+		movechan <- git.RepoFileStatus{Err: err}
+		close(movechan)
+		return
+	}
+
+	paths, err := expandglobs(paths, true)
+	if err != nil {
+		movechan <- git.RepoFileStatus{Err: err}
+		close(movechan)
+		return
+	}
+	git.AnnexMove(ctx, paths, src, dst, movechan)
+}
+
+// checkMoveRemotes returns an error if the source and destination of a content move are the same or either of them is not a configured remote.
+func checkMoveRemotes(src, dst string) error {
+	if src == dst {
+		return fmt.Errorf("source and destination remote are the same: %s", src)
+	}
+	return checkRemotes(src, dst)
+}
+
+// checkRemotes returns an error if any of the given remotes is not configured in the local repository.
+func checkRemotes(names ...string) error {
+	remotes, err := git.RemoteShow()
+	if err != nil {
+		return fmt.Errorf("failed to determine configured remotes")
+	}
+	for _, remote := range names {
+		if _, ok := remotes[remote]; !ok {
+			return fmt.Errorf("no such remote: %s", remote)
+		}
+	}
+	return nil
+}
+
+// CopyContent copies the content of the files specified by 'paths' to the remote 'dst', keeping the local copy.
+// The status channel 'copychan' is closed when this function returns.
+func (gincl *Client) CopyContent(ctx context.Context, paths []string, dst string, copychan chan<- git.RepoFileStatus) {
+	log.Write("CopyContent")
+	if err := checkRemotes(dst); err != nil {
+		copychan <- git.RepoFileStatus{Err: err}
+		close(copychan)
+		return
+	}
+
+	paths, err := expandglobs(paths, true)
+	if err != nil {
+		copychan <- git.RepoFileStatus{Err: err}
+		close(copychan)
+		return
+	}
+	git.AnnexCopy(ctx, paths, dst, copychan)
+}
+
 // LockContent locks local files, turning them into symlinks (if supported by the filesystem).
 // The status channel 'lockchan' is closed when this function returns.
 func (gincl *Client) LockContent(paths []string, lcchan chan<- git.RepoFileStatus) {
@@ -381,6 +1299,47 @@ func (gincl *Client) LockContent(paths []string, lcchan chan<- git.RepoFileStatu
 	return
 }
 
+// RefreshPointers repairs the pointers of annexed files under the given paths and stages the repaired files.
+// Pointers can point to the wrong location after files are moved or restored with external tools.
+// Changes to other tracked files under the paths are staged as well; untracked and deleted files are not.
+// The status channel 'refreshchan' is closed when this function returns.
+func RefreshPointers(paths []string, refreshchan chan<- git.RepoFileStatus) {
+	defer close(refreshchan)
+	log.Write("RefreshPointers")
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	paths, err := expandglobs(paths, true)
+	if err != nil {
+		refreshchan <- git.RepoFileStatus{Err: err}
+		return
+	}
+
+	fixchan := make(chan git.RepoFileStatus)
+	go git.AnnexFix(paths, fixchan)
+	for stat := range fixchan {
+		refreshchan <- stat
+	}
+
+	tracked, err := FilterTracked(paths)
+	if err != nil {
+		refreshchan <- git.RepoFileStatus{Err: err}
+		return
+	}
+	existing := make([]string, 0, len(tracked))
+	for _, fname := range tracked {
+		if _, err := os.Lstat(fname); err == nil {
+			existing = append(existing, fname)
+		}
+	}
+	addchan := make(chan git.RepoFileStatus)
+	go git.Add(existing, addchan)
+	for stat := range addchan {
+		stat.State = "Restaging"
+		refreshchan <- stat
+	}
+}
+
 // UnlockContent unlocks local files turning them into normal files, if the content is locally available.
 // The status channel 'unlockchan' is closed when this function returns.
 func (gincl *Client) UnlockContent(paths []string, ulcchan chan<- git.RepoFileStatus) {
@@ -401,45 +1360,226 @@ func (gincl *Client) UnlockContent(paths []string, ulcchan chan<- git.RepoFileSt
 	return
 }
 
+// DownloadOptions holds options that modify the behaviour of Download.
+type DownloadOptions struct {
+	// Rebase replays local commits on top of the downloaded changes instead of merging the two histories.
+	Rebase bool
+}
+
 // Download downloads changes and placeholder files in an already checked out repository.
-func (gincl *Client) Download(remote string) error {
+// The running git-annex command is stopped if ctx is cancelled.
+func (gincl *Client) Download(ctx context.Context, remote string, opts DownloadOptions) error {
 	log.Write("Download")
-	// err := git.Pull(remote)
-	// if err != nil {
-	// 	return err
-	// }
-	return git.AnnexPull(remote)
+	if opts.Rebase {
+		return git.AnnexPullRebase(ctx, remote)
+	}
+	return git.AnnexPull(ctx, remote)
+}
+
+// lastDownloadRef is the reference that records the state of the upstream branch at the last download (see MarkDownloaded).
+const lastDownloadRef = "refs/gin/last-download"
+
+// DownloadBase returns the commit that the changes of the next download should be compared with to find new files (see NewSinceDownload).
+// This is the state of the upstream branch at the last recorded download.
+// If no download was recorded, the current state of the upstream branch is used, or HEAD if there is no upstream branch.
+func DownloadBase() (string, error) {
+	for _, rev := range []string{lastDownloadRef, "@{upstream}", "HEAD"} {
+		if commit, err := git.RevParse(rev + "^{commit}"); err == nil {
+			return strings.TrimSpace(commit), nil
+		}
+	}
+	return "", fmt.Errorf("failed to determine the state of the last download")
+}
+
+// MarkDownloaded records the current state of the upstream branch as the last download.
+// The state is stored in the local repository (under .git/refs/gin).
+func MarkDownloaded() error {
+	upstream, err := EnsureUpstream()
+	if err != nil {
+		return err
+	}
+	return git.UpdateRef(lastDownloadRef, "refs/remotes/"+upstream)
+}
+
+// NewSinceDownload returns the files that were added or modified on the upstream branch since the commit 'base' (see DownloadBase).
+// Files that do not exist in the working tree (e.g., because they were deleted locally) are omitted.
+// File names are relative to the working directory.
+func NewSinceDownload(base string) ([]string, error) {
+	upstream, err := EnsureUpstream()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := git.DiffNameStatus(base+".."+upstream, nil)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(changes))
+	for fname, change := range changes {
+		if change == "D" {
+			continue
+		}
+		if _, err := os.Lstat(fname); err != nil {
+			continue
+		}
+		files = append(files, fname)
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// DownloadChange describes a change to a file that a download would apply to the local clone.
+type DownloadChange struct {
+	FileName string `json:"filename"`
+	// Change is one of "added", "modified", or "deleted".
+	Change string `json:"change"`
+	// Annexed is true if the content of the file is annexed and is downloaded separately (see GetContent).
+	Annexed bool `json:"annexed"`
+	// Size is the size of the (annexed) content of the file in bytes after the download; 0 for deleted files.
+	Size int64 `json:"size"`
+}
+
+// DownloadPlan fetches the changes from the remote and returns the changes to files that downloading them would apply, without modifying the local branch or the working tree.
+// The changes are those made on the upstream branch since it diverged from the local branch.
+// File names are relative to the working directory.
+func DownloadPlan(remote string) ([]DownloadChange, error) {
+	if err := git.Fetch(remote); err != nil {
+		return nil, err
+	}
+	upstream, err := EnsureUpstream()
+	if err != nil {
+		return nil, err
+	}
+	changes, err := git.DiffNameStatus("HEAD..."+upstream, nil)
+	if err != nil {
+		return nil, err
+	}
+	plan := make([]DownloadChange, 0, len(changes))
+	for fname, status := range changes {
+		change := DownloadChange{FileName: fname}
+		switch status {
+		case "A":
+			change.Change = "added"
+		case "D":
+			change.Change = "deleted"
+		default:
+			change.Change = "modified"
+		}
+		if change.Change != "deleted" {
+			// relative to the working directory, like the names returned by DiffNameStatus
+			content, err := git.CatFileContents(upstream, "./"+filepath.ToSlash(fname))
+			if err != nil {
+				return nil, err
+			}
+			change.Size = int64(len(content))
+			if line := strings.TrimSpace(string(content)); len(content) < 1024 && isAnnexPath(line) {
+				change.Annexed = true
+				change.Size = git.KeySize(line)
+			}
+		}
+		plan = append(plan, change)
+	}
+	sort.Slice(plan, func(i, j int) bool { return plan[i].FileName < plan[j].FileName })
+	return plan, nil
 }
 
 // Sync synchronises changes bidirectionally (uploads and downloads),
 // optionally transferring content between remotes and the local clone.
-func (gincl *Client) Sync(content bool) error {
-	log.Write("Sync %t", content)
-	return git.AnnexSync(content)
+// If no remotes are specified, all configured remotes are synchronised.
+func (gincl *Client) Sync(content bool, remotes ...string) error {
+	log.Write("Sync %t %v", content, remotes)
+	return git.AnnexSync(content, remotes...)
+}
+
+// CloneOptions holds options that modify the behaviour of CloneRepo.
+type CloneOptions struct {
+	// Force allows CloneRepo to continue when the destination directory already exists and is not empty.
+	// The clone is resumed if the directory is a clone of the same repository; otherwise an error is returned.
+	Force bool
+	// Public clones the repository over its public (HTTPS) URL, which does not require the user to be logged in or have an ssh key.
+	// Only public repositories can be cloned this way.
+	Public bool
+	// HTTPS clones the repository over the HTTPS address of the server using the user's access token, for networks where ssh is unavailable.
+	// The HTTPS address is used for all subsequent uploads and downloads.
+	HTTPS bool
+	// Dest is the directory where the repository is cloned.
+	// If empty, the repository is cloned into a new directory with the name of the repository.
+	Dest string
+	// Pack sets the compression and number of threads used for the data of the clone.
+	// If nil, the defaults of git are used.
+	Pack *git.PackOptions
 }
 
 // CloneRepo clones a remote repository and initialises annex.
+// If the destination directory already exists and is not empty, an error is sent and nothing is cloned, unless opts.Force is set.
+// The clone is stopped if ctx is cancelled.
 // The status channel 'clonechan' is closed when this function returns.
-func (gincl *Client) CloneRepo(repopath string, clonechan chan<- git.RepoFileStatus) {
+func (gincl *Client) CloneRepo(ctx context.Context, repopath string, opts CloneOptions, clonechan chan<- git.RepoFileStatus) {
 	defer close(clonechan)
 	log.Write("CloneRepo")
-	clonestatus := make(chan git.RepoFileStatus)
-	remotepath := fmt.Sprintf("%s/%s", gincl.GitAddress(), repopath)
-	go git.Clone(remotepath, repopath, clonestatus)
-	for stat := range clonestatus {
-		clonechan <- stat
-		if stat.Err != nil {
+	var remotepath string
+	var err error
+	if opts.Public {
+		remotepath, err = gincl.publicCloneURL(repopath)
+	} else if opts.HTTPS {
+		remotepath, err = gincl.HTTPSAddress(repopath)
+	} else {
+		remotepath, err = gincl.RemoteAddress(repopath)
+	}
+	if err != nil {
+		clonechan <- git.RepoFileStatus{FileName: repopath, Err: err}
+		return
+	}
+	repoPathParts := strings.SplitN(repopath, "/", 2)
+	repoName := repoPathParts[len(repoPathParts)-1]
+	if opts.Dest != "" {
+		repoName = opts.Dest
+	}
+
+	empty, err := isEmptyDir(repoName)
+	if err != nil {
+		clonechan <- git.RepoFileStatus{FileName: repoName, Err: err}
+		return
+	}
+	if !empty {
+		if !opts.Force {
+			clonechan <- git.RepoFileStatus{
+				FileName: repoName,
+				State:    "Checking destination",
+				Err:      fmt.Errorf("destination directory '%s' already exists and is not empty; use --force to resume an existing clone", repoName),
+			}
 			return
 		}
+		gincl.resumeClone(ctx, remotepath, repoName, opts, clonechan)
+		return
 	}
 
-	repoPathParts := strings.SplitN(repopath, "/", 2)
-	repoName := repoPathParts[1]
+	clonestatus := make(chan git.RepoFileStatus)
+	go git.Clone(ctx, remotepath, repopath, opts.Dest, opts.Pack, clonestatus)
+	var cloneerr error
+	for stat := range clonestatus {
+		if cloneerr != nil {
+			// drain remaining messages
+			continue
+		}
+		clonechan <- stat
+		cloneerr = stat.Err
+	}
+	if cloneerr != nil {
+		return
+	}
+	if err := ctx.Err(); err != nil {
+		clonechan <- git.RepoFileStatus{Err: err}
+		return
+	}
 
 	status := git.RepoFileStatus{State: "Initialising local storage"}
 	clonechan <- status
 	os.Chdir(repoName)
-	err := gincl.InitDir(false)
+	// a newly created repository has no commits; InitDir creates the first one
+	_, reverr := git.RevParse("HEAD")
+	emptyremote := reverr != nil
+	// a public clone can't be uploaded to, so nothing is recorded in it
+	err = gincl.initDir(false, !opts.Public)
 	if err != nil {
 		status.Err = err
 		clonechan <- status
@@ -447,194 +1587,928 @@ func (gincl *Client) CloneRepo(repopath string, clonechan chan<- git.RepoFileSta
 	}
 	status.Progress = "100%"
 	clonechan <- status
+	if emptyremote {
+		gincl.initEmptyRemote(ctx, repopath, opts, clonechan)
+	}
 	return
 }
 
-// CommitIfNew creates an empty initial git commit if the current repository is completely new.
-// If a new commit is created and a default remote exists, the new commit is pushed to initialise the remote as well.
-// Returns 'true' if (and only if) a commit was created.
-func CommitIfNew() (bool, error) {
-	if git.Checkwd() == git.NotRepository {
-		// Other errors allowed
-		return false, fmt.Errorf("not a repository")
+// initEmptyRemote sets up a clone of an empty repository: the current branch is set to track the same branch on origin and, unless the clone is public (read only), the initial commit is uploaded to initialise the remote repository.
+func (gincl *Client) initEmptyRemote(ctx context.Context, repopath string, opts CloneOptions, clonechan chan<- git.RepoFileStatus) {
+	log.Write("Remote repository %s is empty", repopath)
+	clonechan <- git.RepoFileStatus{FileName: repopath, State: "Remote repository is empty; initialised locally"}
+	if err := setUpstream("origin"); err != nil {
+		log.Write("Failed to set upstream for empty repository: %v", err)
+		return
 	}
-	_, err := git.RevParse("HEAD")
-	if err == nil {
-		// All good. No need to do anything
-		return false, nil
+	if opts.Public {
+		return
 	}
+	uploadchan := make(chan git.RepoFileStatus)
+	go gincl.Upload(ctx, nil, []string{"origin"}, uploadchan)
+	forward(ctx, uploadchan, clonechan)
+}
 
-	// Create an empty initial commit
-	hostname, err := os.Hostname()
+// setUpstream configures the current branch to track the branch with the same name on the given remote.
+// Unlike 'git branch --set-upstream-to', this works when the branch does not exist on the remote yet.
+func setUpstream(remote string) error {
+	branch, err := git.CurrentBranch()
 	if err != nil {
-		hostname = unknownhostname
+		return err
 	}
-	initmsg := fmt.Sprintf("Initial commit: Repository initialised on %s", hostname)
-	if err = git.CommitEmpty(initmsg); err != nil {
-		log.Write("Error while creating initial commit")
-		return false, err
+	if err = git.ConfigSet(fmt.Sprintf("branch.%s.remote", branch), remote); err != nil {
+		return err
 	}
-
-	return true, nil
+	return git.ConfigSet(fmt.Sprintf("branch.%s.merge", branch), "refs/heads/"+branch)
 }
 
-// DefaultRemote returns the name of the configured default gin remote.
-// If a remote is not set in the config, the remote of the default git upstream is set and returned.
-func DefaultRemote() (string, error) {
-	defremote, err := git.ConfigGet("gin.remote")
+// EnsureUpstream returns the upstream branch of the current branch, which is used to determine the sync status of files.
+// If the current branch has no upstream configured, it is set to the branch with the same name on the default remote, if that branch is known locally.
+// An error is returned if no usable upstream branch can be determined.
+func EnsureUpstream() (string, error) {
+	upstream, err := git.Upstream()
 	if err == nil {
-		return defremote, nil
+		return upstream, nil
 	}
-	log.Write("Default remote not set. Checking master remote.")
-	defremote, err = git.ConfigGet("branch.master.remote")
-	if err == nil {
-		SetDefaultRemote(defremote)
-		log.Write("Set default remote to %s", defremote)
-		return defremote, nil
+	remote, err := DefaultRemote()
+	if err != nil {
+		return "", err
 	}
-	err = fmt.Errorf("could not determine default remote")
-	return defremote, err
-}
-
-// SetDefaultRemote sets the name of the default gin remote.
-func SetDefaultRemote(remote string) error {
-	remotes, err := git.RemoteShow()
+	branch, err := git.CurrentBranch()
 	if err != nil {
-		return fmt.Errorf("failed to determine configured remotes")
+		return "", err
 	}
-	if _, ok := remotes[remote]; !ok {
-		return fmt.Errorf("no such remote: %s", remote)
+	upstream = fmt.Sprintf("%s/%s", remote, branch)
+	if _, err = git.RevParse("refs/remotes/" + upstream); err != nil {
+		return "", fmt.Errorf("branch '%s' has no upstream and '%s' does not exist; upload or download to create it", branch, upstream)
 	}
-	err = git.ConfigSet("gin.remote", remote)
+	if err = setUpstream(remote); err != nil {
+		return "", err
+	}
+	log.Write("Set upstream of branch %s to %s", branch, upstream)
+	return upstream, nil
+}
+
+// CheckUpstream reports problems with the upstream branch that make the sync status reported by ListFiles inaccurate.
+// ListFiles only logs these problems, since the status of files can still be reported (without remote and local changes).
+// The upstream of the current branch is set if it is missing (see EnsureUpstream).
+func CheckUpstream() error {
+	if git.IsDirect() {
+		return nil
+	}
+	remote, err := DefaultRemote()
+	if err != nil {
+		// no remotes: all files are reported as local changes
+		return nil
+	}
+	if remoterefs, lserr := git.LsRemote(remote); lserr == nil && remoterefs == "" {
+		// uninitialised remote: same as no remotes
+		return nil
+	}
+	upstream, err := EnsureUpstream()
+	if err != nil {
+		return err
+	}
+	if _, err = git.DiffNameStatus(upstream, nil); err != nil {
+		return fmt.Errorf("failed to compare files with upstream branch '%s': %v", upstream, err)
+	}
+	return nil
+}
+
+// CreateAndCloneRepo creates a new repository with the given name and description under the account of the logged in user and clones it (see CloneRepo).
+// The status of the creation is sent on the status channel before the status of the clone.
+// The status channel 'clonechan' is closed when this function returns.
+func (gincl *Client) CreateAndCloneRepo(ctx context.Context, name, description string, opts CloneOptions, clonechan chan<- git.RepoFileStatus) {
+	repopath := fmt.Sprintf("%s/%s", gincl.Username, name)
+	status := git.RepoFileStatus{FileName: repopath, State: "Creating repository"}
+	clonechan <- status
+	if err := gincl.CreateRepo(name, description); err != nil {
+		status.Err = err
+		clonechan <- status
+		close(clonechan)
+		return
+	}
+	status.Progress = "100%"
+	clonechan <- status
+	gincl.CloneRepo(ctx, repopath, opts, clonechan)
+}
+
+// publicCloneURL returns the public (HTTPS) clone URL of a repository.
+// An error is returned if the repository does not exist or is not public.
+func (gincl *Client) publicCloneURL(repopath string) (string, error) {
+	// request without credentials to make sure the repository is accessible to anyone
+	anon := New(gincl.srvalias)
+	repo, err := anon.GetRepo(repopath)
+	if err != nil {
+		if gerr, ok := err.(ginerror); ok && strings.HasPrefix(gerr.UError, "404") {
+			// private repositories are not found by anonymous requests
+			return "", fmt.Errorf("repository '%s' does not exist or is private; log in to get private repositories", repopath)
+		}
+		return "", err
+	}
+	if repo.Private || repo.CloneURL == "" {
+		return "", fmt.Errorf("repository '%s' is private; log in to get private repositories", repopath)
+	}
+	return repo.CloneURL, nil
+}
+
+// resumeClone continues an interrupted or earlier clone of remotepath found in the directory repoName.
+// The directory must be a git repository whose origin is remotepath.
+// Local storage is (re)initialised and the clone is updated from origin.
+func (gincl *Client) resumeClone(ctx context.Context, remotepath, repoName string, opts CloneOptions, clonechan chan<- git.RepoFileStatus) {
+	status := git.RepoFileStatus{FileName: repoName, State: "Resuming existing clone"}
+	if _, err := os.Stat(filepath.Join(repoName, ".git")); err != nil {
+		status.Err = fmt.Errorf("destination directory '%s' already exists and is not a repository", repoName)
+		clonechan <- status
+		return
+	}
+	origdir, err := os.Getwd()
+	if err != nil {
+		status.Err = err
+		clonechan <- status
+		return
+	}
+	if err = os.Chdir(repoName); err != nil {
+		status.Err = err
+		clonechan <- status
+		return
+	}
+	// like a new clone, the clone remains the working directory on success
+	resumed := false
+	defer func() {
+		if !resumed {
+			os.Chdir(origdir)
+		}
+	}()
+	if origin, err := git.ConfigGet("remote.origin.url"); err != nil || origin != remotepath {
+		status.Err = fmt.Errorf("destination directory '%s' already exists and is a clone of a different repository", repoName)
+		clonechan <- status
+		return
+	}
+	clonechan <- status
+
+	status = git.RepoFileStatus{State: "Initialising local storage"}
+	clonechan <- status
+	if err := gincl.initDir(false, !opts.Public); err != nil {
+		status.Err = err
+		clonechan <- status
+		return
+	}
+	status.Progress = "100%"
+	clonechan <- status
+
+	status = git.RepoFileStatus{State: "Updating repository"}
+	clonechan <- status
+	if err := git.AnnexPull(ctx, "origin"); err != nil {
+		status.Err = err
+		clonechan <- status
+		return
+	}
+	resumed = true
+	status.Progress = "100%"
+	clonechan <- status
+}
+
+// isEmptyDir returns true if the directory at path is empty or does not exist.
+// An error is returned if path exists and is not a directory.
+func isEmptyDir(path string) (bool, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return true, nil
+	} else if err != nil {
+		return false, err
+	}
+	if !info.IsDir() {
+		return false, fmt.Errorf("destination '%s' already exists and is not a directory", path)
+	}
+	entries, err := ioutil.ReadDir(path)
+	if err != nil {
+		return false, err
+	}
+	return len(entries) == 0, nil
+}
+
+// CommitIfNew creates an empty initial git commit if the current repository is completely new.
+// If a new commit is created and a default remote exists, the new commit is pushed to initialise the remote as well.
+// Returns 'true' if (and only if) a commit was created.
+func CommitIfNew() (bool, error) {
+	if git.Checkwd() == git.NotRepository {
+		// Other errors allowed
+		return false, fmt.Errorf("not a repository")
+	}
+	_, err := git.RevParse("HEAD")
+	if err == nil {
+		// All good. No need to do anything
+		return false, nil
+	}
+
+	// Create an empty initial commit
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = unknownhostname
+	}
+	initmsg := fmt.Sprintf("Initial commit: Repository initialised on %s", hostname)
+	if err = git.CommitEmpty(initmsg); err != nil {
+		log.Write("Error while creating initial commit")
+		return false, err
+	}
+
+	return true, nil
+}
+
+// DefaultRemote returns the name of the configured default gin remote.
+// If a remote is not set in the config, the remote of the default git upstream is set and returned.
+func DefaultRemote() (string, error) {
+	defremote, err := git.ConfigGet("gin.remote")
+	if err == nil {
+		return defremote, nil
+	}
+	log.Write("Default remote not set. Checking master remote.")
+	defremote, err = git.ConfigGet("branch.master.remote")
+	if err == nil {
+		SetDefaultRemote(defremote)
+		log.Write("Set default remote to %s", defremote)
+		return defremote, nil
+	}
+	err = fmt.Errorf("could not determine default remote")
+	return defremote, err
+}
+
+// SetDefaultRemote sets the name of the default gin remote.
+func SetDefaultRemote(remote string) error {
+	remotes, err := git.RemoteShow()
+	if err != nil {
+		return fmt.Errorf("failed to determine configured remotes")
+	}
+	if _, ok := remotes[remote]; !ok {
+		return fmt.Errorf("no such remote: %s", remote)
+	}
+	err = git.ConfigSet("gin.remote", remote)
 	if err != nil {
 		return fmt.Errorf("failed to set default remote: %s", err)
 	}
 	return nil
 }
 
-// UnsetDefaultRemote unsets the default gin remote in the git configuration.
-func UnsetDefaultRemote() error {
-	err := git.ConfigUnset("gin.remote")
-	if err != nil {
-		return fmt.Errorf("failed to unset default remote: %s", err)
+// UnsetDefaultRemote unsets the default gin remote in the git configuration.
+func UnsetDefaultRemote() error {
+	err := git.ConfigUnset("gin.remote")
+	if err != nil {
+		return fmt.Errorf("failed to unset default remote: %s", err)
+	}
+	return nil
+}
+
+// RemoveRemote removes a remote from the repository configuration.
+func RemoveRemote(remote string) error {
+	remotes, err := git.RemoteShow()
+	if err != nil {
+		return fmt.Errorf("failed to determine configured remotes")
+	}
+	if _, ok := remotes[remote]; !ok {
+		return fmt.Errorf("no such remote: %s", remote)
+	}
+	err = git.RemoteRemove(remote)
+	return err
+}
+
+// RepoPathFromURL returns the repository path (<owner>/<repository>) of a remote URL.
+// Both URLs with a scheme (ssh://, https://) and scp-like ssh addresses (user@host:owner/repository) are supported.
+// An error is returned for local paths and URLs that do not point to a repository on a server.
+func RepoPathFromURL(remoteurl string) (string, error) {
+	var repopath string
+	if strings.Contains(remoteurl, "://") {
+		u, err := url.Parse(remoteurl)
+		if err != nil || u.Scheme == "file" || u.Host == "" {
+			return "", fmt.Errorf("'%s' is not the URL of a repository on a server", git.RedactURLs(remoteurl))
+		}
+		repopath = u.Path
+	} else if parts := strings.SplitN(remoteurl, ":", 2); len(parts) == 2 && len(parts[0]) > 1 && !strings.ContainsAny(parts[0], `/\`) {
+		// scp-like address; single letter hosts are Windows drive letters
+		repopath = parts[1]
+	} else {
+		return "", fmt.Errorf("'%s' is not the URL of a repository on a server", remoteurl)
+	}
+	repopath = strings.TrimSuffix(strings.Trim(repopath, "/"), ".git")
+	if strings.Count(repopath, "/") != 1 {
+		return "", fmt.Errorf("'%s' is not the URL of a repository on a server", git.RedactURLs(remoteurl))
+	}
+	return repopath, nil
+}
+
+// RemoteHostFromURL returns the host name (without user and port) of a remote URL.
+// The same URLs as for RepoPathFromURL are supported.
+func RemoteHostFromURL(remoteurl string) (string, error) {
+	if _, err := RepoPathFromURL(remoteurl); err != nil {
+		return "", err
+	}
+	if strings.Contains(remoteurl, "://") {
+		u, _ := url.Parse(remoteurl)
+		return u.Hostname(), nil
+	}
+	host := strings.SplitN(remoteurl, ":", 2)[0]
+	if idx := strings.LastIndex(host, "@"); idx >= 0 {
+		host = host[idx+1:]
+	}
+	return host, nil
+}
+
+// OnServer returns true if 'remoteurl' points to the configured server of the client, i.e., its host is the host of the web or git server or one of 'oldhosts' (previous addresses of the server).
+func (gincl *Client) OnServer(remoteurl string, oldhosts []string) bool {
+	host, err := RemoteHostFromURL(remoteurl)
+	if err != nil {
+		return false
+	}
+	srvcfg := config.Read().Servers[gincl.srvalias]
+	for _, srvhost := range append([]string{srvcfg.Web.Host, srvcfg.Git.Host}, oldhosts...) {
+		if strings.EqualFold(host, srvhost) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServerRemoteURL returns the URL of the repository that 'remoteurl' points to on the configured server of the client.
+// This can be used to update the remotes of a repository after the server has moved to a new address.
+// HTTPS URLs are rewritten to the HTTPS address of the server (the public address if the user is not logged in); all others to the address returned by RemoteAddress.
+// The returned URL never includes credentials, so access tokens that were stored in the HTTPS URLs of older clones are removed from them.
+func (gincl *Client) ServerRemoteURL(remoteurl string) (string, error) {
+	repopath, err := RepoPathFromURL(remoteurl)
+	if err != nil {
+		return "", err
+	}
+	return gincl.remoteURLForPath(remoteurl, repopath)
+}
+
+// MovedRemoteURL returns the URL of the repository 'newpath' on the configured server of the client, using the same protocol as 'remoteurl' (see ServerRemoteURL).
+// This can be used to update the remotes of a repository after it has been renamed or transferred to another owner.
+func (gincl *Client) MovedRemoteURL(remoteurl, newpath string) (string, error) {
+	if _, err := RepoPathFromURL(remoteurl); err != nil {
+		return "", err
+	}
+	return gincl.remoteURLForPath(remoteurl, newpath)
+}
+
+// remoteURLForPath returns the URL of the repository 'repopath' on the configured server, using HTTPS if 'remoteurl' uses HTTPS.
+func (gincl *Client) remoteURLForPath(remoteurl, repopath string) (string, error) {
+	if strings.HasPrefix(remoteurl, "http://") || strings.HasPrefix(remoteurl, "https://") {
+		if gincl.Token == "" {
+			return gincl.publicCloneURL(repopath)
+		}
+		return gincl.HTTPSAddress(repopath)
+	}
+	return gincl.RemoteAddress(repopath)
+}
+
+// CheckoutVersion checks out all files specified by paths from the revision with the specified commithash.
+func CheckoutVersion(commithash string, paths []string) error {
+	err := git.Checkout(commithash, paths)
+	if err != nil {
+		return err
+	}
+
+	return git.AnnexFsck(paths)
+}
+
+// RestoreFileVersion replaces a single file in the working tree with its version from the revision with the specified commithash.
+// Other files are not modified. The restored file is added to the index, but not committed.
+// The content of an annexed file is retrieved from a remote if it is not available locally.
+// The running git-annex command is stopped if ctx is cancelled.
+// The status channel 'restorechan' is closed when this function returns.
+func RestoreFileVersion(ctx context.Context, commithash, path string, restorechan chan<- git.RepoFileStatus) {
+	defer close(restorechan)
+	status := git.RepoFileStatus{FileName: path, State: "Restoring"}
+	objtype, err := git.CatFileType(fmt.Sprintf("%s:./%s", commithash, filepath.ToSlash(path)))
+	if err != nil {
+		status.Err = fmt.Errorf("'%s' does not exist in version %s", path, commithash)
+		restorechan <- status
+		return
+	}
+	if objtype = strings.TrimSpace(objtype); objtype != "blob" {
+		status.Err = fmt.Errorf("'%s' is not a file in version %s", path, commithash)
+		restorechan <- status
+		return
+	}
+	if err = CheckoutVersion(commithash, []string{path}); err != nil {
+		status.Err = err
+		restorechan <- status
+		return
+	}
+
+	missing, err := git.AnnexFindMatching([]string{"--not", "--in=here"}, []string{path})
+	if err != nil {
+		// not annexed
+		log.Write("Failed to check content of %q: %v", path, err)
+	}
+	if len(missing) == 0 {
+		status.Progress = "100%"
+		restorechan <- status
+		return
+	}
+	getchan := make(chan git.RepoFileStatus)
+	go git.AnnexGet(ctx, []string{path}, getchan)
+	forward(ctx, getchan, restorechan)
+}
+
+// CheckoutFileCopies checks out copies of files specified by path from the revision with the specified commithash.
+// The checked out files are stored in the location specified by outpath.
+// The timestamp of the revision is appended to the original filenames (before the extension).
+func CheckoutFileCopies(commithash string, paths []string, outpath string, suffix string, cochan chan<- FileCheckoutStatus) {
+	rename := func(name string) string {
+		filext := filepath.Ext(name)
+		return fmt.Sprintf("%s-%s%s", strings.TrimSuffix(name, filext), suffix, filext)
+	}
+	checkoutObjects(commithash, paths, outpath, rename, false, cochan)
+}
+
+// ExportFiles writes a plain copy of the files specified by path, as they were in the revision with the specified commithash, to the directory specified by outpath.
+// The directory structure and filenames are preserved and annexed files are replaced by their content, retrieving it from a remote if it is not available locally.
+// Plain symbolic links are recreated in the destination.
+// The destination must not exist or be an empty directory.
+func ExportFiles(commithash string, paths []string, outpath string, cochan chan<- FileCheckoutStatus) {
+	empty, err := isEmptyDir(outpath)
+	if err == nil && !empty {
+		err = fmt.Errorf("destination '%s' already exists and is not an empty directory", outpath)
+	}
+	if err != nil {
+		cochan <- FileCheckoutStatus{Err: err}
+		close(cochan)
+		return
+	}
+	rename := func(name string) string { return name }
+	checkoutObjects(commithash, paths, outpath, rename, true, cochan)
+}
+
+// ImportFiles copies (or moves) the files under the directory source into the directory dest, preserving the directory structure.
+// Files that already exist in the destination are skipped unless opts.Overwrite is set.
+// Git repository directories ('.git') found in the source are not imported.
+// The status channel 'impchan' is closed when this function returns.
+func ImportFiles(source, dest string, opts ImportOptions, impchan chan<- FileImportStatus) {
+	defer close(impchan)
+	source, err := filepath.Abs(source)
+	if err != nil {
+		impchan <- FileImportStatus{Err: err}
+		return
+	}
+	dest, err = filepath.Abs(dest)
+	if err != nil {
+		impchan <- FileImportStatus{Err: err}
+		return
+	}
+	info, err := os.Stat(source)
+	if err != nil {
+		impchan <- FileImportStatus{Err: err}
+		return
+	}
+	if !info.IsDir() {
+		impchan <- FileImportStatus{Err: fmt.Errorf("source '%s' is not a directory", source)}
+		return
+	}
+	if isWithin(source, dest) {
+		impchan <- FileImportStatus{Err: fmt.Errorf("destination '%s' is inside the source directory '%s'", dest, source)}
+		return
+	}
+
+	var dirs []string
+	walker := func(srcpath string, info os.FileInfo, err error) error {
+		rel, rerr := filepath.Rel(source, srcpath)
+		if rerr != nil {
+			return rerr
+		}
+		status := FileImportStatus{Filename: rel, Destination: filepath.Join(dest, rel)}
+		if err != nil {
+			status.Err = err
+			impchan <- status
+			return nil
+		}
+		if info.IsDir() {
+			if info.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			dirs = append(dirs, srcpath)
+			if mderr := os.MkdirAll(status.Destination, 0777); mderr != nil {
+				status.Err = mderr
+				impchan <- status
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, lerr := os.Lstat(status.Destination); lerr == nil {
+			if !opts.Overwrite {
+				status.Skipped = true
+				impchan <- status
+				return nil
+			}
+			if rmerr := os.Remove(status.Destination); rmerr != nil {
+				status.Err = rmerr
+				impchan <- status
+				return nil
+			}
+		}
+		status.Err = importFile(srcpath, status.Destination, info, opts.Move)
+		impchan <- status
+		return nil
+	}
+	if err = filepath.Walk(source, walker); err != nil {
+		impchan <- FileImportStatus{Err: err}
+		return
+	}
+	if opts.Move {
+		// remove the emptied source directories, deepest first; directories
+		// with skipped files are not empty and are left in place
+		for idx := len(dirs) - 1; idx >= 0; idx-- {
+			os.Remove(dirs[idx])
+		}
+	}
+}
+
+// importFile copies or moves the file at srcpath to destpath.
+// Symbolic links are recreated; other non-regular files are not supported.
+func importFile(srcpath, destpath string, info os.FileInfo, move bool) error {
+	if info.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(srcpath)
+		if err != nil {
+			return err
+		}
+		if err = os.Symlink(target, destpath); err != nil {
+			return err
+		}
+	} else if info.Mode().IsRegular() {
+		if move && os.Rename(srcpath, destpath) == nil {
+			return nil
+		}
+		// copy if not moving or if renaming failed (e.g., across filesystems)
+		if err := git.CopyFile(srcpath, destpath); err != nil {
+			return err
+		}
+	} else {
+		return fmt.Errorf("unsupported file type: %s", info.Mode().String())
+	}
+	if move {
+		return os.Remove(srcpath)
+	}
+	return nil
+}
+
+// checkoutObjects writes the contents of the objects specified by path from the revision with the specified commithash to outpath.
+// The destination name of each file is determined by the rename function.
+// If makelinks is true, plain symbolic links are recreated in the destination, otherwise only their target is reported.
+func checkoutObjects(commithash string, paths []string, outpath string, rename func(string) string, makelinks bool, cochan chan<- FileCheckoutStatus) {
+	defer close(cochan)
+	objects, err := git.LsTree(commithash, paths)
+	if err != nil {
+		cochan <- FileCheckoutStatus{Err: err}
+		return
+	}
+
+	for _, obj := range objects {
+		var status FileCheckoutStatus
+		if obj.Type == "blob" {
+			status.Filename = obj.Name
+
+			outfilename := rename(obj.Name)
+			outfile, perr := safeDestination(outpath, outfilename)
+			if perr != nil {
+				status.Err = perr
+				cochan <- status
+				continue
+			}
+			status.Destination = outfile
+
+			// determine if it's an annexed link
+			content, cerr := git.CatFileContents(commithash, obj.Name)
+			if cerr != nil {
+				cochan <- FileCheckoutStatus{Err: cerr}
+				return
+			}
+			if mderr := os.MkdirAll(filepath.Dir(outfile), 0777); mderr != nil {
+				cochan <- FileCheckoutStatus{Err: mderr}
+				return
+			}
+			// check again now that the parent directories exist, in case
+			// any of them is a symbolic link
+			if _, perr := safeDestination(outpath, outfilename); perr != nil {
+				status.Err = perr
+				cochan <- status
+				continue
+			}
+
+			if isAnnexPointer(content) {
+				// Pointer file to annexed content
+				status.Type = "Annex"
+				contentloc, err := annexContentLocation(content)
+				if err != nil {
+					status.Err = err
+					cochan <- status
+					continue
+				}
+				err = git.CopyFile(contentloc, outfile)
+				if err != nil {
+					status.Err = fmt.Errorf("Error writing %s: %s", outfile, err.Error())
+				}
+			} else if obj.Mode == "120000" {
+				// Plain symlink
+				status.Type = "Link"
+				if makelinks {
+					if lerr := os.Symlink(string(content), outfile); lerr != nil {
+						status.Err = fmt.Errorf("Error writing %s: %s", outfile, lerr.Error())
+					}
+				} else {
+					status.Destination = string(content)
+				}
+			} else if obj.Mode == "100755" || obj.Mode == "100644" {
+				status.Type = "Git"
+				werr := ioutil.WriteFile(outfile, content, 0666)
+				if werr != nil {
+					status.Err = fmt.Errorf("Error writing %s: %s", outfile, werr.Error())
+				}
+			} else {
+				status.Err = fmt.Errorf("Unexpected object found in tree: %s", obj.Name)
+			}
+			cochan <- status
+		} else if obj.Type == "tree" {
+			status.Type = "Tree"
+			status.Filename = obj.Name
+			dest, perr := safeDestination(outpath, obj.Name)
+			if perr != nil {
+				status.Err = perr
+				cochan <- status
+				continue
+			}
+			status.Destination = dest
+			os.MkdirAll(status.Destination, 0777)
+			cochan <- status
+		}
+	}
+}
+
+// isAnnexPointer returns true if the given file content is a pointer to annexed content.
+func isAnnexPointer(content []byte) bool {
+	// heuristic check for annexed pointer file:
+	// - check if the first 255 bytes of the file (or the entire
+	// contents if smaller) contain the string /annex/objects
+	maxpathidx := 255
+	if len(content) < maxpathidx {
+		maxpathidx = len(content)
+	}
+	return isAnnexPath(string(content[:maxpathidx]))
+}
+
+// annexContentLocation returns the location of the annexed content that the pointer refers to.
+// If the content is not available locally, it is downloaded first.
+func annexContentLocation(pointer []byte) (string, error) {
+	// strip any newlines from the end of the path
+	keypath := strings.TrimSpace(string(pointer))
+	_, key := path.Split(keypath)
+	contentloc, err := git.AnnexContentLocation(key)
+	if err != nil {
+		getchan := make(chan git.RepoFileStatus)
+		go git.AnnexGetKey(key, getchan)
+		for range getchan {
+		}
+		contentloc, err = git.AnnexContentLocation(key)
+		if err != nil {
+			return "", fmt.Errorf("Annexed content is not available locally")
+		}
+	}
+	return contentloc, nil
+}
+
+// CatFileVersion writes the contents of the file at the repository path 'name', as it was in the revision with the specified commithash, to w.
+// Annexed files are resolved to their content, which is downloaded if it is not available locally.
+// For symbolic links that are not annexed files, the link target is written.
+func CatFileVersion(commithash, name string, w io.Writer) error {
+	objects, err := git.LsTree(commithash, []string{name})
+	if err != nil {
+		return err
+	}
+	var obj *git.Object
+	for idx := range objects {
+		if objects[idx].Name == filepath.ToSlash(name) {
+			obj = &objects[idx]
+			break
+		}
+	}
+	if obj == nil {
+		return fmt.Errorf("'%s' did not exist in revision %s", name, commithash)
+	}
+	if obj.Type != "blob" {
+		return fmt.Errorf("'%s' is not a file in revision %s", name, commithash)
+	}
+	content, err := git.CatFileContents(commithash, obj.Name)
+	if err != nil {
+		return err
+	}
+	if !isAnnexPointer(content) {
+		_, err = w.Write(content)
+		return err
+	}
+	contentloc, err := annexContentLocation(content)
+	if err != nil {
+		return err
+	}
+	contentfile, err := os.Open(contentloc)
+	if err != nil {
+		return err
+	}
+	defer contentfile.Close()
+	_, err = io.Copy(w, contentfile)
+	return err
+}
+
+// safeDestination joins the relative path 'name' to the directory 'base' and returns an error if the resulting path is not inside base.
+// This guards against writing files outside the destination directory when the names come from repository objects.
+// A path is rejected if name is absolute or climbs out of base with '..' elements, if an existing directory along the path is a symbolic link that leads outside base, or if the destination itself is an existing symbolic link.
+func safeDestination(base, name string) (string, error) {
+	outside := fmt.Errorf("refusing to write %q: path is outside the destination directory %q", name, base)
+	if filepath.IsAbs(name) || filepath.VolumeName(name) != "" || strings.HasPrefix(name, "/") {
+		return "", outside
+	}
+	dest := filepath.Join(base, name)
+	if !isWithin(base, dest) {
+		return "", outside
+	}
+
+	if info, err := os.Lstat(dest); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return "", fmt.Errorf("refusing to write %q: destination is a symbolic link", dest)
+	}
+
+	// resolve symbolic links in the deepest existing directory of the path
+	realbase, err := filepath.EvalSymlinks(base)
+	if err != nil {
+		// base doesn't exist yet: nothing can be linked
+		return dest, nil
+	}
+	for dir := filepath.Dir(dest); isWithin(base, dir); dir = filepath.Dir(dir) {
+		realdir, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			continue
+		}
+		if !isWithin(realbase, realdir) {
+			return "", outside
+		}
+		break
+	}
+	return dest, nil
+}
+
+// isWithin returns true if path is base or is inside base.
+// Both paths are compared lexically.
+func isWithin(base, path string) bool {
+	rel, err := filepath.Rel(base, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// InitWithOrigin initialises the current directory (see InitDir) and links it to the existing repository 'repopath' on the server, which is added as the remote 'origin'.
+// If the remote repository has history, the local branch is set to the remote branch: files in the directory are kept as local changes and files that only exist in the remote repository are restored (annexed files as placeholders).
+// If the remote repository is empty, the initial local commit is uploaded to initialise it.
+// An error is returned if the directory is already a repository with a different origin, or if both the local and the remote repository have unrelated history.
+func (gincl *Client) InitWithOrigin(ctx context.Context, repopath string) error {
+	fn := fmt.Sprintf("InitWithOrigin(%s)", repopath)
+	url, err := gincl.RemoteAddress(repopath)
+	if err != nil {
+		return err
+	}
+	hasorigin := false
+	if git.Checkwd() != git.NotRepository {
+		if origin, err := git.ConfigGet("remote.origin.url"); err == nil {
+			if origin != url {
+				return ginerror{Origin: fn, Description: fmt.Sprintf("the directory is already a repository with a different origin (%s)", origin)}
+			}
+			hasorigin = true
+		}
+	} else if err := git.Init(false); err != nil {
+		return err
+	}
+	if !hasorigin {
+		if err := git.RemoteAdd("origin", url); err != nil {
+			return err
+		}
+	}
+	refs, err := git.LsRemote("origin")
+	if err != nil {
+		return err
+	}
+	if branch := remoteHeadBranch(refs); branch != "" {
+		// the remote branches are needed to set up the local branch
+		if err := git.Fetch("origin"); err != nil {
+			return err
+		}
+		remotehead := fmt.Sprintf("origin/%s", branch)
+		if localhead, err := git.RevParse("HEAD"); err == nil {
+			remotecommit, _ := git.RevParse(remotehead)
+			if localhead != remotecommit {
+				return ginerror{Origin: fn, Description: fmt.Sprintf("both the local directory and the remote repository '%s' already have history; use 'gin add-remote' and 'gin download' to combine them", repopath)}
+			}
+		} else if err := checkoutKeepFiles(branch, remotehead); err != nil {
+			return err
+		}
+		if err := setUpstream("origin"); err != nil {
+			return err
+		}
+		return gincl.InitDir(false)
+	}
+
+	// empty remote: InitDir creates the initial commit which is uploaded to
+	// initialise the remote
+	if err := gincl.InitDir(false); err != nil {
+		return err
+	}
+	if err := setUpstream("origin"); err != nil {
+		return err
+	}
+	uploadchan := make(chan git.RepoFileStatus)
+	go gincl.Upload(ctx, nil, []string{"origin"}, uploadchan)
+	for stat := range uploadchan {
+		if stat.Err != nil {
+			return stat.Err
+		}
+	}
+	return nil
+}
+
+// remoteHeadBranch returns the name of the branch that the HEAD of a remote points to, given the output of 'git ls-remote'.
+// It returns an empty string if the remote has no HEAD (e.g., the remote repository is empty).
+func remoteHeadBranch(lsremote string) string {
+	var head string
+	branches := make(map[string]string)
+	for _, line := range strings.Split(lsremote, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if fields[1] == "HEAD" {
+			head = fields[0]
+		} else if strings.HasPrefix(fields[1], "refs/heads/") {
+			branches[strings.TrimPrefix(fields[1], "refs/heads/")] = fields[0]
+		}
 	}
-	return nil
-}
-
-// RemoveRemote removes a remote from the repository configuration.
-func RemoveRemote(remote string) error {
-	remotes, err := git.RemoteShow()
-	if err != nil {
-		return fmt.Errorf("failed to determine configured remotes")
+	if head == "" {
+		return ""
 	}
-	if _, ok := remotes[remote]; !ok {
-		return fmt.Errorf("no such remote: %s", remote)
+	// prefer the conventional names when several branches point to HEAD
+	for _, name := range []string{"master", "main"} {
+		if branches[name] == head {
+			return name
+		}
 	}
-	err = git.RemoteRemove(remote)
-	return err
+	for name, hash := range branches {
+		if hash == head {
+			return name
+		}
+	}
+	return ""
 }
 
-// CheckoutVersion checks out all files specified by paths from the revision with the specified commithash.
-func CheckoutVersion(commithash string, paths []string) error {
-	err := git.Checkout(commithash, paths)
-	if err != nil {
-		return err
+// checkoutKeepFiles points the current (unborn) branch to 'branch' at the revision 'rev' without modifying existing files in the working tree.
+// Files that exist at the revision but not in the working tree are checked out.
+func checkoutKeepFiles(branch, rev string) error {
+	fn := fmt.Sprintf("checkoutKeepFiles(%s, %s)", branch, rev)
+	cmds := [][]string{
+		{"symbolic-ref", "HEAD", fmt.Sprintf("refs/heads/%s", branch)},
+		{"reset", "--quiet", rev},
+	}
+	for _, args := range cmds {
+		cmd := git.Command(args...)
+		if _, stderr, err := cmd.OutputError(); err != nil {
+			log.Write("Error during %s: %s", args[0], string(stderr))
+			return ginerror{UError: string(stderr), Origin: fn, Description: "failed to set up local branch"}
+		}
 	}
 
-	return git.AnnexFsck(paths)
-}
-
-// CheckoutFileCopies checks out copies of files specified by path from the revision with the specified commithash.
-// The checked out files are stored in the location specified by outpath.
-// The timestamp of the revision is appended to the original filenames (before the extension).
-func CheckoutFileCopies(commithash string, paths []string, outpath string, suffix string, cochan chan<- FileCheckoutStatus) {
-	defer close(cochan)
-	objects, err := git.LsTree(commithash, paths)
+	cmd := git.Command("ls-files", "--deleted", "-z")
+	stdout, stderr, err := cmd.OutputError()
 	if err != nil {
-		cochan <- FileCheckoutStatus{Err: err}
-		return
+		return ginerror{UError: string(stderr), Origin: fn, Description: "failed to list missing files"}
 	}
-
-	for _, obj := range objects {
-		var status FileCheckoutStatus
-		if obj.Type == "blob" {
-			status.Filename = obj.Name
-
-			filext := filepath.Ext(obj.Name)
-			outfilename := fmt.Sprintf("%s-%s%s", strings.TrimSuffix(obj.Name, filext), suffix, filext)
-			outfile := filepath.Join(outpath, outfilename)
-			status.Destination = outfile
-
-			// determine if it's an annexed link
-			content, cerr := git.CatFileContents(commithash, obj.Name)
-			if cerr != nil {
-				cochan <- FileCheckoutStatus{Err: cerr}
-				return
-			}
-			if mderr := os.MkdirAll(outpath, 0777); mderr != nil {
-				cochan <- FileCheckoutStatus{Err: mderr}
-				return
-			}
-
-			// heuristic check for annexed pointer file:
-			// - check if the first 255 bytes of the file (or the entire
-			// contents if smaller) contain the string /annex/objects
-			maxpathidx := 255
-			if len(content) < maxpathidx {
-				maxpathidx = len(content)
-			}
-
-			if isAnnexPath(string(content[:maxpathidx])) {
-				// Pointer file to annexed content
-				status.Type = "Annex"
-				// strip any newlines from the end of the path
-				keypath := strings.TrimSpace(string(content))
-				_, key := path.Split(keypath)
-				contentloc, err := git.AnnexContentLocation(key)
-				if err != nil {
-					getchan := make(chan git.RepoFileStatus)
-					go git.AnnexGetKey(key, getchan)
-					for range getchan {
-					}
-					contentloc, err = git.AnnexContentLocation(key)
-					if err != nil {
-						status.Err = fmt.Errorf("Annexed content is not available locally")
-						cochan <- status
-						continue
-					}
-					status.Err = nil
-				}
-				err = git.CopyFile(contentloc, outfile)
-				if err != nil {
-					status.Err = fmt.Errorf("Error writing %s: %s", outfile, err.Error())
-				}
-			} else if obj.Mode == "120000" {
-				// Plain symlink
-				status.Type = "Link"
-				status.Destination = string(content)
-			} else if obj.Mode == "100755" || obj.Mode == "100644" {
-				status.Type = "Git"
-				werr := ioutil.WriteFile(outfile, content, 0666)
-				if werr != nil {
-					status.Err = fmt.Errorf("Error writing %s: %s", outfile, werr.Error())
-				}
-			} else {
-				status.Err = fmt.Errorf("Unexpected object found in tree: %s", obj.Name)
-			}
-			cochan <- status
-		} else if obj.Type == "tree" {
-			status.Type = "Tree"
-			status.Filename = obj.Name
-			status.Destination = filepath.Join(outpath, obj.Name)
-			os.MkdirAll(status.Destination, 0777)
-			cochan <- status
+	var missing []string
+	for _, fname := range strings.Split(string(stdout), "\000") {
+		if fname != "" {
+			missing = append(missing, fname)
 		}
 	}
+	if len(missing) == 0 {
+		return nil
+	}
+	cmd = git.Command(append([]string{"checkout", "--"}, missing...)...)
+	if _, stderr, err := cmd.OutputError(); err != nil {
+		return ginerror{UError: string(stderr), Origin: fn, Description: "failed to check out files from the remote repository"}
+	}
+	return nil
 }
 
 // InitDir initialises the local directory with the default remote and git (and annex) configuration options.
 // Optionally initialised as a bare repository (for annex directory remotes).
 func (gincl *Client) InitDir(bare bool) error {
+	return gincl.initDir(bare, true)
+}
+
+// initDir implements InitDir.
+// If 'commit' is false, the initial commit is not created in a repository without commits.
+func (gincl *Client) initDir(bare, commit bool) error {
 	initerr := ginerror{Origin: "InitDir", Description: "Error initialising local directory"}
 	if git.Checkwd() == git.NotRepository {
 		err := git.Init(bare)
@@ -644,6 +2518,16 @@ func (gincl *Client) InitDir(bare bool) error {
 		}
 	}
 
+	if !bare && config.Read().Annex.NoAnnex {
+		// annex.noannex only applies to repositories that don't have an annex yet
+		if _, cerr := git.ConfigGet("annex.version"); cerr != nil {
+			if err := git.SetNoAnnex(true); err != nil {
+				initerr.UError = err.Error()
+				return initerr
+			}
+		}
+	}
+
 	hostname, err := os.Hostname()
 	if err != nil {
 		hostname = unknownhostname
@@ -678,7 +2562,7 @@ func (gincl *Client) InitDir(bare bool) error {
 		git.ConfigSet("core.symlinks", "false")
 	}
 
-	if !bare {
+	if !bare && commit {
 		_, err = CommitIfNew()
 		if err != nil {
 			initerr.UError = err.Error()
@@ -686,6 +2570,11 @@ func (gincl *Client) InitDir(bare bool) error {
 		}
 	}
 
+	if !bare && git.NoAnnex() {
+		log.Write("git-annex disabled: skipping annex init")
+		return nil
+	}
+
 	err = git.AnnexInit(description)
 	if err != nil {
 		initerr.UError = err.Error()
@@ -695,6 +2584,37 @@ func (gincl *Client) InitDir(bare bool) error {
 	return nil
 }
 
+// InitDirNoAnnex initialises the local directory as a plain git repository without git-annex.
+// The repository is marked with git.SetNoAnnex, so that all files are added to git.
+func (gincl *Client) InitDirNoAnnex() error {
+	if git.Checkwd() == git.NotRepository {
+		err := git.Init(false)
+		if err != nil {
+			return ginerror{UError: err.Error(), Origin: "InitDirNoAnnex", Description: "Error initialising local directory"}
+		}
+	}
+	if err := git.SetNoAnnex(true); err != nil {
+		return ginerror{UError: err.Error(), Origin: "InitDirNoAnnex", Description: "Error initialising local directory"}
+	}
+	return gincl.InitDir(false)
+}
+
+// MigrateDirect converts a repository in direct mode to the current repository version, where the working tree is kept on an adjusted unlocked branch (see git.AnnexUpgrade).
+// New files are added unlocked, as in repositories initialised with InitDir.
+// It returns an error if the repository is not in direct mode.
+func MigrateDirect() error {
+	if !git.IsDirect() {
+		return fmt.Errorf("repository is not in direct mode")
+	}
+	if err := git.AnnexUpgrade(); err != nil {
+		return err
+	}
+	if git.IsDirect() {
+		return ginerror{Origin: "MigrateDirect", Description: "repository is still in direct mode after upgrade; the installed version of git-annex may be too old"}
+	}
+	return git.SetAnnexConfig("addunlocked", "true")
+}
+
 // Description returns the long description of the file status
 func (fs FileStatus) Description() string {
 	switch {
@@ -706,6 +2626,8 @@ func (fs FileStatus) Description() string {
 		return "Locally modified (unsaved)"
 	case fs == LocalChanges:
 		return "Locally modified (not uploaded)"
+	case fs == NewFile:
+		return "New file (not uploaded)"
 	case fs == RemoteChanges:
 		return "Remotely modified (not downloaded)"
 	case fs == Unlocked:
@@ -722,7 +2644,7 @@ func (fs FileStatus) Description() string {
 }
 
 // Abbrev returns the two-letter abbrevation of the file status
-// OK (Synced), NC (NoContent), MD (Modified), LC (LocalUpdates), RC (RemoteUpdates), UL (Unlocked), TC (TypeChange), RM (Removed), ?? (Untracked)
+// OK (Synced), NC (NoContent), MD (Modified), LC (LocalUpdates), NF (NewFile), RC (RemoteUpdates), UL (Unlocked), TC (TypeChange), RM (Removed), ?? (Untracked)
 func (fs FileStatus) Abbrev() string {
 	switch {
 	case fs == Synced:
@@ -733,6 +2655,8 @@ func (fs FileStatus) Abbrev() string {
 		return "MD"
 	case fs == LocalChanges:
 		return "LC"
+	case fs == NewFile:
+		return "NF"
 	case fs == RemoteChanges:
 		return "RC"
 	case fs == Unlocked:
@@ -748,8 +2672,63 @@ func (fs FileStatus) Abbrev() string {
 	}
 }
 
-func lfDirect(paths ...string) (map[string]FileStatus, error) {
-	statuses := make(map[string]FileStatus)
+// ParseFileStatus returns the FileStatus that corresponds to the given two-letter abbreviation (see Abbrev()).
+// The comparison is case insensitive.
+func ParseFileStatus(abbrev string) (FileStatus, error) {
+	for fs := Synced; fs <= Untracked; fs++ {
+		if strings.EqualFold(abbrev, fs.Abbrev()) {
+			return fs, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown file status '%s'", abbrev)
+}
+
+// defaultRemoteUUID returns the annex UUID of the default remote.
+// It returns an empty string if there is no default remote or its UUID is not known (e.g., the remote has no annex).
+func defaultRemoteUUID() string {
+	remote, err := DefaultRemote()
+	if err != nil {
+		return ""
+	}
+	uuid, err := git.ConfigGet(fmt.Sprintf("remote.%s.annex-uuid", remote))
+	if err != nil {
+		return ""
+	}
+	return uuid
+}
+
+// whereisStatus determines the status of an annexed file from the locations of its content.
+// If the content is not available locally, the status is NoContent.
+// If it is available locally, the status is Synced if the default remote, identified by remoteuuid, also has the content and LocalChanges otherwise.
+// If remoteuuid is empty, the content is considered synced if it is available in any location other than the local repository.
+func whereisStatus(locations []git.AnnexLocation, remoteuuid string) FileStatus {
+	here := false
+	elsewhere := false
+	onremote := false
+	for _, loc := range locations {
+		if loc.Here {
+			here = true
+			continue
+		}
+		elsewhere = true
+		if remoteuuid != "" && loc.UUID == remoteuuid {
+			onremote = true
+		}
+	}
+	switch {
+	case !here:
+		return NoContent
+	case onremote, remoteuuid == "" && elsewhere:
+		return Synced
+	default:
+		// content is here only or not on the default remote: not uploaded
+		return LocalChanges
+	}
+}
+
+func lfDirect(paths ...string) (FileStatusMap, error) {
+	statuses := make(FileStatusMap)
+	remoteuuid := defaultRemoteUUID()
 
 	wichan := make(chan git.AnnexWhereisRes)
 	go git.AnnexWhereis(paths, wichan)
@@ -758,18 +2737,7 @@ func lfDirect(paths ...string) (map[string]FileStatus, error) {
 			continue
 		}
 		fname := filepath.Clean(wiInfo.File)
-		for _, remote := range wiInfo.Whereis {
-			// if no remotes are "here", the file is NoContent
-			statuses[fname] = NoContent
-			if remote.Here {
-				if len(wiInfo.Whereis) > 1 {
-					statuses[fname] = Synced
-				} else {
-					statuses[fname] = LocalChanges
-				}
-				break
-			}
-		}
+		statuses[fname] = whereisStatus(wiInfo.Whereis, remoteuuid)
 	}
 
 	asargs := paths
@@ -778,55 +2746,309 @@ func lfDirect(paths ...string) (map[string]FileStatus, error) {
 		asargs = []string{"."}
 	}
 
-	statuschan := make(chan git.AnnexStatusRes)
-	go git.AnnexStatus(asargs, statuschan)
-	for item := range statuschan {
-		if item.Err != nil {
-			return nil, item.Err
+	statuschan := make(chan git.AnnexStatusRes)
+	go git.AnnexStatus(asargs, statuschan)
+	for item := range statuschan {
+		if item.Err != nil {
+			return nil, item.Err
+		}
+		fname := filepath.Clean(item.File)
+		if item.Status == "?" {
+			statuses[fname] = Untracked
+		} else if item.Status == "M" {
+			statuses[fname] = Modified
+		} else if item.Status == "D" {
+			statuses[fname] = Removed
+		}
+	}
+
+	// Unmodified files that are checked into git (not annex) do not show up
+	// Need to run git ls-files, with bare temporarily disabled, and add only files that haven't been added yet
+	git.SetBare(false)
+	defer git.SetBare(true)
+	lschan := make(chan string)
+	go git.LsFiles(paths, lschan)
+	var gitfiles []string
+	for fname := range lschan {
+		fname = filepath.Clean(fname)
+		if _, ok := statuses[fname]; !ok {
+			statuses[fname] = Synced
+			gitfiles = append(gitfiles, fname)
+		}
+	}
+
+	// git files should be checked against upstream (if it exists) for local commits
+	if len(gitfiles) > 0 {
+		diffchan := make(chan string)
+		remote, err := DefaultRemote()
+		if err == nil {
+			upstream := fmt.Sprintf("%s/master", remote)
+			go git.DiffUpstream(gitfiles, upstream, diffchan)
+			for fname := range diffchan {
+				statuses[filepath.Clean(fname)] = LocalChanges
+			}
+		}
+	}
+	return statuses, nil
+}
+
+// whereisChunkSize is the minimum number of files queried by each git-annex process in annexWhereisParallel.
+// Smaller lists are not split, since starting git-annex takes longer than querying a few files.
+const whereisChunkSize = 1000
+
+// maxWhereisProcs is the maximum number of git-annex processes run concurrently by annexWhereisParallel.
+const maxWhereisProcs = 4
+
+// chunkPaths splits paths into at most n consecutive chunks of at least minsize paths each (except for the last chunk).
+func chunkPaths(paths []string, n, minsize int) [][]string {
+	if n < 1 {
+		n = 1
+	}
+	size := (len(paths) + n - 1) / n
+	if size < minsize {
+		size = minsize
+	}
+	var chunks [][]string
+	for start := 0; start < len(paths); start += size {
+		end := start + size
+		if end > len(paths) {
+			end = len(paths)
+		}
+		chunks = append(chunks, paths[start:end])
+	}
+	return chunks
+}
+
+// annexWhereisParallel is like git.AnnexWhereis, but the paths are split into chunks that are queried by concurrent git-annex processes, which is faster for large numbers of files.
+// The results of all processes are sent to 'wichan' as they arrive, so their order is not preserved.
+// Unlike git.AnnexWhereis, no files are queried if paths is empty.
+// The channel 'wichan' is closed when all processes have finished.
+func annexWhereisParallel(paths []string, wichan chan<- git.AnnexWhereisRes) {
+	if git.UseAnnexBatch && len(paths) > 0 {
+		// a single batch process answers all queries (see git.UseAnnexBatch)
+		git.AnnexWhereis(paths, wichan)
+		return
+	}
+	chunks := chunkPaths(paths, maxWhereisProcs, whereisChunkSize)
+	switch len(chunks) {
+	case 0:
+		close(wichan)
+		return
+	case 1:
+		git.AnnexWhereis(paths, wichan)
+		return
+	}
+	defer close(wichan)
+	// merge once before starting the processes, which would otherwise all try to merge
+	git.AnnexMerge()
+	done := make(chan bool)
+	for _, chunk := range chunks {
+		chunkchan := make(chan git.AnnexWhereisRes)
+		go git.AnnexWhereis(chunk, chunkchan)
+		go func() {
+			for res := range chunkchan {
+				wichan <- res
+			}
+			done <- true
+		}()
+	}
+	for range chunks {
+		<-done
+	}
+}
+
+// UseWhereisCache enables the cache of the content locations of annexed files that is used by ListFiles (see annexWhereisCached).
+// When disabled, the locations of all files are queried from git-annex.
+var UseWhereisCache = true
+
+// whereisCachePath is the path of the whereis cache file, relative to the git directory.
+var whereisCachePath = filepath.Join("gin", "whereis-cache.json")
+
+// whereisCacheEntry holds the last known content locations of a file.
+type whereisCacheEntry struct {
+	// Blob is the ID of the object recorded in the index for the file when the locations were queried.
+	Blob string `json:"blob"`
+	// Key is the annex key of the file; empty for files that are not annexed.
+	Key     string              `json:"key,omitempty"`
+	Whereis []git.AnnexLocation `json:"whereis,omitempty"`
+}
+
+// whereisCache maps files (relative to the root of the repository) to their last known content locations.
+type whereisCache struct {
+	// AnnexRef is the commit of the git-annex branch that the entries are up to date with.
+	AnnexRef string                       `json:"annexref"`
+	Entries  map[string]whereisCacheEntry `json:"entries"`
+}
+
+// readWhereisCache reads the whereis cache from the file at 'cachepath'.
+// An empty cache is returned if the file does not exist or cannot be read.
+func readWhereisCache(cachepath string) *whereisCache {
+	cache := &whereisCache{}
+	if data, err := ioutil.ReadFile(cachepath); err == nil {
+		if err = json.Unmarshal(data, cache); err != nil {
+			log.Write("Discarding invalid whereis cache: %v", err)
+			cache = &whereisCache{}
+		}
+	}
+	if cache.Entries == nil {
+		cache.Entries = make(map[string]whereisCacheEntry)
+	}
+	return cache
+}
+
+// write saves the cache to the file at 'cachepath'.
+// The file is replaced atomically, so that concurrent readers never see a partially written cache.
+func (cache *whereisCache) write(cachepath string) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(cachepath), 0755); err != nil {
+		return err
+	}
+	tmpfile, err := ioutil.TempFile(filepath.Dir(cachepath), "whereis-cache-")
+	if err != nil {
+		return err
+	}
+	_, err = tmpfile.Write(data)
+	if cerr := tmpfile.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmpfile.Name())
+		return err
+	}
+	return os.Rename(tmpfile.Name(), cachepath)
+}
+
+// invalidate removes the entries of the given annex keys from the cache.
+func (cache *whereisCache) invalidate(keys []string) {
+	if len(keys) == 0 {
+		return
+	}
+	keyset := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		keyset[key] = true
+	}
+	for name, entry := range cache.Entries {
+		if keyset[entry.Key] {
+			delete(cache.Entries, name)
+		}
+	}
+}
+
+// update brings the cache up to date with the commit 'annexref' of the git-annex branch by removing the entries of all keys whose logs changed since the cache was last updated.
+// All entries are removed if the changes cannot be determined.
+func (cache *whereisCache) update(annexref string) {
+	switch cache.AnnexRef {
+	case annexref:
+		return
+	case "":
+		cache.Entries = make(map[string]whereisCacheEntry)
+	default:
+		keys, err := git.AnnexLogKeys(cache.AnnexRef, annexref)
+		if err != nil {
+			log.Write("Discarding whereis cache: %v", err)
+			cache.Entries = make(map[string]whereisCacheEntry)
+		} else {
+			cache.invalidate(keys)
+		}
+	}
+	cache.AnnexRef = annexref
+}
+
+// annexWhereisCached is like annexWhereisParallel, but the content locations of files whose annex key and location logs have not changed since they were last queried are read from a cache in the git directory.
+// Only the remaining files are queried from git-annex, and the cache is updated with their results.
+// The cache is not used if it is disabled (see UseWhereisCache), or if git-annex has uncommitted changes to its logs (in the journal).
+// The channel 'wichan' is closed when all results have been sent.
+func annexWhereisCached(paths []string, wichan chan<- git.AnnexWhereisRes) {
+	if !UseWhereisCache || len(paths) == 0 {
+		annexWhereisParallel(paths, wichan)
+		return
+	}
+	gitdir, err := git.GitDir()
+	if err != nil {
+		annexWhereisParallel(paths, wichan)
+		return
+	}
+	if journal, _ := ioutil.ReadDir(filepath.Join(gitdir, "annex", "journal")); len(journal) > 0 {
+		log.Write("Not using whereis cache: git-annex journal has uncommitted changes")
+		annexWhereisParallel(paths, wichan)
+		return
+	}
+	// whereis merges the git-annex branches of the remotes; merge before comparing with the cache
+	git.AnnexMerge()
+	annexref, err := git.RevParse("refs/heads/git-annex")
+	if err != nil {
+		annexWhereisParallel(paths, wichan)
+		return
+	}
+	prefix, _ := git.RevParse("--show-prefix")
+	prefix = strings.TrimSpace(prefix)
+	blobs, err := git.IndexBlobs(paths)
+	if err != nil {
+		annexWhereisParallel(paths, wichan)
+		return
+	}
+	cachekey := func(fname string) string {
+		return path.Join(prefix, filepath.ToSlash(fname))
+	}
+
+	defer close(wichan)
+	cachepath := filepath.Join(gitdir, whereisCachePath)
+	cache := readWhereisCache(cachepath)
+	cache.update(strings.TrimSpace(annexref))
+
+	var query []string
+	for _, fname := range paths {
+		fname = filepath.Clean(fname)
+		entry, ok := cache.Entries[cachekey(fname)]
+		if !ok || blobs[fname] == "" || entry.Blob != blobs[fname] {
+			query = append(query, fname)
+			continue
 		}
-		fname := filepath.Clean(item.File)
-		if item.Status == "?" {
-			statuses[fname] = Untracked
-		} else if item.Status == "M" {
-			statuses[fname] = Modified
-		} else if item.Status == "D" {
-			statuses[fname] = Removed
+		if entry.Key != "" {
+			wichan <- git.AnnexWhereisRes{File: fname, Command: "whereis", Success: true, Key: entry.Key, Whereis: entry.Whereis}
 		}
 	}
+	log.Write("Whereis cache: %d of %d files cached", len(paths)-len(query), len(paths))
+	if len(query) == 0 {
+		return
+	}
 
-	// Unmodified files that are checked into git (not annex) do not show up
-	// Need to run git ls-files, with bare temporarily disabled, and add only files that haven't been added yet
-	git.SetBare(false)
-	defer git.SetBare(true)
-	lschan := make(chan string)
-	go git.LsFiles(paths, lschan)
-	var gitfiles []string
-	for fname := range lschan {
-		fname = filepath.Clean(fname)
-		if _, ok := statuses[fname]; !ok {
-			statuses[fname] = Synced
-			gitfiles = append(gitfiles, fname)
+	querychan := make(chan git.AnnexWhereisRes)
+	go annexWhereisParallel(query, querychan)
+	reported := make(map[string]bool, len(query))
+	failed := false
+	for res := range querychan {
+		wichan <- res
+		if res.Err != nil {
+			failed = true
+			continue
+		}
+		fname := filepath.Clean(res.File)
+		reported[fname] = true
+		if blob := blobs[fname]; blob != "" {
+			cache.Entries[cachekey(fname)] = whereisCacheEntry{Blob: blob, Key: res.Key, Whereis: res.Whereis}
 		}
 	}
-
-	// git files should be checked against upstream (if it exists) for local commits
-	if len(gitfiles) > 0 {
-		diffchan := make(chan string)
-		remote, err := DefaultRemote()
-		if err == nil {
-			upstream := fmt.Sprintf("%s/master", remote)
-			go git.DiffUpstream(gitfiles, upstream, diffchan)
-			for fname := range diffchan {
-				statuses[filepath.Clean(fname)] = LocalChanges
+	if !failed {
+		// files that were not reported are not annexed
+		for _, fname := range query {
+			if blob := blobs[fname]; blob != "" && !reported[fname] {
+				cache.Entries[cachekey(fname)] = whereisCacheEntry{Blob: blob}
 			}
 		}
 	}
-	return statuses, nil
+	if err := cache.write(cachepath); err != nil {
+		log.Write("Failed to write whereis cache: %v", err)
+	}
 }
 
-func lfIndirect(paths ...string) (map[string]FileStatus, error) {
-	// TODO: Determine if added files (LocalChanges) are new or not (new status needed?)
-	statuses := make(map[string]FileStatus)
+func lfIndirect(paths ...string) (FileStatusMap, error) {
+	statuses := make(FileStatusMap)
+	// only git is used for the status of files in repositories without an annex
+	noannex := git.NoAnnex()
 
 	cachedchan := make(chan string)
 	var cachedfiles, modifiedfiles, untrackedfiles, deletedfiles []string
@@ -882,9 +3104,12 @@ func lfIndirect(paths ...string) (map[string]FileStatus, error) {
 		}
 	}
 
+	// files that don't exist in the upstream branch
+	var newfiles []string
+	// files that were changed on the remote but not locally
+	var remotefiles []string
 	if len(cachedfiles) > 0 {
 		// Check for git diffs with upstream
-		diffchan := make(chan string)
 		noremotes := true
 		remote, rerr := DefaultRemote()
 		if rerr == nil {
@@ -899,41 +3124,66 @@ func lfIndirect(paths ...string) (map[string]FileStatus, error) {
 				statuses[fname] = LocalChanges
 			}
 		} else if rerr == nil {
-			upstream := fmt.Sprintf("%s/master", remote) // TODO: Don't assume master; use current branch name
-			go git.DiffUpstream(cachedfiles, upstream, diffchan)
-			for fname := range diffchan {
+			upstream, uerr := EnsureUpstream()
+			if uerr != nil {
+				log.Write("Failed to determine upstream branch: %v", uerr)
+				upstream = fmt.Sprintf("%s/master", remote)
+			}
+			changes, derr := git.DiffNameStatus(upstream, cachedfiles)
+			if derr != nil {
+				log.Write("Failed to compare files with upstream: %v", derr)
+			}
+			// Changes on each side since the common ancestor of HEAD and upstream
+			localchanges, lerr := git.DiffNameStatus(upstream+"...HEAD", cachedfiles)
+			remotechanges, rcerr := git.DiffNameStatus("HEAD..."+upstream, cachedfiles)
+			if lerr != nil || rcerr != nil {
+				// no common history: assume all differences are local
+				log.Write("Failed to determine common history with upstream")
+				localchanges, remotechanges = changes, nil
+			}
+			for fname := range changes {
+				// There will definitely be overlap here with the same status in annex (not a problem)
+				lchange, local := localchanges[fname]
+				_, remote := remotechanges[fname]
 				fname = filepath.Clean(fname)
-				// Two notes:
-				//		1. There will definitely be overlap here with the same status in annex (not a problem)
-				//		2. The diff might be due to remote or local changes, but for now we're going to assume local
+				if remote && !local {
+					statuses[fname] = RemoteChanges
+					remotefiles = append(remotefiles, fname)
+					continue
+				}
+				// Files changed on both sides and uncommitted changes in the index are reported as local
 				statuses[fname] = LocalChanges
+				if lchange == "A" {
+					newfiles = append(newfiles, fname)
+				}
 			}
 		}
 
 		// Run whereis on cached files (if any) to see if content is synced for annexed files
-		wichan := make(chan git.AnnexWhereisRes)
-		go git.AnnexWhereis(cachedfiles, wichan)
-		for wiInfo := range wichan {
-			if wiInfo.Err != nil {
-				continue
-			}
-			fname := filepath.Clean(wiInfo.File)
-			// if no content location for this file is "here", the status is NoContent
-			statuses[fname] = NoContent
-			for _, remote := range wiInfo.Whereis {
-				if remote.Here {
-					if len(wiInfo.Whereis) > 1 {
-						// content is here and in one other location: Synced
-						statuses[fname] = Synced
-					} else {
-						// content is here only: LocalChanges (not uploaded)
-						statuses[fname] = LocalChanges
-					}
-					break
+		if !noannex {
+			remoteuuid := defaultRemoteUUID()
+			wichan := make(chan git.AnnexWhereisRes)
+			go annexWhereisCached(cachedfiles, wichan)
+			for wiInfo := range wichan {
+				if wiInfo.Err != nil {
+					continue
 				}
+				fname := filepath.Clean(wiInfo.File)
+				statuses[fname] = whereisStatus(wiInfo.Whereis, remoteuuid)
 			}
 		}
 
+		// files that are not in the upstream branch are new, unless their
+		// content is not available locally
+		for _, fname := range newfiles {
+			if statuses[fname] == LocalChanges {
+				statuses[fname] = NewFile
+			}
+		}
+		// remote changes take precedence over the location of the local content
+		for _, fname := range remotefiles {
+			statuses[fname] = RemoteChanges
+		}
 	}
 
 	// Add leftover cached files to the map
@@ -949,14 +3199,16 @@ func lfIndirect(paths ...string) (map[string]FileStatus, error) {
 	}
 
 	// Check if there are any TypeChange files (lock state change)
-	statuschan := make(chan git.AnnexStatusRes)
-	go git.AnnexStatus(paths, statuschan)
-	for item := range statuschan {
-		if item.Err != nil {
-			log.Write("Error during annex status while searching for unlocked files")
-		}
-		if item.Status == "T" {
-			statuses[filepath.Clean(item.File)] = TypeChange
+	if !noannex {
+		statuschan := make(chan git.AnnexStatusRes)
+		go git.AnnexStatus(paths, statuschan)
+		for item := range statuschan {
+			if item.Err != nil {
+				log.Write("Error during annex status while searching for unlocked files")
+			}
+			if item.Status == "T" {
+				statuses[filepath.Clean(item.File)] = TypeChange
+			}
 		}
 	}
 
@@ -974,17 +3226,496 @@ func lfIndirect(paths ...string) (map[string]FileStatus, error) {
 }
 
 // ListFiles lists the files and directories specified by paths and their sync status.
-func (gincl *Client) ListFiles(paths ...string) (map[string]FileStatus, error) {
+// Remote changes are determined from the last known state of the default remote's branch; no network access is performed for this.
+// To detect the latest remote changes, the remote should be fetched first (see git.Fetch).
+// Files that were added on the remote and do not exist locally are not listed.
+func (gincl *Client) ListFiles(paths ...string) (FileStatusMap, error) {
 	paths, err := expandglobs(paths, false)
 	if err != nil {
 		return nil, err
 	}
-	if git.IsDirect() {
+	if !git.NoAnnex() && git.IsDirect() {
 		return lfDirect(paths...)
 	}
 	return lfIndirect(paths...)
 }
 
+// AuthorStats holds the number of commits and changed files of one author in a repository.
+type AuthorStats struct {
+	Name         string `json:"name"`
+	Email        string `json:"email"`
+	Commits      int    `json:"commits"`
+	FilesChanged int    `json:"fileschanged"`
+}
+
+// MonthStats holds the number of commits made in one month (formatted as YYYY-MM).
+type MonthStats struct {
+	Month   string `json:"month"`
+	Commits int    `json:"commits"`
+}
+
+// FileTypeStats holds the number of files with a given extension and how many of them are annexed.
+type FileTypeStats struct {
+	Extension  string `json:"extension"`
+	Files      int    `json:"files"`
+	AnnexFiles int    `json:"annexfiles"`
+}
+
+// RepoStats holds a summary of the commit history and the files of a repository.
+type RepoStats struct {
+	Commits     int             `json:"commits"`
+	FirstCommit time.Time       `json:"firstcommit"`
+	LastCommit  time.Time       `json:"lastcommit"`
+	Authors     []AuthorStats   `json:"authors"`
+	Months      []MonthStats    `json:"months"`
+	GitFiles    int             `json:"gitfiles"`
+	AnnexFiles  int             `json:"annexfiles"`
+	FileTypes   []FileTypeStats `json:"filetypes"`
+}
+
+// AnnexedFiles returns the set of files under the given paths that are annexed, whether their content is available locally or not.
+// All other tracked files are stored in git.
+// File names are relative to the working directory.
+func AnnexedFiles(paths ...string) (map[string]bool, error) {
+	annexed := make(map[string]bool)
+	if git.NoAnnex() {
+		return annexed, nil
+	}
+	annexfiles, err := git.AnnexFindMatching([]string{"--include=*"}, paths)
+	for _, afr := range annexfiles {
+		annexed[filepath.Clean(afr.File)] = true
+	}
+	return annexed, err
+}
+
+// RepositoryStats collects statistics on the commit history of the current branch and the files in the repository.
+// Authors are sorted by number of commits (descending), months chronologically, and file types by number of files (descending).
+func RepositoryStats() (RepoStats, error) {
+	var stats RepoStats
+	commits, err := git.Log(0, "", nil, true)
+	if err != nil {
+		return stats, err
+	}
+	commitStats(commits, &stats)
+
+	annexed, err := AnnexedFiles()
+	if err != nil {
+		log.Write("Failed to list annexed files: %v", err)
+	}
+
+	lschan := make(chan string)
+	go git.LsFiles([]string{"--cached"}, lschan)
+	var files []string
+	for fname := range lschan {
+		files = append(files, filepath.Clean(fname))
+	}
+	fileStats(files, annexed, &stats)
+	return stats, nil
+}
+
+// commitStats adds the number of commits per author and per month for the given commits to stats.
+func commitStats(commits []git.GinCommit, stats *RepoStats) {
+	authoridx := make(map[string]int)
+	monthidx := make(map[string]int)
+	for _, commit := range commits {
+		stats.Commits++
+		if stats.FirstCommit.IsZero() || commit.Date.Before(stats.FirstCommit) {
+			stats.FirstCommit = commit.Date
+		}
+		if commit.Date.After(stats.LastCommit) {
+			stats.LastCommit = commit.Date
+		}
+
+		key := strings.ToLower(commit.AuthorEmail)
+		idx, ok := authoridx[key]
+		if !ok {
+			// commits are listed newest first, so the most recent name is kept
+			idx = len(stats.Authors)
+			authoridx[key] = idx
+			stats.Authors = append(stats.Authors, AuthorStats{Name: commit.AuthorName, Email: commit.AuthorEmail})
+		}
+		fs := commit.FileStats
+		stats.Authors[idx].Commits++
+		stats.Authors[idx].FilesChanged += len(fs.NewFiles) + len(fs.ModifiedFiles) + len(fs.DeletedFiles)
+
+		month := commit.Date.Format("2006-01")
+		idx, ok = monthidx[month]
+		if !ok {
+			idx = len(stats.Months)
+			monthidx[month] = idx
+			stats.Months = append(stats.Months, MonthStats{Month: month})
+		}
+		stats.Months[idx].Commits++
+	}
+	sort.SliceStable(stats.Authors, func(i, j int) bool { return stats.Authors[i].Commits > stats.Authors[j].Commits })
+	sort.Slice(stats.Months, func(i, j int) bool { return stats.Months[i].Month < stats.Months[j].Month })
+}
+
+// fileStats adds the number of git and annexed files and the breakdown by file extension for the given files to stats.
+func fileStats(files []string, annexed map[string]bool, stats *RepoStats) {
+	typeidx := make(map[string]int)
+	for _, fname := range files {
+		ext := strings.ToLower(filepath.Ext(fname))
+		idx, ok := typeidx[ext]
+		if !ok {
+			idx = len(stats.FileTypes)
+			typeidx[ext] = idx
+			stats.FileTypes = append(stats.FileTypes, FileTypeStats{Extension: ext})
+		}
+		stats.FileTypes[idx].Files++
+		if annexed[fname] {
+			stats.AnnexFiles++
+			stats.FileTypes[idx].AnnexFiles++
+		} else {
+			stats.GitFiles++
+		}
+	}
+	sort.Slice(stats.FileTypes, func(i, j int) bool {
+		a, b := stats.FileTypes[i], stats.FileTypes[j]
+		if a.Files != b.Files {
+			return a.Files > b.Files
+		}
+		return a.Extension < b.Extension
+	})
+}
+
+// UnusedObject describes an annexed object that is no longer used by any file in the repository.
+type UnusedObject struct {
+	// The number git-annex uses to refer to the object in 'dropunused'
+	Number string `json:"number"`
+	Key    string `json:"key"`
+	Size   int64  `json:"size"`
+	// RemoteCopy is true if the content is known to be available from another repository
+	RemoteCopy bool `json:"remotecopy"`
+}
+
+// UnusedGroup holds the number and total size of a group of unused objects.
+type UnusedGroup struct {
+	Count int   `json:"count"`
+	Size  int64 `json:"size"`
+}
+
+// UnusedReport summarises the unused annexed content in the local repository, grouped by whether a copy of the content exists in another repository.
+// Only objects with a remote copy can be removed safely.
+type UnusedReport struct {
+	Objects    []UnusedObject `json:"objects"`
+	Total      UnusedGroup    `json:"total"`
+	RemoteCopy UnusedGroup    `json:"remotecopy"`
+	LocalOnly  UnusedGroup    `json:"localonly"`
+}
+
+// UnusedContentReport lists the annexed objects in the local repository that are no longer used by any file and reports how much space can be reclaimed by removing them.
+// The repository is not modified.
+func UnusedContentReport() (UnusedReport, error) {
+	unused, err := git.AnnexUnused()
+	if err != nil {
+		return UnusedReport{}, err
+	}
+	if len(unused) == 0 {
+		return UnusedReport{}, nil
+	}
+	locations, err := git.AnnexWhereisUnused()
+	if err != nil {
+		// Report everything as local only; nothing is deleted here and
+		// dropping checks for remote copies again
+		log.Write("Failed to determine locations of unused content: %v", err)
+	}
+	return unusedReport(unused, locations), nil
+}
+
+// unusedReport builds the report for the given unused objects.
+// An object has a remote copy if any of its locations is not the local repository.
+func unusedReport(unused []git.AnnexUnusedRes, locations map[string][]git.AnnexLocation) UnusedReport {
+	var report UnusedReport
+	for _, obj := range unused {
+		uobj := UnusedObject{Number: obj.Number, Key: obj.Key, Size: git.KeySize(obj.Key)}
+		for _, loc := range locations[obj.Key] {
+			if !loc.Here {
+				uobj.RemoteCopy = true
+				break
+			}
+		}
+		group := &report.LocalOnly
+		if uobj.RemoteCopy {
+			group = &report.RemoteCopy
+		}
+		group.Count++
+		group.Size += uobj.Size
+		report.Total.Count++
+		report.Total.Size += uobj.Size
+		report.Objects = append(report.Objects, uobj)
+	}
+	return report
+}
+
+// NumCopies returns the minimum number of copies of the content of each file that git-annex maintains in the repository (annex.numcopies).
+// If the option is not set, the git-annex default of 1 is returned.
+func NumCopies() int {
+	value, err := git.GetAnnexConfig("numcopies")
+	if err != nil {
+		return 1
+	}
+	numcopies, err := strconv.Atoi(value)
+	if err != nil {
+		log.Write("Invalid annex.numcopies value %q: %v", value, err)
+		return 1
+	}
+	return numcopies
+}
+
+// SetNumCopies sets the minimum number of copies of the content of each file that git-annex maintains in the repository (annex.numcopies).
+// git-annex refuses to remove content from a repository if fewer copies would remain.
+// The setting applies to the local repository only.
+func SetNumCopies(numcopies int) error {
+	if numcopies < 1 {
+		return fmt.Errorf("invalid number of copies %d: must be at least 1", numcopies)
+	}
+	return git.SetAnnexConfig("numcopies", strconv.Itoa(numcopies))
+}
+
+// checkAnnexRepository returns an error if 'repository' is neither "here" (the local repository) nor the name of a configured remote.
+func checkAnnexRepository(repository string) error {
+	if repository == "here" {
+		return nil
+	}
+	remotes, err := git.RemoteShow()
+	if err != nil {
+		return err
+	}
+	if _, ok := remotes[repository]; !ok {
+		return fmt.Errorf("unknown remote '%s': use a configured remote or 'here' for the local repository", repository)
+	}
+	return nil
+}
+
+// Wanted returns the preferred content expression of a remote (or "here" for the local repository), which determines the file content that git-annex stores in it (e.g., when copying content to all remotes).
+// An empty string is returned if no expression is set, in which case all content is wanted.
+func Wanted(repository string) (string, error) {
+	if err := checkAnnexRepository(repository); err != nil {
+		return "", err
+	}
+	return git.AnnexWanted(repository)
+}
+
+// SetWanted sets the preferred content expression of a remote (or "here" for the local repository).
+// The expression is validated by git-annex; e.g., "include=raw/*" makes a remote want only the content of files in the 'raw' directory.
+// The setting is recorded in the git-annex branch and is shared with other clones when it is uploaded.
+func SetWanted(repository, expression string) error {
+	if err := checkAnnexRepository(repository); err != nil {
+		return err
+	}
+	if strings.TrimSpace(expression) == "" {
+		return fmt.Errorf("preferred content expression must not be empty")
+	}
+	return git.AnnexSetWanted(repository, expression)
+}
+
+// Groups returns the groups that a remote (or "here" for the local repository) belongs to.
+func Groups(repository string) ([]string, error) {
+	if err := checkAnnexRepository(repository); err != nil {
+		return nil, err
+	}
+	return git.AnnexGroups(repository)
+}
+
+// checkGroupName returns an error if 'group' cannot be used as a group name in preferred content expressions.
+func checkGroupName(group string) error {
+	if group == "" || strings.ContainsAny(group, " \t\n()=") {
+		return fmt.Errorf("invalid group name '%s': group names must not be empty or contain whitespace, parentheses, or '='", group)
+	}
+	return nil
+}
+
+// SetGroups adds a remote (or "here" for the local repository) to the groups in 'add' and removes it from the groups in 'remove'.
+func SetGroups(repository string, add, remove []string) error {
+	if err := checkAnnexRepository(repository); err != nil {
+		return err
+	}
+	for _, group := range append(append([]string{}, add...), remove...) {
+		if err := checkGroupName(group); err != nil {
+			return err
+		}
+	}
+	for _, group := range add {
+		if err := git.AnnexGroup(repository, group); err != nil {
+			return err
+		}
+	}
+	for _, group := range remove {
+		if err := git.AnnexUngroup(repository, group); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckCopies reports, for each annexed file under the given paths, whether at least numcopies copies of its content exist.
+// If numcopies is 0, the configured number of copies is used (see NumCopies).
+// The check uses the location information known to the local repository; the copies themselves are not verified.
+// The status channel 'checkchan' is closed when this function returns.
+func CheckCopies(paths []string, numcopies int, checkchan chan<- git.RepoFileStatus) {
+	paths, err := expandglobs(paths, true)
+	if err != nil {
+		checkchan <- git.RepoFileStatus{Err: err}
+		close(checkchan)
+		return
+	}
+	git.AnnexCheckCopies(paths, numcopies, checkchan)
+}
+
+// FilterMaxDepth removes the files from a listing that are more than maxdepth directory levels below the listed paths (or below the working directory if no paths are given).
+// Files directly in a listed directory are at depth 1; files that are listed explicitly are always kept.
+func FilterMaxDepth(statuses FileStatusMap, paths []string, maxdepth int) error {
+	paths, err := expandglobs(paths, false)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	bases := make([]string, len(paths))
+	for idx, p := range paths {
+		if bases[idx], err = filepath.Abs(p); err != nil {
+			return err
+		}
+	}
+	for fname := range statuses {
+		absfname, err := filepath.Abs(fname)
+		if err != nil {
+			return err
+		}
+		keep := false
+		for _, base := range bases {
+			rel, err := filepath.Rel(base, absfname)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				// not under this path
+				continue
+			}
+			depth := 0
+			if rel != "." {
+				depth = len(strings.Split(rel, string(filepath.Separator)))
+			}
+			if depth <= maxdepth {
+				keep = true
+				break
+			}
+		}
+		if !keep {
+			delete(statuses, fname)
+		}
+	}
+	return nil
+}
+
+// VerifyTree compares the working tree with the given commit and returns the files that differ:
+// files whose content differs from the commit (Modified), files that do not exist in the commit (Untracked), files of the commit that are missing (Removed), and annexed files whose content is not available locally (NoContent).
+// Files that are ignored by git and changes in the lock state of annexed files are not reported.
+// An empty result means that the working tree matches the commit.
+// If paths are specified, the comparison is limited to files under those paths.
+func VerifyTree(commit string, paths ...string) (FileStatusMap, error) {
+	hash, err := git.ResolveCommit(commit)
+	if err != nil {
+		return nil, err
+	}
+	paths, err = expandglobs(paths, false)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := git.DiffNameStatus(hash, paths)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(FileStatusMap)
+	for fname, change := range changes {
+		fname = filepath.Clean(fname)
+		switch change {
+		case "D":
+			statuses[fname] = Removed
+		case "A":
+			// added to the index but not in the commit
+			statuses[fname] = Untracked
+		case "T":
+			// locked and unlocked annexed files have the same content
+			continue
+		default:
+			statuses[fname] = Modified
+		}
+	}
+
+	lschan := make(chan string)
+	go git.LsFiles(append([]string{"--others", "--exclude-standard"}, paths...), lschan)
+	for fname := range lschan {
+		statuses[filepath.Clean(fname)] = Untracked
+	}
+
+	missing, err := git.AnnexFindMatching([]string{"--not", "--in=here"}, paths)
+	if err != nil {
+		// not an annex repository or no annexed files
+		log.Write("Failed to list files without local content: %v", err)
+	}
+	for _, afr := range missing {
+		fname := filepath.Clean(afr.File)
+		if _, ok := statuses[fname]; !ok {
+			statuses[fname] = NoContent
+		}
+	}
+	return statuses, nil
+}
+
+// ListFilesModifiedSince lists the files that have changed since the given commit along with their current status.
+// Files that were deleted since the commit are listed with the Removed status.
+// Untracked files are not listed since they are not part of the history.
+// If paths are specified, the listing is limited to files under those paths.
+func (gincl *Client) ListFilesModifiedSince(commit string, paths ...string) (FileStatusMap, error) {
+	paths, err := expandglobs(paths, false)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := git.DiffNameStatus(commit, paths)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make(FileStatusMap)
+	existing := make([]string, 0, len(changes))
+	for fname, change := range changes {
+		if change == "D" {
+			statuses[filepath.Clean(fname)] = Removed
+			continue
+		}
+		existing = append(existing, fname)
+	}
+	if len(existing) == 0 {
+		return statuses, nil
+	}
+	current, err := gincl.ListFiles(existing...)
+	if err != nil {
+		return nil, err
+	}
+	for fname, status := range current {
+		statuses[fname] = status
+	}
+	return statuses, nil
+}
+
+// UnlockedFiles returns the annexed files that are currently unlocked for editing.
+// Unlocked files are locked again when their changes are committed or uploaded.
+// If paths are specified, only files under those paths are returned.
+func UnlockedFiles(paths ...string) ([]string, error) {
+	paths, err := expandglobs(paths, false)
+	if err != nil {
+		return nil, err
+	}
+	results, err := git.AnnexFindMatching([]string{"--unlocked"}, paths)
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, len(results))
+	for idx, res := range results {
+		files[idx] = filepath.Clean(res.File)
+	}
+	return files, nil
+}
+
 // expandglobs expands a list of globs into paths (files and directories).
 // If strictmatch is true, an error is returned if at least one element of the input slice does not match a real path,
 // otherwise the pattern itself is returned when it matches no existing path.
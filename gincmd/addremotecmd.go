@@ -64,8 +64,12 @@ func parseRemote(remotestr string) remote {
 	}
 
 	conf := config.Read()
-	if srvcfg, ok := conf.Servers[rmt.server]; ok {
-		rmt.url = fmt.Sprintf("%s/%s", srvcfg.Git.AddressStr(), rmt.path)
+	if _, ok := conf.Servers[rmt.server]; ok {
+		gincl := ginclient.New(rmt.server)
+		gincl.LoadToken()
+		url, err := gincl.RemoteAddress(rmt.path)
+		CheckError(err)
+		rmt.url = url
 		rmt.rt = ginrt
 		return rmt
 	}
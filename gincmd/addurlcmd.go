@@ -0,0 +1,75 @@
+package gincmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func addURL(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	flags := cmd.Flags()
+	fast, _ := flags.GetBool("fast")
+	relaxed, _ := flags.GetBool("relaxed")
+	if fast && relaxed {
+		usageDie(cmd)
+	}
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	url := args[0]
+	var fname string
+	if len(args) > 1 {
+		fname = args[1]
+	}
+
+	if prStyle.showHeaders() {
+		fmt.Println(":: Adding file from URL")
+	}
+	addchan := make(chan git.RepoFileStatus)
+	go git.AnnexAddURL(context.Background(), url, fname, fast, relaxed, addchan)
+	formatOutput(addchan, prStyle, 1)
+	if prStyle.showHeaders() {
+		fmt.Println("   (use \"gin commit\" or \"gin upload\" to record the new file)")
+	}
+}
+
+// AddURLCmd sets up the 'annex-addurl' subcommand
+func AddURLCmd() *cobra.Command {
+	description := `Add a file to the repository whose content is retrieved from a URL. The location of the content is recorded in the repository, so the content can be downloaded again from the URL (e.g., with 'get-content') in other clones of the repository. This makes it possible to reference large datasets that are hosted elsewhere alongside data stored on the GIN server.
+
+By default, the content is downloaded and added to the repository like any other file. With --fast, the content is not downloaded; only the URL and the size of the file are recorded. With --relaxed, the URL is not contacted at all; this is useful for URLs whose content may change or that are not accessible at the moment.
+
+The new file is not recorded until it is committed or uploaded.`
+	args := map[string]string{
+		"<url>":      "The URL of the file content.",
+		"<filename>": "The name of the file in the repository. If not specified, the name is derived from the URL.",
+	}
+	examples := map[string]string{
+		"Add the file at a URL as 'data/recording.nix'": "$ gin annex-addurl https://example.com/files/rec01.nix data/recording.nix",
+		"Reference a large file without downloading it": "$ gin annex-addurl --fast https://example.com/files/dataset.zip",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "annex-addurl [--json] [--fast | --relaxed] <url> [<filename>]",
+		Short:                 "Add a file whose content is retrieved from a URL",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.RangeArgs(1, 2),
+		Run:                   addURL,
+		Aliases:               []string{"addurl"},
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().Bool("fast", false, "Do not download the content; only record the URL and the size of the file.")
+	cmd.Flags().Bool("relaxed", false, "Do not contact the URL; only record it.")
+	return cmd
+}
@@ -0,0 +1,68 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+func annexInfo(cmd *cobra.Command, args []string) {
+	jsonout, _ := cmd.Flags().GetBool("json")
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	info, err := git.AnnexInfo()
+	CheckError(err)
+	if jsonout {
+		jsonbytes, err := json.Marshal(info)
+		CheckError(err)
+		fmt.Println(string(jsonbytes))
+		return
+	}
+
+	fmt.Printf("Repository mode: %s\n", info.RepositoryMode)
+	fmt.Printf("Annexed files in working tree: %d (%s)\n", info.AnnexedFiles, humanize.IBytes(uint64(info.AnnexedSize)))
+	fmt.Printf("Content in local annex: %d file(s) (%s)\n", info.LocalAnnexKeys, humanize.IBytes(uint64(info.LocalAnnexSize)))
+	if info.AvailableLocalDiskSpace > 0 {
+		fmt.Printf("Available local disk space: %s\n", humanize.IBytes(uint64(info.AvailableLocalDiskSpace)))
+	}
+	fmt.Println("Repositories:")
+	for _, repo := range info.Repositories {
+		here := ""
+		if repo.Here {
+			here = " [here]"
+		}
+		fmt.Printf("  %s %s%s: %s\n", repo.UUID, repo.Description, here, repo.Trust)
+	}
+}
+
+// AnnexInfoCmd sets up the 'annex-info' subcommand
+func AnnexInfoCmd() *cobra.Command {
+	description := `Show information about the annex of the local repository: the repository mode, the number and total size of the annexed files in the working tree, the number and size of the files whose content is stored locally, the available disk space, and the repositories (clones and remotes) that are known to the annex along with their trust levels.
+
+The information is collected by git-annex. Values that are not reported by the installed version of git-annex are shown as zero. Use --json to get the information in a form that can be processed by other programs.`
+	examples := map[string]string{
+		"Show how much file content is stored locally": "$ gin annex-info",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "annex-info [--json]",
+		Short:                 "Show information about the annex of the local repository",
+		Long:                  formatdesc(description, nil),
+		Example:               formatexamples(examples),
+		Args:                  cobra.NoArgs,
+		Run:                   annexInfo,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, "Print information in JSON format.")
+	return cmd
+}
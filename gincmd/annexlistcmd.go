@@ -0,0 +1,94 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+func annexList(cmd *cobra.Command, args []string) {
+	jsonout, _ := cmd.Flags().GetBool("json")
+	allrepos, _ := cmd.Flags().GetBool("all-repos")
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	list, err := git.AnnexList(args, allrepos)
+	CheckError(err)
+	if jsonout {
+		type fpresence struct {
+			File    string          `json:"file"`
+			Present map[string]bool `json:"present"`
+		}
+		presence := make([]fpresence, len(list.Files))
+		for idx, file := range list.Files {
+			presence[idx] = fpresence{File: file.File, Present: make(map[string]bool, len(list.Repositories))}
+			for ridx, repo := range list.Repositories {
+				presence[idx].Present[repo] = file.Present[ridx]
+			}
+		}
+		jsonbytes, err := json.Marshal(presence)
+		CheckError(err)
+		fmt.Println(string(jsonbytes))
+		return
+	}
+	printPresenceTable(list)
+}
+
+// printPresenceTable prints a table with one row per file and one column per repository, with a mark where the repository has the content of the file.
+func printPresenceTable(list git.AnnexListRes) {
+	widths := make([]int, len(list.Repositories))
+	for idx, repo := range list.Repositories {
+		widths[idx] = len(repo)
+		fmt.Printf("%s ", repo)
+	}
+	fmt.Println()
+	for _, file := range list.Files {
+		marks := make([]string, len(file.Present))
+		for idx, present := range file.Present {
+			// center the mark under the repository name
+			pad := (widths[idx] - 1) / 2
+			mark := "."
+			if present {
+				mark = green("*")
+			}
+			marks[idx] = fmt.Sprintf("%s%s%s", strings.Repeat(" ", pad), mark, strings.Repeat(" ", widths[idx]-1-pad))
+		}
+		fmt.Fprintf(color.Output, "%s %s\n", strings.Join(marks, " "), file.File)
+	}
+}
+
+// AnnexListCmd sets up the 'annex-list' subcommand
+func AnnexListCmd() *cobra.Command {
+	description := `List the annexed files and show which repositories have their content, as a table with one column for the local repository ('here') and one for each remote. A '*' marks the repositories that have the content of a file; a '.' marks those that don't.
+
+The table is created from the location information that is stored in the repository and updated on every upload and download, so no remote is contacted. This is much faster than querying the location of each file when a repository has many files, but the information may be out of date if the content of a remote was changed from another clone since the last download.
+
+By default, only the local repository and its remotes are shown. Use --all-repos to show all the repositories that are known to have had a copy of any file (e.g., other clones).`
+	args := map[string]string{"<filenames>": "One or more directories or files to list."}
+	examples := map[string]string{
+		"Show which files in 'data' are available locally and on the remotes": "$ gin annex-list data",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "annex-list [--json] [--all-repos] [<filenames>]...",
+		Short:                 "List which repositories have the content of each file",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   annexList,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, "Print listing in JSON format.")
+	cmd.Flags().Bool("all-repos", false, "Show all repositories that are known to the annex, not only the local repository and its remotes.")
+	return cmd
+}
@@ -0,0 +1,53 @@
+package gincmd
+
+import (
+	"bufio"
+	"os"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func catVersion(cmd *cobra.Command, args []string) {
+	// No notice for old repository layouts: anything printed to stdout would
+	// be mixed with the file contents
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Warn(ginerrors.MissingAnnex)
+	}
+
+	commithash, _ := cmd.Flags().GetString("id")
+	paths := changeToRepoRoot(args, false)
+	fullhash, err := git.ResolveCommit(commithash)
+	CheckError(err)
+
+	stdout := bufio.NewWriter(os.Stdout)
+	err = ginclient.CatFileVersion(fullhash, paths[0], stdout)
+	CheckError(err)
+	CheckError(stdout.Flush())
+}
+
+// CatVersionCmd sets up the 'cat-version' subcommand
+func CatVersionCmd() *cobra.Command {
+	description := "Write the contents of a file, as it was in an older version, to the standard output. Annexed files are resolved to their content, which is downloaded from the server if it is not available locally. The file in the working directory is not changed.\n\nThis can be used to pass an older version of a file to another program, such as a diff tool, without creating a copy."
+	args := map[string]string{"<filename>": "The file to print."}
+	examples := map[string]string{
+		"Compare the current analysis.py with the version with ID 429d51e":    "$ gin cat-version --id 429d51e analysis.py | diff - analysis.py",
+		"Save the version of data.zip from two versions ago under a new name": "$ gin cat-version --id HEAD~2 data.zip > data-old.zip",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "cat-version --id hash <filename>",
+		Short:                 "Print the contents of a file from an older version",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ExactArgs(1),
+		Run:                   catVersion,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().String("id", "HEAD", "Commit `ID` (hash) of the version to print.")
+	return cmd
+}
@@ -24,9 +24,10 @@ func commit(cmd *cobra.Command, args []string) {
 	}
 
 	commitmsg, _ := cmd.Flags().GetString("message")
+	setSignFlag(cmd)
 
 	// TODO: Exit with error if a path argument is neither a file known to git nor a file in the working tree
-	paths := args
+	paths := changeToRepoRoot(args, false)
 	if len(paths) > 0 {
 		if prStyle == psDefault {
 			fmt.Println(":: Adding file changes")
@@ -41,6 +42,9 @@ func commit(cmd *cobra.Command, args []string) {
 	}
 	if commitmsg == "" {
 		commitmsg = makeCommitMessage("commit", paths)
+		if sinceupload, _ := cmd.Flags().GetBool("since-last-upload"); sinceupload {
+			commitmsg += describeSinceUpload()
+		}
 	}
 	err := git.Commit(commitmsg)
 	var stat string
@@ -58,6 +62,18 @@ func commit(cmd *cobra.Command, args []string) {
 	}
 }
 
+// setSignFlag enables commit signing in the git package if the --sign flag is set, using the key given as the flag value, if any.
+func setSignFlag(cmd *cobra.Command) {
+	if !cmd.Flags().Changed("sign") {
+		return
+	}
+	key, _ := cmd.Flags().GetString("sign")
+	git.SignCommits = true
+	if key != signDefaultKey {
+		git.SignKey = key
+	}
+}
+
 func makeCommitMessage(action string, paths []string) (commitmsg string) {
 	// add header commit line
 	hostname, err := os.Hostname()
@@ -74,13 +90,33 @@ func makeCommitMessage(action string, paths []string) (commitmsg string) {
 	return
 }
 
+// describeSinceUpload returns a short summary of the changes in the index compared to the last uploaded state of the current branch (its upstream branch).
+// An empty string is returned if the branch has not been uploaded yet or the changes cannot be determined.
+func describeSinceUpload() string {
+	upstream, err := git.Upstream()
+	if err != nil {
+		log.Write("No upstream branch: omitting changes since last upload from commit message")
+		return ""
+	}
+	changes, err := git.DiffCachedNameStatus(upstream, nil)
+	if err != nil {
+		log.Write("Failed to determine changes since last upload for commit message")
+		return ""
+	}
+	counts := make(map[string]int)
+	for _, status := range changes {
+		counts[status]++
+	}
+	return fmt.Sprintf("\nSince last upload (%s): %d new, %d modified, %d deleted\n", upstream, counts["A"], counts["M"]+counts["T"], counts["D"])
+}
+
 // CommitCmd sets up the 'commit' subcommand
 func CommitCmd() *cobra.Command {
 	description := "Record changes made in a local repository. This command must be called from within the local repository clone. Specific files or directories may be specified. All changes made to the files and directories that are specified will be recorded, including addition of new files, modifications and renaming of existing files, and file deletions.\n\nIf no arguments are specified, no changes are recorded."
 	args := map[string]string{"<filenames>": "One or more directories or files to commit."}
 	var cmd = &cobra.Command{
 		// Use:                   "commit [--json | --verbose] [--message message] [<filenames>]...",
-		Use:                   "commit [--json] [--message message] [<filenames>]...",
+		Use:                   "commit [--json] [--message message] [--since-last-upload] [--sign[=<keyid>]] [<filenames>]...",
 		Short:                 "Record changes in local repository",
 		Long:                  formatdesc(description, args),
 		Args:                  cobra.ArbitraryArgs,
@@ -89,6 +125,9 @@ func CommitCmd() *cobra.Command {
 	}
 	cmd.Flags().Bool("json", false, jsonHelpMsg)
 	cmd.Flags().StringP("message", "m", "", "Commit message")
+	cmd.Flags().Bool("since-last-upload", false, sinceUploadHelpMsg)
+	cmd.Flags().String("sign", "", signHelpMsg)
+	cmd.Flags().Lookup("sign").NoOptDefVal = signDefaultKey
 	// cmd.Flags().Bool("verbose", false, verboseHelpMsg)
 	return cmd
 }
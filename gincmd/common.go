@@ -1,17 +1,26 @@
 package gincmd
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"os"
+	"os/signal"
 	"runtime"
 	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
 	"github.com/G-Node/gin-cli/ginclient/log"
 	"github.com/G-Node/gin-cli/git"
+	"github.com/G-Node/gin-cli/git/shell"
 	"github.com/bbrks/wrap"
 	"github.com/docker/docker/pkg/term"
 	"github.com/fatih/color"
@@ -19,9 +28,20 @@ import (
 )
 
 const (
-	unknownhostname = "(unknown)"
-	jsonHelpMsg     = "Print output in JSON format."
-	verboseHelpMsg  = "Print underlying git and git-annex calls and their unmodified output."
+	unknownhostname   = "(unknown)"
+	jsonHelpMsg       = "Print output in JSON format."
+	verboseHelpMsg    = "Print underlying git and git-annex calls and their unmodified output."
+	checkLoginHelpMsg = "For commands that use the server, check that your login is still valid before running the command."
+	pathsFromHelpMsg  = "Read additional paths from `file`, one per line. Use '-' to read from standard input."
+	nullHelpMsg       = "Paths read with --paths-from are separated by NUL characters instead of newlines."
+
+	signHelpMsg = "Sign the commit with GPG. A key `keyid` may be given as --sign=<keyid>; otherwise the key from the commit.signkey configuration option or the default key is used. Signing can be enabled for all commits with the commit.sign configuration option."
+
+	keepGoingHelpMsg   = "Continue with the remaining files when the operation fails for a file (default). The failures are reported when the command finishes."
+	failFastHelpMsg    = "Stop the whole operation as soon as it fails for a file."
+	sinceUploadHelpMsg = "Add a summary of the files added, modified, and deleted since the last upload to the automatically generated commit message. The last upload is the state of the upstream branch (e.g., origin/master) of the current branch."
+	// signDefaultKey is the value of the --sign flag when no key is specified
+	signDefaultKey = "default"
 )
 
 var (
@@ -32,21 +52,44 @@ var (
 
 	reqgitannex = []string{
 		"add-remote",
+		"annex-addurl",
+		"annex-copy",
+		"annex-enableremote",
+		"annex-group",
+		"annex-info",
+		"annex-list",
+		"annex-transfers",
+		"annex-numcopies",
+		"annex-unused-report",
+		"annex-wanted",
+		"cat-version",
 		"commit",
 		"create",
 		"download",
+		"export",
+		"find",
 		"get",
 		"get-content",
+		"import",
 		"init",
+		"largefiles",
 		"lock",
 		"ls",
+		"metadata",
+		"migrate-direct",
+		"move-content",
+		"refresh",
 		"remotes",
 		"remove-content",
 		"remove-remote",
+		"set-remote-url",
+		"stats",
 		"unlock",
 		"upload",
 		"use-remote",
+		"verify",
 		"version",
+		"watch",
 	}
 )
 
@@ -57,10 +100,29 @@ const (
 	psProgress
 	psJSON
 	psVerbose
+	psQuiet
 )
 
-// Die prints an error message to stderr and exits the program with status 1.
+// showHeaders returns true if the print style includes the section headers (e.g., ":: Uploading") that precede the status lines.
+func (ps printstyle) showHeaders() bool {
+	return ps != psJSON && ps != psQuiet
+}
+
+// Die prints an error message to stderr and exits the program with a non-zero status.
+// If msg is an error, the exit status is determined by the class of the error (see exitCode()); otherwise the status is ExitError.
 func Die(msg interface{}) {
+	code := ExitError
+	if err, ok := msg.(error); ok {
+		code = exitCode(err)
+	}
+	dieWithCode(code, msg)
+}
+
+// dieWithCode prints an error message to stderr and exits the program with the given status.
+func dieWithCode(code int, msg interface{}) {
+	flushJSON()
+	git.StopAnnexBatch()
+	printProfile()
 	msgstring := fmt.Sprintf("%s", msg)
 	if len(msgstring) > 0 {
 		log.Write("Exiting with ERROR message: %s", msgstring)
@@ -69,7 +131,76 @@ func Die(msg interface{}) {
 		log.Write("Exiting with ERROR (no message)")
 	}
 	log.Close()
-	os.Exit(1)
+	os.Exit(code)
+}
+
+// staleLockHandler returns the function that decides whether a stale index lock file is removed (see git.RemoveStaleLock).
+// With --remove-stale-lock, lock files are removed without asking; otherwise the user is asked on the terminal.
+// When no terminal is available, with JSON output ('prompt' is false), or while the status of an operation is being printed, the lock file is kept and the user is told how to remove it.
+func staleLockHandler(force, prompt bool) func(string, time.Duration) bool {
+	return func(lockpath string, age time.Duration) bool {
+		agestr := age.Round(time.Second).String()
+		if force {
+			Warn(fmt.Sprintf("removing stale lock file %s (last modified %s ago)", lockpath, agestr))
+			return true
+		}
+		if !prompt || atomic.LoadInt32(&printingStatus) != 0 || !term.IsTerminal(os.Stdin.Fd()) {
+			Warn(fmt.Sprintf("the repository is locked by the file %s, which was last modified %s ago and appears to be left over from an interrupted operation; use --remove-stale-lock to remove it", lockpath, agestr))
+			return false
+		}
+		fmt.Fprintf(os.Stderr, "The repository is locked by the file %s, which was last modified %s ago.\n", lockpath, agestr)
+		fmt.Fprintln(os.Stderr, "No git process is running, so the lock appears to be left over from an interrupted operation.")
+		fmt.Fprint(os.Stderr, "Remove the lock file and try again? [yes/no]: ")
+		var response string
+		fmt.Scanln(&response)
+		return strings.ToLower(response) == "yes"
+	}
+}
+
+// handleInterrupt sets up a handler for interrupt and termination signals.
+// When a signal is received, any running git and git-annex commands are stopped, the log is closed, and the program exits with a non-zero status.
+// Git removes its own lock files when interrupted, so the repository is left in a consistent state.
+func handleInterrupt() {
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		sig := <-sigchan
+		log.Write("Received signal: %s", sig)
+		restoreTerminal()
+		shell.Interrupt()
+		fmt.Fprintf(color.Error, "\n%s\n", red("Interrupted"))
+		log.Write("Exiting after interrupt")
+		log.Close()
+		// 128 + SIGINT, as is the convention for shells
+		os.Exit(130)
+	}()
+}
+
+// savedTermState holds the state (*term.State) of the terminal before it was modified (e.g., for a password prompt).
+// It is restored if the program is interrupted while the terminal is modified.
+var savedTermState atomic.Value
+
+// saveTerminal saves the state of the terminal on stdin so that it is restored if the program is interrupted.
+// restoreTerminal should be called when the terminal is no longer modified.
+func saveTerminal() {
+	state, err := term.SaveState(os.Stdin.Fd())
+	if err != nil {
+		log.Write("Failed to save terminal state: %v", err)
+		return
+	}
+	savedTermState.Store(state)
+}
+
+// restoreTerminal restores the terminal state saved by saveTerminal, if any.
+func restoreTerminal() {
+	state, _ := savedTermState.Load().(*term.State)
+	if state == nil {
+		return
+	}
+	savedTermState.Store((*term.State)(nil))
+	if err := term.RestoreTerminal(os.Stdin.Fd(), state); err != nil {
+		log.Write("Failed to restore terminal state: %v", err)
+	}
 }
 
 // Warn prints a warning message to stderr, logs it, and returns without interruption.
@@ -80,6 +211,9 @@ func Warn(msg string) {
 
 // Exit prints a message to stdout and exits the program with status 0.
 func Exit(msg string) {
+	flushJSON()
+	git.StopAnnexBatch()
+	printProfile()
 	if len(msg) > 0 {
 		log.Write("Exiting with message: %s", msg)
 		fmt.Println(msg)
@@ -97,7 +231,7 @@ func CheckError(err error) {
 	if err != nil {
 		log.Write(err.Error())
 		if strings.Contains(err.Error(), "Error loading user token") {
-			Die("This operation requires login.")
+			dieWithCode(ExitAuth, "This operation requires login.")
 		}
 		Die(err)
 	}
@@ -108,15 +242,28 @@ func CheckError(err error) {
 func CheckErrorMsg(err error, msg string) {
 	if err != nil {
 		log.Write("The following error occurred:\n%sExiting with message: %s", err, msg)
-		Die(msg)
+		dieWithCode(exitCode(err), msg)
 	}
 }
 
 // requirelogin prompts for login if the user is not already logged in.
-// It only checks if a local token exists and does not confirm its validity with the server.
+// By default, it only checks if a local token exists and does not confirm its validity with the server.
+// If the --check-login flag is set, the token is validated with the server and the command exits with an error if it is not valid.
 // The function should be called at the start of any command that requires being logged in to run.
 func requirelogin(cmd *cobra.Command, gincl *ginclient.Client, prompt bool) {
 	gincl.LoadToken()
+	if check, _ := cmd.Flags().GetBool("check-login"); check {
+		if gincl.Token == "" {
+			dieWithCode(ExitAuth, "You are not logged in.\nPlease log in using 'gin login'.")
+		}
+		if err := gincl.Ping(); err != nil {
+			code := exitCode(err)
+			if code == ExitError {
+				code = ExitAuth
+			}
+			dieWithCode(code, fmt.Sprintf("%s\nPlease log in again using 'gin login'.", err))
+		}
+	}
 }
 
 func annexVersionNotice() {
@@ -138,14 +285,175 @@ Visit the following page for a description of how this change may affect your wo
 func usageDie(cmd *cobra.Command) {
 	cmd.Help()
 	// exit without message
-	Die("")
+	dieWithCode(ExitUsage, "")
+}
+
+// changeToRepoRoot changes the working directory to the root of the current repository and returns the given paths rewritten relative to the root.
+// This lets commands run from any subdirectory of the repository.
+// If no paths are given, 'defaultcwd' determines whether the previous working directory is returned as the only path (for commands that operate on the working directory by default) or no paths are returned.
+func changeToRepoRoot(paths []string, defaultcwd bool) []string {
+	root, relpaths, err := ginclient.RepoRootPaths(paths)
+	CheckError(err)
+	CheckError(os.Chdir(root))
+	if len(paths) == 0 && !defaultcwd {
+		return nil
+	}
+	return relpaths
+}
+
+// readPaths reads newline-separated paths from r, or NUL-separated paths if nullsep is true.
+// Empty entries are ignored and only the separators (and carriage returns before newlines) are removed, so paths may contain spaces.
+func readPaths(r io.Reader, nullsep bool) ([]string, error) {
+	var paths []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 4096), 1<<20)
+	if nullsep {
+		scanner.Split(scanNull)
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !nullsep {
+			line = strings.TrimSuffix(line, "\r")
+		}
+		if line == "" {
+			continue
+		}
+		paths = append(paths, line)
+	}
+	return paths, scanner.Err()
+}
+
+// scanNull is a bufio.SplitFunc that splits input at NUL characters.
+func scanNull(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if idx := bytes.IndexByte(data, '\000'); idx >= 0 {
+		return idx + 1, data[:idx], nil
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// argsWithPathsFrom returns the positional arguments with the paths read from the file given with the --paths-from flag appended.
+// With the --null flag, the paths in the file are separated by NUL characters.
+// If the flag is given but no paths are found, the command exits with an error instead of operating on the default paths.
+func argsWithPathsFrom(cmd *cobra.Command, args []string) []string {
+	fname, _ := cmd.Flags().GetString("paths-from")
+	if fname == "" {
+		return args
+	}
+	var r io.Reader
+	if fname == "-" {
+		fname = "standard input"
+		r = os.Stdin
+	} else {
+		f, err := os.Open(fname)
+		if err != nil {
+			Die(fmt.Sprintf("failed to read paths: %s", err))
+		}
+		defer f.Close()
+		r = f
+	}
+	nullsep, _ := cmd.Flags().GetBool("null")
+	paths, err := readPaths(r, nullsep)
+	if err != nil {
+		Die(fmt.Sprintf("failed to read paths from %s: %s", fname, err))
+	}
+	if len(paths) == 0 && len(args) == 0 {
+		Die(fmt.Sprintf("no paths found in %s", fname))
+	}
+	return append(args, paths...)
+}
+
+// jsonSchemaVersion is the version of the format of the JSON lines printed by commands with the --json flag.
+// Adding fields does not change the version; it is incremented when fields are removed or change meaning.
+const jsonSchemaVersion = 1
+
+// activeCommand is the name of the command being run (e.g., "upload" or "metadata set").
+// It is set before the command runs and included in JSON output.
+var activeCommand string
+
+// jsonLine returns the JSON encoding of v with the schema version, the name of the active command, and the message type added as the fields "schema", "command", and "type".
+// v must encode to a JSON object.
+func jsonLine(msgtype string, v interface{}) ([]byte, error) {
+	j, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(j, &fields); err != nil {
+		return nil, err
+	}
+	fields["schema"], _ = json.Marshal(jsonSchemaVersion)
+	fields["command"], _ = json.Marshal(activeCommand)
+	fields["type"], _ = json.Marshal(msgtype)
+	return json.Marshal(fields)
+}
+
+// jsonArray is set by the --json-array flag: JSON messages are collected and printed as a single array when the command finishes (see flushJSON) instead of one per line.
+var jsonArray bool
+
+// jsonMessages holds the messages collected in array mode.
+// It is nil until the first message (or the first stream of messages) is printed.
+var jsonMessages []json.RawMessage
+
+// printJSONMessage prints a JSON message on its own line, or keeps it for flushJSON in array mode.
+func printJSONMessage(j []byte) {
+	if jsonArray {
+		jsonMessages = append(jsonMessages, j)
+		return
+	}
+	fmt.Println(string(j))
+}
+
+// flushJSON prints the messages collected in array mode as a single JSON array.
+// It does nothing if array mode is not enabled or no JSON output was started.
+func flushJSON() {
+	if !jsonArray || jsonMessages == nil {
+		return
+	}
+	j, _ := json.Marshal(jsonMessages)
+	fmt.Println(string(j))
+	jsonMessages = nil
+}
+
+// profile is set by the --profile flag: the time spent in git and git-annex commands is printed when the command finishes (see printProfile).
+var profile bool
+
+// printProfile prints the number of invocations and the total time spent for each type of git and git-annex command to stderr if profiling is enabled.
+// The duration of each individual command is written to the log.
+func printProfile() {
+	if !profile {
+		return
+	}
+	timings := shell.Profile()
+	fmt.Fprintln(os.Stderr, ":: Time spent in git and git-annex commands")
+	if len(timings) == 0 {
+		fmt.Fprintln(os.Stderr, "   No commands were run")
+		return
+	}
+	fmt.Fprintf(os.Stderr, "  %-28s  %7s  %10s\n", "Command", "Calls", "Time")
+	var total time.Duration
+	for _, timing := range timings {
+		fmt.Fprintf(os.Stderr, "  %-28s  %7d  %10s\n", timing.Command, timing.Count, timing.Duration.Round(time.Millisecond))
+		total += timing.Duration
+	}
+	fmt.Fprintf(os.Stderr, "  %-28s  %7s  %10s\n", "Total", "", total.Round(time.Millisecond))
 }
 
 func printJSON(statuschan <-chan git.RepoFileStatus) (filesuccess map[string]bool) {
 	filesuccess = make(map[string]bool)
+	if jsonArray && jsonMessages == nil {
+		// print an empty array if there are no messages
+		jsonMessages = make([]json.RawMessage, 0)
+	}
 	for stat := range statuschan {
-		j, _ := json.Marshal(stat)
-		fmt.Println(string(j))
+		msgtype := "status"
+		if stat.Err != nil {
+			msgtype = "error"
+		}
+		j, _ := jsonLine(msgtype, stat)
+		printJSONMessage(j)
 		filesuccess[stat.FileName] = true
 		if stat.Err != nil {
 			filesuccess[stat.FileName] = false
@@ -245,63 +553,105 @@ func printProgressWithBar(statuschan <-chan git.RepoFileStatus, nitems int) (fil
 	return
 }
 
-func printProgressOutput(statuschan <-chan git.RepoFileStatus) (filesuccess map[string]bool) {
-	filesuccess = make(map[string]bool)
-	var fname, state string
-	var lastprint string
-	outline := new(bytes.Buffer)
-	outappend := func(part string) {
-		if len(part) > 0 {
-			outline.WriteString(part)
-			outline.WriteString(" ")
-		}
+// progressPrinter is the terminal implementation of ginclient.ProgressReporter.
+// It prints one line per file and state and updates the line in place as the
+// progress changes.
+type progressPrinter struct {
+	filesuccess map[string]bool
+	fname       string
+	state       string
+	lastprint   string
+	printed     bool
+	outline     *bytes.Buffer
+}
+
+func newProgressPrinter() *progressPrinter {
+	return &progressPrinter{
+		filesuccess: make(map[string]bool),
+		outline:     new(bytes.Buffer),
 	}
+}
 
-	printed := false
-	for stat := range statuschan {
-		outline.Reset()
-		outline.WriteString(" ")
-		if stat.FileName != fname || stat.State != state {
-			// New line if new file or new state
-			if len(lastprint) > 0 {
-				fmt.Println()
-			}
-			lastprint = ""
-			fname = stat.FileName
-			state = stat.State
-		}
-		outappend(stat.State)
-		if stat.FileName != "" {
-			outappend(fmt.Sprintf("%q", stat.FileName))
+func (pp *progressPrinter) outappend(part string) {
+	if len(part) > 0 {
+		pp.outline.WriteString(part)
+		pp.outline.WriteString(" ")
+	}
+}
+
+// Report prints a single status update.
+func (pp *progressPrinter) Report(stat git.RepoFileStatus) {
+	pp.outline.Reset()
+	pp.outline.WriteString(" ")
+	if stat.FileName != pp.fname || stat.State != pp.state {
+		// New line if new file or new state
+		if len(pp.lastprint) > 0 {
+			fmt.Println()
 		}
-		if stat.Err == nil {
-			if stat.Progress == "100%" {
-				outappend(green("OK"))
-				filesuccess[stat.FileName] = true
-			} else {
-				outappend(stat.Progress)
-				outappend(stat.Rate)
-			}
+		pp.lastprint = ""
+		pp.fname = stat.FileName
+		pp.state = stat.State
+	}
+	pp.outappend(stat.State)
+	if stat.FileName != "" {
+		pp.outappend(fmt.Sprintf("%q", stat.FileName))
+	}
+	if stat.Err == nil {
+		if stat.Progress == "100%" {
+			pp.outappend(green("OK"))
+			pp.filesuccess[stat.FileName] = true
 		} else {
-			log.WriteError(stat.Err)
-			outappend(stat.Err.Error())
-			filesuccess[stat.FileName] = false
-		}
-		newprint := outline.String()
-		if newprint != lastprint {
-			fmt.Printf("\r%s\r", strings.Repeat(" ", len(lastprint))) // clear the line
-			fmt.Fprint(color.Output, newprint)
-			fmt.Print("\r")
-			lastprint = newprint
-			printed = true
+			pp.outappend(stat.Progress)
+			pp.outappend(stat.Rate)
 		}
+	} else {
+		log.WriteError(stat.Err)
+		pp.outappend(stat.Err.Error())
+		pp.filesuccess[stat.FileName] = false
 	}
-	if !printed {
+	newprint := pp.outline.String()
+	if newprint != pp.lastprint {
+		fmt.Printf("\r%s\r", strings.Repeat(" ", len(pp.lastprint))) // clear the line
+		fmt.Fprint(color.Output, newprint)
+		fmt.Print("\r")
+		pp.lastprint = newprint
+		pp.printed = true
+	}
+}
+
+// Done terminates the last line, or notes that nothing was reported.
+func (pp *progressPrinter) Done() {
+	if !pp.printed {
 		fmt.Println("   Nothing to do")
 	}
-	if len(lastprint) > 0 {
+	if len(pp.lastprint) > 0 {
 		fmt.Println()
 	}
+}
+
+func printProgressOutput(statuschan <-chan git.RepoFileStatus) (filesuccess map[string]bool) {
+	printer := newProgressPrinter()
+	ginclient.ReportProgress(statuschan, printer)
+	return printer.filesuccess
+}
+
+// quietOutput consumes the status messages without printing any progress.
+// Errors are printed to stderr.
+func quietOutput(statuschan <-chan git.RepoFileStatus) (filesuccess map[string]bool) {
+	filesuccess = make(map[string]bool)
+	for stat := range statuschan {
+		if stat.Err != nil {
+			log.WriteError(stat.Err)
+			msg := stat.Err.Error()
+			if stat.FileName != "" {
+				msg = fmt.Sprintf("%q: %s", stat.FileName, msg)
+			}
+			fmt.Fprintf(color.Error, "%s %s\n", red("[error]"), msg)
+			filesuccess[stat.FileName] = false
+		} else if stat.Progress == "100%" {
+			filesuccess[stat.FileName] = true
+		}
+	}
 	return
 }
 
@@ -326,6 +676,11 @@ func verboseOutput(statuschan <-chan git.RepoFileStatus) (filesuccess map[string
 func determinePrintStyle(cmd *cobra.Command) printstyle {
 	verboseOn, _ := cmd.Flags().GetBool("verbose")
 	jsonOn, _ := cmd.Flags().GetBool("json")
+	if jsonArrayOn, _ := cmd.Flags().GetBool("json-array"); jsonArrayOn && cmd.Flags().Lookup("json") != nil {
+		jsonOn = true
+		jsonArray = true
+	}
+	quietOn, _ := cmd.Flags().GetBool("quiet")
 
 	isProgressCmd := func() bool {
 		progressCmds := []string{"lock", "unlock", "remove-content"}
@@ -339,12 +694,17 @@ func determinePrintStyle(cmd *cobra.Command) printstyle {
 
 	switch {
 	case verboseOn && jsonOn:
-		Die("--verbose and --json cannot be used together")
+		dieWithCode(ExitUsage, "--verbose and --json cannot be used together")
+	case verboseOn && quietOn:
+		dieWithCode(ExitUsage, "--verbose and --quiet cannot be used together")
 	case verboseOn:
 		git.RawMode = true
 		return psVerbose
 	case jsonOn:
+		// JSON output is meant for programs; --quiet has no effect
 		return psJSON
+	case quietOn:
+		return psQuiet
 	case isProgressCmd():
 		return psProgress
 	default:
@@ -353,9 +713,15 @@ func determinePrintStyle(cmd *cobra.Command) printstyle {
 	return psDefault
 }
 
-func formatOutput(statuschan <-chan git.RepoFileStatus, pstyle printstyle, nitems int) {
-	// TODO: instead of a true/false success, add an error for every file and then group the errors by type and print a report
-	var filesuccess map[string]bool
+// printingStatus is non-zero while printStatus is printing the status of an operation.
+var printingStatus int32
+
+func printStatus(statuschan <-chan git.RepoFileStatus, pstyle printstyle, nitems int) (filesuccess map[string]bool) {
+	atomic.StoreInt32(&printingStatus, 1)
+	defer atomic.StoreInt32(&printingStatus, 0)
+	if abortOperation != nil {
+		statuschan = stopOnError(statuschan)
+	}
 	switch pstyle {
 	case psJSON:
 		filesuccess = printJSON(statuschan)
@@ -365,9 +731,60 @@ func formatOutput(statuschan <-chan git.RepoFileStatus, pstyle printstyle, nitem
 		filesuccess = printProgressWithBar(statuschan, nitems)
 	case psDefault:
 		filesuccess = printProgressOutput(statuschan)
+	case psQuiet:
+		filesuccess = quietOutput(statuschan)
+	}
+	return
+}
+
+// abortOperation cancels the context of the running operation (see operationContext).
+// It is only set with --fail-fast.
+var abortOperation context.CancelFunc
+
+// operationAborted is set when the running operation was cancelled after the first failure with --fail-fast.
+var operationAborted bool
+
+// operationContext returns the context for an operation of a command that processes many files and reports their status on a status channel.
+// With --fail-fast, the context is cancelled when printStatus receives the first error, which stops the operation; otherwise (--keep-going, the default) the operation continues past errors and they are reported when it finishes.
+// The command exits with a usage message if both flags are given.
+func operationContext(cmd *cobra.Command) context.Context {
+	failfast, _ := cmd.Flags().GetBool("fail-fast")
+	if failfast && cmd.Flags().Changed("keep-going") {
+		usageDie(cmd)
+	}
+	if !failfast {
+		return context.Background()
 	}
+	ctx, cancel := context.WithCancel(context.Background())
+	abortOperation = cancel
+	return ctx
+}
+
+// stopOnError relays the messages of statuschan until the first error, which is relayed before the operation is cancelled with abortOperation.
+// The messages the operation sends while it stops (e.g., the cancellation error) are logged but not relayed.
+func stopOnError(statuschan <-chan git.RepoFileStatus) <-chan git.RepoFileStatus {
+	relaychan := make(chan git.RepoFileStatus)
+	go func() {
+		defer close(relaychan)
+		for stat := range statuschan {
+			if operationAborted {
+				log.Write("Ignoring status after abort: %+v", stat)
+				continue
+			}
+			relaychan <- stat
+			if stat.Err != nil {
+				log.Write("Aborting operation after error: %v", stat.Err)
+				operationAborted = true
+				abortOperation()
+			}
+		}
+	}()
+	return relaychan
+}
 
-	// count unique file errors
+// checkFileErrors counts the unique file errors and exits with an error message if there were any.
+func checkFileErrors(filesuccess map[string]bool) {
+	// TODO: instead of a true/false success, add an error for every file and then group the errors by type and print a report
 	nerrors := 0
 	for _, stat := range filesuccess {
 		if !stat {
@@ -380,10 +797,29 @@ func formatOutput(statuschan <-chan git.RepoFileStatus, pstyle printstyle, nitem
 		if nerrors > 1 {
 			plural = "s"
 		}
-		Die(fmt.Sprintf("%d operation%s failed", nerrors, plural))
+		if operationAborted {
+			dieWithCode(ExitPartial, fmt.Sprintf("%d operation%s failed; the remaining operations were cancelled (--fail-fast)", nerrors, plural))
+		}
+		dieWithCode(ExitPartial, fmt.Sprintf("%d operation%s failed", nerrors, plural))
 	}
 }
 
+func formatOutput(statuschan <-chan git.RepoFileStatus, pstyle printstyle, nitems int) {
+	filesuccess := printStatus(statuschan, pstyle, nitems)
+	checkFileErrors(filesuccess)
+}
+
+// formatTransferOutput prints the status of a file transfer operation (upload or content download) like formatOutput and follows it with a summary of the number of files and bytes transferred, the elapsed time, and the average transfer rate.
+// The summary is printed even if some transfers failed.
+// The summary is returned if all transfers succeeded.
+func formatTransferOutput(statuschan <-chan git.RepoFileStatus, pstyle printstyle) *transferSummary {
+	summary := newTransferSummary()
+	filesuccess := printStatus(summary.collect(statuschan), pstyle, 0)
+	summary.print(pstyle)
+	checkFileErrors(filesuccess)
+	return summary
+}
+
 var wouter = wrap.NewWrapper()
 var winner = wrap.NewWrapper()
 
@@ -464,6 +900,9 @@ func disableCommands(cmds map[string]*cobra.Command, giterr, annexerr error) {
 		cmds[cname].Run = func(c *cobra.Command, args []string) {
 			Die(diemsg)
 		}
+		for _, subcmd := range cmds[cname].Commands() {
+			subcmd.Run = cmds[cname].Run
+		}
 	}
 
 }
@@ -476,7 +915,42 @@ func SetUpCommands(verinfo VersionInfo) *cobra.Command {
 		Long:                  "GIN Command Line Interface and client for the GIN services", // TODO: Add license and web info
 		Version:               fmt.Sprintln(verstr),
 		DisableFlagsInUseLine: true,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			activeCommand = strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()+" ")
+			handleInterrupt()
+			profile, _ = cmd.Flags().GetBool("profile")
+			git.UseAnnexBatch, _ = cmd.Flags().GetBool("batch")
+			removelock, _ := cmd.Flags().GetBool("remove-stale-lock")
+			git.RemoveStaleLock = staleLockHandler(removelock, determinePrintStyle(cmd) != psJSON)
+			git.HTTPCredentials = ginclient.ServerCredentials()
+			conf := config.Read()
+			for _, msg := range config.Warnings() {
+				Warn(msg)
+			}
+			directModeNotice(cmd)
+			logpath, _ := cmd.Flags().GetString("log-file")
+			if logpath == "" {
+				logpath = conf.LogFile
+			}
+			if logpath != "" {
+				if err := log.SetFile(logpath); err != nil {
+					Warn(fmt.Sprintf("could not use log file %s: %s", logpath, err.Error()))
+				}
+			}
+		},
+		PersistentPostRun: func(cmd *cobra.Command, args []string) {
+			flushJSON()
+			git.StopAnnexBatch()
+			printProfile()
+		},
 	}
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Do not print progress or summary output. Errors are still printed and the exit status still indicates failure. Has no effect with --json.")
+	rootCmd.PersistentFlags().Bool("json-array", false, "For commands that print one JSON object per line for each file (e.g., upload, get-content), print all objects as a single JSON array when the command finishes instead. Implies --json.")
+	rootCmd.PersistentFlags().String("log-file", "", "Write the log to the file at `path` instead of the default location.")
+	rootCmd.PersistentFlags().Bool("batch", false, "Keep a single git-annex process running for queries about many files (e.g., the content locations of files listed by 'ls') instead of starting a new process for each query.")
+	rootCmd.PersistentFlags().Bool("remove-stale-lock", false, "If a git command fails because the repository index is locked by a lock file that was left over from an interrupted operation, remove the lock file without asking and run the command again. Without this flag, the lock file is only removed after confirmation on the terminal, and never with --json or while the progress of an operation is shown. Lock files that may still be in use are never removed.")
+	rootCmd.PersistentFlags().Bool("check-login", false, checkLoginHelpMsg)
+	rootCmd.PersistentFlags().Bool("profile", false, "Print the number of calls and the time spent for each type of git and git-annex command when the command finishes. The duration of each call is written to the log.")
 	cmds := make(map[string]*cobra.Command)
 
 	// Login
@@ -563,6 +1037,81 @@ func SetUpCommands(verinfo VersionInfo) *cobra.Command {
 	// Version
 	cmds["version"] = VersionCmd()
 
+	// Export plain copy
+	cmds["export"] = ExportCmd()
+
+	// Print old version of a file
+	cmds["cat-version"] = CatVersionCmd()
+
+	// Import directory
+	cmds["import"] = ImportCmd()
+
+	// Add file from URL
+	cmds["annex-addurl"] = AddURLCmd()
+
+	// Metadata
+	cmds["metadata"] = MetadataCmd()
+
+	// Find files
+	cmds["find"] = FindCmd()
+
+	// Repository statistics
+	cmds["stats"] = StatsCmd()
+
+	// Unused content report
+	cmds["annex-unused-report"] = UnusedReportCmd()
+
+	// Minimum number of copies
+	cmds["annex-numcopies"] = NumCopiesCmd()
+
+	// Preferred content
+	cmds["annex-wanted"] = WantedCmd()
+
+	// Repository groups
+	cmds["annex-group"] = GroupCmd()
+
+	// Move content between remotes
+	cmds["move-content"] = MoveContentCmd()
+
+	// Compare working tree with a version
+	cmds["verify"] = VerifyCmd()
+
+	// Annex/git filtering expression
+	cmds["largefiles"] = LargefilesCmd()
+
+	// Repair annex pointers
+	cmds["refresh"] = RefreshCmd()
+
+	// Enable special remotes
+	cmds["annex-enableremote"] = EnableRemoteCmd()
+
+	// Copy content to a remote
+	cmds["annex-copy"] = CopyContentCmd()
+
+	// Migrate from direct mode
+	cmds["migrate-direct"] = MigrateDirectCmd()
+
+	// Update remote URLs after server migration
+	cmds["set-remote-url"] = SetRemoteURLCmd()
+
+	// Transfer repository ownership
+	cmds["transfer"] = TransferCmd()
+
+	// Upload changes automatically
+	cmds["watch"] = WatchCmd()
+
+	// Remote repository history
+	cmds["remote-log"] = RemoteLogCmd()
+
+	// Annex information
+	cmds["annex-info"] = AnnexInfoCmd()
+
+	// Content presence overview
+	cmds["annex-list"] = AnnexListCmd()
+
+	// Transfer queue status
+	cmds["annex-transfers"] = AnnexTransfersCmd()
+
 	cmds["git"] = GitCmd()
 
 	cmds["annex"] = AnnexCmd()
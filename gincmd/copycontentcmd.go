@@ -0,0 +1,63 @@
+package gincmd
+
+import (
+	"context"
+	"fmt"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func copyContent(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	dst, _ := cmd.Flags().GetString("to")
+	if dst == "" {
+		usageDie(cmd)
+	}
+	conf := config.Read()
+	gincl := ginclient.New(conf.DefaultServer)
+	requirelogin(cmd, gincl, prStyle != psJSON)
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	if prStyle.showHeaders() {
+		fmt.Printf(":: Copying file content to %s\n", dst)
+	}
+	paths := changeToRepoRoot(args, true)
+	copychan := make(chan git.RepoFileStatus)
+	go gincl.CopyContent(context.Background(), paths, dst, copychan)
+	formatTransferOutput(copychan, prStyle)
+}
+
+// CopyContentCmd sets up the 'annex-copy' subcommand
+func CopyContentCmd() *cobra.Command {
+	description := "Copy the content of files to a remote, for instance to keep a backup copy on a second remote. Unlike 'upload', no changes are uploaded and the content is copied only to the specified remote. The content in the local repository is kept. Only content that is available locally and missing from the remote is transferred.\n\nWith no arguments, copies the content of all files under the current working directory. The remote must be configured in the local repository (see 'gin remotes')."
+	args := map[string]string{
+		"<filenames>": "One or more directories or files whose content should be copied.",
+	}
+	examples := map[string]string{
+		"Copy the content of all files in 'raw' to the remote 'backup'": "$ gin annex-copy --to backup raw",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "annex-copy [--json | --verbose] --to <remote> [<filenames>]...",
+		Short:                 "Copy the content of files to a remote",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   copyContent,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().String("to", "", "The `remote` to copy the content to.")
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().Bool("verbose", false, verboseHelpMsg)
+	return cmd
+}
@@ -1,6 +1,7 @@
 package gincmd
 
 import (
+	"context"
 	"fmt"
 
 	"github.com/G-Node/gin-cli/ginclient"
@@ -17,8 +18,11 @@ func createRepo(cmd *cobra.Command, args []string) {
 	here, _ := flags.GetBool("here")
 	noclone, _ := flags.GetBool("no-clone")
 	srvalias, _ := flags.GetString("server")
+	clone, _ := flags.GetBool("clone")
+	clonedir, _ := flags.GetString("clone-dir")
+	cloneflags := clone || clonedir != "" || flags.Changed("https") || flags.Changed("compression") || flags.Changed("pack-threads")
 
-	if noclone && here {
+	if noclone && here || cloneflags && (here || noclone) {
 		usageDie(cmd)
 	}
 
@@ -40,6 +44,18 @@ func createRepo(cmd *cobra.Command, args []string) {
 		}
 	}
 	repopath := fmt.Sprintf("%s/%s", gincl.Username, repoName)
+	if !here && !noclone {
+		// Create and clone repository
+		prStyle := determinePrintStyle(cmd)
+		opts := cloneOptions(cmd, conf)
+		opts.Dest = clonedir
+		clonechan := make(chan git.RepoFileStatus)
+		go gincl.CreateAndCloneRepo(context.Background(), repoName, repoDesc, opts, clonechan)
+		formatOutput(clonechan, prStyle, 0)
+		defaultRemoteIfUnset("origin")
+		return
+	}
+
 	fmt.Printf(":: Creating repository '%s' ", repopath)
 	err := gincl.CreateRepo(repoName, repoDesc)
 	CheckError(err)
@@ -49,7 +65,8 @@ func createRepo(cmd *cobra.Command, args []string) {
 		// Init cwd
 		err = gincl.InitDir(false)
 		CheckError(err)
-		url := fmt.Sprintf("%s/%s", gincl.GitAddress(), repopath)
+		url, err := gincl.RemoteAddress(repopath)
+		CheckError(err)
 		err = git.RemoteAdd("origin", url)
 		CheckError(err)
 		defaultRemoteIfUnset("origin")
@@ -58,20 +75,17 @@ func createRepo(cmd *cobra.Command, args []string) {
 		if new {
 			// Push the new commit to initialise origin
 			uploadchan := make(chan git.RepoFileStatus)
-			go gincl.Upload(nil, []string{"origin"}, uploadchan)
+			go gincl.Upload(context.Background(), nil, []string{"origin"}, uploadchan)
 			for range uploadchan {
 				// Wait for channel to close
 			}
 		}
-	} else if !noclone {
-		// Clone repository after creation
-		getRepo(cmd, []string{repopath})
 	}
 }
 
 // CreateCmd sets up the 'create' subcommand
 func CreateCmd() *cobra.Command {
-	description := "Create a new repository on the GIN server and optionally clone it locally or initialise working directory."
+	description := "Create a new repository on the GIN server and optionally clone it locally or initialise working directory.\n\nBy default, the new repository is cloned into a new directory with the name of the repository. Use --clone-dir to clone it into a different directory. As with 'gin get', the repository can be cloned over HTTPS (--https) and the compression and threads used for the clone can be set (--compression and --pack-threads, or the 'clone.compression' and 'clone.packthreads' configuration options)."
 
 	args := map[string]string{
 		"<name>":        "The name of the repository. If none is provided, you will be prompted for one. If you want to provide a description, you need to provide a repository name on the command line first and the description second. Names should contain only alphanumberic characters, '.', '-', and '_'.",
@@ -83,10 +97,11 @@ func CreateCmd() *cobra.Command {
 		"Create a repository named 'example' with no description":                                            "$ gin create example",
 		"Create a repository named 'mydata' and initialise the current working directory as the local clone": "$ gin create --here mydata",
 		"Create a repository named 'eegdata' with a description":                                             "$ gin create eegdata \"My repository for storing EEG data\"",
+		"Create a repository named 'mydata' and clone it into the directory 'projects/mydata'":               "$ gin create --clone-dir projects/mydata mydata",
 	}
 
 	var cmd = &cobra.Command{
-		Use:                   "create [--here | --no-clone] [<repository>] [<description>]",
+		Use:                   "create [--here | --no-clone | --clone [--clone-dir directory] [--https] [--compression <level>] [--pack-threads <n>]] [<repository>] [<description>]",
 		Short:                 "Create a new repository on the GIN server",
 		Long:                  formatdesc(description, args),
 		Example:               formatexamples(examples),
@@ -96,6 +111,11 @@ func CreateCmd() *cobra.Command {
 	}
 	cmd.Flags().Bool("here", false, "Create the local repository clone in the current working directory. Cannot be used with --no-clone.")
 	cmd.Flags().Bool("no-clone", false, "Create repository on the server but do not clone it locally. Cannot be used with --here.")
+	cmd.Flags().Bool("clone", false, "Clone the new repository locally after creating it. This is the default behaviour. Cannot be used with --here or --no-clone.")
+	cmd.Flags().String("clone-dir", "", "Clone the new repository into `directory` instead of a new directory with the name of the repository. Implies --clone.")
+	cmd.Flags().Bool("https", false, "Clone the new repository over HTTPS instead of ssh, using your login credentials.")
+	cmd.Flags().Int("compression", -1, "Compress the objects written during the clone with the zlib compression `level` (0-9; -1 uses the default of git).")
+	cmd.Flags().Int("pack-threads", 0, "Use `n` threads to process the received data (0 uses one thread per CPU).")
 	cmd.Flags().String("server", "", "Specify server `alias` where the repository will be created. See also 'gin servers'.")
 	return cmd
 }
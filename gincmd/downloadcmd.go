@@ -1,13 +1,17 @@
 package gincmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
 	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/log"
 	"github.com/G-Node/gin-cli/gincmd/ginerrors"
 	"github.com/G-Node/gin-cli/git"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
@@ -17,6 +21,7 @@ func download(cmd *cobra.Command, args []string) {
 	// TODO: no client necessary? Just use remotes
 	conf := config.Read()
 	gincl := ginclient.New(conf.DefaultServer)
+	requirelogin(cmd, gincl, prStyle != psJSON)
 	switch git.Checkwd() {
 	case git.NotRepository:
 		Die(ginerrors.NotInRepo)
@@ -31,27 +36,151 @@ func download(cmd *cobra.Command, args []string) {
 	}
 
 	content, _ := cmd.Flags().GetBool("content")
+	newonly, _ := cmd.Flags().GetBool("new")
+	if content && newonly {
+		usageDie(cmd)
+	}
+	if dryrun, _ := cmd.Flags().GetBool("dry-run"); dryrun {
+		reporoot, _ := git.FindRepoRoot(".")
+		os.Chdir(reporoot)
+		printDownloadPlan(remote, content, prStyle)
+		return
+	}
+	var base string
+	if newonly {
+		base, err = ginclient.DownloadBase()
+		CheckError(err)
+	}
 	if prStyle == psDefault {
 		fmt.Print(":: Downloading changes ")
 	}
-	err = gincl.Download(remote)
+	rebase, _ := cmd.Flags().GetBool("rebase")
+	err = gincl.Download(context.Background(), remote, ginclient.DownloadOptions{Rebase: rebase})
 	CheckError(err)
 	if prStyle == psDefault {
 		fmt.Fprintln(color.Output, green("OK"))
 	}
-	if content {
+	var summary *transferSummary
+	if content || newonly {
 		reporoot, _ := git.FindRepoRoot(".")
 		os.Chdir(reporoot)
-		getContent(cmd, nil)
 	}
+	if content {
+		summary = downloadContent(cmd, nil)
+	} else if newonly {
+		newfiles, err := ginclient.NewSinceDownload(base)
+		CheckError(err)
+		if len(newfiles) > 0 {
+			summary = downloadContent(cmd, newfiles)
+		} else if prStyle == psDefault {
+			fmt.Println(":: No new or changed files to download")
+		}
+	}
+	if err := ginclient.MarkDownloaded(); err != nil {
+		log.Write("Failed to record download: %v", err)
+	}
+	if prune, _ := cmd.Flags().GetBool("prune"); prune && !git.NoAnnex() {
+		pruneUnused(prStyle)
+	}
+	runHook("download", hookEnv("download", []string{remote}, summary))
+}
+
+// pruneUnused removes the content of annexed objects that are no longer used by any file (e.g., the content of files that were deleted on the remote) if the content is available from a remote.
+// Objects whose content cannot be removed safely are kept and reported.
+func pruneUnused(prStyle printstyle) {
+	if prStyle.showHeaders() {
+		fmt.Println(":: Removing unused content")
+	}
+	unused, err := git.AnnexUnused()
+	CheckError(err)
+	if len(unused) == 0 {
+		if prStyle.showHeaders() {
+			fmt.Println("   No unused content found")
+		}
+		return
+	}
+	summary := newTransferSummary()
+	dropchan := make(chan git.RepoFileStatus)
+	go git.AnnexDropUnused(unused, false, dropchan)
+	filesuccess := printStatus(summary.collect(dropchan), prStyle, len(unused))
+	kept := 0
+	for _, ok := range filesuccess {
+		if !ok {
+			kept++
+		}
+	}
+	if prStyle.showHeaders() {
+		fmt.Printf(":: Removed %d object(s), reclaimed %s\n", summary.nfiles(), humanize.IBytes(uint64(summary.nbytes())))
+		if kept > 0 {
+			fmt.Printf("   Kept %d object(s) whose content is not available from any remote (see 'gin annex-unused-report')\n", kept)
+		}
+	}
+}
+
+// printDownloadPlan prints the changes that a download from the remote would apply and the size of the content that would be downloaded with it.
+func printDownloadPlan(remote string, content bool, prStyle printstyle) {
+	if prStyle == psDefault {
+		fmt.Print(":: Checking remote changes ")
+	}
+	plan, err := ginclient.DownloadPlan(remote)
+	CheckError(err)
+	if prStyle == psDefault {
+		fmt.Fprintln(color.Output, green("OK"))
+	}
+
+	// content of new and modified files (downloaded with --new)
+	var newsize int64
+	counts := make(map[string]int)
+	for _, change := range plan {
+		counts[change.Change]++
+		if change.Annexed {
+			newsize += change.Size
+		}
+	}
+	contentsize := newsize
+	if content {
+		// content of the current files that is not available locally
+		missing, err := ginclient.MissingContentSize(nil)
+		CheckError(err)
+		contentsize += missing
+	}
+
+	if prStyle == psJSON {
+		j, _ := json.Marshal(struct {
+			Changes      []ginclient.DownloadChange `json:"changes"`
+			ContentBytes int64                      `json:"contentbytes"`
+		}{plan, contentsize})
+		printJSONMessage(j)
+		return
+	}
+
+	if len(plan) == 0 {
+		fmt.Println(":: No changes to download")
+	} else {
+		fmt.Println(":: Changes that would be downloaded")
+		for _, change := range plan {
+			if change.Annexed {
+				fmt.Printf("   %-9s %s (content: %s)\n", change.Change, change.FileName, humanize.IBytes(uint64(change.Size)))
+			} else {
+				fmt.Printf("   %-9s %s\n", change.Change, change.FileName)
+			}
+		}
+		fmt.Printf(":: %d added, %d modified, %d deleted\n", counts["added"], counts["modified"], counts["deleted"])
+	}
+	if content {
+		fmt.Printf(":: Content to download with --content: %s\n", humanize.IBytes(uint64(contentsize)))
+	} else {
+		fmt.Printf(":: Content of new and modified files (downloaded with --new): %s\n", humanize.IBytes(uint64(newsize)))
+	}
+	fmt.Println(":: Dry run: nothing was changed")
 }
 
 // DownloadCmd sets up the 'download' subcommand
 func DownloadCmd() *cobra.Command {
-	description := "Downloads changes from the remote repository to the local clone. This will create new files that were added remotely, delete files that were removed, and update files that were changed.\n\nOptionally downloads the content of all files in the repository. If 'content' is not specified, new files will be empty placeholders. Content of individual files can later be retrieved using the 'get-content' command."
+	description := "Downloads changes from the remote repository to the local clone. This will create new files that were added remotely, delete files that were removed, and update files that were changed.\n\nOptionally downloads the content of all files in the repository. If 'content' is not specified, new files will be empty placeholders. With --new, only the content of the files that were added or changed on the remote since the last download is downloaded, which keeps the local content of large repositories small while keeping up with new data. When downloading content, the command stops before the download if the content does not fit in the free disk space, unless --force is specified. Content of individual files can later be retrieved using the 'get-content' command.\n\nWith --dry-run, the changes are retrieved from the remote and the files that would be added, modified, or deleted are listed along with the size of the content that would be downloaded, without changing the local clone.\n\nWith --prune, the content of files that are no longer used in the repository (e.g., files that were deleted on the remote, or old versions of modified files) is removed from the local clone after the download and the reclaimed space is reported. Only content that is available from a remote is removed.\n\nWhen changes were made both locally and remotely, the two histories are merged by default, which records a merge commit. With the --rebase option, local commits that have not been uploaded are instead replayed on top of the remote changes, keeping the history linear. Rebasing rewrites the local commits (they get new version IDs), so it should only be used for changes that have not been uploaded to any other remote. If a conflict occurs in either mode, the download is cancelled and the conflicting files are listed."
 	var cmd = &cobra.Command{
 		// Use:                   "download [--json | --verbose] [--content]",
-		Use:                   "download [--json] [--content]",
+		Use:                   "download [--json] [--content | --new] [--force] [--rebase] [--prune] [--dry-run]",
 		Short:                 "Download all new information from a remote repository",
 		Long:                  formatdesc(description, nil),
 		Args:                  cobra.NoArgs,
@@ -61,5 +190,10 @@ func DownloadCmd() *cobra.Command {
 	cmd.Flags().Bool("json", false, jsonHelpMsg)
 	// cmd.Flags().Bool("verbose", false, verboseHelpMsg)
 	cmd.Flags().Bool("content", false, "Download the content for all files in the repository.")
+	cmd.Flags().Bool("new", false, "Download the content for the files that were added or changed on the remote since the last download.")
+	cmd.Flags().Bool("force", false, "With --content or --new, download even if the content does not fit in the free disk space.")
+	cmd.Flags().Bool("rebase", false, "Rebase local commits on top of the downloaded changes instead of merging.")
+	cmd.Flags().Bool("prune", false, "Remove the content of files that are no longer used in the repository (e.g., deleted on the remote) if it is available from a remote.")
+	cmd.Flags().Bool("dry-run", false, "List the changes that would be downloaded and the size of their content without changing the local clone.")
 	return cmd
 }
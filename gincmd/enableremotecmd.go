@@ -0,0 +1,134 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/docker/docker/pkg/term"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+func printSpecialRemotes(remotes []git.SpecialRemote, jsonout bool) {
+	if jsonout {
+		j, _ := json.Marshal(remotes)
+		fmt.Println(string(j))
+		return
+	}
+	if len(remotes) == 0 {
+		fmt.Println(":: No special remotes configured")
+		return
+	}
+	fmt.Println(":: Special remotes")
+	for _, remote := range remotes {
+		status := "not enabled"
+		if remote.Enabled {
+			status = green("enabled")
+		}
+		fmt.Fprintf(color.Output, "  %s (%s): %s\n", remote.Name, remote.Type, status)
+	}
+}
+
+// readCredentialVars makes sure the environment variables with the credentials for the special remote are set.
+// Missing values are prompted for on the terminal; the command exits if no terminal is available.
+func readCredentialVars(remote git.SpecialRemote) {
+	for _, envvar := range remote.CredentialVars() {
+		if os.Getenv(envvar) != "" {
+			continue
+		}
+		if !term.IsTerminal(os.Stdin.Fd()) {
+			Die(fmt.Sprintf("special remote '%s' requires credentials: set the %s environment variable", remote.Name, envvar))
+		}
+		var value string
+		if strings.Contains(envvar, "SECRET") || strings.Contains(envvar, "PASSWORD") {
+			value = promptSecret(envvar)
+		} else {
+			fmt.Printf("%s: ", envvar)
+			fmt.Scanln(&value)
+		}
+		os.Setenv(envvar, value)
+	}
+}
+
+func enableRemote(cmd *cobra.Command, args []string) {
+	jsonout, _ := cmd.Flags().GetBool("json")
+	if jsonout && len(args) > 0 {
+		usageDie(cmd)
+	}
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	remotes, err := git.SpecialRemotes()
+	CheckError(err)
+	if len(args) == 0 {
+		printSpecialRemotes(remotes, jsonout)
+		return
+	}
+
+	name, params := args[0], args[1:]
+	for _, param := range params {
+		if !strings.Contains(param, "=") {
+			usageDie(cmd)
+		}
+	}
+	var remote *git.SpecialRemote
+	for idx := range remotes {
+		if remotes[idx].Name == name {
+			remote = &remotes[idx]
+			break
+		}
+	}
+	if remote == nil {
+		names := make([]string, len(remotes))
+		for idx, r := range remotes {
+			names[idx] = r.Name
+		}
+		if len(names) == 0 {
+			Die(fmt.Sprintf("unknown special remote '%s': no special remotes configured", name))
+		}
+		Die(fmt.Sprintf("unknown special remote '%s': available special remotes are %s", name, strings.Join(names, ", ")))
+	}
+	readCredentialVars(*remote)
+
+	fmt.Printf(":: Enabling special remote '%s' (%s) ", remote.Name, remote.Type)
+	CheckError(git.EnableRemote(remote.Name, params))
+	fmt.Fprintln(color.Output, green("OK"))
+}
+
+// EnableRemoteCmd sets up the 'annex-enableremote' subcommand
+func EnableRemoteCmd() *cobra.Command {
+	description := `Enable a git-annex special remote (e.g., an S3 bucket or a WebDAV server) that is configured in the repository, so that file content can be uploaded to and downloaded from it in the local repository. Special remotes are configured with git-annex and are recorded in the repository, but each clone must enable them before use.
+
+Credentials that are not stored in the repository are read from the environment: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY for S3 and Glacier remotes, WEBDAV_USERNAME and WEBDAV_PASSWORD for WebDAV remotes. If a variable is not set, its value is prompted for.
+
+With no arguments, lists the special remotes configured in the repository and whether they are enabled.`
+	args := map[string]string{
+		"<name>":        "The name of the special remote to enable.",
+		"<key>=<value>": "Parameters of the special remote to change for the local repository (see the git-annex documentation for the parameters of each type of special remote).",
+	}
+	examples := map[string]string{
+		"List the special remotes of the repository": "$ gin annex-enableremote",
+		"Enable the special remote 'backup'":         "$ gin annex-enableremote backup",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "annex-enableremote [--json | <name> [<key>=<value>]...]",
+		Short:                 "Enable a special remote configured in the repository",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   enableRemote,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, "Print the list of special remotes in JSON format.")
+	return cmd
+}
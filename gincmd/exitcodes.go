@@ -0,0 +1,74 @@
+package gincmd
+
+import (
+	"strings"
+
+	"github.com/G-Node/gin-cli/git/shell"
+)
+
+// Exit status codes of the client.
+// See doc/exitcodes.md for a description of each code.
+const (
+	// ExitError indicates a generic error.
+	ExitError = 1
+	// ExitUsage indicates that the command was called incorrectly (e.g., unknown flags or wrong number of arguments).
+	ExitUsage = 2
+	// ExitAuth indicates that the operation requires logging in or that the login is not valid.
+	ExitAuth = 3
+	// ExitNetwork indicates that the server could not be reached.
+	ExitNetwork = 4
+	// ExitPartial indicates that some operations of a command that operates on multiple files (e.g., a transfer) failed.
+	ExitPartial = 5
+)
+
+// authErrors and networkErrors are (lowercase) fragments of error messages that identify the class of an error when the error itself does not carry a status code.
+var (
+	authErrors = []string{
+		"authorisation failed",
+		"authentication failed",
+		"not logged in",
+		"error loading user token",
+		"token has expired",
+		"permission denied (publickey)",
+	}
+	networkErrors = []string{
+		"server refused connection",
+		"server unreachable",
+		"request timed out",
+		"could not resolve host",
+		"connection refused",
+		"connection timed out",
+		"network is unreachable",
+		"no route to host",
+		"could not read from remote repository",
+	}
+)
+
+// exitCode returns the exit status code that corresponds to the class of the given error.
+// Errors that are not recognised as authentication or network errors map to ExitError.
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	errmsg := err.Error()
+	if serr, ok := err.(shell.Error); ok {
+		// web errors carry the HTTP status of the response
+		if strings.HasPrefix(serr.UError, "401") || strings.HasPrefix(serr.UError, "403") {
+			return ExitAuth
+		}
+		// the description may hide the underlying error
+		errmsg = serr.Description + "\n" + serr.UError
+	}
+	errmsg = strings.ToLower(errmsg)
+	for _, fragment := range authErrors {
+		if strings.Contains(errmsg, fragment) {
+			return ExitAuth
+		}
+	}
+	for _, fragment := range networkErrors {
+		if strings.Contains(errmsg, fragment) {
+			return ExitNetwork
+		}
+	}
+	return ExitError
+}
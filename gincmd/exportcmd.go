@@ -0,0 +1,95 @@
+package gincmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func export(cmd *cobra.Command, args []string) {
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Warn(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	commithash, _ := cmd.Flags().GetString("id")
+	// the destination is relative to the working directory the command was
+	// called from, so resolve it before changing to the repository root
+	destination, err := filepath.Abs(args[0])
+	CheckError(err)
+	paths := changeToRepoRoot(args[1:], false)
+
+	fullhash, err := git.ResolveCommit(commithash)
+	CheckError(err)
+	commits, err := git.Log(1, fullhash, nil, false)
+	CheckError(err)
+	if len(commits) == 0 {
+		Die("No revisions matched request")
+	}
+	gcommit := commits[0]
+	hash := gcommit.AbbreviatedHash
+
+	exportchan := make(chan ginclient.FileCheckoutStatus)
+	go ginclient.ExportFiles(gcommit.Hash, paths, destination, exportchan)
+
+	var nfiles, nerr int
+	fmt.Printf(":: Exporting files from revision %s (%s)\n", hash, gcommit.Date.Format("Jan 2 15:04:05 2006 (-0700)"))
+	for status := range exportchan {
+		if status.Err != nil {
+			if status.Filename == "" {
+				Die(status.Err)
+			}
+			fmt.Printf("Failed to export '%s': %s\n", status.Filename, status.Err.Error())
+			nerr++
+			continue
+		}
+		switch status.Type {
+		case "Git", "Annex", "Link":
+			nfiles++
+			fmt.Printf(" Exported '%s' to '%s'\n", status.Filename, status.Destination)
+		case "Tree":
+			fmt.Printf(" Created subdirectory '%s'\n", status.Destination)
+		}
+	}
+	fmt.Println()
+	fmt.Printf("%d files were exported to '%s'\n", nfiles, destination)
+	if nerr > 0 {
+		plural := ""
+		if nerr > 1 {
+			plural = "s"
+		}
+		dieWithCode(ExitPartial, fmt.Sprintf("%d operation%s failed", nerr, plural))
+	}
+}
+
+// ExportCmd sets up the 'export' subcommand
+func ExportCmd() *cobra.Command {
+	description := "Write a plain copy of the repository files to a new directory. Annexed files are replaced by their content, so the result can be used without git or git-annex. Content that is not available locally is downloaded from the server first.\n\nThe directory structure and filenames of the repository are preserved. By default, files are exported from the current version (HEAD). The destination must not exist or must be an empty directory."
+	args := map[string]string{
+		"<destination>": "Directory where the files will be written.",
+		"<filenames>":   "One or more directories or files to export. When no filenames are given, the entire repository is exported.",
+	}
+	examples := map[string]string{
+		"Export the current version of the repository to /data/plaincopy":                                   "$ gin export /data/plaincopy",
+		"Export the code/ directory as it was in the version with ID 918a06f to a directory called oldcode": "$ gin export --id 918a06f oldcode code",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "export [--id hash] <destination> [<filenames>]...",
+		Short:                 "Write a plain copy of the repository files to a directory",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.MinimumNArgs(1),
+		Run:                   export,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().String("id", "HEAD", "Commit `ID` (hash) of the version to export.")
+	return cmd
+}
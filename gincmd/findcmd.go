@@ -0,0 +1,107 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func find(cmd *cobra.Command, args []string) {
+	flags := cmd.Flags()
+	jsonout, _ := flags.GetBool("json")
+	mdexprs, _ := flags.GetStringArray("metadata")
+	inremotes, _ := flags.GetStringArray("in")
+	notinremotes, _ := flags.GetStringArray("not-in")
+	names, _ := flags.GetStringArray("name")
+
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	// match all annexed files, regardless of content location, and narrow
+	// down using the given criteria (all criteria must match)
+	matchargs := []string{"--include=*"}
+	for _, expr := range mdexprs {
+		if !strings.Contains(expr, "=") {
+			Die(fmt.Sprintf("invalid metadata expression %q: use the form field=value", expr))
+		}
+		matchargs = append(matchargs, fmt.Sprintf("--metadata=%s", expr))
+	}
+	for _, remote := range inremotes {
+		matchargs = append(matchargs, fmt.Sprintf("--in=%s", remote))
+	}
+	for _, remote := range notinremotes {
+		matchargs = append(matchargs, "--not", fmt.Sprintf("--in=%s", remote))
+	}
+	if len(names) > 0 {
+		// a file matches if it matches any of the name patterns
+		matchargs = append(matchargs, "-(")
+		for idx, name := range names {
+			if idx > 0 {
+				matchargs = append(matchargs, "--or")
+			}
+			matchargs = append(matchargs, fmt.Sprintf("--include=%s", name))
+		}
+		matchargs = append(matchargs, "-)")
+	}
+
+	results, err := git.AnnexFindMatching(matchargs, args)
+	CheckError(err)
+
+	if jsonout {
+		type findjson struct {
+			File string `json:"file"`
+			Key  string `json:"key"`
+			Size int64  `json:"size"`
+		}
+		files := make([]findjson, 0, len(results))
+		for _, res := range results {
+			size, _ := strconv.ParseInt(res.Bytesize, 10, 64)
+			files = append(files, findjson{File: res.File, Key: res.Key, Size: size})
+		}
+		j, _ := json.Marshal(files)
+		fmt.Println(string(j))
+		return
+	}
+	for _, res := range results {
+		fmt.Println(res.File)
+	}
+}
+
+// FindCmd sets up the 'find' subcommand
+func FindCmd() *cobra.Command {
+	description := "Find annexed files by their metadata, by the locations of their content, or by name. All criteria must match for a file to be listed. With no criteria, all annexed files are listed. With no arguments, files under the current directory are searched, recursively.\n\nMetadata values may contain wildcards (e.g., 'session=1*'). The special remote name 'here' refers to the local repository."
+	args := map[string]string{
+		"<filenames>": "One or more directories or files to search.",
+	}
+	examples := map[string]string{
+		"Find files with the metadata field 'species' set to 'mouse'":           "$ gin find --metadata species=mouse",
+		"Find files whose content has not been uploaded to the remote 'origin'": "$ gin find --not-in origin",
+		"Find '.nix' files in the 'recordings' directory with local content":    "$ gin find --in here --name '*.nix' recordings",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "find [--json] [--metadata <field>=<value>]... [--in <remote>]... [--not-in <remote>]... [--name <pattern>]... [<filenames>]...",
+		Short:                 "Find annexed files by metadata, content location, or name",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   find,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().StringArray("metadata", nil, "Match files whose metadata field has the given value, specified as `field=value`. Can be specified multiple times.")
+	cmd.Flags().StringArray("in", nil, "Match files whose content is available in the given `remote`. Can be specified multiple times.")
+	cmd.Flags().StringArray("not-in", nil, "Match files whose content is not available in the given `remote`. Can be specified multiple times.")
+	cmd.Flags().StringArray("name", nil, "Match files whose path matches the given glob `pattern`. Can be specified multiple times, in which case files matching any of the patterns are listed.")
+	return cmd
+}
@@ -1,11 +1,13 @@
 package gincmd
 
 import (
+	"context"
 	"fmt"
 	"strings"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
 	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/log"
 	"github.com/G-Node/gin-cli/git"
 	"github.com/spf13/cobra"
 )
@@ -23,41 +25,61 @@ func getRepo(cmd *cobra.Command, args []string) {
 	}
 	repostr := args[0]
 	gincl := ginclient.New(srvalias)
-	requirelogin(cmd, gincl, prStyle != psJSON)
+	public, _ := cmd.Flags().GetBool("public")
+	if err := gincl.LoadToken(); err != nil && !public {
+		// not logged in: public repositories can still be retrieved
+		log.Write("Not logged in; getting public repository")
+		public = true
+	}
 
 	if !isValidRepoPath(repostr) {
 		Die(fmt.Sprintf("Invalid repository path '%s'. Full repository name should be the owner's username followed by the repository name, separated by a '/'.\nType 'gin help get' for information and examples.", repostr))
 	}
 
+	opts := cloneOptions(cmd, conf)
+	opts.Public = public
 	clonechan := make(chan git.RepoFileStatus)
-	go gincl.CloneRepo(repostr, clonechan)
+	go gincl.CloneRepo(context.Background(), repostr, opts, clonechan)
 	formatOutput(clonechan, prStyle, 0)
 	defaultRemoteIfUnset("origin")
-	new, err := ginclient.CommitIfNew()
-	if new {
-		// Push the new commit to initialise origin
-		uploadchan := make(chan git.RepoFileStatus)
-		go gincl.Upload(nil, []string{"origin"}, uploadchan)
-		for range uploadchan {
-			// Wait for channel to close
-		}
+	runHook("get", append(hookEnv("get", []string{"origin"}, nil), fmt.Sprintf("GIN_REPOSITORY=%s", repostr)))
+}
+
+// cloneOptions returns the options for cloning a repository from the flags of the command (--force, --public, --https, --compression, and --pack-threads) and the clone configuration.
+// Flags that are not defined for the command are not set.
+func cloneOptions(cmd *cobra.Command, conf config.GinCliCfg) ginclient.CloneOptions {
+	flags := cmd.Flags()
+	force, _ := flags.GetBool("force")
+	public, _ := flags.GetBool("public")
+	https, _ := flags.GetBool("https")
+	pack := git.PackOptions{Compression: conf.Clone.Compression, Threads: conf.Clone.PackThreads}
+	if flags.Changed("compression") {
+		pack.Compression, _ = flags.GetInt("compression")
+	}
+	if flags.Changed("pack-threads") {
+		pack.Threads, _ = flags.GetInt("pack-threads")
+	}
+	if pack.Compression > 9 || pack.Threads < 0 {
+		usageDie(cmd)
 	}
-	CheckError(err)
+	return ginclient.CloneOptions{Force: force, Public: public, HTTPS: https, Pack: &pack}
 }
 
 // GetCmd sets up the 'get' repository subcommand
 func GetCmd() *cobra.Command {
-	description := "Download a remote repository to a new directory and initialise the directory with the default options. The local directory is referred to as the 'clone' of the repository."
+	description := "Download a remote repository to a new directory and initialise the directory with the default options. The local directory is referred to as the 'clone' of the repository.\n\nPublic repositories can be retrieved without logging in. When you are not logged in, or when the --public flag is specified, the repository is downloaded over its public (HTTPS) address, which requires no account or ssh key. Changes to a repository retrieved this way cannot be uploaded. Private repositories always require logging in.\n\nRepositories are downloaded over ssh by default. If your network blocks ssh connections, use the --https flag to download the repository over the HTTPS address of the server, using your login credentials. The HTTPS address is then also used for all uploads and downloads in the new clone. To always use HTTPS for a server, set the 'servers.<alias>.git.https' configuration option to true.\n\nOn machines with little CPU power, cloning large repositories can be sped up by lowering the compression level git uses for the objects it writes (--compression, from 0 for no compression to 9 for the best compression) or limiting the number of threads that process the received data (--pack-threads). By default, the defaults of git are used: compression level 1 for loose objects, the default zlib level for packs, and one thread per CPU. The defaults can be changed with the 'clone.compression' and 'clone.packthreads' configuration options. The compression of the data sent by the server is determined by the server."
 	args := map[string]string{
 		"<repopath>": "The repository path must be specified on the command line. A repository path is the owner's username, followed by a \"/\" and the repository name.",
 	}
 	examples := map[string]string{
 		"Get and initialise the repository named 'example' owned by user 'alice'": "$ gin get alice/example",
 		"Get and initialise the repository named 'eegdata' owned by user 'peter'": "$ gin get peter/eegdata",
+		"Resume a clone of 'peter/eegdata' that was interrupted":                  "$ gin get --force peter/eegdata",
+		"Get a large repository on a slow machine without compressing objects":    "$ gin get --compression 0 --pack-threads 2 peter/eegdata",
 	}
 	var cmd = &cobra.Command{
 		// Use:                   "get [--json | --verbose] <repopath>",
-		Use:                   "get [--json] <repopath>",
+		Use:                   "get [--json] [--force] [--public | --https] [--compression <level>] [--pack-threads <n>] <repopath>",
 		Short:                 "Retrieve (clone) a repository from the remote server",
 		Long:                  formatdesc(description, args),
 		Example:               formatexamples(examples),
@@ -67,6 +89,11 @@ func GetCmd() *cobra.Command {
 	}
 	cmd.Flags().Bool("json", false, jsonHelpMsg)
 	// cmd.Flags().Bool("verbose", false, verboseHelpMsg)
+	cmd.Flags().Bool("force", false, "Continue if the destination directory already exists and is not empty. If the directory is a clone of the same repository, the clone is resumed and updated.")
+	cmd.Flags().Bool("https", false, "Download the repository over HTTPS instead of ssh, using your login credentials.")
+	cmd.Flags().Bool("public", false, "Retrieve a public repository over its public address, without using login credentials.")
 	cmd.Flags().String("server", "", "Specify server `alias` for the repository. See also 'gin servers'.")
+	cmd.Flags().Int("compression", -1, "Compress the objects written during the clone with the zlib compression `level` (0-9; -1 uses the default of git).")
+	cmd.Flags().Int("pack-threads", 0, "Use `n` threads to process the received data (0 uses one thread per CPU).")
 	return cmd
 }
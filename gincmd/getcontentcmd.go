@@ -11,6 +11,11 @@ import (
 )
 
 func getContent(cmd *cobra.Command, args []string) {
+	downloadContent(cmd, argsWithPathsFrom(cmd, args))
+}
+
+// downloadContent downloads the content of the given files and returns a summary of the transfer.
+func downloadContent(cmd *cobra.Command, args []string) *transferSummary {
 	prStyle := determinePrintStyle(cmd)
 	conf := config.Read()
 	// TODO: no need for client; use remotes (and all keys?)
@@ -28,20 +33,25 @@ func getContent(cmd *cobra.Command, args []string) {
 	if prStyle == psDefault {
 		fmt.Println(":: Downloading file content")
 	}
+	paths := changeToRepoRoot(args, true)
+	if force, _ := cmd.Flags().GetBool("force"); !force {
+		CheckError(ginclient.CheckFreeSpace(paths))
+	}
+	verify, _ := cmd.Flags().GetBool("verify")
 	getcchan := make(chan git.RepoFileStatus)
-	go gincl.GetContent(args, getcchan)
-	formatOutput(getcchan, prStyle, 0)
+	go gincl.GetContent(operationContext(cmd), paths, ginclient.GetContentOptions{Verify: verify}, getcchan)
+	return formatTransferOutput(getcchan, prStyle)
 }
 
 // GetContentCmd sets up the 'get-content' subcommand
 func GetContentCmd() *cobra.Command {
-	description := "Download the content of the listed files. The get-content command is intended to be used to retrieve the content of placeholder files in a local repository. This command must be called from within the local repository clone. With no arguments, downloads the content for all files under the working directory, recursively."
+	description := "Download the content of the listed files. The get-content command is intended to be used to retrieve the content of placeholder files in a local repository. This command must be called from within the local repository clone. With no arguments, downloads the content for all files under the working directory, recursively.\n\nFiles whose content is already available locally are skipped, so an interrupted download can be resumed by running the command again. With --verify, the integrity of the content that is already available is checked and any content that fails the check is downloaded again.\n\nBefore downloading, the size of the content is compared with the free space on the disk and the command stops if there is not enough space. Use --force to download anyway.\n\nIf the download of a file fails, the remaining files are still downloaded and the failures are reported at the end (--keep-going, the default). Use --fail-fast to stop the whole download at the first failure instead. In both cases, the command exits with a non-zero status if any file failed.\n\nLong lists of files can be read from a file (or standard input) with --paths-from instead of being specified as arguments. The file should contain one path per line, or paths separated by NUL characters if --null (-z) is specified. The paths are added to any paths specified as arguments."
 	args := map[string]string{
 		"<filenames>": "One or more directories or files to download.",
 	}
 	var cmd = &cobra.Command{
 		// Use:                   "get-content [--json | --verbose] [<filenames>]...",
-		Use:                   "get-content [--json] [<filenames>]...",
+		Use:                   "get-content [--json] [--verify] [--force] [--keep-going | --fail-fast] [--paths-from <file> [-z]] [<filenames>]...",
 		Short:                 "Download the content of files from a remote repository",
 		Long:                  formatdesc(description, args),
 		Args:                  cobra.ArbitraryArgs,
@@ -51,5 +61,11 @@ func GetContentCmd() *cobra.Command {
 	}
 	cmd.Flags().Bool("json", false, jsonHelpMsg)
 	// cmd.Flags().Bool("verbose", false, verboseHelpMsg)
+	cmd.Flags().String("paths-from", "", pathsFromHelpMsg)
+	cmd.Flags().BoolP("null", "z", false, nullHelpMsg)
+	cmd.Flags().Bool("force", false, "Download even if the content does not fit in the free disk space.")
+	cmd.Flags().Bool("verify", false, "Verify the content of files that are already available locally and download any content that fails verification again.")
+	cmd.Flags().Bool("keep-going", true, keepGoingHelpMsg)
+	cmd.Flags().Bool("fail-fast", false, failFastHelpMsg)
 	return cmd
 }
@@ -0,0 +1,70 @@
+package gincmd
+
+import (
+	"fmt"
+	"strings"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func group(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	add, _ := cmd.Flags().GetStringSlice("add")
+	remove, _ := cmd.Flags().GetStringSlice("remove")
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	repository := args[0]
+	if len(add) > 0 || len(remove) > 0 {
+		CheckError(ginclient.SetGroups(repository, add, remove))
+	}
+	groups, err := ginclient.Groups(repository)
+	CheckError(err)
+	if len(add) == 0 && len(remove) == 0 {
+		for _, g := range groups {
+			fmt.Println(g)
+		}
+		return
+	}
+	if prStyle.showHeaders() {
+		fmt.Printf(":: Groups of %s: %s\n", repository, strings.Join(groups, ", "))
+	}
+}
+
+// GroupCmd sets up the 'annex-group' subcommand
+func GroupCmd() *cobra.Command {
+	description := `Show or change the groups that a remote or the local repository belongs to (the git-annex 'group' setting). Groups can be used in preferred content expressions (see 'annex-wanted'), e.g., 'inallgroup=backup' to want the content that all repositories in the group 'backup' have. Some groups, such as 'archive' and 'backup', have standard preferred content settings that are used when the expression of a repository is 'standard'.
+
+The setting is recorded in the git-annex branch, so it is shared with other clones of the repository after it is uploaded.
+
+With no flags, prints the groups of the repository, one per line.`
+	args := map[string]string{
+		"<remote>": "The name of a remote, or 'here' for the local repository.",
+	}
+	examples := map[string]string{
+		"Add the remote 'labstore' to the groups 'backup' and 'raw'": "$ gin annex-group labstore --add backup,raw",
+		"Remove the local repository from the group 'raw'":           "$ gin annex-group here --remove raw",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "annex-group <remote> [--add <group>]... [--remove <group>]...",
+		Short:                 "Show or change the groups of a remote",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ExactArgs(1),
+		Run:                   group,
+		Aliases:               []string{"group"},
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().StringSlice("add", nil, "Add the repository to the `group`. Supports multiple groups, either by specifying multiple times or as a comma separated list.")
+	cmd.Flags().StringSlice("remove", nil, "Remove the repository from the `group`. Supports multiple groups, either by specifying multiple times or as a comma separated list.")
+	return cmd
+}
@@ -0,0 +1,75 @@
+package gincmd
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/git"
+)
+
+// hookCommand returns the configured hook command for the given operation.
+func hookCommand(operation string) string {
+	hooks := config.Read().Hooks
+	switch operation {
+	case "upload":
+		return hooks.Upload
+	case "download":
+		return hooks.Download
+	case "get":
+		return hooks.Get
+	}
+	return ""
+}
+
+// hookEnv builds the environment variables that describe an operation for a hook.
+// The summary may be nil for operations that don't transfer file content.
+func hookEnv(operation string, remotes []string, summary *transferSummary) []string {
+	env := []string{
+		fmt.Sprintf("GIN_HOOK=%s", operation),
+		fmt.Sprintf("GIN_REMOTES=%s", strings.Join(remotes, ",")),
+	}
+	if reporoot, err := git.FindRepoRoot("."); err == nil {
+		env = append(env, fmt.Sprintf("GIN_REPO_PATH=%s", reporoot))
+	}
+	if summary != nil {
+		env = append(env,
+			fmt.Sprintf("GIN_FILES=%d", summary.nfiles()),
+			fmt.Sprintf("GIN_BYTES=%d", summary.nbytes()),
+			fmt.Sprintf("GIN_DURATION=%.3f", summary.duration().Seconds()),
+		)
+	}
+	return env
+}
+
+// runHook runs the hook configured for the given operation (hooks.<operation> in the user configuration), if any.
+// The hook runs in the system shell with the repository root as the working directory.
+// The environment variables in 'env' are added to the environment of the hook.
+// The output of the hook is logged. A failing hook prints a warning but does not fail the operation.
+func runHook(operation string, env []string) {
+	hookcmd := hookCommand(operation)
+	if hookcmd == "" {
+		return
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", hookcmd)
+	} else {
+		cmd = exec.Command("sh", "-c", hookcmd)
+	}
+	cmd.Env = append(os.Environ(), env...)
+	if reporoot, err := git.FindRepoRoot("."); err == nil {
+		cmd.Dir = reporoot
+	}
+	log.Write("Running %s hook: %s", operation, hookcmd)
+	output, err := cmd.CombinedOutput()
+	log.Write("Hook output:\n%s", string(output))
+	if err != nil {
+		log.Write("Hook failed: %v", err)
+		Warn(fmt.Sprintf("%s hook failed: %v", operation, err))
+	}
+}
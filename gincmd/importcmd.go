@@ -0,0 +1,97 @@
+package gincmd
+
+import (
+	"fmt"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func importdir(cmd *cobra.Command, args []string) {
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Warn(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	move, _ := cmd.Flags().GetBool("move")
+	overwrite, _ := cmd.Flags().GetBool("overwrite")
+	source := args[0]
+	destination := "."
+	if len(args) > 1 {
+		destination = args[1]
+	}
+	// fail early if the destination is outside the repository
+	_, _, err := ginclient.RepoRootPaths([]string{destination})
+	CheckError(err)
+
+	opts := ginclient.ImportOptions{Move: move, Overwrite: overwrite}
+	impchan := make(chan ginclient.FileImportStatus)
+	go ginclient.ImportFiles(source, destination, opts, impchan)
+
+	verb := "Copied"
+	if move {
+		verb = "Moved"
+	}
+	var nfiles, nskipped, nerr int
+	fmt.Printf(":: Importing files from '%s'\n", source)
+	for status := range impchan {
+		if status.Err != nil {
+			if status.Filename == "" {
+				Die(status.Err)
+			}
+			fmt.Printf("Failed to import '%s': %s\n", status.Filename, status.Err.Error())
+			nerr++
+			continue
+		}
+		if status.Skipped {
+			fmt.Printf(" Skipped '%s': '%s' already exists\n", status.Filename, status.Destination)
+			nskipped++
+			continue
+		}
+		nfiles++
+		fmt.Printf(" %s '%s' to '%s'\n", verb, status.Filename, status.Destination)
+	}
+	fmt.Printf("%d files imported, %d skipped\n", nfiles, nskipped)
+	if nerr > 0 {
+		plural := ""
+		if nerr > 1 {
+			plural = "s"
+		}
+		dieWithCode(ExitPartial, fmt.Sprintf("%d operation%s failed; no changes were recorded", nerr, plural))
+	}
+	if nfiles > 0 {
+		commit(cmd, []string{destination})
+	}
+}
+
+// ImportCmd sets up the 'import' subcommand
+func ImportCmd() *cobra.Command {
+	description := "Copy the contents of an existing directory into the repository, preserving its directory structure, and record the new files. This is a convenient way to add an existing dataset to a repository in one step. The changes are recorded locally and can be uploaded with the 'upload' command.\n\nFiles that already exist in the destination are skipped unless the --overwrite option is given. With --move, the files are moved into the repository instead of being copied, and the emptied source directories are removed. Git repository directories ('.git') in the source are not imported."
+	args := map[string]string{
+		"<source>":      "The directory to import.",
+		"<destination>": "The directory in the repository where the files will be placed. Defaults to the current directory.",
+	}
+	examples := map[string]string{
+		"Copy the contents of /data/recordings into the recordings/ directory of the repository": "$ gin import /data/recordings recordings",
+		"Move the contents of ~/Downloads/sessions into the current directory":                   "$ gin import --move ~/Downloads/sessions",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "import [--move] [--overwrite] [--message message] <source> [<destination>]",
+		Short:                 "Copy an existing directory into the repository and record the new files",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.RangeArgs(1, 2),
+		Run:                   importdir,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("move", false, "Move the files into the repository instead of copying them.")
+	cmd.Flags().Bool("overwrite", false, "Overwrite files that already exist in the destination instead of skipping them.")
+	cmd.Flags().StringP("message", "m", "", "Commit message")
+	return cmd
+}
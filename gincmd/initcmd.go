@@ -1,31 +1,72 @@
 package gincmd
 
 import (
+	"context"
 	"fmt"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
 
 func initRepo(cmd *cobra.Command, args []string) {
+	origin, _ := cmd.Flags().GetString("origin")
+	noannex, _ := cmd.Flags().GetBool("no-annex")
+	if origin != "" {
+		if noannex {
+			usageDie(cmd)
+		}
+		initWithOrigin(cmd, origin)
+		return
+	}
 	gincl := ginclient.New("")
 	fmt.Print(":: Initialising local storage ")
-	err := gincl.InitDir(false)
+	var err error
+	if noannex {
+		err = gincl.InitDirNoAnnex()
+	} else {
+		err = gincl.InitDir(false)
+	}
 	CheckError(err)
 	fmt.Fprintln(color.Output, green("OK"))
 }
 
+func initWithOrigin(cmd *cobra.Command, repopath string) {
+	if !isValidRepoPath(repopath) {
+		Die(fmt.Sprintf("Invalid repository path '%s'. Full repository name should be the owner's username followed by the repository name, separated by a '/'.", repopath))
+	}
+	srvalias, _ := cmd.Flags().GetString("server")
+	if srvalias == "" {
+		srvalias = config.Read().DefaultServer
+	}
+	gincl := ginclient.New(srvalias)
+	requirelogin(cmd, gincl, true)
+	fmt.Printf(":: Initialising local storage and linking to '%s' ", repopath)
+	err := gincl.InitWithOrigin(context.Background(), repopath)
+	CheckError(err)
+	defaultRemoteIfUnset("origin")
+	fmt.Fprintln(color.Output, green("OK"))
+}
+
 // InitCmd sets up the 'init' repository subcommand
 func InitCmd() *cobra.Command {
-	description := "Initialise a local repository in the current directory with the default options."
+	description := "Initialise a local repository in the current directory with the default options.\n\nWith --origin, the local repository is linked to an existing repository on the server, which is added as the remote 'origin'. If the repository on the server has files, they are added to the directory without changing any existing files, so that the existing files appear as local changes that can be uploaded. If the repository on the server is empty, it is initialised with the local repository. The directory must not already be a repository with a different origin.\n\nWith --no-annex, the repository is initialised without git-annex. All files are added to git, which is suitable for repositories of small (text) files. Commands that manage annexed content (e.g., get-content, remove-content) are not useful in such a repository."
+	examples := map[string]string{
+		"Initialise the current directory and link it to the repository 'alice/mydata' on the server": "$ gin init --origin alice/mydata",
+		"Initialise the current directory as a plain git repository":                                  "$ gin init --no-annex",
+	}
 	var cmd = &cobra.Command{
-		Use:                   "init",
+		Use:                   "init [--origin <repopath> [--server <alias>] | --no-annex]",
 		Short:                 "Initialise the current directory as a gin repository",
 		Long:                  formatdesc(description, nil),
+		Example:               formatexamples(examples),
 		Args:                  cobra.NoArgs,
 		Run:                   initRepo,
 		DisableFlagsInUseLine: true,
 	}
+	cmd.Flags().String("origin", "", "Link the local repository to the existing repository at `repopath` (e.g., alice/mydata) on the server.")
+	cmd.Flags().String("server", "", "Specify server `alias` of the repository given with --origin. See also 'gin servers'.")
+	cmd.Flags().Bool("no-annex", false, "Initialise the repository without git-annex and add all files to git.")
 	return cmd
 }
@@ -0,0 +1,71 @@
+package gincmd
+
+import (
+	"fmt"
+
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func largefiles(cmd *cobra.Command, args []string) {
+	unset, _ := cmd.Flags().GetBool("unset")
+	if unset && len(args) > 0 {
+		usageDie(cmd)
+	}
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	if unset {
+		CheckError(git.UnsetLargefiles())
+		fmt.Printf(":: Largefiles expression removed; using configured filters: %s\n", git.ConfigLargefiles())
+		return
+	}
+
+	if len(args) == 1 {
+		CheckError(git.SetLargefiles(args[0]))
+		fmt.Printf(":: Largefiles expression set to: %s\n", args[0])
+		return
+	}
+
+	expression, err := git.GetLargefiles()
+	CheckError(err)
+	if expression == "" {
+		fmt.Printf("%s (configured filters)\n", git.ConfigLargefiles())
+		return
+	}
+	fmt.Println(expression)
+}
+
+// LargefilesCmd sets up the 'largefiles' subcommand
+func LargefilesCmd() *cobra.Command {
+	description := `Show or set the expression that decides which files are added to the annex and which are added to git (the git-annex 'annex.largefiles' setting). Files that match the expression are added to the annex; all other files are added to git. The expression is checked with git-annex before it is set. The file 'config.yml' is never added to the annex.
+
+The expression is stored in the repository and applies to all clones. When set, it replaces the filters in the client configuration (annex.minsize and annex.exclude). See the git-annex documentation for the expression syntax (e.g., 'largerthan=10mb', 'include=*.dat', 'mimetype=text/*', combined with 'and', 'or', 'not', and parentheses).
+
+With no arguments, prints the current expression.`
+	args := map[string]string{
+		"<expression>": "The largefiles expression to set. Should be quoted to be passed as a single argument.",
+	}
+	examples := map[string]string{
+		"Annex files larger than 10 MB except text files": `$ gin largefiles "largerthan=10mb and not include=*.txt"`,
+		"Return to the configured filters":                "$ gin largefiles --unset",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "largefiles [<expression> | --unset]",
+		Short:                 "Show or set the expression that decides which files are added to the annex",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.MaximumNArgs(1),
+		Run:                   largefiles,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("unset", false, "Remove the expression and use the filters in the client configuration.")
+	return cmd
+}
@@ -20,7 +20,7 @@ func lock(cmd *cobra.Command, args []string) {
 	case git.UpgradeRequired:
 		annexVersionNotice()
 	}
-	if prStyle != psJSON {
+	if prStyle.showHeaders() {
 		fmt.Println(":: Locking files")
 	}
 	// lock should do nothing in direct mode
@@ -33,6 +33,7 @@ func lock(cmd *cobra.Command, args []string) {
 	// TODO: need server config? Just use remotes
 	conf := config.Read()
 	gincl := ginclient.New(conf.DefaultServer)
+	args = changeToRepoRoot(args, false)
 	nitems := countItemsLockChange(args)
 	lockchan := make(chan git.RepoFileStatus)
 
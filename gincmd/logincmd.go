@@ -1,18 +1,24 @@
 package gincmd
 
 import (
+	"bufio"
+	"encoding/json"
 	"fmt"
+	"io"
+	"os"
+	"strings"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
 	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/docker/docker/pkg/term"
+	"github.com/fatih/color"
+	gogs "github.com/gogits/go-gogs-client"
 	"github.com/howeyc/gopass"
 	"github.com/spf13/cobra"
 )
 
 // login requests credentials, performs login with auth server, and stores the token.
 func login(cmd *cobra.Command, args []string) {
-	var username, password string
-
 	flags := cmd.Flags()
 	srvalias, _ := flags.GetString("server")
 
@@ -20,9 +26,64 @@ func login(cmd *cobra.Command, args []string) {
 	if srvalias == "" {
 		srvalias = conf.DefaultServer
 	}
+	if list, _ := flags.GetBool("list"); list {
+		if flags.Changed("token") || flags.Changed("ssh-key") {
+			usageDie(cmd)
+		}
+		listCredentials(cmd, srvalias, args)
+		return
+	}
 	fmt.Printf("Logging into %s\n", srvalias)
 
+	gincl := ginclient.New(srvalias)
+	if keyfile, _ := flags.GetString("ssh-key"); keyfile != "" {
+		// the key file is only stored in the configuration if the login succeeds
+		err := gincl.SetKeyFile(keyfile)
+		CheckError(err)
+	}
+
+	if flags.Changed("token") {
+		token, _ := flags.GetString("token")
+		genkey, _ := flags.GetBool("gen-key")
+		// a key file specified on the command line should always be registered
+		genkey = genkey || flags.Changed("ssh-key")
+		loginWithToken(gincl, srvalias, token, genkey, args)
+		return
+	}
+	if gincl.HTTPAuth != nil {
+		// the Authorization header holds the credentials of the HTTP authentication layer, so the password can't be sent to the server
+		pwstdin, _ := flags.GetBool("password-stdin")
+		if pwstdin || os.Getenv(passwordEnvVar) != "" || !term.IsTerminal(os.Stdin.Fd()) {
+			Die("cannot log in with a password through the HTTP authentication layer of the server: log in with --token")
+		}
+		fmt.Printf("The server is behind an HTTP authentication layer, which does not allow logging in with a password.\nCreate an access token in the settings of your account on the web interface [%s] and enter it below.\n", gincl.WebAddress())
+		// like a password login, a key is created unless one is specified
+		loginWithToken(gincl, srvalias, promptSecret("Access token"), true, args)
+		return
+	}
+
+	username, password := readCredentials(cmd, args)
+	err := gincl.Login(username, password, "gin-cli")
+	CheckError(err)
+	info, err := gincl.RequestAccount(username)
+	CheckError(err)
+	name := info.FullName
+	if name == "" {
+		name = info.UserName
+	}
+	fmt.Printf(":: Welcome %s\n", name)
+	fmt.Printf(":: Successfully logged into %s [%s]\n", srvalias, gincl.WebAddress())
+}
+
+// readCredentials returns the username from the command line arguments (or a prompt) and the password from the prompt, stdin, or the environment.
+func readCredentials(cmd *cobra.Command, args []string) (username, password string) {
+	pwstdin, _ := cmd.Flags().GetBool("password-stdin")
+	envpassword := os.Getenv(passwordEnvVar)
+	interactive := term.IsTerminal(os.Stdin.Fd())
 	if len(args) == 0 {
+		if pwstdin || !interactive {
+			Die("cannot prompt for username: specify the username on the command line")
+		}
 		// prompt for login
 		fmt.Print("Login: ")
 		fmt.Scanln(&username)
@@ -30,9 +91,99 @@ func login(cmd *cobra.Command, args []string) {
 		username = args[0]
 	}
 
-	// prompt for password
-	fmt.Print("Password: ")
+	if pwstdin {
+		password = readSecretStdin("password")
+	} else if envpassword != "" {
+		password = envpassword
+	} else if !interactive {
+		Die(fmt.Sprintf("cannot prompt for password: standard input is not a terminal (%s)", noTTYHint))
+	} else {
+		password = promptPassword()
+	}
+	if password == "" {
+		Die("No password provided. Aborting.")
+	}
+	return
+}
+
+// listedToken is an access token as shown by 'login --list', with the token secret masked.
+type listedToken struct {
+	Name   string `json:"name"`
+	Token  string `json:"token"`
+	Client bool   `json:"client"`
+}
+
+// maskToken hides all but the first four characters of a token.
+func maskToken(token string) string {
+	if len(token) <= 4 {
+		return strings.Repeat("*", len(token))
+	}
+	return token[:4] + strings.Repeat("*", len(token)-4)
+}
+
+// listCredentials prints the access tokens and ssh keys registered to a user account.
+// Listing tokens requires the user's password, even when logged in.
+func listCredentials(cmd *cobra.Command, srvalias string, args []string) {
+	jsonout, _ := cmd.Flags().GetBool("json")
+	username, password := readCredentials(cmd, args)
+	gincl := ginclient.New(srvalias)
+	tokens, err := gincl.GetTokens(username, password)
+	CheckError(err)
+	keys, err := gincl.GetUserKeysBasicAuth(username, password)
+	CheckError(err)
+
+	// mark the token used by this client, if logged in as the same user
+	var clienttoken string
+	if gincl.LoadToken() == nil && gincl.Username == username {
+		clienttoken = gincl.Token
+	}
+	listed := make([]listedToken, len(tokens))
+	for idx, token := range tokens {
+		listed[idx] = listedToken{Name: token.Name, Token: maskToken(token.Sha1), Client: token.Sha1 == clienttoken}
+	}
+
+	if jsonout {
+		j, _ := json.Marshal(struct {
+			Tokens []listedToken    `json:"tokens"`
+			Keys   []gogs.PublicKey `json:"keys"`
+		}{listed, keys})
+		fmt.Println(string(j))
+		return
+	}
+	fmt.Fprintf(color.Output, "%s\n", green(fmt.Sprintf("Access tokens (%d)", len(listed))))
+	for _, token := range listed {
+		var note string
+		if token.Client {
+			note = " (this client)"
+		}
+		fmt.Printf("  %-24s %s%s\n", token.Name, token.Token, note)
+	}
+	fmt.Fprintf(color.Output, "\n%s\n", green(fmt.Sprintf("SSH keys (%d)", len(keys))))
+	for idx, key := range keys {
+		fmt.Printf("  [%v] \"%s\"\n", idx+1, key.Title)
+	}
+	fmt.Println("\nKeys can be removed with 'gin keys --delete <number>'. Access tokens can be revoked in the account settings of the web interface.")
+}
+
+// passwordEnvVar is the environment variable that is read for the password when logging in without a prompt.
+const passwordEnvVar = "GIN_PASSWORD"
+
+// noTTYHint describes the options for logging in when no terminal is available for prompts.
+const noTTYHint = "use --password-stdin, set the " + passwordEnvVar + " environment variable, or log in with --token"
+
+// promptPassword prompts for a password on the terminal without echoing the input.
+// The terminal state is restored even if the program is interrupted during the prompt.
+func promptPassword() string {
+	return promptSecret("Password")
+}
+
+// promptSecret prompts for a secret value with the given label on the terminal without echoing the input.
+// The terminal state is restored even if the program is interrupted during the prompt.
+func promptSecret(label string) string {
+	fmt.Printf("%s: ", label)
+	saveTerminal()
 	pwbytes, err := gopass.GetPasswdMasked()
+	restoreTerminal()
 	fmt.Println()
 	if err != nil {
 		// read error or gopass.ErrInterrupted
@@ -44,30 +195,48 @@ func login(cmd *cobra.Command, args []string) {
 		}
 		Die(err)
 	}
+	return string(pwbytes)
+}
+
+// readSecretStdin reads the first line from stdin and returns it without surrounding whitespace.
+// The name of the secret is used in error messages.
+func readSecretStdin(name string) string {
+	reader := bufio.NewReader(os.Stdin)
+	line, err := reader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		Die(fmt.Sprintf("failed to read %s from stdin: %s", name, err))
+	}
+	return strings.TrimSpace(line)
+}
 
-	password = string(pwbytes)
-	if password == "" {
-		Die("No password provided. Aborting.")
+// loginWithToken performs a login using an access token, provided with the --token flag or at the prompt for servers behind an HTTP authentication layer.
+// If the token is '-', it is read from stdin.
+// If genkey is true, an ssh key is created and registered (or the key file set on the client is registered).
+func loginWithToken(gincl *ginclient.Client, srvalias, token string, genkey bool, args []string) {
+	if token == "-" {
+		token = readSecretStdin("token")
+	}
+	token = strings.TrimSpace(token)
+	if token == "" {
+		Die("No token provided. Aborting.")
 	}
 
-	gincl := ginclient.New(srvalias)
-	err = gincl.Login(username, password, "gin-cli")
-	CheckError(err)
-	info, err := gincl.RequestAccount(username)
-	CheckError(err)
-	name := info.FullName
-	if name == "" {
-		name = info.UserName
+	var username string
+	if len(args) > 0 {
+		username = args[0]
 	}
-	fmt.Printf(":: Welcome %s\n", name)
+
+	err := gincl.LoginWithToken(username, token, genkey)
+	CheckError(err)
+	fmt.Printf(":: Welcome %s\n", gincl.Username)
 	fmt.Printf(":: Successfully logged into %s [%s]\n", srvalias, gincl.WebAddress())
 }
 
 // LoginCmd sets up the 'login' subcommand
 func LoginCmd() *cobra.Command {
-	description := "Login to the GIN services.\n\nIf no username is specified on the command line, you will be prompted for it. The login command always prompts for a password."
+	description := "Login to the GIN services.\n\nIf no username is specified on the command line, you will be prompted for it. The login command prompts for a password, unless an access token is provided with the --token flag.\n\nIf no terminal is available for the password prompt (e.g., in scripts), the password can be read from the first line of the standard input with --password-stdin or from the GIN_PASSWORD environment variable. The username must then be specified on the command line.\n\nLogging in with a token is useful for non-interactive environments (e.g., continuous integration), where a long-lived token can be created in the web interface of the server and provided to the client. The token is checked with the server before it is stored. If a username is specified along with a token, it must match the owner of the token. By default, no ssh key is created when logging in with a token; use --gen-key to create one.\n\nOn login, a new ssh key pair is created for accessing the server's repositories and the public key is added to your account. If you prefer to use an existing key, specify the private key file with --ssh-key. The public key is read from the file with the same name and the extension '.pub' and added to your account; the key files are never modified or deleted. The key file is stored in the configuration (servers.<alias>.git.keyfile) and is used for all subsequent logins to the server.\n\nLogins are stored separately for each configured server, so you can be logged in to multiple servers at the same time. Use the --server flag to log in to a server other than the default. The 'gin servers' command shows which servers you are logged in to.\n\nWith --list, the access tokens and ssh keys registered to the account are listed instead of logging in, so that they can be reviewed and revoked if necessary. The token secrets are masked. The token used by this client is marked. Listing the tokens requires the password, even if you are already logged in.\n\nIf the server is behind an additional HTTP authentication layer (see the 'authuser' and 'netrc' server options), the password cannot be sent to the server. The login command then prompts for an access token instead, which can be created in the web interface, and --list is not available."
 	var cmd = &cobra.Command{
-		Use:                   "login [<username>]",
+		Use:                   "login [--token <token> [--gen-key] | --password-stdin] [--ssh-key <keyfile>] [--list [--json]] [<username>]",
 		Short:                 "Login to the GIN services",
 		Long:                  formatdesc(description, nil),
 		Args:                  cobra.MaximumNArgs(1),
@@ -75,5 +244,11 @@ func LoginCmd() *cobra.Command {
 		DisableFlagsInUseLine: true,
 	}
 	cmd.Flags().String("server", "", "Specify server `alias` to log into. See also 'gin servers'.")
+	cmd.Flags().String("token", "", "Log in using an existing access `token` instead of a password. Use '-' to read the token from stdin.")
+	cmd.Flags().String("ssh-key", "", "Use the existing private key in `keyfile` for accessing repositories instead of generating a new key.")
+	cmd.Flags().Bool("password-stdin", false, "Read the password from the first line of stdin instead of prompting for it.")
+	cmd.Flags().Bool("gen-key", false, "Create and register an ssh key for the session when logging in with --token.")
+	cmd.Flags().Bool("list", false, "List the access tokens and ssh keys of the account instead of logging in (requires the password).")
+	cmd.Flags().Bool("json", false, "Print the list of tokens and keys in JSON format.")
 	return cmd
 }
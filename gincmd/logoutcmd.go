@@ -34,11 +34,11 @@ func LogoutCmd() *cobra.Command {
 	var cmd = &cobra.Command{
 		Use:                   "logout",
 		Short:                 "Logout of the GIN services",
-		Long:                  "Logout of the GIN services.\n\nThis command takes no arguments.",
+		Long:                  "Logout of the GIN services.\n\nOnly the login for the default server, or the server specified with --server, is removed. Logins to other servers are not affected.\n\nThis command takes no arguments.",
 		Args:                  cobra.NoArgs,
 		Run:                   logout,
 		DisableFlagsInUseLine: true,
 	}
-	cmd.Flags().String("server", "", "Specify server `alias` where the repository will be created. See also 'gin servers'.")
+	cmd.Flags().String("server", "", "Specify server `alias` to log out from. See also 'gin servers'.")
 	return cmd
 }
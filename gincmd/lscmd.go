@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"sort"
+	"path/filepath"
 	"strings"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/log"
 	"github.com/G-Node/gin-cli/gincmd/ginerrors"
 	"github.com/G-Node/gin-cli/git"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
 )
@@ -23,57 +25,185 @@ func lsRepo(cmd *cobra.Command, args []string) {
 	}
 
 	flags := cmd.Flags()
-	if flags.NFlag() > 1 {
-		usageDie(cmd)
-	}
 	jsonout, _ := flags.GetBool("json")
 	short, _ := flags.GetBool("short")
+	nullsep, _ := flags.GetBool("null")
+	tree, _ := flags.GetBool("tree")
+	if jsonout && (short || nullsep) || tree && (jsonout || short || nullsep) {
+		usageDie(cmd)
+	}
+	if nullsep {
+		// NUL-terminated output is only available in short form
+		short = true
+	}
+	since, _ := flags.GetString("modified-since")
+	showsize, _ := flags.GetBool("size")
+	onlyunlocked, _ := flags.GetBool("unlocked")
+	statusfilter, _ := flags.GetString("status")
+	changedonly, _ := flags.GetBool("changed")
+	if changedonly && statusfilter != "" {
+		usageDie(cmd)
+	}
+	fetch, _ := flags.GetBool("fetch")
+	verbose, _ := flags.GetBool("verbose")
+	maxdepth, _ := flags.GetInt("max-depth")
+	if maxdepth < 0 && flags.Changed("max-depth") {
+		usageDie(cmd)
+	}
+	annexonly, _ := flags.GetBool("annex-only")
+	gitonly, _ := flags.GetBool("git-only")
+	if annexonly && gitonly {
+		usageDie(cmd)
+	}
+	var showstatus []ginclient.FileStatus
+	if statusfilter != "" {
+		for _, abbrev := range strings.Split(statusfilter, ",") {
+			status, err := ginclient.ParseFileStatus(strings.TrimSpace(abbrev))
+			if err != nil {
+				Die(fmt.Sprintf("%s; valid values are OK, NC, MD, LC, NF, RC, UL, TC, RM, and ??", err.Error()))
+			}
+			showstatus = append(showstatus, status)
+		}
+	}
+
+	if rescan, _ := flags.GetBool("force-rescan"); rescan {
+		CheckError(git.RefreshIndex())
+		ginclient.UseWhereisCache = false
+	}
+	if nocache, _ := flags.GetBool("no-cache"); nocache {
+		ginclient.UseWhereisCache = false
+	}
+	if fetch {
+		remote, err := ginclient.DefaultRemote()
+		CheckError(err)
+		CheckError(git.Fetch(remote))
+	}
+	if verbose {
+		if err := ginclient.CheckUpstream(); err != nil {
+			Warn(fmt.Sprintf("file status may be inaccurate: %v", err))
+		}
+	}
 
 	// TODO: Use repo remotes; no server configuration
 	gincl := ginclient.New("gin")
 
-	filesStatus, err := gincl.ListFiles(args...)
+	var filesStatus ginclient.FileStatusMap
+	var err error
+	if since != "" {
+		filesStatus, err = gincl.ListFilesModifiedSince(since, args...)
+	} else {
+		filesStatus, err = gincl.ListFiles(args...)
+	}
 	CheckError(err)
 
+	var unlocked []string
+	if onlyunlocked || (!short && !jsonout) {
+		unlocked, err = ginclient.UnlockedFiles(args...)
+		if err != nil {
+			log.Write("Failed to list unlocked files: %v", err)
+		}
+	}
+	if onlyunlocked {
+		CheckError(err)
+		unlockedStatus := make(ginclient.FileStatusMap, len(unlocked))
+		for _, fname := range unlocked {
+			if status, ok := filesStatus[fname]; ok {
+				unlockedStatus[fname] = status
+			}
+		}
+		filesStatus = unlockedStatus
+	}
+	if flags.Changed("max-depth") {
+		CheckError(ginclient.FilterMaxDepth(filesStatus, args, maxdepth))
+	}
+	if showstatus != nil {
+		filesStatus = filesStatus.Filter(showstatus...)
+	}
+	if changedonly {
+		filesStatus = filesStatus.Changed()
+	}
+	if annexonly || gitonly {
+		annexed, err := ginclient.AnnexedFiles(args...)
+		CheckError(err)
+		for fname, status := range filesStatus {
+			if annexed[fname] != annexonly || (gitonly && status == ginclient.Untracked) {
+				delete(filesStatus, fname)
+			}
+		}
+	}
+
 	// TODO: Print warning when in direct mode: git files that have not been uploaded will show up as synced.
 
+	var sizes map[string]int64
+	if showsize {
+		sizes = make(map[string]int64, len(filesStatus))
+		for fname := range filesStatus {
+			size, err := ginclient.ContentSize(fname)
+			if err != nil {
+				log.Write("Failed to determine size of %q: %v", fname, err)
+			}
+			sizes[fname] = size
+		}
+	}
+
 	if short {
-		for fname, status := range filesStatus {
-			fmt.Printf("%s %s\n", status.Abbrev(), fname)
+		term := "\n"
+		if nullsep {
+			term = "\000"
+		}
+		for _, fname := range filesStatus.SortedFiles() {
+			status := filesStatus[fname]
+			if showsize {
+				fmt.Printf("%s %10s %s%s", status.Abbrev(), humanize.IBytes(uint64(sizes[fname])), fname, term)
+				continue
+			}
+			fmt.Printf("%s %s%s", status.Abbrev(), fname, term)
 		}
 	} else if jsonout {
 		type fstat struct {
 			FileName string `json:"filename"`
 			Status   string `json:"status"`
+			Size     *int64 `json:"size,omitempty"`
 		}
 		var statuses []fstat
-		for fname, status := range filesStatus {
-			statuses = append(statuses, fstat{FileName: fname, Status: status.Abbrev()})
+		for _, fname := range filesStatus.SortedFiles() {
+			fs := fstat{FileName: fname, Status: filesStatus[fname].Abbrev()}
+			if showsize {
+				size := sizes[fname]
+				fs.Size = &size
+			}
+			statuses = append(statuses, fs)
 		}
 		jsonbytes, err := json.Marshal(statuses)
 		CheckError(err)
 		fmt.Println(string(jsonbytes))
 	} else {
-		// Files are printed separated by status and sorted by name
-		statFiles := make(map[ginclient.FileStatus][]string)
-
-		for file, status := range filesStatus {
-			statFiles[status] = append(statFiles[status], file)
+		if tree {
+			printFileStatusTree(filesStatus, sizes)
+		} else {
+			printFileStatusList(filesStatus, sizes)
+		}
+		if len(unlocked) > 0 {
+			fmt.Fprintf(color.Output, "%s %d file(s) are unlocked for editing. Unlocked files are locked again when changes are committed or uploaded; use \"gin unlock <file>...\" to continue editing afterwards. Use \"gin ls --unlocked\" to list them.\n", yellow("Note:"), len(unlocked))
 		}
-		printFileStatusList(statFiles)
 	}
 }
 
-func printFileStatusList(statFiles map[ginclient.FileStatus][]string) {
-	// sort files in each status (stable sorting unnecessary)
-	// also collect active statuses for sorting
+// printFileStatusList prints the files grouped by status and sorted by name along with instructions for each status.
+// If sizes is not nil, the size of each file is printed next to its name.
+func printFileStatusList(filesStatus ginclient.FileStatusMap, sizes map[string]int64) {
+	statFiles := make(map[ginclient.FileStatus][]string)
 	var statuses ginclient.FileStatusSlice
-	for status := range statFiles {
-		sort.Sort(sort.StringSlice(statFiles[status]))
-		statuses = append(statuses, status)
+	for _, fname := range filesStatus.SortedFiles() {
+		status := filesStatus[fname]
+		if len(statFiles[status]) == 0 {
+			// files are sorted by status, so statuses are collected in order
+			statuses = append(statuses, status)
+		}
+		statFiles[status] = append(statFiles[status], fname)
 	}
-	sort.Sort(statuses)
 
+	counts := filesStatus.Counts()
 	summary := new(bytes.Buffer)
 	summary.WriteString("Summary\n")
 	// print each category with len(items) > 0 with appropriate header
@@ -90,7 +220,7 @@ func printFileStatusList(statFiles map[ginclient.FileStatus][]string) {
 			fmt.Print("  (use \"gin commit <file>...\" to save changes locally)\n")
 			fmt.Print("  (use \"gin upload <file>...\" to save changes and upload them\n")
 			cwriter = yellow
-		case ginclient.LocalChanges:
+		case ginclient.LocalChanges, ginclient.NewFile:
 			fmt.Print("  (use \"gin upload\" to upload changes)\n")
 			cwriter = yellow
 		case ginclient.RemoteChanges:
@@ -108,16 +238,77 @@ func printFileStatusList(statFiles map[ginclient.FileStatus][]string) {
 			fmt.Print("  (use \"gin commit <file>...\" to begin tracking and save the current state)\n")
 			fmt.Print("  (use \"gin upload <file>...\" to save the current state and upload directly)\n")
 		}
-		fmt.Fprintf(color.Output, "\n\t%s\n\n", cwriter(strings.Join(statFiles[status], "\n\t")))
-		summary.WriteString(fmt.Sprintf("   %s: %d", cwriter(status.Abbrev()), len(statFiles[status])))
+		files := statFiles[status]
+		if sizes != nil {
+			sizedfiles := make([]string, len(files))
+			for idx, fname := range files {
+				sizedfiles[idx] = fmt.Sprintf("%s (%s)", fname, humanize.IBytes(uint64(sizes[fname])))
+			}
+			files = sizedfiles
+		}
+		fmt.Fprintf(color.Output, "\n\t%s\n\n", cwriter(strings.Join(files, "\n\t")))
+		summary.WriteString(fmt.Sprintf("   %s: %d", cwriter(status.Abbrev()), counts[status]))
 	}
 	fmt.Fprintln(color.Output, summary)
 }
 
+// printFileStatusTree prints the files as an indented directory tree with the status of each file and the aggregate status of each directory.
+// If sizes is not nil, the size of each file is printed next to its name.
+func printFileStatusTree(filesStatus ginclient.FileStatusMap, sizes map[string]int64) {
+	root := filesStatus.Tree()
+	fmt.Fprintf(color.Output, "./ (%s)\n", statusColor(root)(root.Summary()))
+	printStatusTreeNodes(root, filepath.Clean("."), "  ", sizes)
+}
+
+// printStatusTreeNodes prints the children of a directory node, indenting each level further.
+// The path of the directory is used to look up the sizes of the files.
+func printStatusTreeNodes(node *ginclient.StatusTree, path string, indent string, sizes map[string]int64) {
+	for _, child := range node.Children {
+		childpath := filepath.Join(path, child.Name)
+		cwriter := statusColor(child)
+		if child.IsDir() {
+			fmt.Fprintf(color.Output, "%s%s/ (%s)\n", indent, child.Name, cwriter(child.Summary()))
+			printStatusTreeNodes(child, childpath, indent+"  ", sizes)
+			continue
+		}
+		if sizes != nil {
+			fmt.Fprintf(color.Output, "%s%s %s (%s)\n", indent, cwriter(child.Status.Abbrev()), child.Name, humanize.IBytes(uint64(sizes[childpath])))
+			continue
+		}
+		fmt.Fprintf(color.Output, "%s%s %s\n", indent, cwriter(child.Status.Abbrev()), child.Name)
+	}
+}
+
+// statusColor returns the function for coloring the status of a node in the same colors as the full listing.
+func statusColor(node *ginclient.StatusTree) func(...interface{}) string {
+	if node.IsDir() {
+		switch node.Summary() {
+		case "synced":
+			return green
+		case "contains unsynced changes":
+			return yellow
+		default:
+			return cyan
+		}
+	}
+	switch node.Status {
+	case ginclient.Synced:
+		return green
+	case ginclient.NoContent:
+		return cyan
+	case ginclient.Removed:
+		return red
+	case ginclient.Untracked:
+		return fmt.Sprint
+	default:
+		return yellow
+	}
+}
+
 // LsRepoCmd sets up the file 'ls' subcommand
 func LsRepoCmd() *cobra.Command {
 
-	description := `List one or more files or the contents of directories and the status of the files within it. With no arguments, lists the status of the files under the current directory. Directory listings are performed recursively.
+	description := `List one or more files or the contents of directories and the status of the files within it. With no arguments, lists the status of the files under the current directory. Directory listings are performed recursively. Use --max-depth to limit the listing to files that are at most a given number of directory levels below the listed directories (1 lists only the files directly in each directory).
 
 In the short form, the meaning of the status abbreviations is as follows:
 OK: The file is part of the GIN repository and its contents are synchronised with the server.
@@ -125,17 +316,51 @@ TC: The file has been locked or unlocked and the change has not been recorded ye
 NC: The local file is a placeholder and its contents have not been downloaded.
 MD: The file has been modified locally and the changes have not been recorded yet.
 LC: The file has been modified locally, the changes have been recorded but they haven't been uploaded.
+NF: The file has been added locally and recorded but it hasn't been uploaded.
+RC: The file has been modified on the remote and the changes haven't been downloaded.
 RM: The file has been removed from the repository.
-??: The file is not under repository control.`
+??: The file is not under repository control.
+
+Remote changes (RC) are determined from the state of the default remote when it was last contacted (e.g., during the last upload or download). Use --fetch to retrieve the latest state of the remote before listing; this requires a network connection but does not change any local files. Files that have been added on the remote and do not exist locally are not listed.
+
+Changes to files are detected from their size and modification time. If a file is modified by a program that keeps its modification time (e.g., some copy, sync, or backup restore tools), or its timestamp is otherwise unreliable (e.g., on network file systems with clock differences), the change may not be detected and the file is listed with its previous status. Use --force-rescan to read the content of all files instead. This can take a long time for repositories with many or large unlocked files.
+
+The locations of the content of annexed files are cached in the repository's git directory, so that only the files whose content or locations changed since the last listing are queried from git-annex. Use --no-cache (or --force-rescan) to query all files.
+
+With --tree, the files are shown as an indented directory tree with the status abbreviation of each file. Each directory is shown with the aggregate status of the files below it: "synced", "contains files without local content", or "contains unsynced changes" if any file has a status other than OK or NC.
+
+With --null (-z), the listing is printed in short form and each entry is terminated by a NUL character instead of a newline, so file names that contain newlines can be processed safely.
+
+With --size, the size of each file is shown. For annexed files whose content is not available locally, the size of the content on the remote is shown.
+
+With --modified-since, only files that have been added, modified, or removed since the given commit are listed. Untracked files are not listed in this mode.
+
+With --status, only files with the given statuses are listed. The statuses are specified as a comma-separated list of the abbreviations above (e.g., LC,MD). With --changed, only files that differ from the recorded and uploaded state of the repository are listed, i.e., all files except those that are OK or NC. These are usually the files of interest before an upload.
+
+With --unlocked, only annexed files that are currently unlocked for editing are listed. Unlocked files are locked again when their changes are committed or uploaded, so the full listing ends with a note when any files are unlocked.
+
+With --annex-only, only annexed files are listed; with --git-only, only files that are stored in git are listed (untracked files are not listed). These can be used to check which files are added to the annex (see 'gin largefiles').`
 
 	args := map[string]string{
 		"<filenames>": "One or more directories or files to list.",
 	}
 
+	examples := map[string]string{
+		"List files changed since commit 'a3f9b1c'":              "$ gin ls --modified-since a3f9b1c",
+		"List files in 'data' changed in the last three commits": "$ gin ls --modified-since HEAD~3 data",
+		"List files that are unlocked for editing":               "$ gin ls --unlocked",
+		"List files with changes that have not been uploaded":    "$ gin ls --status LC,NF,MD",
+		"List files that have changed on the server":             "$ gin ls --fetch --status RC",
+		"List the files in 'code' that are stored in the annex":  "$ gin ls --annex-only code",
+		"List all changed files in short form":                   "$ gin ls --changed --short",
+		"Show the status of the files in 'data' as a tree":       "$ gin ls --tree data",
+	}
+
 	var cmd = &cobra.Command{
-		Use:                   "ls [--json | --short | -s] [<filenames>]...",
+		Use:                   "ls [--json | --short | -s | --null | -z | --tree] [--size] [--modified-since <commit>] [--unlocked] [--status <statuses> | --changed] [--annex-only | --git-only] [--max-depth <n>] [--fetch] [--force-rescan] [--no-cache] [--verbose] [<filenames>]...",
 		Short:                 "List the sync status of files in the local repository",
 		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
 		Args:                  cobra.ArbitraryArgs,
 		Run:                   lsRepo,
 		Aliases:               []string{"status"},
@@ -143,5 +368,19 @@ RM: The file has been removed from the repository.
 	}
 	cmd.Flags().Bool("json", false, "Print listing in JSON format (uses short form abbreviations).")
 	cmd.Flags().BoolP("short", "s", false, "Print listing in short form.")
+	cmd.Flags().BoolP("null", "z", false, "Print listing in short form with each entry terminated by a NUL character instead of a newline.")
+	cmd.Flags().Bool("tree", false, "Print listing as a directory tree with the aggregate status of each directory.")
+	cmd.Flags().Bool("size", false, "Show the size of each file.")
+	cmd.Flags().String("modified-since", "", "List only files that have changed since the given `commit`.")
+	cmd.Flags().Bool("unlocked", false, "List only files that are unlocked for editing.")
+	cmd.Flags().String("status", "", "List only files with the given `statuses` (comma-separated short form abbreviations, e.g., LC,MD).")
+	cmd.Flags().Bool("changed", false, "List only files that differ from the recorded and uploaded state (all statuses except OK and NC).")
+	cmd.Flags().Bool("annex-only", false, "List only annexed files.")
+	cmd.Flags().Bool("git-only", false, "List only files that are stored in git.")
+	cmd.Flags().Int("max-depth", 0, "List only files at most `n` directory levels below the listed directories.")
+	cmd.Flags().Bool("fetch", false, "Retrieve the latest state of the default remote before listing to detect remote changes (requires network access).")
+	cmd.Flags().Bool("force-rescan", false, "Read the content of all files to detect changes instead of relying on file sizes and modification times. Implies --no-cache.")
+	cmd.Flags().Bool("no-cache", false, "Query the content locations of all annexed files from git-annex instead of using the cached locations.")
+	cmd.Flags().Bool("verbose", false, "Print a warning when the status of files may be inaccurate, e.g., when the current branch has no upstream branch on the default remote.")
 	return cmd
 }
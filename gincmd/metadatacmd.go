@@ -0,0 +1,158 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+// userMetadata returns the metadata fields of an annexed file without the
+// timestamp fields that git-annex maintains automatically.
+func userMetadata(md git.AnnexMetadataRes) map[string][]string {
+	fields := make(map[string][]string)
+	for field, values := range md.Fields {
+		if field == "lastchanged" || strings.HasSuffix(field, "-lastchanged") {
+			continue
+		}
+		fields[field] = values
+	}
+	return fields
+}
+
+func printMetadata(metadata []git.AnnexMetadataRes, jsonout bool, skipempty bool) {
+	type mdjson struct {
+		File   string              `json:"file"`
+		Key    string              `json:"key"`
+		Fields map[string][]string `json:"fields"`
+	}
+	var mdlist []mdjson
+	for _, md := range metadata {
+		fields := userMetadata(md)
+		if skipempty && len(fields) == 0 {
+			continue
+		}
+		mdlist = append(mdlist, mdjson{File: md.File, Key: md.Key, Fields: fields})
+	}
+	if jsonout {
+		j, _ := json.Marshal(mdlist)
+		fmt.Println(string(j))
+		return
+	}
+	for _, md := range mdlist {
+		fmt.Println(md.File)
+		if len(md.Fields) == 0 {
+			fmt.Println("  (no metadata)")
+			continue
+		}
+		names := make([]string, 0, len(md.Fields))
+		for name := range md.Fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("  %s: %s\n", name, strings.Join(md.Fields[name], ", "))
+		}
+	}
+}
+
+func checkMetadataRepo() {
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+}
+
+func getMetadata(cmd *cobra.Command, args []string) {
+	jsonout, _ := cmd.Flags().GetBool("json")
+	checkMetadataRepo()
+	metadata, err := git.AnnexMetadataGet(args)
+	CheckError(err)
+	if len(metadata) == 0 {
+		Die("no annexed files found: metadata is only available for annexed files")
+	}
+	printMetadata(metadata, jsonout, false)
+}
+
+func setMetadata(cmd *cobra.Command, args []string) {
+	checkMetadataRepo()
+	fname := args[0]
+	for _, assignment := range args[1:] {
+		parts := strings.SplitN(assignment, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			Die(fmt.Sprintf("invalid metadata assignment %q: use the form field=value", assignment))
+		}
+		CheckError(git.AnnexMetadataSet(fname, parts[0], parts[1]))
+	}
+}
+
+func listMetadata(cmd *cobra.Command, args []string) {
+	jsonout, _ := cmd.Flags().GetBool("json")
+	checkMetadataRepo()
+	metadata, err := git.AnnexMetadataGet(args)
+	CheckError(err)
+	printMetadata(metadata, jsonout, true)
+}
+
+// MetadataCmd sets up the 'metadata' subcommand and its 'get', 'set', and 'list' subcommands
+func MetadataCmd() *cobra.Command {
+	description := "View and edit the metadata of annexed files. Metadata consists of fields with one or more values, which can be used to describe and later find files (e.g., species=mouse, session=3). Metadata is stored by git-annex and is attached to the file content. It is only available for annexed files.\n\nMetadata changes are uploaded along with other changes the next time the 'upload' command is run."
+	var cmd = &cobra.Command{
+		Use:                   "metadata <command>",
+		Short:                 "View and edit the metadata of annexed files",
+		Long:                  formatdesc(description, nil),
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+	}
+
+	getdesc := "Print the metadata of one or more annexed files. If a directory is specified, the metadata of all annexed files under it are printed."
+	getargs := map[string]string{"<filenames>": "One or more annexed files or directories."}
+	var getcmd = &cobra.Command{
+		Use:                   "get [--json] <filenames>...",
+		Short:                 "Print the metadata of annexed files",
+		Long:                  formatdesc(getdesc, getargs),
+		Example:               formatexamples(map[string]string{"Print the metadata of 'recording.nix'": "$ gin metadata get recording.nix"}),
+		Args:                  cobra.MinimumNArgs(1),
+		Run:                   getMetadata,
+		DisableFlagsInUseLine: true,
+	}
+	getcmd.Flags().Bool("json", false, jsonHelpMsg)
+
+	setdesc := "Set the value of one or more metadata fields of an annexed file. Existing values of the specified fields are replaced."
+	setargs := map[string]string{
+		"<filename>":      "An annexed file.",
+		"<field>=<value>": "The name of the metadata field and the value to set.",
+	}
+	var setcmd = &cobra.Command{
+		Use:                   "set <filename> <field>=<value>...",
+		Short:                 "Set metadata fields of an annexed file",
+		Long:                  formatdesc(setdesc, setargs),
+		Example:               formatexamples(map[string]string{"Set the species and session of 'recording.nix'": "$ gin metadata set recording.nix species=mouse session=3"}),
+		Args:                  cobra.MinimumNArgs(2),
+		Run:                   setMetadata,
+		DisableFlagsInUseLine: true,
+	}
+
+	listdesc := "List all annexed files that have metadata along with their metadata fields. With no arguments, lists files under the current directory, recursively."
+	listargs := map[string]string{"<filenames>": "One or more directories or files to list."}
+	var listcmd = &cobra.Command{
+		Use:                   "list [--json] [<filenames>]...",
+		Short:                 "List annexed files with metadata",
+		Long:                  formatdesc(listdesc, listargs),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   listMetadata,
+		DisableFlagsInUseLine: true,
+	}
+	listcmd.Flags().Bool("json", false, jsonHelpMsg)
+
+	cmd.AddCommand(getcmd, setcmd, listcmd)
+	return cmd
+}
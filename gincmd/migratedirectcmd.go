@@ -0,0 +1,72 @@
+package gincmd
+
+import (
+	"fmt"
+	"strings"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/bbrks/wrap"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+// directNoticeKey is the git configuration key that records that the direct mode notice was shown for a repository.
+const directNoticeKey = "gin.directnotice"
+
+// directModeNotice prints a notice about migrating from direct mode the first time a command is run in a repository in direct mode.
+func directModeNotice(cmd *cobra.Command) {
+	if cmd.Name() == "migrate-direct" || !git.IsDirect() {
+		return
+	}
+	if shown, _ := git.ConfigGet(directNoticeKey); shown == "true" {
+		return
+	}
+	Warn("this repository uses git-annex direct mode, which is deprecated and not supported by newer versions of git-annex. Run 'gin migrate-direct' to convert it. This notice is shown only once.")
+	git.ConfigSet(directNoticeKey, "true")
+}
+
+func migrateDirect(cmd *cobra.Command, args []string) {
+	if git.Checkwd() == git.NotRepository {
+		Die(ginerrors.NotInRepo)
+	}
+	if !git.IsDirect() {
+		Exit(":: Repository is not in direct mode: nothing to do")
+	}
+
+	yes, _ := cmd.Flags().GetBool("yes")
+	if !yes {
+		msg := `This repository is in direct mode. Migrating converts it to the current git-annex repository version. The files are then kept on an adjusted branch (e.g., 'adjusted/master(unlocked)') where all annexed files are unlocked. Committed history and other clones of the repository are not changed, but older versions of git-annex (and gin) cannot use the migrated repository. Make sure no other program is using the repository during the migration.`
+		w := termwidth()
+		if w > 80 {
+			w = 80
+		}
+		fmt.Println(wrap.Wrap(msg, w))
+		fmt.Print("Migrate the repository? [yes/no]: ")
+		var response string
+		fmt.Scanln(&response)
+		if strings.ToLower(response) != "yes" {
+			Exit("Aborted")
+		}
+	}
+
+	fmt.Print(":: Migrating repository from direct mode ")
+	CheckError(ginclient.MigrateDirect())
+	fmt.Fprintln(color.Output, green("OK"))
+}
+
+// MigrateDirectCmd sets up the 'migrate-direct' subcommand
+func MigrateDirectCmd() *cobra.Command {
+	description := "Convert a repository that uses git-annex direct mode to the current repository version. Direct mode was used by older versions of the client on some filesystems and is no longer supported by git-annex. After the migration, the files are kept on an adjusted branch where all annexed files are unlocked, so they can be edited directly, as in direct mode.\n\nA notice is shown the first time any command is run in a repository in direct mode. The migration must be confirmed, unless --yes is specified."
+	var cmd = &cobra.Command{
+		Use:                   "migrate-direct [--yes]",
+		Short:                 "Convert a repository from git-annex direct mode",
+		Long:                  formatdesc(description, nil),
+		Args:                  cobra.NoArgs,
+		Run:                   migrateDirect,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("yes", false, "Migrate without asking for confirmation.")
+	return cmd
+}
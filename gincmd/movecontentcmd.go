@@ -0,0 +1,65 @@
+package gincmd
+
+import (
+	"context"
+	"fmt"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func moveContent(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	flags := cmd.Flags()
+	src, _ := flags.GetString("from")
+	dst, _ := flags.GetString("to")
+	if src == "" || dst == "" {
+		usageDie(cmd)
+	}
+	conf := config.Read()
+	gincl := ginclient.New(conf.DefaultServer)
+	requirelogin(cmd, gincl, prStyle != psJSON)
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	if prStyle.showHeaders() {
+		fmt.Printf(":: Moving file content from %s to %s\n", src, dst)
+	}
+	paths := changeToRepoRoot(args, true)
+	movechan := make(chan git.RepoFileStatus)
+	go gincl.MoveContent(context.Background(), paths, src, dst, movechan)
+	formatTransferOutput(movechan, prStyle)
+}
+
+// MoveContentCmd sets up the 'move-content' subcommand
+func MoveContentCmd() *cobra.Command {
+	description := "Move the content of files from one remote to another, for instance to decommission a backup remote. The content is transferred to the destination remote and then removed from the source remote. Content is not removed from the source if this would leave fewer copies than required (see 'gin annex-numcopies'). The content in the local repository is not affected.\n\nWith no arguments, moves the content of all files under the current working directory. Both remotes must be configured in the local repository (see 'gin remotes')."
+	args := map[string]string{
+		"<filenames>": "One or more directories or files whose content should be moved.",
+	}
+	examples := map[string]string{
+		"Move all content from the remote 'backup' to 'lab'": "$ gin move-content --from backup --to lab",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "move-content [--json] --from <remote> --to <remote> [<filenames>]...",
+		Short:                 "Move the content of files from one remote to another",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   moveContent,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().String("from", "", "The `remote` to move the content from.")
+	cmd.Flags().String("to", "", "The `remote` to move the content to.")
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	return cmd
+}
@@ -0,0 +1,81 @@
+package gincmd
+
+import (
+	"fmt"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func numCopies(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	flags := cmd.Flags()
+	check, _ := flags.GetBool("check")
+	setcopies, _ := flags.GetInt("set")
+	if !check && len(args) > 0 {
+		usageDie(cmd)
+	}
+	if flags.Changed("set") && setcopies < 1 {
+		usageDie(cmd)
+	}
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	if flags.Changed("set") {
+		CheckError(ginclient.SetNumCopies(setcopies))
+		if prStyle.showHeaders() {
+			fmt.Printf(":: Number of copies set to %d\n", setcopies)
+		}
+	}
+	if !check {
+		if !flags.Changed("set") {
+			fmt.Println(ginclient.NumCopies())
+		}
+		return
+	}
+
+	if prStyle.showHeaders() {
+		fmt.Printf(":: Checking for at least %d copies of each file\n", ginclient.NumCopies())
+	}
+	checkchan := make(chan git.RepoFileStatus)
+	go ginclient.CheckCopies(args, 0, checkchan)
+	formatOutput(checkchan, prStyle, 0)
+}
+
+// NumCopiesCmd sets up the 'annex-numcopies' subcommand
+func NumCopiesCmd() *cobra.Command {
+	description := `Show or set the minimum number of copies of the content of each file that should exist in the local repository and its remotes (the git-annex 'numcopies' setting). Content is never removed from a repository (e.g., by 'remove-content') if fewer copies than this number would remain. The setting applies to the local repository only.
+
+With --check, the files under the listed directories (or the current working directory) are checked and files with fewer copies are reported as failed. The check uses the location information known to the local repository; the content of the copies is not verified. Combined with --set, the files are checked against the new number of copies.
+
+With no flags, prints the current number of copies.`
+	args := map[string]string{
+		"<filenames>": "One or more directories or files to check. Requires --check.",
+	}
+	examples := map[string]string{
+		"Require two copies of each file":                    "$ gin annex-numcopies --set 2",
+		"Report the files in 'raw' that have too few copies": "$ gin annex-numcopies --check raw",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "annex-numcopies [--set <n>] [--check [--json] [<filenames>]...]",
+		Short:                 "Show, set, or check the minimum number of copies of file content",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   numCopies,
+		Aliases:               []string{"numcopies"},
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Int("set", 0, "Set the minimum number of copies to `n` (at least 1).")
+	cmd.Flags().Bool("check", false, "Report the files that have fewer copies than required.")
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	return cmd
+}
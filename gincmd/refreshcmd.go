@@ -0,0 +1,51 @@
+package gincmd
+
+import (
+	"fmt"
+
+	"github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func refresh(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+	if git.NoAnnex() {
+		Die("git-annex is disabled for this repository: there are no annexed files to refresh")
+	}
+	if prStyle.showHeaders() {
+		fmt.Println(":: Refreshing annexed file pointers")
+	}
+	refreshchan := make(chan git.RepoFileStatus)
+	go ginclient.RefreshPointers(args, refreshchan)
+	formatOutput(refreshchan, prStyle, 0)
+}
+
+// RefreshCmd sets up the file 'refresh' subcommand
+func RefreshCmd() *cobra.Command {
+	description := "Repair the pointers of annexed files and stage the repaired files. The pointers of annexed files can point to the wrong location after the files have been moved, or restored with external tools (e.g., from a backup). Tracked files whose content changed are staged as well. Untracked and deleted files are not changed. A 'commit' command is required to save the changes.\n\nOnly files that were changed are reported."
+	args := map[string]string{
+		"<filenames>": "One or more directories or files to refresh. Defaults to the current directory.",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "refresh [--json | --verbose] [<filenames>]...",
+		Short:                 "Repair the pointers of annexed files",
+		Long:                  formatdesc(description, args),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   refresh,
+		Aliases:               []string{"touch"},
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().Bool("verbose", false, verboseHelpMsg)
+	return cmd
+}
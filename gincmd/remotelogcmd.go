@@ -0,0 +1,88 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+func remoteLog(cmd *cobra.Command, args []string) {
+	flags := cmd.Flags()
+	srvalias, _ := flags.GetString("server")
+	jsonout, _ := flags.GetBool("json")
+	count, _ := flags.GetUint("max-count")
+	ref, _ := flags.GetString("ref")
+
+	repopath := args[0]
+	if !isValidRepoPath(repopath) {
+		Die(fmt.Sprintf("Invalid repository path '%s'. Full repository name should be the owner's username followed by the repository name, separated by a '/'.\nType 'gin help remote-log' for information and examples.", repopath))
+	}
+	conf := config.Read()
+	if srvalias == "" {
+		srvalias = conf.DefaultServer
+	}
+	gincl := ginclient.New(srvalias)
+	requirelogin(cmd, gincl, !jsonout)
+	commits, err := gincl.RemoteLog(repopath, ref, count)
+	CheckError(err)
+
+	if jsonout {
+		j, _ := json.Marshal(commits)
+		fmt.Println(string(j))
+		return
+	}
+	width := termwidth()
+	for _, commit := range commits {
+		fmt.Fprintf(color.Output, "%s * %s\n", green(commit.AbbreviatedHash), commit.Date.Format("Mon Jan 2 15:04:05 2006 (-0700)"))
+		fmt.Printf("  Author: %s <%s>\n", commit.AuthorName, commit.AuthorEmail)
+		fmt.Printf("%s\n", winner.Wrap(commit.Subject, width))
+		if len(commit.Body) > 0 {
+			fmt.Printf("%s\n", winner.Wrap(commit.Body, width))
+		}
+		fstats := commit.FileStats
+		if len(fstats.NewFiles) > 0 {
+			fmt.Printf("  Added\n%s\n", winner.Wrap(strings.Join(fstats.NewFiles, ", "), width))
+		}
+		if len(fstats.ModifiedFiles) > 0 {
+			fmt.Printf("  Modified\n%s\n", winner.Wrap(strings.Join(fstats.ModifiedFiles, ", "), width))
+		}
+		if len(fstats.DeletedFiles) > 0 {
+			fmt.Printf("  Deleted\n%s\n", winner.Wrap(strings.Join(fstats.DeletedFiles, ", "), width))
+		}
+		fmt.Println()
+	}
+}
+
+// RemoteLogCmd sets up the 'remote-log' subcommand
+func RemoteLogCmd() *cobra.Command {
+	description := `Show the history of a repository on the server without cloning it. For each version, the ID, date, author, and description are shown, along with the files that were added, modified, or deleted, if the server provides this information.
+
+The history of the default branch of the repository is shown, starting with the most recent version. Use --ref to start at a different branch, tag, or version. Each version is retrieved with a separate request, so long histories take longer to show.`
+
+	args := map[string]string{
+		"<repopath>": "The repository path must be specified on the command line. A repository path is the owner's username, followed by a \"/\" and the repository name.",
+	}
+	examples := map[string]string{
+		"Show the 5 most recent versions of the repository 'alice/ephys'": "$ gin remote-log -n 5 alice/ephys",
+		"Show the history of the branch 'analysis' in JSON format":        "$ gin remote-log --json --ref analysis alice/ephys",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "remote-log [--json] [--max-count <number>] [--ref <ref>] [--server <alias>] <repopath>",
+		Short:                 "Show the history of a repository on the server",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ExactArgs(1),
+		Run:                   remoteLog,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, "Print the history in JSON format.")
+	cmd.Flags().UintP("max-count", "n", 10, "Maximum `number` of versions to show. 0 means 'all'.")
+	cmd.Flags().String("ref", "", "Show the history starting at the branch, tag, or version `ref` instead of the default branch.")
+	cmd.Flags().String("server", "", "Specify server `alias` where the repository resides. See also 'gin servers'.")
+	return cmd
+}
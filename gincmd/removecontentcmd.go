@@ -2,11 +2,15 @@ package gincmd
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
 	"github.com/G-Node/gin-cli/ginclient/config"
 	"github.com/G-Node/gin-cli/gincmd/ginerrors"
 	"github.com/G-Node/gin-cli/git"
+	"github.com/docker/docker/pkg/term"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
 )
 
@@ -32,24 +36,105 @@ func remove(cmd *cobra.Command, args []string) {
 	case git.UpgradeRequired:
 		annexVersionNotice()
 	}
+	if unused, _ := cmd.Flags().GetBool("unused"); unused {
+		if len(args) > 0 {
+			usageDie(cmd)
+		}
+		yes, _ := cmd.Flags().GetBool("yes")
+		removeUnused(prStyle, yes)
+		return
+	}
+	args = changeToRepoRoot(args, true)
 	nitems := countItemsRemove(args)
+	force, _ := cmd.Flags().GetBool("force")
+	if force {
+		yes, _ := cmd.Flags().GetBool("yes")
+		confirmForceRemove(prStyle, yes, nitems)
+	}
 	rmchan := make(chan git.RepoFileStatus)
 	if prStyle == psProgress {
 		fmt.Println(":: Removing file content")
 	}
-	go gincl.RemoveContent(args, rmchan)
+	go gincl.RemoveContent(args, force, rmchan)
 	formatOutput(rmchan, prStyle, nitems)
 }
 
+// confirmForceRemove warns that content removed with --force may not be available anywhere else and exits unless the user confirms.
+// The confirmation is skipped if 'yes' is true; it is required if the user can't be prompted (with JSON or progress output, or without a terminal).
+func confirmForceRemove(prStyle printstyle, yes bool, nitems int) {
+	if yes {
+		return
+	}
+	if prStyle != psDefault || !term.IsTerminal(os.Stdin.Fd()) {
+		Die("--yes is required to remove content with --force without a confirmation prompt (e.g., with --json)")
+	}
+	fmt.Println("--- WARNING ---")
+	fmt.Printf("You are about to remove the content of %d file(s) without checking that a copy is available on a remote.\n", nitems)
+	fmt.Println("Content that has not been uploaded will be lost permanently.")
+	fmt.Print("Remove the content anyway? [yes/no]: ")
+	var response string
+	fmt.Scanln(&response)
+	if strings.ToLower(response) != "yes" {
+		Exit("Aborted")
+	}
+}
+
+// removeUnused removes the content of annexed objects that are no longer used by any file in the repository history, after asking the user for confirmation.
+// The confirmation is skipped if 'yes' is true; with JSON output, it is required.
+func removeUnused(prStyle printstyle, yes bool) {
+	if prStyle == psJSON && !yes {
+		Die("--yes is required to remove unused content with --json")
+	}
+	if prStyle.showHeaders() {
+		fmt.Println(":: Checking for unused content")
+	}
+	unused, err := git.AnnexUnused()
+	CheckError(err)
+	if len(unused) == 0 {
+		if prStyle.showHeaders() {
+			fmt.Println("   No unused content found")
+		}
+		return
+	}
+	var total int64
+	for _, obj := range unused {
+		total += git.KeySize(obj.Key)
+	}
+	if prStyle.showHeaders() {
+		fmt.Printf("   Found %d unused object(s) with a total size of %s\n", len(unused), humanize.IBytes(uint64(total)))
+		fmt.Println("   Content that is not available from any remote will not be removed.")
+	}
+	if !yes {
+		fmt.Print("Remove the unused content? [yes/no]: ")
+		var response string
+		fmt.Scanln(&response)
+		if strings.ToLower(response) != "yes" {
+			Exit("Aborted")
+		}
+	}
+
+	if prStyle.showHeaders() {
+		fmt.Println(":: Removing unused content")
+	}
+	summary := newTransferSummary()
+	dropchan := make(chan git.RepoFileStatus)
+	go git.AnnexDropUnused(unused, false, dropchan)
+	filesuccess := printStatus(summary.collect(dropchan), prStyle, len(unused))
+	if prStyle.showHeaders() {
+		fmt.Printf(":: Removed %d object(s), reclaimed %s\n", summary.nfiles(), humanize.IBytes(uint64(summary.nbytes())))
+	}
+	checkFileErrors(filesuccess)
+}
+
 // RemoveContentCmd sets up the 'remove-content' subcommand
 func RemoveContentCmd() *cobra.Command {
-	description := "Remove the content of local files. This command will not remove the content of files that have not been already uploaded to a remote repository, even if the user specifies such files explicitly. Removed content can be retrieved from the server by using the 'get-content' command. With no arguments, removes the content of all files under the current working directory, as long as they have been safely uploaded to a remote repository.\n\nNote that after removal, placeholder files will remain in the local repository. These files appear as 'No Content' when running the 'gin ls' command."
+	description := "Remove the content of local files. This command will not remove the content of files that have not been already uploaded to a remote repository, even if the user specifies such files explicitly. Removed content can be retrieved from the server by using the 'get-content' command. With no arguments, removes the content of all files under the current working directory, as long as they have been safely uploaded to a remote repository.\n\nNote that after removal, placeholder files will remain in the local repository. These files appear as 'No Content' when running the 'gin ls' command.\n\nWith the --unused flag, the content of files that are no longer used in the repository (e.g., old versions of modified files or files that have been deleted) is removed instead. The unused content is listed and must be confirmed before removal, unless --yes is specified (required with --json). Only content that is available from a remote is removed.\n\nFiles whose content cannot be verified to exist on a remote are reported as failed. Upload them or copy their content to a remote first. With --force, the content of the specified files is removed regardless, after confirmation; content that exists nowhere else is lost. The confirmation is skipped with --yes, which is required with --json or when no terminal is available for the prompt."
 	args := map[string]string{
 		"<filenames>": "One or more directories or files to remove.",
 	}
 	var cmd = &cobra.Command{
 		// Use:                   "remove-content [--json | --verbose] [<filenames>]...",
-		Use:                   "remove-content [--json] [<filenames>]...",
+		Use:                   "remove-content [--json] [--unused [--yes] | [--force [--yes]] <filenames>...]",
 		Short:                 "Remove the content of local files that have already been uploaded",
 		Long:                  formatdesc(description, args),
 		Args:                  cobra.ArbitraryArgs,
@@ -59,5 +144,8 @@ func RemoveContentCmd() *cobra.Command {
 	}
 	cmd.Flags().Bool("json", false, jsonHelpMsg)
 	// cmd.Flags().Bool("verbose", false, verboseHelpMsg)
+	cmd.Flags().Bool("unused", false, "Remove the content of files that are no longer used in the repository instead of the specified files.")
+	cmd.Flags().Bool("yes", false, "Remove unused content (--unused) or content with --force without asking for confirmation.")
+	cmd.Flags().Bool("force", false, "Remove the content even if no copy can be verified on a remote. The removal must be confirmed, or --yes must be specified. Content that has not been uploaded is lost.")
 	return cmd
 }
@@ -21,8 +21,13 @@ func printRepoInfo(repo gogs.Repository) {
 	if repo.Website != "" {
 		fmt.Printf("\tWebsite: %s\n", repo.Website)
 	}
-	if !repo.Private {
-		fmt.Println("\tThis repository is public")
+	if repo.Private {
+		fmt.Println("\tVisibility: private")
+	} else {
+		fmt.Println("\tVisibility: public")
+	}
+	if !repo.Updated.IsZero() {
+		fmt.Printf("\tLast updated: %s\n", repo.Updated.Local().Format("2006-01-02 15:04"))
 	}
 	fmt.Println()
 }
@@ -16,52 +16,82 @@ func printRepoList(repolist []gogs.Repository) {
 	}
 }
 
+// repoTypes are the valid values for the --type flag of the repos command.
+var repoTypes = []string{"owned", "collaborated", "public", "all"}
+
 func repos(cmd *cobra.Command, args []string) {
 	flags := cmd.Flags()
 	jsonout, _ := flags.GetBool("json")
 	allrepos, _ := flags.GetBool("all")
 	sharedrepos, _ := flags.GetBool("shared")
 	srvalias, _ := flags.GetString("server")
+	limit, _ := flags.GetInt("limit")
+	repotype, _ := flags.GetString("type")
+	typeset := flags.Changed("type")
 
 	conf := config.Read()
 	if srvalias == "" {
 		srvalias = conf.DefaultServer
 	}
-	if (allrepos && sharedrepos) || ((allrepos || sharedrepos) && len(args) > 0) {
+	if (allrepos && sharedrepos) || ((allrepos || sharedrepos || typeset) && len(args) > 0) || ((allrepos || sharedrepos) && typeset) || limit < 0 {
 		usageDie(cmd)
 	}
+	if allrepos {
+		repotype = "all"
+	} else if sharedrepos {
+		repotype = "collaborated"
+	}
+	validtype := false
+	for _, t := range repoTypes {
+		validtype = validtype || repotype == t
+	}
+	if !validtype {
+		Die(fmt.Sprintf("invalid repository type %q; valid values are owned, collaborated, public, and all", repotype))
+	}
 
 	gincl := ginclient.New(srvalias)
 	requirelogin(cmd, gincl, !jsonout)
-	username := gincl.Username
-	if len(args) == 1 && args[0] != username {
-		username = args[0]
-		// for other users, print everything
-		allrepos = true
-	}
-	repolist, err := gincl.ListRepos(username)
-	CheckError(err)
 
-	var userrepos []gogs.Repository
-	var otherrepos []gogs.Repository
-
-	for _, repo := range repolist {
-		if repo.Owner.UserName == gincl.Username {
-			userrepos = append(userrepos, repo)
-		} else {
-			otherrepos = append(otherrepos, repo)
+	var outlist []gogs.Repository
+	if len(args) == 1 && args[0] != gincl.Username {
+		// for other users, print everything
+		repolist, err := gincl.ListRepos(args[0])
+		CheckError(err)
+		outlist = repolist
+	} else if repotype == "public" {
+		repolist, err := gincl.SearchRepos("")
+		CheckError(err)
+		for _, repo := range repolist {
+			if !repo.Private {
+				outlist = append(outlist, repo)
+			}
 		}
-	}
-
-	if jsonout {
-		var outlist []gogs.Repository
-		if allrepos {
+	} else {
+		repolist, err := gincl.ListMyRepos()
+		CheckError(err)
+		var userrepos []gogs.Repository
+		var otherrepos []gogs.Repository
+		for _, repo := range repolist {
+			if repo.Owner.UserName == gincl.Username {
+				userrepos = append(userrepos, repo)
+			} else {
+				otherrepos = append(otherrepos, repo)
+			}
+		}
+		switch repotype {
+		case "all":
 			outlist = append(userrepos, otherrepos...)
-		} else if sharedrepos {
+		case "collaborated":
 			outlist = otherrepos
-		} else {
+		default:
 			outlist = userrepos
 		}
+	}
+	if limit > 0 && len(outlist) > limit {
+		outlist = outlist[:limit]
+	}
+
+	if jsonout {
 		if len(outlist) > 0 {
 			j, _ := json.Marshal(outlist)
 			fmt.Println(string(j))
@@ -69,39 +99,33 @@ func repos(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	printedlines := 0
-	if len(userrepos) > 0 && !sharedrepos {
-		printedlines += len(userrepos)
-		printRepoList(userrepos)
-	}
-	if len(otherrepos) > 0 && (sharedrepos || allrepos) {
-		printedlines += len(otherrepos)
-		printRepoList(otherrepos)
-	}
-
-	if printedlines == 0 {
+	if len(outlist) == 0 {
 		fmt.Println("No repositories found")
+		return
 	}
+	printRepoList(outlist)
 }
 
 // ReposCmd sets up the 'repos' listing subcommand
 func ReposCmd() *cobra.Command {
-	description := "List repositories on the server that provide read access. If no argument is provided, it will list the repositories owned by the logged in user.\n\nNote that only one of the options can be specified."
+	description := "List repositories on the server that provide read access. If no argument is provided, it will list the repositories owned by the logged in user.\n\nThe --type option selects which repositories are listed: 'owned' (default) lists the repositories owned by the logged in user, 'collaborated' lists the repositories of other users that are shared with the logged in user, 'all' lists both, and 'public' lists all public repositories on the server. The --shared and --all options are short forms of '--type collaborated' and '--type all' respectively.\n\nFor each repository, the name, location, description, visibility (public or private), and time of the last update are shown. With --json, the full information for each repository is printed as a JSON list.\n\nNote that only one of the options --type, --shared, --all, or <username> can be specified."
 
 	args := map[string]string{
 		"<username>": "The name of the user whose repositories should be listed. The list consists of public repositories and repositories shared with the logged in user.",
 	}
 	var cmd = &cobra.Command{
-		Use:                   "repos [--shared | --all | <username>]",
+		Use:                   "repos [--json] [--limit <n>] [--type <type> | --shared | --all | <username>]",
 		Short:                 "List available remote repositories",
 		Long:                  formatdesc(description, args),
 		Args:                  cobra.MaximumNArgs(1),
 		Run:                   repos,
 		DisableFlagsInUseLine: true,
 	}
-	cmd.Flags().Bool("all", false, "List all repositories accessible to the logged in user.")
-	cmd.Flags().Bool("shared", false, "List all repositories that the user is a member of (excluding own repositories).")
+	cmd.Flags().String("type", "owned", "List repositories of the given `type`: owned, collaborated, public, or all.")
+	cmd.Flags().Bool("all", false, "List all repositories accessible to the logged in user (same as --type all).")
+	cmd.Flags().Bool("shared", false, "List all repositories that the user is a member of, excluding own repositories (same as --type collaborated).")
 	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().Int("limit", 0, "List at most `n` repositories (0 lists all).")
 	cmd.Flags().String("server", "", "Specify server `alias` where the repository will be created. See also 'gin servers'.")
 	return cmd
 }
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 
+	ginclient "github.com/G-Node/gin-cli/ginclient"
 	"github.com/G-Node/gin-cli/ginclient/config"
 	"github.com/fatih/color"
 	"github.com/spf13/cobra"
@@ -12,6 +13,8 @@ import (
 type srvcfgWithDefault struct {
 	config.ServerCfg
 	Default bool
+	// User is the name of the user logged in to the server (empty if not logged in)
+	User string
 }
 
 func servers(cmd *cobra.Command, args []string) {
@@ -24,7 +27,9 @@ func servers(cmd *cobra.Command, args []string) {
 	// augment servermap with Default field
 	serverdefaultmap := make(map[string]srvcfgWithDefault, len(servermap))
 	for alias, srvcfg := range servermap {
-		serverdefaultmap[alias] = srvcfgWithDefault{srvcfg, defserver == alias}
+		gincl := ginclient.New(alias)
+		gincl.LoadToken()
+		serverdefaultmap[alias] = srvcfgWithDefault{srvcfg, defserver == alias, gincl.Username}
 	}
 
 	if jsonout {
@@ -39,14 +44,18 @@ func servers(cmd *cobra.Command, args []string) {
 			}
 			fmt.Println()
 			fmt.Printf("  web: %s\n", srvcfg.Web.AddressStr())
-			fmt.Printf("  git: %s\n\n", srvcfg.Git.AddressStr())
+			fmt.Printf("  git: %s\n", srvcfg.Git.AddressStr())
+			if srvcfg.User != "" {
+				fmt.Printf("  logged in as: %s\n", srvcfg.User)
+			}
+			fmt.Println()
 		}
 	}
 }
 
 // ServersCmd sets up the 'servers' subcommand
 func ServersCmd() *cobra.Command {
-	description := `List globally configured servers and their information.`
+	description := `List globally configured servers and their information. For each server that you are logged in to, the name of the logged in user is also shown. You can be logged in to multiple servers at the same time; see 'gin login --help'.`
 	var cmd = &cobra.Command{
 		Use:                   "servers",
 		Short:                 "List the globally configured servers",
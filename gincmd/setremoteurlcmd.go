@@ -0,0 +1,119 @@
+package gincmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func setRemoteURL(cmd *cobra.Command, args []string) {
+	if git.Checkwd() == git.NotRepository {
+		Die(ginerrors.NotInRepo)
+	}
+	srvalias, _ := cmd.Flags().GetString("server")
+	conf := config.Read()
+	if srvalias == "" {
+		srvalias = conf.DefaultServer
+	}
+	if _, ok := conf.Servers[srvalias]; !ok {
+		Die(fmt.Sprintf("unknown server alias '%s': see 'gin servers'", srvalias))
+	}
+	nocheck, _ := cmd.Flags().GetBool("no-check")
+	newpath, _ := cmd.Flags().GetString("repository")
+	oldhosts, _ := cmd.Flags().GetStringSlice("old-host")
+	if newpath != "" && (len(args) == 0 || !isValidRepoPath(newpath)) {
+		usageDie(cmd)
+	}
+
+	remotes, err := git.RemoteShow()
+	CheckError(err)
+	names := args
+	if len(names) == 0 {
+		for name := range remotes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+	}
+
+	gincl := ginclient.New(srvalias)
+	gincl.LoadToken()
+	var unreachable []string
+	for _, name := range names {
+		oldurl, ok := remotes[name]
+		if !ok {
+			Die(fmt.Sprintf("no such remote: %s", name))
+		}
+		if len(args) == 0 && !gincl.OnServer(oldurl, oldhosts) {
+			// only explicitly requested remotes are moved from other hosts
+			fmt.Printf(":: Skipping remote %s: not an address of server '%s' [%s]\n", name, srvalias, git.RedactURLs(oldurl))
+			continue
+		}
+		var newurl string
+		if newpath != "" {
+			newurl, err = gincl.MovedRemoteURL(oldurl, newpath)
+		} else {
+			newurl, err = gincl.ServerRemoteURL(oldurl)
+		}
+		if err != nil {
+			if len(args) > 0 {
+				// explicitly requested remotes must be updated
+				Die(err)
+			}
+			fmt.Printf(":: Skipping remote %s: %s\n", name, err)
+			continue
+		}
+		if newurl == oldurl {
+			fmt.Printf(":: Remote %s is up to date [%s]\n", name, git.RedactURLs(newurl))
+		} else {
+			CheckError(git.RemoteSetURL(name, newurl))
+			fmt.Printf(":: Updated remote %s: %s\n", name, git.RedactURLs(newurl))
+		}
+		if nocheck {
+			continue
+		}
+		if err := checkRemote(cmd, name); err != nil {
+			unreachable = append(unreachable, name)
+		}
+	}
+	if len(unreachable) > 0 {
+		Die(fmt.Sprintf("the following remotes could not be reached at their new address: %s\nCheck the configuration of server '%s' (see 'gin servers')", strings.Join(unreachable, ", "), srvalias))
+	}
+}
+
+// SetRemoteURLCmd sets up the 'set-remote-url' repository subcommand
+func SetRemoteURLCmd() *cobra.Command {
+	description := `Update the address of remotes of the current repository after a GIN server has moved to a new address. The repository path of each remote (<owner>/<repository>) is kept and the address is rebuilt from the current configuration of the server, the same way it is done by 'add-remote'. Remotes that use HTTPS keep using HTTPS. Credentials in HTTPS addresses are removed; gin provides your login credentials to git when it accesses the remote.
+
+If no remote names are specified, all remotes that point to a repository on the server are updated: remotes whose host is the current host of the server, or one of its previous hosts specified with --old-host. All other remotes (e.g., mirrors on other servers and directory remotes) are skipped. Remotes specified by name are always updated. The server configuration should be updated first (see 'gin add-server'). After the update, the client checks that each remote can be reached at its new address.
+
+With --repository, the specified remotes are changed to point to a different repository on the server, e.g., after the repository has been transferred to a new owner (see 'gin transfer').`
+
+	args := map[string]string{
+		"<name>": "The name of a remote to update",
+	}
+	examples := map[string]string{
+		"Update all remotes after the address of the default server has been changed from gin.example.org": "$ gin add-server gin\n$ gin set-remote-url --old-host gin.example.org",
+		"Point the remote 'origin' to the server with alias 'labgin'":                                      "$ gin set-remote-url --server labgin origin",
+		"Point the remote 'origin' to the repository after a transfer to 'bob'":                            "$ gin set-remote-url --repository bob/example origin",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "set-remote-url [--server <alias>] [--old-host <host>]... [--repository <owner>/<repository>] [--no-check] [<name>]...",
+		Short:                 "Update the address of remotes after a server has moved",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   setRemoteURL,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().String("server", "", "Specify server `alias` whose address the remotes should use. See also 'gin servers'.")
+	cmd.Flags().String("repository", "", "Point the specified remotes to the `repository` (<owner>/<repository>) on the server instead of keeping their repository path.")
+	cmd.Flags().StringSlice("old-host", nil, "Also update remotes that point to `host`, a previous address of the server, when no remote names are specified. Can be specified multiple times.")
+	cmd.Flags().Bool("no-check", false, "Do not check that the remotes can be reached at their new address.")
+	return cmd
+}
@@ -0,0 +1,84 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+func stats(cmd *cobra.Command, args []string) {
+	jsonout, _ := cmd.Flags().GetBool("json")
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Warn(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+	changeToRepoRoot(nil, false)
+
+	repostats, err := ginclient.RepositoryStats()
+	CheckError(err)
+
+	if jsonout {
+		j, _ := json.Marshal(repostats)
+		fmt.Println(string(j))
+		return
+	}
+	printRepoStats(repostats)
+}
+
+func printRepoStats(repostats ginclient.RepoStats) {
+	if repostats.Commits == 0 {
+		fmt.Println("No commits")
+	} else {
+		datefmt := "2006-01-02"
+		fmt.Fprintf(color.Output, "%s %d (%s to %s)\n", green("Commits:"), repostats.Commits, repostats.FirstCommit.Format(datefmt), repostats.LastCommit.Format(datefmt))
+
+		fmt.Fprintf(color.Output, "\n%s\n", green("Contributors"))
+		fmt.Printf("  %7s  %7s  %s\n", "Commits", "Files", "Author")
+		for _, author := range repostats.Authors {
+			fmt.Printf("  %7d  %7d  %s <%s>\n", author.Commits, author.FilesChanged, author.Name, author.Email)
+		}
+
+		fmt.Fprintf(color.Output, "\n%s\n", green("Commits per month"))
+		for _, month := range repostats.Months {
+			fmt.Printf("  %s  %7d\n", month.Month, month.Commits)
+		}
+	}
+
+	nfiles := repostats.GitFiles + repostats.AnnexFiles
+	fmt.Fprintf(color.Output, "\n%s %d (git: %d, annex: %d)\n", green("Files:"), nfiles, repostats.GitFiles, repostats.AnnexFiles)
+	if nfiles == 0 {
+		return
+	}
+	fmt.Printf("  %-12s  %7s  %7s\n", "Type", "Files", "Annexed")
+	for _, ftype := range repostats.FileTypes {
+		ext := ftype.Extension
+		if ext == "" {
+			ext = "(none)"
+		}
+		fmt.Printf("  %-12s  %7d  %7d\n", ext, ftype.Files, ftype.AnnexFiles)
+	}
+}
+
+// StatsCmd sets up the 'stats' subcommand
+func StatsCmd() *cobra.Command {
+	description := "Show statistics for the local repository: the number of commits (versions) per contributor and per month, and the number of files in the repository by file type, including how many of them are stored in the annex.\n\nThe commit statistics cover the history of the current branch. The file statistics cover the files in the current version, regardless of whether their content is available locally."
+	var cmd = &cobra.Command{
+		Use:                   "stats [--json]",
+		Short:                 "Show commit and file statistics for the repository",
+		Long:                  formatdesc(description, nil),
+		Args:                  cobra.NoArgs,
+		Run:                   stats,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, "Print statistics in JSON format.")
+	return cmd
+}
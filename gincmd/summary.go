@@ -0,0 +1,115 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/G-Node/gin-cli/git"
+	humanize "github.com/dustin/go-humanize"
+)
+
+// transferSummary accumulates the number of files and bytes transferred during an upload or download, based on the status messages of the operation.
+type transferSummary struct {
+	start time.Time
+	end   time.Time
+	// completed file transfers and their sizes
+	files map[string]int64
+	// files that did not need to be transferred
+	skipped map[string]bool
+}
+
+func newTransferSummary() *transferSummary {
+	return &transferSummary{start: time.Now(), files: make(map[string]int64), skipped: make(map[string]bool)}
+}
+
+// collect records each status message that passes through the returned channel.
+// The returned channel is closed when statuschan is closed.
+func (s *transferSummary) collect(statuschan <-chan git.RepoFileStatus) <-chan git.RepoFileStatus {
+	outchan := make(chan git.RepoFileStatus)
+	go func() {
+		defer close(outchan)
+		for stat := range statuschan {
+			if stat.Skipped {
+				s.skipped[stat.FileName] = true
+			} else if stat.Err == nil && stat.Progress == "100%" && stat.FileName != "" {
+				s.files[stat.FileName] = stat.Size
+			}
+			outchan <- stat
+		}
+		s.end = time.Now()
+	}()
+	return outchan
+}
+
+func (s *transferSummary) nfiles() int {
+	return len(s.files)
+}
+
+func (s *transferSummary) nbytes() (total int64) {
+	for _, size := range s.files {
+		total += size
+	}
+	return
+}
+
+func (s *transferSummary) duration() time.Duration {
+	if s.end.IsZero() {
+		return time.Since(s.start)
+	}
+	return s.end.Sub(s.start)
+}
+
+// rate returns the average transfer rate in bytes per second.
+func (s *transferSummary) rate() int64 {
+	dt := s.duration()
+	if dt <= 0 {
+		return 0
+	}
+	return int64(float64(s.nbytes()) / dt.Seconds())
+}
+
+func (s *transferSummary) String() string {
+	var plural string
+	if s.nfiles() != 1 {
+		plural = "s"
+	}
+	summary := fmt.Sprintf("%d file%s (%s) in %s (%s/s)", s.nfiles(), plural, humanize.IBytes(uint64(s.nbytes())), s.duration().Round(time.Millisecond), humanize.IBytes(uint64(s.rate())))
+	if len(s.skipped) > 0 {
+		summary = fmt.Sprintf("%s; %d file(s) skipped", summary, len(s.skipped))
+	}
+	return summary
+}
+
+// MarshalJSON returns the summary as a JSON object with the file count, total bytes, duration in seconds, and average rate in bytes per second.
+func (s *transferSummary) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Files    int     `json:"files"`
+		Skipped  int     `json:"skipped"`
+		Bytes    int64   `json:"bytes"`
+		Duration float64 `json:"duration"`
+		Rate     int64   `json:"rate"`
+	}{
+		Files:    s.nfiles(),
+		Skipped:  len(s.skipped),
+		Bytes:    s.nbytes(),
+		Duration: s.duration().Seconds(),
+		Rate:     s.rate(),
+	})
+}
+
+// print prints the summary line, or the summary object when the JSON print style is used.
+// Nothing is printed in verbose or quiet mode.
+func (s *transferSummary) print(pstyle printstyle) {
+	switch pstyle {
+	case psJSON:
+		j, _ := jsonLine("summary", struct {
+			Summary *transferSummary `json:"summary"`
+		}{s})
+		printJSONMessage(j)
+	case psVerbose, psQuiet:
+		return
+	default:
+		fmt.Printf(":: Transferred %s\n", s)
+	}
+}
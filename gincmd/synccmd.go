@@ -26,10 +26,20 @@ func sync(cmd *cobra.Command, args []string) {
 	}
 
 	content, _ := cmd.Flags().GetBool("content")
+	remotes := args
+	if len(remotes) > 0 {
+		confremotes, err := git.RemoteShow()
+		CheckError(err)
+		for _, remote := range remotes {
+			if _, ok := confremotes[remote]; !ok {
+				Die(fmt.Sprintf("unknown remote '%s'; use 'gin remotes' to list the configured remotes", remote))
+			}
+		}
+	}
 	if prStyle == psDefault {
 		fmt.Print(":: Synchronising changes ")
 	}
-	err := gincl.Sync(content)
+	err := gincl.Sync(content, remotes...)
 	CheckError(err)
 	if prStyle == psDefault {
 		fmt.Fprintln(color.Output, green("OK"))
@@ -38,12 +48,18 @@ func sync(cmd *cobra.Command, args []string) {
 
 // SyncCmd sets up the 'sync' subcommand
 func SyncCmd() *cobra.Command {
-	description := "Synchronises changes bidirectionally between remote repositories and the local clone. This will create new files that were added remotely, delete files that were removed, and update files that were changed.\n\nOptionally downloads and uploads the content of all files in the repository. If 'content' is not specified, new files will be empty placeholders. Content of individual files can later be retrieved using the 'get-content' command."
+	description := "Synchronises changes bidirectionally between remote repositories and the local clone. This will create new files that were added remotely, delete files that were removed, and update files that were changed.\n\nOptionally downloads and uploads the content of all files in the repository. If 'content' is not specified, new files will be empty placeholders and no file content is transferred. Only the information about which files exist and which remotes have the content of each file is synchronised. This is useful for keeping track of file locations across multiple remotes without the cost of transferring data. Content of individual files can later be retrieved using the 'get-content' command.\n\nBy default, all configured remotes are synchronised. One or more remotes can be specified to limit synchronisation to those remotes."
+	args := map[string]string{"<remote>": "The name of a configured remote to synchronise with. See 'gin remotes'."}
+	examples := map[string]string{
+		"Synchronise changes and file locations with all remotes, without transferring content": "$ gin sync",
+		"Synchronise changes and content with the remote named 'labdata'":                       "$ gin sync --content labdata",
+	}
 	var cmd = &cobra.Command{
-		Use:                   "sync [--json] [--content]",
+		Use:                   "sync [--json] [--content] [<remote>]...",
 		Short:                 "Sync all new information bidirectionally between local and remote repositories",
-		Long:                  formatdesc(description, nil),
-		Args:                  cobra.NoArgs,
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
 		Run:                   sync,
 		DisableFlagsInUseLine: true,
 	}
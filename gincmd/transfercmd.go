@@ -0,0 +1,124 @@
+package gincmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func transferRepo(cmd *cobra.Command, args []string) {
+	srvalias, _ := cmd.Flags().GetString("server")
+	conf := config.Read()
+	if srvalias == "" {
+		srvalias = conf.DefaultServer
+	}
+	gincl := ginclient.New(srvalias)
+	requirelogin(cmd, gincl, true)
+
+	repostr, newowner := args[0], args[1]
+	if !isValidRepoPath(repostr) {
+		Die(fmt.Sprintf("Invalid repository path '%s'. Full repository name should be the owner's username followed by the repository name, separated by a '/'.\nType 'gin help transfer' for information and examples.", repostr))
+	}
+	repoinfo, err := gincl.GetRepo(repostr)
+	CheckError(err)
+	if repoinfo.FullName != repostr {
+		log.Write("ERROR: Mismatch in repository names: %s != %s", repoinfo.FullName, repostr)
+		Die("An unexpected error occurred while communicating with the server.")
+	}
+	parts := strings.SplitN(repostr, "/", 2)
+	owner, name := parts[0], parts[1]
+	if newowner == owner {
+		Die(fmt.Sprintf("repository '%s' is already owned by '%s'", repostr, owner))
+	}
+	newpath := fmt.Sprintf("%s/%s", newowner, name)
+
+	fmt.Println("--- WARNING ---")
+	fmt.Printf("You are about to transfer the repository at %s to '%s'.\n", repoinfo.HTMLURL, newowner)
+	fmt.Println("You will lose access to the repository unless the new owner grants it to you.")
+	fmt.Println("This action can only be reversed by the new owner.")
+	fmt.Println("If you are sure you want to transfer this repository, type its full name (owner/name) below")
+	fmt.Print("> ")
+	var confirmation string
+	fmt.Scanln(&confirmation)
+	if confirmation != repostr {
+		Die("Confirmation does not match repository name. Cancelling.")
+	}
+
+	pending, err := gincl.TransferRepo(owner, name, newowner)
+	CheckError(err)
+	if pending {
+		fmt.Printf(":: The transfer of %s to '%s' is pending until it is accepted by '%s'\n", repostr, newowner, newowner)
+	} else {
+		fmt.Printf(":: Repository %s has been transferred to %s\n", repostr, newpath)
+	}
+	updateTransferredRemotes(gincl, repostr, newpath, pending)
+}
+
+// updateTransferredRemotes changes the remotes of the repository in the working directory that point to the transferred repository 'oldpath' to point to 'newpath'.
+// If the transfer is pending, the command to update each remote after the transfer is accepted is printed instead.
+func updateTransferredRemotes(gincl *ginclient.Client, oldpath, newpath string, pending bool) {
+	if git.Checkwd() == git.NotRepository {
+		return
+	}
+	remotes, err := git.RemoteShow()
+	if err != nil {
+		log.Write("Failed to read remotes: %v", err)
+		return
+	}
+	var names []string
+	for name, remoteurl := range remotes {
+		if repopath, err := ginclient.RepoPathFromURL(remoteurl); err == nil && repopath == oldpath {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if pending {
+			fmt.Printf(":: When the transfer has been accepted, update the remote %s with:\n   gin set-remote-url --repository %s %s\n", name, newpath, name)
+			continue
+		}
+		newurl, err := gincl.MovedRemoteURL(remotes[name], newpath)
+		if err == nil {
+			err = git.RemoteSetURL(name, newurl)
+		}
+		if err != nil {
+			Warn(fmt.Sprintf("failed to update remote %s: %v\nUse 'gin set-remote-url --repository %s %s' to update it", name, err, newpath, name))
+			continue
+		}
+		fmt.Printf(":: Updated remote %s: %s\n", name, git.RedactURLs(newurl))
+	}
+}
+
+// TransferCmd sets up the 'transfer' repository subcommand
+func TransferCmd() *cobra.Command {
+	description := `Transfer a repository on the server to another user or organisation. The repository keeps its name, files, and history, and is owned by the new owner after the transfer. You must be the owner of the repository (or an administrator of the organisation that owns it) to transfer it. The transfer must be confirmed by typing the full name of the repository.
+
+Depending on the server, the new owner may have to accept the transfer before it is completed. Until then, the repository remains with its current owner.
+
+If the command is run inside a local clone of the repository, the remotes that point to the repository are updated to point to its new location once the transfer is complete.`
+
+	args := map[string]string{
+		"<repository>": "The full name of the repository to transfer (<owner>/<repository>)",
+		"<new-owner>":  "The user or organisation that receives the repository",
+	}
+	examples := map[string]string{
+		"Transfer the repository 'alice/example' to the user 'bob'": "$ gin transfer alice/example bob",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "transfer [--server <alias>] <repository> <new-owner>",
+		Short:                 "Transfer a repository to another user or organisation",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ExactArgs(2),
+		Run:                   transferRepo,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().String("server", "", "Specify server `alias` on which the repository resides. See also 'gin servers'.")
+	return cmd
+}
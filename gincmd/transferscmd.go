@@ -0,0 +1,81 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+func annexTransfers(cmd *cobra.Command, args []string) {
+	jsonout, _ := cmd.Flags().GetBool("json")
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	}
+
+	transfers, err := git.AnnexTransfers()
+	CheckError(err)
+	if jsonout {
+		if transfers == nil {
+			transfers = []git.AnnexTransfer{}
+		}
+		jsonbytes, err := json.Marshal(transfers)
+		CheckError(err)
+		fmt.Println(string(jsonbytes))
+		return
+	}
+	if len(transfers) == 0 {
+		fmt.Println("No transfers in progress")
+		return
+	}
+	for _, transfer := range transfers {
+		direction := "to"
+		if transfer.Direction == "download" {
+			direction = "from"
+		}
+		state := "in progress"
+		if transfer.Failed {
+			state = "failed"
+		}
+		progress := humanize.IBytes(uint64(transfer.BytesDone))
+		if transfer.Size > 0 {
+			progress = fmt.Sprintf("%s of %s (%d%%)", progress, humanize.IBytes(uint64(transfer.Size)), transfer.BytesDone*100/transfer.Size)
+		}
+		started := ""
+		if !transfer.Started.IsZero() {
+			started = fmt.Sprintf(", started %s", humanize.Time(transfer.Started))
+		}
+		name := transfer.File
+		if name == "" {
+			name = transfer.Key
+		}
+		fmt.Printf("%s %s %s %s: %s%s [%s]\n", transfer.Direction, name, direction, transfer.Remote, progress, started, state)
+	}
+}
+
+// AnnexTransfersCmd sets up the 'annex-transfers' subcommand
+func AnnexTransfersCmd() *cobra.Command {
+	description := `List the transfers of file content that are in progress in the local repository, along with the direction of each transfer, the remote, and the number of bytes transferred so far. Transfers that failed and have not been retried are also listed.
+
+The list is read from the records that git-annex keeps while it transfers content, so it includes the transfers of all running commands (e.g., a 'gin upload' running in another terminal). It does not start or change any transfer. A transfer that was interrupted (e.g., because the computer was shut down) may remain listed as in progress until the file is transferred again.`
+	examples := map[string]string{
+		"Show the progress of an upload running in another terminal": "$ gin annex-transfers",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "annex-transfers [--json]",
+		Short:                 "List the file content transfers in progress",
+		Long:                  formatdesc(description, nil),
+		Example:               formatexamples(examples),
+		Args:                  cobra.NoArgs,
+		Run:                   annexTransfers,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, "Print listing in JSON format.")
+	return cmd
+}
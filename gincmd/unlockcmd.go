@@ -31,7 +31,7 @@ func unlock(cmd *cobra.Command, args []string) {
 		annexVersionNotice()
 	}
 
-	if prStyle != psJSON {
+	if prStyle.showHeaders() {
 		fmt.Println(":: Unlocking files")
 	}
 	// unlock should do nothing in direct mode
@@ -44,6 +44,7 @@ func unlock(cmd *cobra.Command, args []string) {
 	conf := config.Read()
 	defserver := conf.DefaultServer
 	gincl := ginclient.New(defserver)
+	args = changeToRepoRoot(args, false)
 	nitems := countItemsLockChange(args)
 	unlockchan := make(chan git.RepoFileStatus)
 	go gincl.UnlockContent(args, unlockchan)
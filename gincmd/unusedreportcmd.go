@@ -0,0 +1,79 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+func unusedReport(cmd *cobra.Command, args []string) {
+	jsonout, _ := cmd.Flags().GetBool("json")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Warn(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+	changeToRepoRoot(nil, false)
+
+	report, err := ginclient.UnusedContentReport()
+	CheckError(err)
+
+	if jsonout {
+		j, _ := json.Marshal(report)
+		fmt.Println(string(j))
+		return
+	}
+	printUnusedReport(report, verbose)
+}
+
+func printUnusedReport(report ginclient.UnusedReport, verbose bool) {
+	if report.Total.Count == 0 {
+		fmt.Println("No unused content found")
+		return
+	}
+	size := func(group ginclient.UnusedGroup) string {
+		return humanize.IBytes(uint64(group.Size))
+	}
+	fmt.Fprintf(color.Output, "%s %d object(s), %s\n", green("Unused content:"), report.Total.Count, size(report.Total))
+	fmt.Printf("  %-22s %7d  %10s\n", "Available from remote", report.RemoteCopy.Count, size(report.RemoteCopy))
+	fmt.Printf("  %-22s %7d  %10s\n", "Local copy only", report.LocalOnly.Count, size(report.LocalOnly))
+	if verbose {
+		fmt.Println()
+		for _, obj := range report.Objects {
+			location := "local only"
+			if obj.RemoteCopy {
+				location = "remote"
+			}
+			fmt.Printf("  %4s  %10s  %-10s  %s\n", obj.Number, humanize.IBytes(uint64(obj.Size)), location, obj.Key)
+		}
+	}
+	if report.RemoteCopy.Count > 0 {
+		fmt.Printf("\nRun 'gin remove-content --unused' to reclaim %s.\n", size(report.RemoteCopy))
+	}
+}
+
+// UnusedReportCmd sets up the 'annex-unused-report' subcommand
+func UnusedReportCmd() *cobra.Command {
+	description := "Report the annexed content in the local repository that is no longer used by any file (e.g., old versions of modified files or files that have been deleted) and the space that can be reclaimed by removing it. The unused objects are grouped by whether their content is also available from a remote.\n\nThis command does not modify the repository. Unused content that is available from a remote can be removed with 'gin remove-content --unused'."
+	var cmd = &cobra.Command{
+		Use:                   "annex-unused-report [--json | --verbose]",
+		Short:                 "Report unused content and the space it occupies",
+		Long:                  formatdesc(description, nil),
+		Args:                  cobra.NoArgs,
+		Run:                   unusedReport,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, "Print the report in JSON format.")
+	cmd.Flags().Bool("verbose", false, "List each unused object.")
+	return cmd
+}
@@ -4,8 +4,10 @@ import (
 	"fmt"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
 	"github.com/G-Node/gin-cli/gincmd/ginerrors"
 	"github.com/G-Node/gin-cli/git"
+	humanize "github.com/dustin/go-humanize"
 	"github.com/spf13/cobra"
 )
 
@@ -13,6 +15,7 @@ func upload(cmd *cobra.Command, args []string) {
 	prStyle := determinePrintStyle(cmd)
 	remotes, _ := cmd.Flags().GetStringSlice("to")
 	gincl := ginclient.New("gin") // TODO: probably doesn't need a client
+	requirelogin(cmd, ginclient.New(config.Read().DefaultServer), prStyle != psJSON)
 	switch git.Checkwd() {
 	case git.NotRepository:
 		Die(ginerrors.NotInRepo)
@@ -40,18 +43,62 @@ func upload(cmd *cobra.Command, args []string) {
 		}
 	}
 
-	paths := args
-	if len(paths) > 0 {
-		commit(cmd, paths)
+	paths := changeToRepoRoot(argsWithPathsFrom(cmd, args), false)
+	addpaths := paths
+	if onlytracked, _ := cmd.Flags().GetBool("only-tracked"); onlytracked && len(paths) > 0 {
+		var err error
+		addpaths, err = ginclient.FilterTracked(paths)
+		CheckError(err)
+	}
+	if nolock, _ := cmd.Flags().GetBool("no-lock"); nolock {
+		git.AddUnlocked = true
+	}
+	if len(addpaths) > 0 {
+		commit(cmd, addpaths)
 	}
 
-	if prStyle != psJSON {
+	if len(remotes) == 0 {
+		defremote, _ := ginclient.DefaultRemote()
+		remotes = []string{defremote}
+	}
+	checkRepoSizeLimit(cmd, paths, remotes)
+
+	if prStyle.showHeaders() {
 		fmt.Println(":: Uploading")
 	}
 
 	uploadchan := make(chan git.RepoFileStatus)
-	go gincl.Upload(paths, remotes, uploadchan)
-	formatOutput(uploadchan, prStyle, 0)
+	go gincl.Upload(operationContext(cmd), paths, remotes, uploadchan)
+	summary := formatTransferOutput(uploadchan, prStyle)
+	runHook("upload", hookEnv("upload", remotes, summary))
+}
+
+// checkRepoSizeLimit checks that the upload does not make the repositories of the remotes larger than the size limit of the configuration (upload.sizelimit), if one is set.
+// If the limit would be exceeded, the command exits with an error, unless the --force flag is set, in which case a warning is printed.
+func checkRepoSizeLimit(cmd *cobra.Command, paths []string, remotes []string) {
+	limitstr := config.Read().Upload.SizeLimit
+	if limitstr == "" || git.NoAnnex() {
+		return
+	}
+	limit, err := humanize.ParseBytes(limitstr)
+	if err != nil {
+		Warn(fmt.Sprintf("invalid value '%s' for upload.sizelimit: size limit not checked", limitstr))
+		return
+	}
+	force, _ := cmd.Flags().GetBool("force")
+	gincl := ginclient.New(config.Read().DefaultServer)
+	gincl.LoadToken()
+	for _, remote := range remotes {
+		err := gincl.CheckRepoSizeLimit(paths, remote, int64(limit))
+		if err == nil {
+			continue
+		}
+		if force {
+			Warn(err.Error())
+			continue
+		}
+		Die(fmt.Sprintf("%s\nUse --force to upload anyway", err))
+	}
 }
 
 // UploadCmd sets up the 'upload' subcommand
@@ -60,18 +107,31 @@ func UploadCmd() *cobra.Command {
 
 You can specify which remotes the content will be uploaded to using the --to flag. The flag can be specified multiple times. If the keyword 'all' is specified as a remote, the data is uploaded to all configured remotes.
 
-If no arguments are specified, only changes to files already being tracked are uploaded.`
+By default, new files found under the specified paths are added to the repository. Use the --only-tracked flag to upload only changes to files that are already being tracked; new files are then ignored.
+
+Files that are added to the annex may be locked, depending on the configuration of the repository. Use the --no-lock flag to keep all uploaded files unlocked for further editing (see also 'gin unlock'). Unlocked files take up more disk space, since a copy of their content is kept in the annex in addition to the file in the working tree.
+
+If no arguments are specified, only changes that have already been committed are uploaded.
+
+If a size limit for repositories is configured (upload.sizelimit), the size of the repository on the server and the size of the content to be uploaded are checked first and the upload is refused if the repository would exceed the limit. Use --force to upload regardless of the limit.
+
+If the upload of a file fails, the remaining files are still uploaded and the failures are reported at the end (--keep-going, the default). Use --fail-fast to stop the whole upload at the first failure instead, e.g., in scripts that should not continue after an error. In both cases, the command exits with a non-zero status if any file failed.
+
+Long lists of files can be read from a file (or standard input) with --paths-from instead of being specified as arguments. The file should contain one path per line, or paths separated by NUL characters if --null (-z) is specified. The paths are added to any paths specified as arguments.`
 
 	args := map[string]string{"<filenames>": "One or more directories or files to upload and update."}
 	examples := map[string]string{
-		"Upload 'data1.dat' and 'values.csv' to default remote":             "$ gin upload data1.dat values.csv",
-		"Upload all files in current directory to default remote":           "$ gin upload .",
-		"Upload all previously committed changes to remote named 'labdata'": "$ gin upload --to labdata",
-		"Upload all '.zip' files to remotes named 'gin' and 'labdata'":      "$ gin upload --to gin --to labdata *.zip\n    or\n$ gin upload --to gin,labdata *.zip",
+		"Upload 'data1.dat' and 'values.csv' to default remote":              "$ gin upload data1.dat values.csv",
+		"Upload all files in current directory to default remote":            "$ gin upload .",
+		"Upload all previously committed changes to remote named 'labdata'":  "$ gin upload --to labdata",
+		"Upload changes to tracked files in 'data' without adding new files": "$ gin upload --only-tracked data",
+		"Upload 'analysis.ipynb' and keep it unlocked for further editing":   "$ gin upload --no-lock analysis.ipynb",
+		"Upload all '.zip' files to remotes named 'gin' and 'labdata'":       "$ gin upload --to gin --to labdata *.zip\n    or\n$ gin upload --to gin,labdata *.zip",
+		"Upload the files listed in 'filelist.txt'":                          "$ gin upload --paths-from filelist.txt",
 	}
 	var cmd = &cobra.Command{
 		// Use:                   "upload [--json | --verbose] [--to <remote>] [<filenames>]...",
-		Use:                   "upload [--json] [--to <remote>] [<filenames>]...",
+		Use:                   "upload [--json] [--only-tracked] [--no-lock] [--force] [--since-last-upload] [--sign[=<keyid>]] [--keep-going | --fail-fast] [--to <remote>] [--paths-from <file> [-z]] [<filenames>]...",
 		Short:                 "Upload local changes to a remote repository",
 		Long:                  formatdesc(description, args),
 		Args:                  cobra.ArbitraryArgs,
@@ -82,5 +142,15 @@ If no arguments are specified, only changes to files already being tracked are u
 	cmd.Flags().Bool("json", false, jsonHelpMsg)
 	// cmd.Flags().Bool("verbose", false, verboseHelpMsg)
 	cmd.Flags().StringSliceP("to", "t", nil, "Upload to specific `remote`. Supports multiple remotes, either by specifying multiple times or as a comma separated list (see Examples). If the keyword 'all' is specified, the data is uploaded to all configured remotes.")
+	cmd.Flags().Bool("only-tracked", false, "Only upload changes to files that are already tracked by the repository. New files under the specified paths are not added.")
+	cmd.Flags().String("paths-from", "", pathsFromHelpMsg)
+	cmd.Flags().BoolP("null", "z", false, nullHelpMsg)
+	cmd.Flags().Bool("no-lock", false, "Keep the uploaded files unlocked so that they can still be edited. Unlocked files use more disk space, since their content is kept both in the working tree and in the annex.")
+	cmd.Flags().Bool("since-last-upload", false, sinceUploadHelpMsg)
+	cmd.Flags().Bool("force", false, "Upload even if the repository on the server would exceed the size limit of the configuration (upload.sizelimit).")
+	cmd.Flags().String("sign", "", signHelpMsg)
+	cmd.Flags().Lookup("sign").NoOptDefVal = signDefaultKey
+	cmd.Flags().Bool("keep-going", true, keepGoingHelpMsg)
+	cmd.Flags().Bool("fail-fast", false, failFastHelpMsg)
 	return cmd
 }
@@ -0,0 +1,89 @@
+package gincmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+func verify(cmd *cobra.Command, args []string) {
+	flags := cmd.Flags()
+	jsonout, _ := flags.GetBool("json")
+	commit, _ := flags.GetString("id")
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Warn(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	differences, err := ginclient.VerifyTree(commit, args...)
+	CheckError(err)
+
+	if jsonout {
+		type fstat struct {
+			FileName string `json:"filename"`
+			Status   string `json:"status"`
+		}
+		statuses := []fstat{}
+		for _, fname := range differences.SortedFiles() {
+			statuses = append(statuses, fstat{FileName: fname, Status: differences[fname].Abbrev()})
+		}
+		jsonbytes, err := json.Marshal(statuses)
+		CheckError(err)
+		fmt.Println(string(jsonbytes))
+	} else {
+		for _, fname := range differences.SortedFiles() {
+			status := differences[fname]
+			fmt.Fprintf(color.Output, "%s %s\n", verifyColor(status)(status.Abbrev()), fname)
+		}
+	}
+	if len(differences) > 0 {
+		Die(fmt.Sprintf("working tree differs from %s: %d file(s)", commit, len(differences)))
+	}
+	if !jsonout {
+		fmt.Fprintf(color.Output, "%s working tree matches %s\n", green("OK"), commit)
+	}
+}
+
+// verifyColor returns the color function used to print a difference of the given status.
+func verifyColor(status ginclient.FileStatus) func(...interface{}) string {
+	switch status {
+	case ginclient.NoContent:
+		return cyan
+	case ginclient.Removed:
+		return red
+	default:
+		return yellow
+	}
+}
+
+// VerifyCmd sets up the 'verify' subcommand
+func VerifyCmd() *cobra.Command {
+	description := "Check that the working tree matches a version of the repository exactly, for instance before running an analysis that should be reproducible. By default, the working tree is compared with the current version (HEAD).\n\nThe following differences are reported with their short status abbreviations (see 'gin ls'):\n\n  MD: the content of the file differs from the version\n\n  ??: the file does not exist in the version (stray file)\n\n  RM: the file of the version is missing\n\n  NC: the content of an annexed file is not available locally\n\nFiles ignored by git and changes in the lock state of annexed files are not reported. If there are differences, the command exits with an error."
+	args := map[string]string{
+		"<filenames>": "One or more directories or files to check. With no arguments, the entire working tree under the current directory is checked.",
+	}
+	examples := map[string]string{
+		"Check that the working tree matches a specific version": "$ gin verify --id 429d51e",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "verify [--id <hash>] [--json] [<filenames>]...",
+		Short:                 "Check that the working tree matches a version exactly",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   verify,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().String("id", "HEAD", "The `hash` of the version to compare with.")
+	cmd.Flags().Bool("json", false, "Print the differences in JSON format (uses short form abbreviations).")
+	return cmd
+}
@@ -1,12 +1,14 @@
 package gincmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
 
 	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
 	"github.com/G-Node/gin-cli/gincmd/ginerrors"
 	"github.com/G-Node/gin-cli/git"
 	"github.com/fatih/color"
@@ -22,11 +24,31 @@ func repoversion(cmd *cobra.Command, args []string) {
 	case git.UpgradeRequired:
 		annexVersionNotice()
 	}
+	conf := config.Read()
 	count, _ := cmd.Flags().GetUint("max-count")
+	if !cmd.Flags().Changed("max-count") {
+		count = conf.Version.MaxCount
+	}
+	if all, _ := cmd.Flags().GetBool("all"); all {
+		if cmd.Flags().Changed("max-count") {
+			usageDie(cmd)
+		}
+		count = 0
+	}
 	jsonout, _ := cmd.Flags().GetBool("json")
 	commithash, _ := cmd.Flags().GetString("id")
 	copyto, _ := cmd.Flags().GetString("copy-to")
+	contentof, _ := cmd.Flags().GetString("content-of")
+	if abbrevlen, _ := cmd.Flags().GetUint("abbrev-len"); abbrevlen > 0 {
+		git.AbbrevLength = int(abbrevlen)
+	}
 	paths := args
+	if contentof != "" {
+		if copyto != "" || len(args) > 0 {
+			usageDie(cmd)
+		}
+		paths = []string{contentof}
+	}
 
 	var gcommit git.GinCommit
 	if commithash == "" {
@@ -40,17 +62,30 @@ func repoversion(cmd *cobra.Command, args []string) {
 		if len(commits) == 0 {
 			Die("No revisions matched request")
 		}
-		gcommit = verprompt(commits)
+		if conf.Version.Select == config.VersionSelectLatest {
+			gcommit = commits[0]
+			fmt.Fprintf(color.Output, ":: Using latest version %s * %s\n", green(gcommit.AbbreviatedHash), gcommit.Date.Format("Mon Jan 2 15:04:05 2006 (-0700)"))
+		} else {
+			gcommit = verprompt(commits)
+		}
 	} else {
-		commits, err := git.Log(1, commithash, paths, false)
+		// resolve the ID to the full hash of a commit, which cannot be ambiguous
+		fullhash, err := git.ResolveCommit(commithash)
+		CheckError(err)
+		commits, err := git.Log(1, fullhash, paths, false)
 		CheckError(err)
+		if len(commits) == 0 {
+			Die("No revisions matched request")
+		}
 		gcommit = commits[0]
 	}
 
-	if copyto == "" {
+	if contentof != "" {
+		restorefile(cmd, gcommit, contentof)
+	} else if copyto == "" {
 		// TODO: Print some sort of output (similar to copy-to variant)
 		// e.g., File 'fname' restored to version <revision> (date)
-		err := ginclient.CheckoutVersion(gcommit.AbbreviatedHash, paths)
+		err := ginclient.CheckoutVersion(gcommit.Hash, paths)
 		CheckError(err)
 		commit(cmd, paths)
 	} else {
@@ -63,7 +98,7 @@ func checkoutcopies(commit git.GinCommit, paths []string, destination string) {
 	isodate := commit.Date.Format("2006-01-02-150405")
 	prettydate := commit.Date.Format("Jan 2 15:04:05 2006 (-0700)")
 	checkoutchan := make(chan ginclient.FileCheckoutStatus)
-	go ginclient.CheckoutFileCopies(hash, paths, destination, isodate, checkoutchan)
+	go ginclient.CheckoutFileCopies(commit.Hash, paths, destination, isodate, checkoutchan)
 
 	// TODO: JSON output
 	var newfiles int
@@ -98,7 +133,35 @@ func checkoutcopies(commit git.GinCommit, paths []string, destination string) {
 		if nerr > 1 {
 			plural = "s"
 		}
-		Die(fmt.Sprintf("%d operation%s failed", nerr, plural))
+		dieWithCode(ExitPartial, fmt.Sprintf("%d operation%s failed", nerr, plural))
+	}
+}
+
+// restorefile replaces a single file with its version from the given commit, retrieving its content if necessary, and records the change.
+// Other files and changes are not affected.
+func restorefile(cmd *cobra.Command, commit git.GinCommit, path string) {
+	prStyle := determinePrintStyle(cmd)
+	prettydate := commit.Date.Format("Jan 2 15:04:05 2006 (-0700)")
+	if prStyle.showHeaders() {
+		fmt.Printf(":: Restoring '%s' from version %s (%s)\n", path, commit.AbbreviatedHash, prettydate)
+	}
+	restorechan := make(chan git.RepoFileStatus)
+	go ginclient.RestoreFileVersion(context.Background(), commit.Hash, path, restorechan)
+	formatOutput(restorechan, prStyle, 1)
+
+	if prStyle.showHeaders() {
+		fmt.Print(":: Recording changes ")
+	}
+	err := git.CommitPaths(makeCommitMessage("version", []string{path}), []string{path})
+	stat := green("OK")
+	if err != nil {
+		if err.Error() != "Nothing to commit" {
+			Die(err)
+		}
+		stat = "\n   No changes recorded (the file is already at this version)"
+	}
+	if prStyle.showHeaders() {
+		fmt.Fprintln(color.Output, stat)
 	}
 }
 
@@ -133,12 +196,19 @@ func verprompt(commits []git.GinCommit) git.GinCommit {
 		return commits[num-1]
 	}
 
-	// try to match hash
+	// try to match hash (or a unique prefix of it)
+	var matches []git.GinCommit
 	for _, commit := range commits {
-		if commit.AbbreviatedHash == selstr {
-			return commit
+		if selstr != "" && strings.HasPrefix(commit.Hash, strings.ToLower(selstr)) {
+			matches = append(matches, commit)
 		}
 	}
+	if len(matches) == 1 {
+		return matches[0]
+	}
+	if len(matches) > 1 {
+		Die(fmt.Sprintf("version ID '%s' is ambiguous; specify more characters of the ID", selstr))
+	}
 
 	Die("Aborting")
 	return git.GinCommit{}
@@ -146,16 +216,17 @@ func verprompt(commits []git.GinCommit) git.GinCommit {
 
 // VersionCmd sets up the 'version' subcommand
 func VersionCmd() *cobra.Command {
-	description := "Roll back directories or files to older versions."
+	description := "Roll back directories or files to older versions.\n\nVersions are identified by abbreviated commit IDs (hashes). In repositories with a long history, short IDs may match more than one version. Use --abbrev-len to show longer IDs. The IDs specified with --id are only matched against versions, and an error is shown if an ID is ambiguous.\n\nWith --content-of, only a single file is returned to the selected version; the content of annexed files is downloaded if it is not available locally. Only the change to this file is recorded, and other files and changes in the working tree are left untouched.\n\nThe number of versions listed by default and whether to prompt for a version or use the most recent one can be changed in the configuration (version.maxcount and version.select)."
 	args := map[string]string{"<filenames>": "One or more directories or files to roll back."}
 	examples := map[string]string{
 		"Show the 50 most recent versions of recordings.nix and prompt for version":                                                "$ gin version -n 50 recordings.nix",
 		"Return the files in the code/ directory to the version with ID 429d51e":                                                   "$ gin version --id 429d51e code/",
 		"Retrieve all files from the code/ directory from version with ID 918a06f and copy it to a directory called oldcode/":      "$ gin version --id 918a06f --copy-to oldcode code",
 		"Show the 15 most recent versions of data.zip, prompt for version, and copy the selected version to the current directory": "$ gin version -n 15 --copy-to . data.zip",
+		"Return only the file analysis/params.json to the version with ID 918a06f":                                                 "$ gin version --id 918a06f --content-of analysis/params.json",
 	}
 	var cmd = &cobra.Command{
-		Use:                   "version [--json] [--max-count n | --id hash | --copy-to location] [<filenames>]...",
+		Use:                   "version [--json] [--abbrev-len n] [--max-count n | --all | --id hash | --copy-to location] [--content-of <filename> | <filenames>...]",
 		Short:                 "Roll back files or directories to older versions",
 		Long:                  formatdesc(description, args),
 		Example:               formatexamples(examples),
@@ -164,8 +235,11 @@ func VersionCmd() *cobra.Command {
 		DisableFlagsInUseLine: true,
 	}
 	cmd.Flags().Bool("json", false, jsonHelpMsg)
-	cmd.Flags().UintP("max-count", "n", 10, "Maximum `number` of versions to display before prompting. 0 means 'all'.")
+	cmd.Flags().UintP("max-count", "n", 10, "Maximum `number` of versions to display before prompting. 0 means 'all'. The default can be changed in the configuration (version.maxcount).")
+	cmd.Flags().Bool("all", false, "Display all versions before prompting (same as --max-count 0).")
 	cmd.Flags().String("id", "", "Commit `ID` (hash) to return to.")
+	cmd.Flags().Uint("abbrev-len", 0, "Show at least `n` digits of version IDs. By default, the length is chosen by git to keep the IDs unique.")
+	cmd.Flags().String("content-of", "", "Return only the single file `filename` to the selected version and record the change, leaving all other files untouched.")
 	cmd.Flags().String("copy-to", "", "Retrieve files from history and copy them to a new `location` instead of overwriting the existing ones. The new files will be placed in the directory specified and will be renamed to include the date and time of their version.")
 	return cmd
 }
@@ -0,0 +1,65 @@
+package gincmd
+
+import (
+	"fmt"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/spf13/cobra"
+)
+
+func wanted(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Die(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+
+	repository := args[0]
+	if len(args) == 1 {
+		expression, err := ginclient.Wanted(repository)
+		CheckError(err)
+		fmt.Println(expression)
+		return
+	}
+	expression := args[1]
+	CheckError(ginclient.SetWanted(repository, expression))
+	if prStyle.showHeaders() {
+		fmt.Printf(":: Preferred content of %s set to: %s\n", repository, expression)
+	}
+}
+
+// WantedCmd sets up the 'annex-wanted' subcommand
+func WantedCmd() *cobra.Command {
+	description := `Show or set the preferred content expression of a remote or the local repository (the git-annex 'wanted' setting). The expression determines which file content a repository should hold, e.g., which content is sent to a remote by 'gin annex sync --content' or 'gin annex copy --auto'. A repository with no expression wants all content.
+
+Expressions are written in the git-annex preferred content language, for example 'include=raw/*' (only files in the 'raw' directory), 'largerthan=100mb', or 'inallgroup=backup', combined with 'and', 'or', 'not', and parentheses. The expression is checked by git-annex before it is set. Remotes can be added to groups with 'annex-group' and groups can be referred to in expressions.
+
+The setting is recorded in the git-annex branch, so it is shared with other clones of the repository after it is uploaded.
+
+With only a remote name, prints the current expression.`
+	args := map[string]string{
+		"<remote>":     "The name of a remote, or 'here' for the local repository.",
+		"<expression>": "The preferred content expression to set. Quote the expression so that it is passed as a single argument.",
+	}
+	examples := map[string]string{
+		"Make the remote 'rawstore' hold only the content of files in 'raw'": "$ gin annex-wanted rawstore 'include=raw/*'",
+		"Show the preferred content of the local repository":                 "$ gin annex-wanted here",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "annex-wanted <remote> [<expression>]",
+		Short:                 "Show or set the preferred content of a remote",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.RangeArgs(1, 2),
+		Run:                   wanted,
+		Aliases:               []string{"wanted"},
+		DisableFlagsInUseLine: true,
+	}
+	return cmd
+}
@@ -0,0 +1,225 @@
+package gincmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	ginclient "github.com/G-Node/gin-cli/ginclient"
+	"github.com/G-Node/gin-cli/ginclient/config"
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/gincmd/ginerrors"
+	"github.com/G-Node/gin-cli/git"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cobra"
+)
+
+func watch(cmd *cobra.Command, args []string) {
+	prStyle := determinePrintStyle(cmd)
+	remotes, _ := cmd.Flags().GetStringSlice("to")
+	interval, _ := cmd.Flags().GetDuration("interval")
+	if interval <= 0 {
+		usageDie(cmd)
+	}
+	gincl := ginclient.New(config.Read().DefaultServer)
+	requirelogin(cmd, gincl, prStyle != psJSON)
+	switch git.Checkwd() {
+	case git.NotRepository:
+		Die(ginerrors.NotInRepo)
+	case git.NotAnnex:
+		Warn(ginerrors.MissingAnnex)
+	case git.UpgradeRequired:
+		annexVersionNotice()
+	}
+	if _, err := ginclient.DefaultRemote(); err != nil && len(remotes) == 0 {
+		Die("watch failed: no remote configured")
+	}
+
+	paths := changeToRepoRoot(args, true)
+	watcher, err := fsnotify.NewWatcher()
+	CheckError(err)
+	defer watcher.Close()
+	for _, p := range paths {
+		dir := p
+		if info, err := os.Stat(p); err != nil {
+			Die(fmt.Sprintf("cannot watch '%s': %v", p, err))
+		} else if !info.IsDir() {
+			// watch the parent directory; events for other files in it are filtered out
+			dir = filepath.Dir(p)
+		}
+		CheckError(addWatchDirs(watcher, dir))
+	}
+
+	if prStyle.showHeaders() {
+		fmt.Printf(":: Watching for changes (upload interval %s); press Ctrl+C to stop\n", interval)
+	}
+	var timer <-chan time.Time
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !watchedPath(event.Name, paths) {
+				continue
+			}
+			log.Write("watch: %s", event)
+			watchNewDir(watcher, event)
+			// restart the interval on every change so that an upload starts only after changes have settled
+			timer = time.After(interval)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return
+			}
+			Warn(fmt.Sprintf("file watcher error: %v", err))
+		case <-timer:
+			timer = nil
+			watchUpload(gincl, paths, remotes, prStyle)
+			// the upload itself modifies the watched files (e.g., when their content is moved to the annex), so the events that arrived during the upload are discarded
+			// and the working tree is checked for changes that were made during the upload instead
+			drainEvents(watcher, paths)
+			timer = rearmTimer(paths, interval)
+		}
+	}
+}
+
+// watchUpload commits and uploads the changes under the watched paths and reports the result.
+// Failures are reported as warnings so that watching can continue.
+func watchUpload(gincl *ginclient.Client, paths []string, remotes []string, prStyle printstyle) {
+	if prStyle.showHeaders() {
+		fmt.Printf(":: [%s] Uploading changes\n", time.Now().Format("2006-01-02 15:04:05"))
+	}
+	statuschan := make(chan git.RepoFileStatus)
+	go gincl.CommitAndUpload(context.Background(), paths, makeCommitMessage("watch", paths), remotes, statuschan)
+	summary := newTransferSummary()
+	filesuccess := printStatus(summary.collect(statuschan), prStyle, 0)
+	summary.print(prStyle)
+	nerrors := 0
+	for _, stat := range filesuccess {
+		if !stat {
+			nerrors++
+		}
+	}
+	if nerrors > 0 {
+		Warn(fmt.Sprintf("%d operation(s) failed during upload; retrying on next change", nerrors))
+		return
+	}
+	hookremotes := remotes
+	if len(hookremotes) == 0 {
+		defremote, _ := ginclient.DefaultRemote()
+		hookremotes = []string{defremote}
+	}
+	runHook("upload", hookEnv("upload", hookremotes, summary))
+}
+
+// addWatchDirs adds the directory 'root' and all its subdirectories to the watcher, skipping the git directory.
+func addWatchDirs(watcher *fsnotify.Watcher, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if info.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		return watcher.Add(path)
+	})
+}
+
+// watchedPath returns true if the file 'name' (relative to the repository root) is one of the given paths or inside one of them.
+// Files in the git directory are never watched.
+func watchedPath(name string, paths []string) bool {
+	name = filepath.Clean(name)
+	if name == ".git" || strings.HasPrefix(name, ".git"+string(filepath.Separator)) {
+		return false
+	}
+	for _, p := range paths {
+		p = filepath.Clean(p)
+		if p == "." || name == p || strings.HasPrefix(name, p+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// watchNewDir adds a directory that was created under the watched paths to the watcher, along with its subdirectories.
+func watchNewDir(watcher *fsnotify.Watcher, event fsnotify.Event) {
+	if event.Op&fsnotify.Create == 0 {
+		return
+	}
+	if info, err := os.Lstat(event.Name); err == nil && info.IsDir() {
+		if err := addWatchDirs(watcher, event.Name); err != nil {
+			Warn(fmt.Sprintf("cannot watch new directory '%s': %v", event.Name, err))
+		}
+	}
+}
+
+// drainEvents discards the events that are waiting in the watcher's event channel.
+// Directories that were created under the watched paths are still added to the watcher.
+func drainEvents(watcher *fsnotify.Watcher, paths []string) {
+	for {
+		select {
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return
+			}
+			if !watchedPath(event.Name, paths) {
+				continue
+			}
+			log.Write("watch: ignoring %s", event)
+			watchNewDir(watcher, event)
+		default:
+			return
+		}
+	}
+}
+
+// rearmTimer returns a timer for the next upload if the watched paths have changes that were not recorded by the last upload (e.g., files that were written while the upload was running), or nil if there are none.
+// If the working tree cannot be checked, the timer is returned so that the upload is retried.
+func rearmTimer(paths []string, interval time.Duration) <-chan time.Time {
+	pending, err := ginclient.PendingChanges(paths...)
+	if err != nil {
+		log.Write("watch: %v", err)
+		return time.After(interval)
+	}
+	if !pending {
+		return nil
+	}
+	log.Write("watch: changes remain after upload")
+	return time.After(interval)
+}
+
+// WatchCmd sets up the 'watch' subcommand
+func WatchCmd() *cobra.Command {
+	description := `Watch the files of a local repository for changes and upload them automatically. This command must be called from within the local repository clone and runs until it is interrupted (Ctrl+C).
+
+When files change, the command waits until no further changes have been made for the duration of the interval and then records and uploads the changes, the same way as 'gin upload' does. Each upload is reported as it happens. This can be used to continuously back up data while it is being collected.
+
+If directories or files are specified, only changes to those paths are uploaded. Otherwise, the current directory is watched.
+
+You can specify which remotes the content will be uploaded to using the --to flag, as with 'gin upload'.`
+
+	args := map[string]string{"<filenames>": "One or more directories or files to watch."}
+	examples := map[string]string{
+		"Upload changes in the 'recordings' directory after 30 seconds without changes": "$ gin watch --interval 30s recordings",
+		"Upload all changes to the remotes named 'gin' and 'labdata'":                   "$ gin watch --to gin,labdata",
+	}
+	var cmd = &cobra.Command{
+		Use:                   "watch [--json] [--interval <duration>] [--to <remote>] [<filenames>]...",
+		Short:                 "Upload changes automatically as files change",
+		Long:                  formatdesc(description, args),
+		Example:               formatexamples(examples),
+		Args:                  cobra.ArbitraryArgs,
+		Run:                   watch,
+		DisableFlagsInUseLine: true,
+	}
+	cmd.Flags().Bool("json", false, jsonHelpMsg)
+	cmd.Flags().Duration("interval", 10*time.Second, "Wait until no changes have been made for `duration` (e.g., 30s, 5m) before uploading.")
+	cmd.Flags().StringSliceP("to", "t", nil, "Upload to specific `remote`. Supports multiple remotes, either by specifying multiple times or as a comma separated list.")
+	return cmd
+}
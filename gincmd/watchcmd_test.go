@@ -0,0 +1,77 @@
+package gincmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/G-Node/gin-cli/git"
+)
+
+func TestWatchedPath(t *testing.T) {
+	paths := []string{"data", filepath.Join("notes", "log.txt")}
+	cases := map[string]bool{
+		"data":                                   true,
+		filepath.Join("data", "rec01.nix"):       true,
+		filepath.Join("data", "sub", "rec.nix"):  true,
+		filepath.Join("notes", "log.txt"):        true,
+		filepath.Join("notes", "other.txt"):      false,
+		"database":                               false,
+		filepath.Join(".git", "index"):           false,
+		filepath.Join("data", "..", "other.txt"): false,
+	}
+	for name, expected := range cases {
+		if watched := watchedPath(name, paths); watched != expected {
+			t.Errorf("watchedPath(%q): expected %t, got %t", name, expected, watched)
+		}
+	}
+	if !watchedPath(filepath.Join("any", "file"), []string{"."}) {
+		t.Error("All files should be watched when watching the repository root")
+	}
+	if watchedPath(filepath.Join(".git", "index"), []string{"."}) {
+		t.Error("Files in the git directory should never be watched")
+	}
+}
+
+func TestRearmTimer(t *testing.T) {
+	testdir, err := ioutil.TempDir("", "gin-watch-test-")
+	if err != nil {
+		t.Fatalf("Failed to create temporary directory: %s", err.Error())
+	}
+	defer os.RemoveAll(testdir)
+	origdir, _ := os.Getwd()
+	defer os.Chdir(origdir)
+	os.Chdir(testdir)
+	if err := git.Init(false); err != nil {
+		t.Fatalf("Failed to initialise repository: %s", err.Error())
+	}
+	git.ConfigSet("user.name", "testuser")
+	git.ConfigSet("user.email", "testuser@example.com")
+	os.Mkdir("data", 0777)
+	ioutil.WriteFile(filepath.Join("data", "rec01.nix"), []byte("recording"), 0666)
+	git.Command("add", "data").Run()
+	if err := git.Commit("add recording"); err != nil {
+		t.Fatalf("Failed to commit: %s", err.Error())
+	}
+
+	if timer := rearmTimer([]string{"data"}, time.Millisecond); timer != nil {
+		t.Error("Timer should not be re-armed without changes")
+	}
+
+	// a file written while the upload was running
+	ioutil.WriteFile(filepath.Join("data", "rec02.nix"), []byte("new recording"), 0666)
+	timer := rearmTimer([]string{"data"}, time.Millisecond)
+	if timer == nil {
+		t.Fatal("Timer should be re-armed for changes made during the upload")
+	}
+	select {
+	case <-timer:
+	case <-time.After(5 * time.Second):
+		t.Error("Re-armed timer did not fire")
+	}
+	if timer := rearmTimer([]string{"other"}, time.Millisecond); timer != nil {
+		t.Error("Timer should not be re-armed for changes outside the watched paths")
+	}
+}
@@ -2,10 +2,15 @@ package git
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -23,6 +28,10 @@ const addedToGitNote = "non-large file; adding content to git repository"
 // RawMode disables --json output for annex commands
 var RawMode bool = false
 
+// AddUnlocked makes AnnexAdd add files unlocked, regardless of the annex.addunlocked option of the repository, so that they remain editable after they are added.
+// Unlocked files use more disk space, since their content is kept both in the working tree and in the annex.
+var AddUnlocked = false
+
 // Types (private)
 type annexAction struct {
 	Command string   `json:"command"`
@@ -72,19 +81,29 @@ type AnnexFindRes struct {
 
 // AnnexWhereisRes holds the output of a "git annex whereis" command
 type AnnexWhereisRes struct {
-	File      string   `json:"file"`
-	Command   string   `json:"command"`
-	Note      string   `json:"note"`
-	Success   bool     `json:"success"`
-	Untrusted []string `json:"untrusted"`
-	Key       string   `json:"key"`
-	Whereis   []struct {
-		Here        bool     `json:"here"`
-		UUID        string   `json:"uuid"`
-		URLs        []string `json:"urls"`
-		Description string   `json:"description"`
-	}
-	Err error `json:"err"`
+	File      string          `json:"file"`
+	Command   string          `json:"command"`
+	Note      string          `json:"note"`
+	Success   bool            `json:"success"`
+	Untrusted []string        `json:"untrusted"`
+	Key       string          `json:"key"`
+	Whereis   []AnnexLocation `json:"whereis"`
+	Err       error           `json:"err"`
+}
+
+// AnnexLocation is a repository that holds the content of an annexed file, as reported by "git annex whereis".
+type AnnexLocation struct {
+	Here        bool     `json:"here"`
+	UUID        string   `json:"uuid"`
+	URLs        []string `json:"urls"`
+	Description string   `json:"description"`
+}
+
+// AnnexMetadataRes holds the metadata of an annexed file, as reported by "git annex metadata"
+type AnnexMetadataRes struct {
+	File   string              `json:"file"`
+	Key    string              `json:"key"`
+	Fields map[string][]string `json:"fields"`
 }
 
 // AnnexStatusRes for getting the (annex) status of individual files
@@ -94,32 +113,57 @@ type AnnexStatusRes struct {
 	Err    error  `json:"err"`
 }
 
-// AnnexInfoRes holds the information returned by AnnexInfo
+// SpecialRemote holds the configuration of a git-annex special remote, as recorded in the git-annex branch.
+type SpecialRemote struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	UUID string `json:"uuid"`
+	// Enabled is true if the special remote is enabled in the local repository.
+	Enabled bool `json:"enabled"`
+	// Config holds all the parameters of the special remote, including the name and type.
+	Config map[string]string `json:"-"`
+}
+
+// CredentialVars returns the environment variables that git-annex reads the credentials for the special remote from when they are not stored in the repository.
+// The list is empty for remote types that do not need credentials, or if the credentials are embedded in the repository (embedcreds=yes).
+func (r SpecialRemote) CredentialVars() []string {
+	if r.Config["embedcreds"] == "yes" {
+		return nil
+	}
+	switch strings.ToLower(r.Type) {
+	case "s3", "glacier":
+		return []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY"}
+	case "webdav":
+		return []string{"WEBDAV_USERNAME", "WEBDAV_PASSWORD"}
+	}
+	return nil
+}
+
+// AnnexInfoRes holds the information returned by AnnexInfo.
+// Sizes are in bytes. Values that are not reported by the installed version of git-annex are zero.
 type AnnexInfoRes struct {
-	TransfersInProgress             []interface{} `json:"transfers in progress"`
-	LocalAnnexKeys                  int           `json:"local annex keys"`
-	AvailableLocalDiskSpace         string        `json:"available local disk space"`
-	AnnexedFilesInWorkingTree       int           `json:"annexed files in working tree"`
-	File                            interface{}   `json:"file"`
-	TrustedRepositories             []interface{} `json:"trusted repositories"`
-	SizeOfAnnexedFilesInWorkingTree string        `json:"size of annexed files in working tree"`
-	LocalAnnexSize                  string        `json:"local annex size"`
-	Command                         string        `json:"command"`
-	UntrustedRepositories           []interface{} `json:"untrusted repositories"`
-	SemitrustedRepositories         []struct {
-		Description string `json:"description"`
-		Here        bool   `json:"here"`
-		UUID        string `json:"uuid"`
-	} `json:"semitrusted repositories"`
-	Success         bool   `json:"success"`
-	BloomFilterSize string `json:"bloom filter size"`
-	BackendUsage    struct {
-		SHA256E int `json:"SHA256E"`
-		WORM    int `json:"WORM"`
-	} `json:"backend usage"`
-	RepositoryMode string `json:"repository mode"`
+	RepositoryMode          string          `json:"repositorymode"`
+	AnnexedFiles            int64           `json:"annexedfiles"`
+	AnnexedSize             int64           `json:"annexedsize"`
+	LocalAnnexKeys          int64           `json:"localannexkeys"`
+	LocalAnnexSize          int64           `json:"localannexsize"`
+	AvailableLocalDiskSpace int64           `json:"availablelocaldiskspace"`
+	Repositories            []AnnexRepoInfo `json:"repositories"`
+	Success                 bool            `json:"success"`
+}
+
+// AnnexRepoInfo describes a repository that is known to the annex and the level of trust it has been given.
+type AnnexRepoInfo struct {
+	UUID        string `json:"uuid"`
+	Description string `json:"description"`
+	Here        bool   `json:"here"`
+	// Trust is one of "trusted", "semitrusted", "untrusted", or "dead".
+	Trust string `json:"trust"`
 }
 
+// annexTrustLevels are the trust levels in the order in which they are listed by git annex info.
+var annexTrustLevels = []string{"trusted", "semitrusted", "untrusted", "dead"}
+
 // AnnexInit initialises the repository for annex.
 // (git annex init)
 func AnnexInit(description string) error {
@@ -152,9 +196,10 @@ func AnnexInit(description string) error {
 
 // AnnexPull downloads all annexed files. Optionally also downloads all file content.
 // (git annex sync --no-push [--content])
-func AnnexPull(remote string) error {
+func AnnexPull(ctx context.Context, remote string) error {
 	args := []string{"sync", "--verbose", "--no-push", "--no-commit", remote}
-	cmd := AnnexCommand(args...)
+	cmd := AnnexCommandContext(ctx, args...)
+	withCredentials(&cmd, remote)
 	stdout, stderr, err := cmd.OutputError()
 	sstdout := string(stdout)
 	sstderr := string(stderr)
@@ -167,15 +212,18 @@ func AnnexPull(remote string) error {
 
 	// some conflicts are resolved automatically and don't produce an error in some combinations
 	if err := checkMergeErrors(sstdout, sstderr); err != nil {
-		mergeAbort() // abort a potential failed merge attempt
-		return fmt.Errorf("download failed: %v", err)
+		// abort a potential failed merge attempt
+		return fmt.Errorf("download failed: %v", abortConflictedMerge(err))
 	}
 
 	if err != nil { // command actually failed
 		log.Write("Error during AnnexPull")
 		log.Write("[Error]: %v", err)
 		logstd(stdout, stderr)
-		mergeAbort() // abort a potential failed merge attempt (that wasn't caught earlier)
+		// abort a potential failed merge attempt (that wasn't caught earlier)
+		if merr := abortConflictedMerge(nil); merr != nil {
+			return fmt.Errorf("download failed: %v", merr)
+		}
 
 		// since we don't know what the error was, show the internal annex sync
 		// error to the user
@@ -185,15 +233,75 @@ func AnnexPull(remote string) error {
 	return nil
 }
 
+// AnnexPullRebase downloads all changes from a remote and rebases any local commits on top of the remote branch, instead of merging (see AnnexPull).
+// Uncommitted local changes are kept.
+// If the rebase fails, it is aborted and the repository is left unchanged.
+// (git fetch; git rebase --autostash <remote>/<branch>; git annex merge)
+func AnnexPullRebase(ctx context.Context, remote string) error {
+	fn := fmt.Sprintf("AnnexPullRebase(%s)", remote)
+	branch, err := CurrentBranch()
+	if err != nil {
+		return err
+	}
+	if strings.HasPrefix(branch, "adjusted/") {
+		return giterror{Origin: fn, Description: "download failed: --rebase is not supported in repositories with adjusted (unlocked) branches"}
+	}
+
+	cmd := CommandContext(ctx, "fetch", remote)
+	withCredentials(&cmd, remote)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during fetch")
+		logstd(stdout, stderr)
+		if serr := parseSyncErrors(string(stderr)); serr != nil {
+			return fmt.Errorf("download failed: %v", serr)
+		}
+		return giterror{UError: string(stderr), Origin: fn, Description: "download failed"}
+	}
+
+	upstream := fmt.Sprintf("%s/%s", remote, branch)
+	cmd = CommandContext(ctx, "rebase", "--autostash", upstream)
+	stdout, stderr, err = cmd.OutputError()
+	if err != nil {
+		log.Write("Error during rebase")
+		logstd(stdout, stderr)
+		conflicts, _ := UnmergedFiles()
+		Command("rebase", "--abort").Run()
+		if len(conflicts) > 0 {
+			return fmt.Errorf("download failed: files changed locally and remotely and local commits cannot be rebased (conflict):\n  %s\nThe rebase was cancelled and your local files were not changed.\nTo resolve the conflict, rename or revert your local changes to the listed files and download again", strings.Join(conflicts, "\n  "))
+		}
+		if strings.Contains(string(stderr), "invalid upstream") || strings.Contains(string(stderr), "unknown revision") {
+			return giterror{UError: string(stderr), Origin: fn, Description: fmt.Sprintf("download failed: remote branch '%s' does not exist", upstream)}
+		}
+		return giterror{UError: string(stderr), Origin: fn, Description: "download failed: rebase failed and was cancelled"}
+	}
+
+	// merge the git-annex branch to update content location information
+	cmd = AnnexCommandContext(ctx, "merge")
+	stdout, stderr, err = cmd.OutputError()
+	if err != nil {
+		log.Write("Error during annex merge")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: fn, Description: "download failed: failed to merge annex information"}
+	}
+	return nil
+}
+
 // AnnexSync performs a bidirectional synchronisation between local and remote
 // repositories, automatically resolving merge conflicts.
+// If content is false, only changes to the repository and the annex location tracking information are synchronised and no file content is transferred, regardless of the annex.synccontent configuration.
+// If remotes are specified, only those remotes are synchronised; otherwise all remotes are used.
 // (git annex sync --resolvemerge)
-func AnnexSync(content bool) error {
+func AnnexSync(content bool, remotes ...string) error {
 	cmdargs := []string{"sync", "--verbose", "--resolvemerge"}
 	if content {
 		cmdargs = append(cmdargs, "--content")
+	} else {
+		cmdargs = append(cmdargs, "--no-content")
 	}
+	cmdargs = append(cmdargs, remotes...)
 	cmd := AnnexCommand(cmdargs...)
+	withCredentials(&cmd, remotes...)
 	stdout, stderr, err := cmd.OutputError()
 	sstdout := string(stdout)
 	sstderr := string(stderr)
@@ -206,15 +314,18 @@ func AnnexSync(content bool) error {
 
 	// some conflicts are resolved automatically and don't produce an error in some combinations
 	if err := checkMergeErrors(sstdout, sstderr); err != nil {
-		mergeAbort() // abort a potential failed merge attempt
-		return fmt.Errorf("sync failed: %v", err)
+		// abort a potential failed merge attempt
+		return fmt.Errorf("sync failed: %v", abortConflictedMerge(err))
 	}
 
 	if err != nil { // command actually failed
 		log.Write("Error during AnnexSync")
 		log.Write("[Error]: %v", err)
 		logstd(stdout, stderr)
-		mergeAbort() // abort a potential failed merge attempt (that wasn't caught earlier)
+		// abort a potential failed merge attempt (that wasn't caught earlier)
+		if merr := abortConflictedMerge(nil); merr != nil {
+			return fmt.Errorf("sync failed: %v", merr)
+		}
 
 		// since we don't know what the error was, show the internal annex sync
 		// error to the user
@@ -226,9 +337,10 @@ func AnnexSync(content bool) error {
 // AnnexPush uploads all changes and new content to the default remote.
 // The status channel 'pushchan' is closed when this function returns.
 // (git annex sync --no-pull; git annex copy --to=<defaultremote>)
-func AnnexPush(paths []string, remote string, pushchan chan<- RepoFileStatus) {
+func AnnexPush(ctx context.Context, paths []string, remote string, pushchan chan<- RepoFileStatus) {
 	defer close(pushchan)
-	cmd := AnnexCommand("sync", "--verbose", "--no-pull", "--no-commit", remote) // NEVER commit changes when doing annex-sync
+	cmd := AnnexCommandContext(ctx, "sync", "--verbose", "--no-pull", "--no-commit", remote) // NEVER commit changes when doing annex-sync
+	withCredentials(&cmd, remote)
 	stdout, stderr, err := cmd.OutputError()
 	sstderr := string(stderr)
 
@@ -276,7 +388,8 @@ func AnnexPush(paths []string, remote string, pushchan chan<- RepoFileStatus) {
 	}
 	args = append(args, paths...)
 
-	cmd = AnnexCommand(args...)
+	cmd = AnnexCommandContext(ctx, args...)
+	withCredentials(&cmd, remote)
 	err = cmd.Start()
 	if err != nil {
 		pushchan <- RepoFileStatus{Err: err}
@@ -324,12 +437,15 @@ func AnnexPush(paths []string, remote string, pushchan chan<- RepoFileStatus) {
 				continue
 			}
 			status.FileName = getresult.File
+			status.Size = KeySize(getresult.Key)
 			if getresult.Success {
 				status.Progress = progcomplete
 				status.Err = nil
 			} else {
 				errmsg := getresult.Note
-				if strings.Contains(errmsg, "Unable to access") {
+				if isQuotaError(errmsg + strings.Join(getresult.Errors, "\n")) {
+					errmsg = quotaErrMsg
+				} else if strings.Contains(errmsg, "Unable to access") {
 					errmsg = "authorisation failed or remote storage unavailable"
 				}
 				status.Err = fmt.Errorf("failed: %s", errmsg)
@@ -347,6 +463,7 @@ func AnnexPush(paths []string, remote string, pushchan chan<- RepoFileStatus) {
 			}
 			// otherwise the same name as before is used
 			status.Progress = progress.PercentProgress
+			status.Size = int64(progress.TotalSize)
 
 			dbytes := progress.ByteProgress - prevByteProgress
 			now := time.Now()
@@ -374,15 +491,22 @@ func AnnexPush(paths []string, remote string, pushchan chan<- RepoFileStatus) {
 	return
 }
 
-func baseAnnexGet(cmdargs []string, getchan chan<- RepoFileStatus) {
-	cmd := AnnexCommand(cmdargs...)
+func baseAnnexGet(ctx context.Context, cmdargs []string, getchan chan<- RepoFileStatus) {
+	baseAnnexTransfer(ctx, cmdargs, nil, "Downloading", getchan)
+}
+
+// baseAnnexTransfer runs a git-annex command that transfers content with --json-progress output (e.g., get or move) and sends the progress of each file on the status channel with the given state.
+// The command is given the credentials for the specified remotes, or for all remotes if none are specified (see withCredentials).
+func baseAnnexTransfer(ctx context.Context, cmdargs []string, remotes []string, state string, getchan chan<- RepoFileStatus) {
+	cmd := AnnexCommandContext(ctx, cmdargs...)
+	withCredentials(&cmd, remotes...)
 	if err := cmd.Start(); err != nil {
 		getchan <- RepoFileStatus{Err: err}
 		return
 	}
 
 	var status RepoFileStatus
-	status.State = "Downloading"
+	status.State = state
 
 	var outline []byte
 	var rerr error
@@ -411,12 +535,13 @@ func baseAnnexGet(cmdargs []string, getchan chan<- RepoFileStatus) {
 			err = json.Unmarshal(outline, &getresult)
 			if err != nil || getresult.Command == "" {
 				// Couldn't parse output
-				log.Write("Could not parse 'git annex get' output")
+				log.Write("Could not parse 'git annex %s' output", cmdargs[0])
 				log.Write(string(outline))
 				// TODO: Print error at the end: Command succeeded but there was an error understanding the output
 				continue
 			}
 			status.FileName = getresult.File
+			status.Size = KeySize(getresult.Key)
 			if getresult.Success {
 				status.Progress = progcomplete
 				status.Err = nil
@@ -430,6 +555,7 @@ func baseAnnexGet(cmdargs []string, getchan chan<- RepoFileStatus) {
 		} else {
 			status.FileName = progress.Action.File
 			status.Progress = progress.PercentProgress
+			status.Size = int64(progress.TotalSize)
 			dbytes := progress.ByteProgress - prevByteProgress
 			now := time.Now()
 			dt := now.Sub(prevT)
@@ -446,23 +572,244 @@ func baseAnnexGet(cmdargs []string, getchan chan<- RepoFileStatus) {
 		for rerr = nil; rerr == nil; errline, rerr = cmd.OutReader.ReadBytes('\000') {
 			stderr = append(stderr, errline...)
 		}
-		log.Write("Error during AnnexGet")
+		log.Write("Error during git annex %s", cmdargs[0])
 		log.Write(string(stderr))
 	}
 	return
 }
 
+// AnnexMove moves the content of the specified files from one remote to another.
+// The content is removed from the source remote only after it has been transferred to the destination, and only if enough copies remain (see AnnexCheckCopies).
+// If no paths are specified, the content of all files under the working directory is moved.
+// The status channel 'movechan' is closed when this function returns.
+// (git annex move --from=<src> --to=<dst>)
+func AnnexMove(ctx context.Context, paths []string, src, dst string, movechan chan<- RepoFileStatus) {
+	defer close(movechan)
+	outflag := "--json-progress"
+	if RawMode {
+		outflag = "--verbose"
+	}
+	cmdargs := []string{"move", outflag, fmt.Sprintf("--from=%s", src), fmt.Sprintf("--to=%s", dst)}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	cmdargs = append(cmdargs, paths...)
+	baseAnnexTransfer(ctx, cmdargs, []string{src, dst}, fmt.Sprintf("Moving (%s to %s)", src, dst), movechan)
+}
+
+// AnnexCopy copies the content of the specified files to a remote, keeping the local copy.
+// Only content that is available locally and missing from the remote is transferred.
+// If no paths are specified, the content of all files under the working directory is copied.
+// The status channel 'copychan' is closed when this function returns.
+// (git annex copy --to=<dst>)
+func AnnexCopy(ctx context.Context, paths []string, dst string, copychan chan<- RepoFileStatus) {
+	defer close(copychan)
+	outflag := "--json-progress"
+	if RawMode {
+		outflag = "--verbose"
+	}
+	cmdargs := []string{"copy", outflag, fmt.Sprintf("--to=%s", dst)}
+	if len(paths) == 0 {
+		paths = []string{"."}
+	}
+	cmdargs = append(cmdargs, paths...)
+	baseAnnexTransfer(ctx, cmdargs, []string{dst}, fmt.Sprintf("Copying (to: %s)", dst), copychan)
+}
+
+// AnnexAddURL adds a file to the annex whose content is retrieved from the given URL.
+// If filepath is empty, the file name is derived from the URL.
+// With 'fast', the content is not downloaded and only the URL is recorded (the size is checked).
+// With 'relaxed', the URL is not contacted at all.
+// The status channel 'addchan' is closed when this function returns.
+// (git annex addurl)
+func AnnexAddURL(ctx context.Context, url, filepath string, fast, relaxed bool, addchan chan<- RepoFileStatus) {
+	defer close(addchan)
+	cmdargs := []string{"addurl"}
+	if !RawMode {
+		cmdargs = append(cmdargs, "--json", "--json-progress", "--json-error-messages")
+	}
+	if fast {
+		cmdargs = append(cmdargs, "--fast")
+	}
+	if relaxed {
+		cmdargs = append(cmdargs, "--relaxed")
+	}
+	if filepath != "" {
+		cmdargs = append(cmdargs, "--file", filepath)
+	}
+	cmdargs = append(cmdargs, url)
+	cmd := AnnexCommandContext(ctx, cmdargs...)
+	if err := cmd.Start(); err != nil {
+		addchan <- RepoFileStatus{Err: err}
+		return
+	}
+
+	var status RepoFileStatus
+	status.State = "Adding from URL"
+	status.FileName = filepath
+
+	var outline []byte
+	var rerr error
+	var progress annexProgress
+	var addresult annexAction
+	var prevByteProgress int
+	var prevT time.Time
+	var added bool
+
+	for rerr = nil; rerr == nil; outline, rerr = cmd.OutReader.ReadBytes('\n') {
+		if len(outline) == 0 {
+			// skip empty lines
+			continue
+		}
+
+		if RawMode {
+			status.RawInput = strings.Join(cmd.Args, " ")
+			status.RawOutput = string(outline)
+			addchan <- status
+			continue
+		}
+		progress = annexProgress{}
+		err := json.Unmarshal(outline, &progress)
+		if err != nil || progress.Action.Command == "" {
+			addresult = annexAction{}
+			err = json.Unmarshal(outline, &addresult)
+			if err != nil || addresult.Command == "" {
+				// Couldn't parse output
+				log.Write("Could not parse 'git annex addurl' output")
+				log.Write(string(outline))
+				continue
+			}
+			if addresult.File != "" {
+				status.FileName = addresult.File
+			}
+			status.Size = KeySize(addresult.Key)
+			if addresult.Success {
+				log.Write("%s added from %s", status.FileName, url)
+				status.State = "Added from URL"
+				status.Progress = progcomplete
+				status.Err = nil
+				added = true
+			} else {
+				log.Write("Error adding %s from %s", status.FileName, url)
+				errmsg := strings.Join(addresult.Errors, "; ")
+				if errmsg == "" {
+					errmsg = addresult.Note
+				}
+				status.Err = fmt.Errorf("failed: %s", errmsg)
+			}
+		} else {
+			if progress.Action.File != "" {
+				status.FileName = progress.Action.File
+			}
+			status.Progress = progress.PercentProgress
+			status.Size = int64(progress.TotalSize)
+			dbytes := progress.ByteProgress - prevByteProgress
+			now := time.Now()
+			status.Rate = calcRate(dbytes, now.Sub(prevT))
+			prevByteProgress = progress.ByteProgress
+			prevT = now
+			status.Err = nil
+		}
+		addchan <- status
+	}
+	if cmd.Wait() != nil {
+		var stderr, errline []byte
+		for rerr = nil; rerr == nil; errline, rerr = cmd.ErrReader.ReadBytes('\000') {
+			stderr = append(stderr, errline...)
+		}
+		log.Write("Error during AnnexAddURL")
+		logstd(nil, stderr)
+		if !added && status.Err == nil {
+			addchan <- RepoFileStatus{FileName: filepath, State: "Adding from URL", Err: fmt.Errorf("failed: %s", strings.TrimSpace(string(stderr)))}
+		}
+		return
+	}
+	if added && !RawMode {
+		setAnnexMetadataName(status.FileName)
+	}
+}
+
 // AnnexGet retrieves the content of specified files.
 // The status channel 'getchan' is closed when this function returns.
 // (git annex get)
-func AnnexGet(filepaths []string, getchan chan<- RepoFileStatus) {
+func AnnexGet(ctx context.Context, filepaths []string, getchan chan<- RepoFileStatus) {
 	defer close(getchan)
 	cmdargs := []string{"get"}
 	if !RawMode {
 		cmdargs = append(cmdargs, "--json-progress")
 	}
 	cmdargs = append(cmdargs, filepaths...)
-	baseAnnexGet(cmdargs, getchan)
+	baseAnnexGet(ctx, cmdargs, getchan)
+}
+
+// AnnexVerify checks the integrity of the locally available content of the specified files.
+// Content that fails the check is moved out of the local repository by git-annex, so that it can be retrieved again.
+// Files that fail the check are reported with an error.
+// The status channel 'verifychan' is closed when this function returns.
+// (git annex fsck)
+func AnnexVerify(ctx context.Context, filepaths []string, verifychan chan<- RepoFileStatus) {
+	defer close(verifychan)
+	cmdargs := []string{"fsck", "--json"}
+	cmdargs = append(cmdargs, filepaths...)
+	runAnnexFsck(AnnexCommandContext(ctx, cmdargs...), "Verifying content", "content verification failed", verifychan)
+}
+
+// AnnexCheckCopies checks whether at least numcopies copies of the content of the specified files exist, according to the location information known to the local repository.
+// If numcopies is 0, the configured number of copies (annex.numcopies) is used.
+// Content is not verified and no content is transferred or removed.
+// Files with too few copies are reported with an error.
+// The status channel 'checkchan' is closed when this function returns.
+// (git annex fsck --fast --numcopies)
+func AnnexCheckCopies(filepaths []string, numcopies int, checkchan chan<- RepoFileStatus) {
+	defer close(checkchan)
+	cmdargs := []string{"fsck", "--fast", "--json", "--json-error-messages"}
+	if numcopies > 0 {
+		cmdargs = append(cmdargs, fmt.Sprintf("--numcopies=%d", numcopies))
+	}
+	cmdargs = append(cmdargs, filepaths...)
+	runAnnexFsck(AnnexCommand(cmdargs...), "Checking copies", "not enough copies", checkchan)
+}
+
+// runAnnexFsck runs the given 'git annex fsck --json' command and sends the result for each file on fsckchan.
+// The error message of failed files starts with failmsg.
+func runAnnexFsck(cmd shell.Cmd, state, failmsg string, fsckchan chan<- RepoFileStatus) {
+	if err := cmd.Start(); err != nil {
+		fsckchan <- RepoFileStatus{Err: err}
+		return
+	}
+
+	var status RepoFileStatus
+	status.State = state
+	status.RawInput = strings.Join(cmd.Args, " ")
+	var outline []byte
+	var rerr error
+	for rerr = nil; rerr == nil; outline, rerr = cmd.OutReader.ReadBytes('\n') {
+		if len(outline) == 0 {
+			continue
+		}
+		status.RawOutput = string(outline)
+		var fsckresult annexAction
+		if err := json.Unmarshal(outline, &fsckresult); err != nil || fsckresult.Command == "" {
+			log.Write("Could not parse 'git annex fsck' output")
+			log.Write(string(outline))
+			continue
+		}
+		status.FileName = fsckresult.File
+		status.Size = KeySize(fsckresult.Key)
+		if fsckresult.Success {
+			status.Progress = progcomplete
+			status.Err = nil
+		} else {
+			status.Progress = ""
+			status.Err = fmt.Errorf("%s: %s", failmsg, strings.Join(fsckresult.Errors, "; "))
+		}
+		fsckchan <- status
+	}
+	// fsck exits with an error when a check fails; this is reported for
+	// each file above
+	if err := cmd.Wait(); err != nil {
+		log.Write("fsck exited with error: %v", err)
+	}
 }
 
 // AnnexGetKey retrieves the content of a single specified key.
@@ -471,22 +818,28 @@ func AnnexGet(filepaths []string, getchan chan<- RepoFileStatus) {
 func AnnexGetKey(key string, getchan chan<- RepoFileStatus) {
 	defer close(getchan)
 	cmdargs := []string{"get", "--json-progress", fmt.Sprintf("--key=%s", key)}
-	baseAnnexGet(cmdargs, getchan)
+	baseAnnexGet(context.Background(), cmdargs, getchan)
 	return
 }
 
 // AnnexDrop drops the content of specified files.
+// git-annex refuses to drop content that it cannot verify to be available from enough other repositories (see numcopies), unless 'force' is true.
 // The status channel 'dropchan' is closed when this function returns.
 // (git annex drop)
-func AnnexDrop(filepaths []string, dropchan chan<- RepoFileStatus) {
+func AnnexDrop(filepaths []string, force bool, dropchan chan<- RepoFileStatus) {
 	defer close(dropchan)
 	cmdargs := []string{"drop"}
 	if !RawMode {
-		cmdargs = append(cmdargs, "--json")
+		cmdargs = append(cmdargs, "--json", "--json-error-messages")
+	}
+	if force {
+		cmdargs = append(cmdargs, "--force")
 	}
 	cmdargs = append(cmdargs, filepaths...)
 
 	cmd := AnnexCommand(cmdargs...)
+	// remotes are contacted to verify that enough copies remain
+	withCredentials(&cmd)
 	err := cmd.Start()
 	if err != nil {
 		dropchan <- RepoFileStatus{Err: err}
@@ -494,11 +847,12 @@ func AnnexDrop(filepaths []string, dropchan chan<- RepoFileStatus) {
 	}
 	var status RepoFileStatus
 	var annexDropRes struct {
-		Command string `json:"command"`
-		File    string `json:"file"`
-		Key     string `json:"key"`
-		Success bool   `json:"success"`
-		Note    string `json:"note"`
+		Command string   `json:"command"`
+		File    string   `json:"file"`
+		Key     string   `json:"key"`
+		Success bool     `json:"success"`
+		Note    string   `json:"note"`
+		Errors  []string `json:"error-messages"`
 	}
 
 	status.State = "Removing content"
@@ -532,8 +886,10 @@ func AnnexDrop(filepaths []string, dropchan chan<- RepoFileStatus) {
 		} else {
 			log.Write("Error dropping %s", annexDropRes.File)
 			errmsg := annexDropRes.Note
-			if strings.Contains(errmsg, "unsafe") {
-				errmsg = "failed (unsafe): could not verify remote copy"
+			if isUnsafeDrop(errmsg + strings.Join(annexDropRes.Errors, "\n")) {
+				errmsg = unsafeDropErrMsg
+			} else if len(annexDropRes.Errors) > 0 {
+				errmsg = strings.Join(annexDropRes.Errors, "; ")
 			}
 			status.Err = fmt.Errorf(errmsg)
 		}
@@ -551,94 +907,462 @@ func AnnexDrop(filepaths []string, dropchan chan<- RepoFileStatus) {
 	return
 }
 
-// getAnnexMetadataName returns the filename, key, and last modification time stored in the metadata of an annexed file given the key.
-// If an unused key does not have a name associated with it, the filename will be empty.
-func getAnnexMetadataName(key string) annexFilenameDate {
-	var cmdargs []string
-	cmdargs = []string{"metadata", "--json", fmt.Sprintf("--key=%s", key)}
-	cmd := AnnexCommand(cmdargs...)
+// AnnexUnusedRes describes an annexed object that is not used by any file in the current or previous revisions of any branch.
+type AnnexUnusedRes struct {
+	// The number git-annex uses to refer to the object in 'dropunused'
+	Number string `json:"number"`
+	Key    string `json:"key"`
+}
+
+// AnnexUnused lists the annexed objects in the local repository that are no longer used by any file.
+// (git annex unused)
+func AnnexUnused() ([]AnnexUnusedRes, error) {
+	fn := "AnnexUnused()"
+	cmd := AnnexCommand("unused")
 	stdout, stderr, err := cmd.OutputError()
 	if err != nil {
-		log.Write("Error retrieving annexed content metadata")
+		log.Write("Error during AnnexUnused")
 		logstd(stdout, stderr)
-		return annexFilenameDate{}
-	}
-	var annexmd annexMetadata
-	json.Unmarshal(bytes.TrimSpace(stdout), &annexmd)
-	if len(annexmd.Fields.Ginfilename) > 0 {
-		name := annexmd.Fields.Ginfilename[0]
-		modtime, _ := time.Parse("2006-01-02@15-04-05", annexmd.Fields.GinefilenameLC[0])
-		return annexFilenameDate{Key: key, FileName: name, ModTime: modtime}
+		return nil, giterror{UError: string(stderr), Origin: fn}
 	}
-	return annexFilenameDate{Key: key, FileName: annexmd.File}
+	return parseAnnexUnused(string(stdout)), nil
 }
 
-// AnnexWhereis returns information about annexed files in the repository
-// The output channel 'wichan' is closed when this function returns.
-// (git annex whereis)
-func AnnexWhereis(paths []string, wichan chan<- AnnexWhereisRes) {
-	defer close(wichan)
-	cmdargs := []string{"whereis", "--json"}
-	cmdargs = append(cmdargs, paths...)
-	cmd := AnnexCommand(cmdargs...)
-	err := cmd.Start()
-	if err != nil {
-		log.Write("Error during AnnexWhereis")
-		wichan <- AnnexWhereisRes{Err: fmt.Errorf("Failed to run git-annex whereis: %s", err)}
-		return
-	}
-
-	var line string
-	var rerr error
-	var info AnnexWhereisRes
-	for rerr = nil; rerr == nil; line, rerr = cmd.OutReader.ReadString('\n') {
-		line = strings.TrimSpace(line)
-		if len(line) == 0 {
-			// Empty line output. Ignore
+// parseAnnexUnused parses the output of 'git annex unused'.
+// The explanatory text around the list differs between git-annex versions, so
+// only lines that consist of a number followed by a key are used.
+func parseAnnexUnused(output string) []AnnexUnusedRes {
+	// The list of unused objects is printed as a table:
+	//     NUMBER  KEY
+	//     1       SHA256E-s12--...
+	var unused []AnnexUnusedRes
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
 			continue
 		}
-		jsonerr := json.Unmarshal([]byte(line), &info)
-		info.Err = jsonerr
-		wichan <- info
+		if _, err := strconv.Atoi(fields[0]); err != nil {
+			continue
+		}
+		if !strings.Contains(fields[1], "--") {
+			// not a key
+			continue
+		}
+		unused = append(unused, AnnexUnusedRes{Number: fields[0], Key: fields[1]})
 	}
-	return
+	return unused
 }
 
-// AnnexStatus returns the status of a file or files in a directory
-// The output channel 'statuschan' is closed when this function returns.
-// (git annex status)
-func AnnexStatus(paths []string, statuschan chan<- AnnexStatusRes) {
-	defer close(statuschan)
-	cmdargs := []string{"status", "--json"}
+// AnnexListRes holds the presence of the content of annexed files in the repositories known to the annex (see AnnexList).
+type AnnexListRes struct {
+	// Repositories are the names of the repositories in the order of the columns of Present ("here" is the local repository).
+	Repositories []string        `json:"repositories"`
+	Files        []AnnexListFile `json:"files"`
+}
+
+// AnnexListFile holds the presence of the content of a file in each repository of an AnnexListRes.
+type AnnexListFile struct {
+	File string `json:"file"`
+	// Present has one entry for each repository, which is true if the repository has the content of the file.
+	Present []bool `json:"present"`
+}
+
+// AnnexList lists the annexed files under the given paths and whether each of the repositories known to the annex has their content, using the location tracking information (no remote is contacted).
+// This is faster than AnnexWhereis for many files, since git-annex only prints a compact table.
+// If allrepos is true, all repositories are listed, not only the local repository and its remotes.
+// (git annex list)
+func AnnexList(paths []string, allrepos bool) (AnnexListRes, error) {
+	fn := fmt.Sprintf("AnnexList(%v)", paths)
+	cmdargs := []string{"list"}
+	if allrepos {
+		cmdargs = append(cmdargs, "--allrepos")
+	}
 	cmdargs = append(cmdargs, paths...)
 	cmd := AnnexCommand(cmdargs...)
-	// TODO: Parse output
-	err := cmd.Start()
+	stdout, stderr, err := cmd.OutputError()
 	if err != nil {
-		log.Write("Error setting up git-annex status")
-		statuschan <- AnnexStatusRes{Err: fmt.Errorf("Failed to run git-annex status: %s", err)}
-		return
+		log.Write("Error during AnnexList")
+		logstd(stdout, stderr)
+		return AnnexListRes{}, giterror{UError: string(stderr), Origin: fn, Description: "failed to list file content locations"}
 	}
+	return parseAnnexList(string(stdout)), nil
+}
 
-	var line string
-	var rerr error
-	var status AnnexStatusRes
-	for rerr = nil; rerr == nil; line, rerr = cmd.OutReader.ReadString('\n') {
-		line = strings.TrimSpace(line)
-		if len(line) == 0 {
-			// Empty line output. Ignore
+// parseAnnexList parses the output of 'git annex list'.
+func parseAnnexList(output string) AnnexListRes {
+	// The repositories are listed first, each indented by one '|' per
+	// preceding repository, followed by a line with one '|' per repository.
+	// Each file is then printed with one character per repository ('X' if
+	// the repository has the content, 'x' if the repository is untrusted,
+	// '_' if it doesn't) followed by a space and the file name:
+	//     here
+	//     |origin
+	//     ||
+	//     X_ file
+	var res AnnexListRes
+	lines := strings.Split(output, "\n")
+	idx := 0
+	for ; idx < len(lines); idx++ {
+		line := lines[idx]
+		name := strings.TrimLeft(line, "|")
+		if len(line)-len(name) != len(res.Repositories) {
+			// unexpected line; skip it
 			continue
 		}
-		jsonerr := json.Unmarshal([]byte(line), &status)
-		status.Err = jsonerr
-		statuschan <- status
+		if name == "" {
+			idx++
+			break
+		}
+		res.Repositories = append(res.Repositories, name)
 	}
-	return
+	nrepos := len(res.Repositories)
+	for ; idx < len(lines); idx++ {
+		line := lines[idx]
+		if len(line) < nrepos+2 || line[nrepos] != ' ' {
+			continue
+		}
+		present := make([]bool, nrepos)
+		for ridx, mark := range line[:nrepos] {
+			present[ridx] = mark == 'X' || mark == 'x'
+		}
+		res.Files = append(res.Files, AnnexListFile{File: line[nrepos+1:], Present: present})
+	}
+	return res
 }
 
-// AnnexDescribe changes the description of a repository.
-// (git annex describe)
-func AnnexDescribe(repository, description string) error {
+// AnnexWhereisUnused returns the locations of the unused objects found by the last AnnexUnused call, keyed by the object's key.
+// Objects that are not available from any repository have no locations.
+// (git annex whereis --unused)
+func AnnexWhereisUnused() (map[string][]AnnexLocation, error) {
+	fn := "AnnexWhereisUnused()"
+	cmd := AnnexCommand("whereis", "--json", "--unused")
+	// whereis fails when some objects have no copies, so the exit status is
+	// only considered if nothing was printed
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil && len(bytes.TrimSpace(stdout)) == 0 {
+		log.Write("Error during AnnexWhereisUnused")
+		logstd(stdout, stderr)
+		return nil, giterror{UError: string(stderr), Origin: fn}
+	}
+	locations := make(map[string][]AnnexLocation)
+	for _, line := range bytes.Split(stdout, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var info AnnexWhereisRes
+		if jsonerr := json.Unmarshal(line, &info); jsonerr != nil || info.Key == "" {
+			log.Write("Skipping unexpected whereis output: %s", string(line))
+			continue
+		}
+		locations[info.Key] = info.Whereis
+	}
+	return locations, nil
+}
+
+// AnnexDropUnused removes the content of the given unused objects from the local repository.
+// Objects are only removed if they are available from a remote, unless force is true.
+// The objects must have been listed by AnnexUnused beforehand.
+// The status channel 'dropchan' is closed when this function returns.
+// (git annex dropunused)
+func AnnexDropUnused(unused []AnnexUnusedRes, force bool, dropchan chan<- RepoFileStatus) {
+	defer close(dropchan)
+	if len(unused) == 0 {
+		return
+	}
+	cmdargs := []string{"dropunused"}
+	if force {
+		cmdargs = append(cmdargs, "--force")
+	}
+	keys := make(map[string]string, len(unused))
+	for _, obj := range unused {
+		cmdargs = append(cmdargs, obj.Number)
+		keys[obj.Number] = obj.Key
+	}
+	cmd := AnnexCommand(cmdargs...)
+	withCredentials(&cmd)
+	if err := cmd.Start(); err != nil {
+		dropchan <- RepoFileStatus{Err: err}
+		return
+	}
+
+	// Output for each object is of the form
+	//     dropunused <number> [notes...] ok|failed
+	// possibly spanning multiple lines
+	var status RepoFileStatus
+	status.State = "Removing unused content"
+	var line, current string
+	var rerr error
+	for rerr = nil; rerr == nil; line, rerr = cmd.OutReader.ReadString('\n') {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if RawMode {
+			status.RawInput = strings.Join(cmd.Args, " ")
+			status.RawOutput = line
+			dropchan <- status
+			continue
+		}
+		fields := strings.Fields(line)
+		if fields[0] == "dropunused" && len(fields) > 1 {
+			current = fields[1]
+		}
+		if current == "" {
+			continue
+		}
+		status.FileName = keys[current]
+		status.Size = KeySize(keys[current])
+		switch fields[len(fields)-1] {
+		case "ok":
+			status.Err = nil
+		case "failed":
+			status.Err = fmt.Errorf("failed (unsafe): could not verify remote copy")
+		default:
+			continue
+		}
+		status.Progress = progcomplete
+		dropchan <- status
+		current = ""
+	}
+	if cmd.Wait() != nil {
+		var stderr, errline []byte
+		for rerr = nil; rerr == nil; errline, rerr = cmd.ErrReader.ReadBytes('\000') {
+			stderr = append(stderr, errline...)
+		}
+		log.Write("Error during AnnexDropUnused")
+		log.Write("[stderr]\n%s", string(stderr))
+	}
+	return
+}
+
+// getAnnexMetadataName returns the filename, key, and last modification time stored in the metadata of an annexed file given the key.
+// If an unused key does not have a name associated with it, the filename will be empty.
+func getAnnexMetadataName(key string) annexFilenameDate {
+	var cmdargs []string
+	cmdargs = []string{"metadata", "--json", fmt.Sprintf("--key=%s", key)}
+	cmd := AnnexCommand(cmdargs...)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error retrieving annexed content metadata")
+		logstd(stdout, stderr)
+		return annexFilenameDate{}
+	}
+	var annexmd annexMetadata
+	json.Unmarshal(bytes.TrimSpace(stdout), &annexmd)
+	if len(annexmd.Fields.Ginfilename) > 0 {
+		name := annexmd.Fields.Ginfilename[0]
+		modtime, _ := time.Parse("2006-01-02@15-04-05", annexmd.Fields.GinefilenameLC[0])
+		return annexFilenameDate{Key: key, FileName: name, ModTime: modtime}
+	}
+	return annexFilenameDate{Key: key, FileName: annexmd.File}
+}
+
+// AnnexMetadataGet returns the metadata of the annexed files under the given paths.
+// Files that are not annexed are skipped, since metadata can only be attached to annexed content.
+// The fields that record the time of the last change of each value (lastchanged) are included as reported by git-annex.
+// (git annex metadata --json)
+func AnnexMetadataGet(paths []string) ([]AnnexMetadataRes, error) {
+	fn := "AnnexMetadataGet()"
+	cmdargs := append([]string{"metadata", "--json"}, paths...)
+	cmd := AnnexCommand(cmdargs...)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error retrieving annexed content metadata")
+		logstd(stdout, stderr)
+		return nil, giterror{UError: string(stderr), Origin: fn}
+	}
+	var results []AnnexMetadataRes
+	for _, line := range bytes.Split(stdout, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		var md AnnexMetadataRes
+		if err := json.Unmarshal(line, &md); err != nil {
+			log.Write("Could not parse 'git annex metadata' output")
+			log.Write(string(line))
+			continue
+		}
+		results = append(results, md)
+	}
+	return results, nil
+}
+
+// AnnexMetadataSet sets the value of a metadata field for an annexed file, replacing any existing values of the field.
+// An error is returned if the file is not annexed.
+// (git annex metadata --set field=value)
+func AnnexMetadataSet(path, field, value string) error {
+	fn := fmt.Sprintf("AnnexMetadataSet(%s)", path)
+	cmd := AnnexCommand("metadata", "--json", fmt.Sprintf("--set=%s=%s", field, value), path)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error setting annexed content metadata")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: fn, Description: strings.TrimSpace(string(stderr))}
+	}
+	var res annexAction
+	if jerr := json.Unmarshal(bytes.TrimSpace(stdout), &res); jerr != nil || res.Command == "" {
+		// git-annex prints nothing for files it doesn't manage
+		return giterror{UError: string(stdout), Origin: fn, Description: fmt.Sprintf("'%s' is not an annexed file: metadata can only be set on annexed files", path)}
+	}
+	if !res.Success {
+		return giterror{UError: res.Note, Origin: fn, Description: fmt.Sprintf("failed to set metadata on '%s': %s", path, strings.Join(res.Errors, "; "))}
+	}
+	return nil
+}
+
+// AnnexWhereis returns information about annexed files in the repository
+// The output channel 'wichan' is closed when this function returns.
+// (git annex whereis)
+func AnnexWhereis(paths []string, wichan chan<- AnnexWhereisRes) {
+	defer close(wichan)
+	if UseAnnexBatch && batchable(paths) {
+		annexWhereisBatch(paths, wichan)
+		return
+	}
+	cmdargs := []string{"whereis", "--json"}
+	cmdargs = append(cmdargs, paths...)
+	cmd := AnnexCommand(cmdargs...)
+	err := cmd.Start()
+	if err != nil {
+		log.Write("Error during AnnexWhereis")
+		wichan <- AnnexWhereisRes{Err: fmt.Errorf("Failed to run git-annex whereis: %s", err)}
+		return
+	}
+
+	var line string
+	var rerr error
+	var info AnnexWhereisRes
+	for rerr = nil; rerr == nil; line, rerr = cmd.OutReader.ReadString('\n') {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			// Empty line output. Ignore
+			continue
+		}
+		jsonerr := json.Unmarshal([]byte(line), &info)
+		info.Err = jsonerr
+		wichan <- info
+	}
+	cmd.Wait()
+	return
+}
+
+// annexWhereisBatch queries the locations of the content of the given files from a git-annex batch process (see UseAnnexBatch).
+// Files that are not annexed are skipped, as with AnnexWhereis.
+// (git annex whereis --json --batch)
+func annexWhereisBatch(paths []string, wichan chan<- AnnexWhereisRes) {
+	proc, err := annexBatch("whereis", "--json")
+	if err != nil {
+		log.Write("Error during AnnexWhereis")
+		wichan <- AnnexWhereisRes{Err: err}
+		return
+	}
+	for _, p := range paths {
+		line, err := proc.query(p)
+		if err != nil {
+			log.Write("git-annex whereis batch process failed: %v", err)
+			dropAnnexBatch(proc)
+			wichan <- AnnexWhereisRes{Err: fmt.Errorf("Failed to run git-annex whereis: %s", err)}
+			return
+		}
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			// File is not annexed. Ignore
+			continue
+		}
+		var info AnnexWhereisRes
+		info.Err = json.Unmarshal([]byte(line), &info)
+		wichan <- info
+	}
+}
+
+// AnnexLogKeys returns the annex keys whose logs (e.g., the location log, which records the repositories that have the content) differ between two commits of the git-annex branch.
+// (git diff --name-only)
+func AnnexLogKeys(from, to string) ([]string, error) {
+	fn := fmt.Sprintf("AnnexLogKeys(%s, %s)", from, to)
+	cmd := Command("diff", "--name-only", "-z", from, to, "--")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during diff of git-annex branch")
+		logstd(stdout, stderr)
+		return nil, giterror{UError: string(stderr), Origin: fn}
+	}
+	var keys []string
+	for _, name := range strings.Split(string(stdout), "\000") {
+		// log files are stored as <hash>/<hash>/<key>.log, with additional suffixes for some log types (e.g., .log.met)
+		name = path.Base(name)
+		if idx := strings.LastIndex(name, ".log"); idx > 0 {
+			keys = append(keys, name[:idx])
+		}
+	}
+	return keys, nil
+}
+
+// AnnexMerge merges the git-annex branches of the remotes into the local git-annex branch.
+// Most annex commands do this automatically when needed; merging first avoids concurrent annex commands attempting the same merge.
+// Errors are logged and otherwise ignored, since the annex commands that follow will report any problems.
+// (git annex merge)
+func AnnexMerge() {
+	cmd := AnnexCommand("merge")
+	if stdout, stderr, err := cmd.OutputError(); err != nil {
+		log.Write("Failed to merge git-annex branch")
+		logstd(stdout, stderr)
+	}
+}
+
+// AnnexStatus returns the status of a file or files in a directory
+// The output channel 'statuschan' is closed when this function returns.
+// (git annex status)
+func AnnexStatus(paths []string, statuschan chan<- AnnexStatusRes) {
+	defer close(statuschan)
+	cmdargs := []string{"status", "--json"}
+	cmdargs = append(cmdargs, paths...)
+	cmd := AnnexCommand(cmdargs...)
+	// TODO: Parse output
+	err := cmd.Start()
+	if err != nil {
+		log.Write("Error setting up git-annex status")
+		statuschan <- AnnexStatusRes{Err: fmt.Errorf("Failed to run git-annex status: %s", err)}
+		return
+	}
+
+	var line string
+	var rerr error
+	var status AnnexStatusRes
+	for rerr = nil; rerr == nil; line, rerr = cmd.OutReader.ReadString('\n') {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			// Empty line output. Ignore
+			continue
+		}
+		jsonerr := json.Unmarshal([]byte(line), &status)
+		status.Err = jsonerr
+		statuschan <- status
+	}
+	return
+}
+
+// AnnexUpgrade upgrades the annex of the current repository to the latest supported repository version.
+// Repositories in direct mode are converted to an adjusted unlocked branch, where all annexed files are unlocked.
+// (git annex upgrade)
+func AnnexUpgrade() error {
+	cmd := AnnexCommand("upgrade")
+	stdout, stderr, err := cmd.OutputError()
+	// the cached mode is no longer valid
+	abspath, _ := filepath.Abs(".")
+	delete(annexmodecache, abspath)
+	if err != nil {
+		log.Write("Error during AnnexUpgrade")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: "AnnexUpgrade()", Description: "failed to upgrade repository"}
+	}
+	return nil
+}
+
+// AnnexDescribe changes the description of a repository.
+// (git annex describe)
+func AnnexDescribe(repository, description string) error {
 	fn := fmt.Sprintf("AnnexDescribe(%s, %s)", repository, description)
 	cmd := AnnexCommand("describe", repository, description)
 	stdout, stderr, err := cmd.OutputError()
@@ -650,20 +1374,443 @@ func AnnexDescribe(repository, description string) error {
 	return nil
 }
 
-// AnnexInfo returns the annex information for a given repository
-// (git annex info)
+// AnnexWanted returns the preferred content expression of a repository, which determines the content that git-annex stores in it.
+// The repository may be a remote name, a description, a UUID, or "here" for the local repository.
+// An empty string is returned if no expression is set.
+// (git annex wanted <repository>)
+func AnnexWanted(repository string) (string, error) {
+	fn := fmt.Sprintf("AnnexWanted(%s)", repository)
+	cmd := AnnexCommand("wanted", repository)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during AnnexWanted")
+		logstd(stdout, stderr)
+		return "", giterror{Origin: fn, UError: string(stderr)}
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// AnnexSetWanted sets the preferred content expression of a repository (see AnnexWanted).
+// (git annex wanted <repository> <expression>)
+func AnnexSetWanted(repository, expression string) error {
+	fn := fmt.Sprintf("AnnexSetWanted(%s, %s)", repository, expression)
+	cmd := AnnexCommand("wanted", repository, expression)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during AnnexSetWanted")
+		logstd(stdout, stderr)
+		gerr := giterror{Origin: fn, UError: string(stderr)}
+		if strings.Contains(string(stderr), "arse error") {
+			gerr.Description = fmt.Sprintf("invalid preferred content expression '%s'", expression)
+		}
+		return gerr
+	}
+	return nil
+}
+
+// AnnexGroups returns the groups that a repository belongs to.
+// Groups are used in preferred content expressions (e.g., "inallgroup=backup") and some groups have standard preferred content settings (e.g., "archive").
+// (git annex group <repository>)
+func AnnexGroups(repository string) ([]string, error) {
+	fn := fmt.Sprintf("AnnexGroups(%s)", repository)
+	cmd := AnnexCommand("group", repository)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during AnnexGroups")
+		logstd(stdout, stderr)
+		return nil, giterror{Origin: fn, UError: string(stderr)}
+	}
+	return strings.Fields(string(stdout)), nil
+}
+
+// AnnexGroup adds a repository to a group.
+// (git annex group <repository> <group>)
+func AnnexGroup(repository, group string) error {
+	fn := fmt.Sprintf("AnnexGroup(%s, %s)", repository, group)
+	cmd := AnnexCommand("group", repository, group)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during AnnexGroup")
+		logstd(stdout, stderr)
+		return giterror{Origin: fn, UError: string(stderr)}
+	}
+	return nil
+}
+
+// AnnexUngroup removes a repository from a group.
+// (git annex ungroup <repository> <group>)
+func AnnexUngroup(repository, group string) error {
+	fn := fmt.Sprintf("AnnexUngroup(%s, %s)", repository, group)
+	cmd := AnnexCommand("ungroup", repository, group)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during AnnexUngroup")
+		logstd(stdout, stderr)
+		return giterror{Origin: fn, UError: string(stderr)}
+	}
+	return nil
+}
+
+// parseRemoteLog parses the contents of the remote.log file of the git-annex branch.
+// Each line holds the UUID of a special remote followed by its parameters (key=value) and a timestamp.
+// When a remote has multiple lines, the one with the latest timestamp is used.
+// The returned remotes are sorted by name.
+func parseRemoteLog(content string) []SpecialRemote {
+	type entry struct {
+		remote    SpecialRemote
+		timestamp float64
+	}
+	entries := make(map[string]entry)
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		remote := SpecialRemote{UUID: fields[0], Config: make(map[string]string)}
+		var timestamp float64
+		for _, field := range fields[1:] {
+			kv := strings.SplitN(field, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			if kv[0] == "timestamp" {
+				timestamp, _ = strconv.ParseFloat(strings.TrimSuffix(kv[1], "s"), 64)
+				continue
+			}
+			remote.Config[kv[0]] = kv[1]
+		}
+		remote.Name = remote.Config["name"]
+		remote.Type = remote.Config["type"]
+		if prev, ok := entries[remote.UUID]; ok && prev.timestamp > timestamp {
+			continue
+		}
+		entries[remote.UUID] = entry{remote: remote, timestamp: timestamp}
+	}
+	remotes := make([]SpecialRemote, 0, len(entries))
+	for _, e := range entries {
+		if e.remote.Name == "" {
+			continue
+		}
+		remotes = append(remotes, e.remote)
+	}
+	sort.Slice(remotes, func(i, j int) bool { return remotes[i].Name < remotes[j].Name })
+	return remotes
+}
+
+// SpecialRemotes returns the special remotes that are configured in the repository (recorded in the git-annex branch) and whether each one is enabled locally.
+// (git cat-file -p git-annex:remote.log)
+func SpecialRemotes() ([]SpecialRemote, error) {
+	// make sure the git-annex branch includes the information from the remotes
+	AnnexMerge()
+	cmd := Command("cat-file", "-p", "git-annex:remote.log")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		if bytes.Contains(stderr, []byte("does not exist")) || bytes.Contains(stderr, []byte("Not a valid object name")) {
+			// no special remotes
+			return nil, nil
+		}
+		log.Write("Error reading special remotes")
+		logstd(stdout, stderr)
+		return nil, giterror{UError: string(stderr), Origin: "SpecialRemotes()", Description: "failed to read special remote configuration"}
+	}
+	remotes := parseRemoteLog(string(stdout))
+	for idx, remote := range remotes {
+		uuid, _ := ConfigGet(fmt.Sprintf("remote.%s.annex-uuid", remote.Name))
+		remotes[idx].Enabled = uuid == remote.UUID
+	}
+	return remotes, nil
+}
+
+// EnableRemote enables the special remote with the given name in the local repository, so that content can be transferred to and from it.
+// Additional parameters (key=value) are passed to git-annex, e.g., to change settings that differ for the local repository.
+// Credentials are read by git-annex from the environment (see SpecialRemote.CredentialVars).
+// (git annex enableremote)
+func EnableRemote(name string, params []string) error {
+	fn := fmt.Sprintf("EnableRemote(%s)", name)
+	cmdargs := append([]string{"enableremote", name}, params...)
+	cmd := AnnexCommand(cmdargs...)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during EnableRemote")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: fn, Description: fmt.Sprintf("failed to enable special remote '%s': %s", name, strings.TrimSpace(string(stderr)))}
+	}
+	return nil
+}
+
+// SetAnnexConfig sets a git-annex option in the local git config.
+// The key is the name of the option without the 'annex.' prefix (e.g., numcopies).
+// (git config --local annex.<key>)
+func SetAnnexConfig(key, value string) error {
+	return ConfigSet("annex."+key, value)
+}
+
+// GetAnnexConfig returns the value of a git-annex option from the git config.
+// The key is the name of the option without the 'annex.' prefix (e.g., numcopies).
+// (git config --get annex.<key>)
+func GetAnnexConfig(key string) (string, error) {
+	return ConfigGet("annex." + key)
+}
+
+// AnnexInfo returns the annex information for the repository in the working directory: the repository mode, the number and size of the annexed files, the size of the local annex, and the repositories known to the annex with their trust levels.
+// (git annex info --json --bytes)
 func AnnexInfo() (AnnexInfoRes, error) {
-	cmd := AnnexCommand("info", "--json")
+	fn := "AnnexInfo()"
+	cmd := AnnexCommand("info", "--json", "--bytes")
 	stdout, stderr, err := cmd.OutputError()
 	if err != nil {
 		log.Write("Error during AnnexInfo")
 		logstd(stdout, stderr)
-		return AnnexInfoRes{}, fmt.Errorf("Error retrieving annex info")
+		return AnnexInfoRes{}, giterror{UError: string(stderr), Origin: fn, Description: "failed to retrieve annex information"}
+	}
+	info, err := parseAnnexInfo(stdout)
+	if err != nil {
+		logstd(stdout, stderr)
+		return info, giterror{UError: err.Error(), Origin: fn, Description: "failed to parse annex information"}
 	}
+	if info.RepositoryMode == "" {
+		// newer versions of git-annex no longer report the mode since direct mode was removed
+		info.RepositoryMode = "indirect"
+		if IsDirect() {
+			info.RepositoryMode = "direct"
+		}
+	}
+	return info, nil
+}
 
+// parseAnnexInfo reads the output of git annex info --json.
+// The names and types of the fields differ between versions of git-annex: numbers may be reported as JSON numbers or strings and sizes may be reported in bytes or with units (without --bytes), so each field is read separately and unknown fields are ignored.
+func parseAnnexInfo(output []byte) (AnnexInfoRes, error) {
+	var fields map[string]json.RawMessage
 	var info AnnexInfoRes
-	err = json.Unmarshal(stdout, &info)
-	return info, err
+	var err error
+	// some versions print additional objects (e.g., for warnings); the info is the first object
+	for _, line := range bytes.Split(output, []byte("\n")) {
+		if err = json.Unmarshal(line, &fields); err == nil {
+			break
+		}
+	}
+	if fields == nil {
+		if err == nil {
+			err = fmt.Errorf("no output")
+		}
+		return info, err
+	}
+
+	jsonString := func(key string) string {
+		var str string
+		if raw, ok := fields[key]; ok {
+			if json.Unmarshal(raw, &str) != nil {
+				str = strings.Trim(string(raw), "\"")
+			}
+		}
+		return str
+	}
+	jsonCount := func(key string) int64 {
+		n, _ := strconv.ParseInt(jsonString(key), 10, 64)
+		return n
+	}
+	jsonSize := func(key string) int64 {
+		size, err := parseAnnexSize(jsonString(key))
+		if err != nil {
+			log.Write("Failed to parse %q in annex info: %v", key, err)
+		}
+		return size
+	}
+
+	info.RepositoryMode = jsonString("repository mode")
+	info.AnnexedFiles = jsonCount("annexed files in working tree")
+	info.AnnexedSize = jsonSize("size of annexed files in working tree")
+	info.LocalAnnexKeys = jsonCount("local annex keys")
+	info.LocalAnnexSize = jsonSize("local annex size")
+	info.AvailableLocalDiskSpace = jsonSize("available local disk space")
+	json.Unmarshal(fields["success"], &info.Success)
+	for _, trust := range annexTrustLevels {
+		var repos []AnnexRepoInfo
+		if raw, ok := fields[trust+" repositories"]; ok {
+			if err := json.Unmarshal(raw, &repos); err != nil {
+				log.Write("Failed to parse %s repositories in annex info: %v", trust, err)
+			}
+		}
+		for _, repo := range repos {
+			repo.Trust = trust
+			info.Repositories = append(info.Repositories, repo)
+		}
+	}
+	return info, nil
+}
+
+// parseAnnexSize parses a size as reported by git-annex: a number of bytes (with --bytes), or a number with a unit (e.g., "1.5 megabytes" or "20 MB").
+// An empty or unknown size (e.g., unknown available disk space) is 0.
+func parseAnnexSize(sizestr string) (int64, error) {
+	sizestr = strings.TrimSpace(sizestr)
+	if sizestr == "" || sizestr == "unknown" {
+		return 0, nil
+	}
+	// git-annex may append a note to the size (e.g., "(+1 GB reserved)")
+	if idx := strings.Index(sizestr, " ("); idx >= 0 {
+		sizestr = sizestr[:idx]
+	}
+	parts := strings.Fields(sizestr)
+	number, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return 0, err
+	}
+	if len(parts) == 1 {
+		return int64(number), nil
+	}
+	units := map[string]float64{
+		"byte": 1, "b": 1,
+		"kilobyte": 1e3, "kb": 1e3,
+		"megabyte": 1e6, "mb": 1e6,
+		"gigabyte": 1e9, "gb": 1e9,
+		"terabyte": 1e12, "tb": 1e12,
+		"petabyte": 1e15, "pb": 1e15,
+	}
+	unit := strings.TrimSuffix(strings.ToLower(parts[1]), "s")
+	factor, ok := units[unit]
+	if !ok {
+		return 0, fmt.Errorf("unknown size unit %q", parts[1])
+	}
+	return int64(number * factor), nil
+}
+
+// AnnexTransfer describes a transfer of file content that git-annex has recorded as in progress or failed (see AnnexTransfers).
+type AnnexTransfer struct {
+	File string `json:"file"`
+	Key  string `json:"key"`
+	// Direction is "upload" or "download".
+	Direction string `json:"direction"`
+	// Remote is the name of the remote, or its UUID if it is not a remote of the local repository.
+	Remote    string    `json:"remote"`
+	Size      int64     `json:"size"`
+	BytesDone int64     `json:"bytesdone"`
+	Started   time.Time `json:"started"`
+	Failed    bool      `json:"failed"`
+}
+
+// AnnexTransfers returns the transfers that git-annex has recorded in the repository: the transfers that are in progress (in any git-annex process) and the transfers that failed and were not retried.
+// The information is read from the transfer log in the git directory (.git/annex/transfer), which git-annex updates while a transfer is running.
+// A transfer that is listed as in progress may have been interrupted if git-annex was killed.
+func AnnexTransfers() ([]AnnexTransfer, error) {
+	fn := "AnnexTransfers()"
+	gitdir, err := GitDir()
+	if err != nil {
+		return nil, err
+	}
+	remotenames := remoteUUIDNames()
+	transferdir := filepath.Join(gitdir, "annex", "transfer")
+	var transfers []AnnexTransfer
+	for _, failed := range []bool{false, true} {
+		for _, direction := range []string{"upload", "download"} {
+			dir := filepath.Join(transferdir, direction)
+			if failed {
+				dir = filepath.Join(transferdir, "failed", direction)
+			}
+			infofiles, err := filepath.Glob(filepath.Join(dir, "*", "*"))
+			if err != nil {
+				return nil, giterror{UError: err.Error(), Origin: fn, Description: "failed to read transfer log"}
+			}
+			for _, infofile := range infofiles {
+				keyfile := filepath.Base(infofile)
+				if strings.HasPrefix(keyfile, "lck.") {
+					continue
+				}
+				content, err := ioutil.ReadFile(infofile)
+				if err != nil {
+					// transfer finished in the meantime
+					log.Write("Failed to read transfer info %s: %v", infofile, err)
+					continue
+				}
+				transfer := parseTransferInfo(string(content))
+				transfer.Key = decodeKeyFile(keyfile)
+				transfer.Size = KeySize(transfer.Key)
+				transfer.Direction = direction
+				transfer.Failed = failed
+				uuid := filepath.Base(filepath.Dir(infofile))
+				transfer.Remote = uuid
+				if name, ok := remotenames[uuid]; ok {
+					transfer.Remote = name
+				}
+				transfers = append(transfers, transfer)
+			}
+		}
+	}
+	return transfers, nil
+}
+
+// parseTransferInfo parses the content of a git-annex transfer info file.
+// The first line holds the start time of the transfer (seconds since the epoch, with an 's' suffix) and the number of bytes transferred so far; the second line holds the name of the file.
+// Either value may be missing, depending on the version of git-annex and the state of the transfer.
+func parseTransferInfo(content string) AnnexTransfer {
+	var transfer AnnexTransfer
+	lines := strings.SplitN(content, "\n", 2)
+	fields := strings.Fields(lines[0])
+	if len(fields) > 0 {
+		if secs, err := strconv.ParseFloat(strings.TrimSuffix(fields[0], "s"), 64); err == nil {
+			transfer.Started = time.Unix(0, int64(secs*1e9))
+		}
+	}
+	if len(fields) > 1 {
+		transfer.BytesDone, _ = strconv.ParseInt(fields[1], 10, 64)
+	}
+	if len(lines) > 1 {
+		transfer.File = strings.TrimSuffix(lines[1], "\n")
+	}
+	return transfer
+}
+
+// decodeKeyFile returns the key for the name of a file that git-annex uses to store information about the key.
+// git-annex escapes '&' as '&a', '%' as '&s', ':' as '&c', and '/' as '%' in these names.
+func decodeKeyFile(name string) string {
+	var key strings.Builder
+	for idx := 0; idx < len(name); idx++ {
+		switch c := name[idx]; {
+		case c == '%':
+			key.WriteByte('/')
+		case c == '&' && idx+1 < len(name):
+			idx++
+			switch name[idx] {
+			case 'a':
+				key.WriteByte('&')
+			case 's':
+				key.WriteByte('%')
+			case 'c':
+				key.WriteByte(':')
+			default:
+				key.WriteByte('&')
+				key.WriteByte(name[idx])
+			}
+		default:
+			key.WriteByte(c)
+		}
+	}
+	return key.String()
+}
+
+// remoteUUIDNames returns the names of the remotes of the repository keyed by their annex UUID.
+// (git config --get-regexp remote\..*\.annex-uuid)
+func remoteUUIDNames() map[string]string {
+	names := make(map[string]string)
+	cmd := Command("config", "--get-regexp", `^remote\..*\.annex-uuid$`)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		// no remotes with annex UUIDs
+		logstd(stdout, stderr)
+		return names
+	}
+	for _, line := range strings.Split(string(stdout), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(fields[0], "remote."), ".annex-uuid")
+		names[fields[1]] = name
+	}
+	if uuid, err := ConfigGet("annex.uuid"); err == nil {
+		names[uuid] = "here"
+	}
+	return names
 }
 
 // AnnexLock locks the specified files and directory contents if they are annexed.
@@ -825,7 +1972,24 @@ func AnnexUnlock(filepaths []string, unlockchan chan<- RepoFileStatus) {
 // Returned items are indexed by their annex key.
 // (git annex find)
 func AnnexFind(paths []string) (map[string]AnnexFindRes, error) {
+	results, err := AnnexFindMatching(nil, paths)
+	if err != nil {
+		return nil, err
+	}
+	items := make(map[string]AnnexFindRes, len(results))
+	for _, afr := range results {
+		items[afr.Key] = afr
+	}
+	return items, nil
+}
+
+// AnnexFindMatching lists the annexed files that satisfy the given git-annex matching options (e.g., --in=origin, --metadata=species=mouse, --include=*.dat).
+// With no matching options, only files whose content is available locally are listed.
+// Specifying 'paths' limits the search to files matching a given path.
+// (git annex find <matchargs>)
+func AnnexFindMatching(matchargs []string, paths []string) ([]AnnexFindRes, error) {
 	cmdargs := []string{"find", "--json"}
+	cmdargs = append(cmdargs, matchargs...)
 	if len(paths) > 0 {
 		cmdargs = append(cmdargs, paths...)
 	}
@@ -837,7 +2001,7 @@ func AnnexFind(paths []string) (map[string]AnnexFindRes, error) {
 	}
 
 	outlines := bytes.Split(stdout, []byte("\n"))
-	items := make(map[string]AnnexFindRes, len(outlines))
+	items := make([]AnnexFindRes, 0, len(outlines))
 	for _, line := range outlines {
 		line = bytes.TrimSpace(line)
 		if len(line) == 0 {
@@ -846,7 +2010,7 @@ func AnnexFind(paths []string) (map[string]AnnexFindRes, error) {
 		}
 		var afr AnnexFindRes
 		json.Unmarshal(line, &afr)
-		items[afr.Key] = afr
+		items = append(items, afr)
 	}
 	return items, nil
 }
@@ -884,6 +2048,72 @@ func AnnexContentLocation(key string) (string, error) {
 	return sstdout, nil
 }
 
+// AnnexFix repairs the pointers (symbolic links) of annexed files under the specified paths that point to the wrong location, e.g., after the files were moved with external tools.
+// A status is sent for each file that is fixed; files that do not need fixing are not reported.
+// The status channel 'fixchan' is closed when this function returns.
+// (git annex fix)
+func AnnexFix(paths []string, fixchan chan<- RepoFileStatus) {
+	defer close(fixchan)
+	cmdargs := []string{"fix"}
+	if !RawMode {
+		cmdargs = append(cmdargs, "--json", "--json-error-messages")
+	}
+	cmdargs = append(cmdargs, paths...)
+	cmd := AnnexCommand(cmdargs...)
+	err := cmd.Start()
+	if err != nil {
+		fixchan <- RepoFileStatus{Err: err}
+		return
+	}
+
+	var outline []byte
+	var rerr error
+	var status RepoFileStatus
+	var fixresult annexAction
+	status.State = "Fixing"
+	status.RawInput = strings.Join(cmd.Args, " ")
+	for rerr = nil; rerr == nil; outline, rerr = cmd.OutReader.ReadBytes('\n') {
+		if len(outline) == 0 {
+			// Empty line output. Ignore
+			continue
+		}
+		status.RawOutput = string(outline)
+		if RawMode {
+			fixchan <- status
+			continue
+		}
+		err := json.Unmarshal(outline, &fixresult)
+		if err != nil || fixresult.Command == "" {
+			// Couldn't parse output
+			log.Write("Could not parse 'git annex fix' output")
+			log.Write(string(outline))
+			continue
+		}
+		status.FileName = fixresult.File
+		if fixresult.Success {
+			log.Write("%s fixed", fixresult.File)
+			status.Err = nil
+		} else {
+			errmsgs := strings.Join(fixresult.Errors, " | ")
+			log.Write("Error fixing %s: %s", fixresult.File, errmsgs)
+			status.Err = fmt.Errorf(errmsgs)
+		}
+		status.Progress = progcomplete
+		fixchan <- status
+	}
+	var stderr, errline []byte
+	if cmd.Wait() != nil {
+		for rerr = nil; rerr == nil; errline, rerr = cmd.ErrReader.ReadBytes('\000') {
+			stderr = append(stderr, errline...)
+		}
+		log.Write("Error during fix")
+		logstd(nil, stderr)
+		if len(stderr) > 0 {
+			fixchan <- RepoFileStatus{State: "Fixing", Err: fmt.Errorf(strings.TrimSpace(string(stderr)))}
+		}
+	}
+}
+
 // AnnexFsck runs fsck (filesystem check) on the specified files, fixing any
 // issues with the annexed files in the working tree.
 func AnnexFsck(paths []string) error {
@@ -901,22 +2131,103 @@ func AnnexFsck(paths []string) error {
 // build exclusion argument list
 // files < annex.minsize or matching exclusion extensions will not be annexed and
 // will instead be handled by git
+// If a largefiles expression is set for the repository (see SetLargefiles), it replaces the configured filters.
 func annexExclArgs() string {
+	expression, err := GetLargefiles()
+	if err != nil {
+		log.Write("Failed to read largefiles expression: %v", err)
+	}
+	if expression == "" {
+		expression = ConfigLargefiles()
+	}
+	// explicitly exclude config file
+	return fmt.Sprintf("annex.largefiles=((%s) and (exclude=config.yml))", expression)
+}
+
+// ConfigLargefiles returns the largefiles expression that corresponds to the filters in the client configuration (annex.minsize and annex.exclude).
+func ConfigLargefiles() string {
 	var expbuilder strings.Builder
 	config := config.Read()
 	if config.Annex.MinSize != "" {
 		largerthan := fmt.Sprintf("(largerthan=%s)", config.Annex.MinSize)
 		expbuilder.WriteString(largerthan)
+	} else {
+		expbuilder.WriteString("anything")
 	}
 
 	for _, pattern := range config.Annex.Exclude {
 		exclarg := fmt.Sprintf(" and (exclude=%s)", pattern)
 		expbuilder.WriteString(exclarg)
 	}
+	return expbuilder.String()
+}
 
-	// explicitly exclude config file
-	expbuilder.WriteString(fmt.Sprintf(" and (exclude=config.yml)"))
-	return fmt.Sprintf("annex.largefiles=(%s)", expbuilder.String())
+// GetLargefiles returns the largefiles expression set for the repository.
+// The string is empty if no expression is set.
+// (git annex config --get annex.largefiles)
+func GetLargefiles() (string, error) {
+	cmd := AnnexCommand("config", "--get", "annex.largefiles")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		if len(bytes.TrimSpace(stderr)) == 0 {
+			// not set
+			return "", nil
+		}
+		log.Write("Error during GetLargefiles")
+		logstd(stdout, stderr)
+		return "", giterror{UError: string(stderr), Origin: "GetLargefiles()"}
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// SetLargefiles sets the largefiles expression for the repository, which decides which files are added to the annex and which to git.
+// The expression is stored in the git-annex branch, so it applies to all clones of the repository.
+// The expression is validated before it is set (see ValidateLargefiles).
+// (git annex config --set annex.largefiles)
+func SetLargefiles(expression string) error {
+	fn := fmt.Sprintf("SetLargefiles(%s)", expression)
+	if err := ValidateLargefiles(expression); err != nil {
+		return err
+	}
+	cmd := AnnexCommand("config", "--set", "annex.largefiles", expression)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during SetLargefiles")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: fn, Description: "failed to set largefiles expression (git-annex 7.20191230 or newer required)"}
+	}
+	return nil
+}
+
+// UnsetLargefiles removes the largefiles expression of the repository, so that the filters in the client configuration are used again.
+// (git annex config --unset annex.largefiles)
+func UnsetLargefiles() error {
+	cmd := AnnexCommand("config", "--unset", "annex.largefiles")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during UnsetLargefiles")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: "UnsetLargefiles()"}
+	}
+	return nil
+}
+
+// ValidateLargefiles checks the syntax of a largefiles expression by matching it against a dummy file.
+// (git annex matchexpression --largefiles)
+func ValidateLargefiles(expression string) error {
+	fn := fmt.Sprintf("ValidateLargefiles(%s)", expression)
+	if strings.TrimSpace(expression) == "" {
+		return giterror{Origin: fn, Description: "empty largefiles expression"}
+	}
+	cmd := AnnexCommand("matchexpression", "--largefiles", "--file=file.dat", "--size=0", "--mimetype=application/octet-stream", expression)
+	stdout, stderr, err := cmd.OutputError()
+	// exit status 1 means the expression is valid but does not match
+	if err != nil && cmd.ProcessState.ExitCode() != 1 {
+		log.Write("Error during ValidateLargefiles")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: fn, Description: fmt.Sprintf("invalid largefiles expression '%s': %s", expression, strings.TrimSpace(string(stderr)))}
+	}
+	return nil
 }
 
 // AnnexAdd adds paths to the annex.
@@ -937,6 +2248,9 @@ func AnnexAdd(filepaths []string, addchan chan<- RepoFileStatus) {
 	if len(exclargs) > 0 {
 		cmdargs = append(cmdargs, "-c", exclargs)
 	}
+	if AddUnlocked {
+		cmdargs = append(cmdargs, "-c", "annex.addunlocked=true")
+	}
 
 	cmdargs = append(cmdargs, filepaths...)
 	cmd := AnnexCommand(cmdargs...)
@@ -1054,13 +2368,19 @@ func GetAnnexVersion() (string, error) {
 
 // AnnexCommand sets up a git annex command with the provided arguments and returns a GinCmd struct.
 func AnnexCommand(args ...string) shell.Cmd {
+	return AnnexCommandContext(context.Background(), args...)
+}
+
+// AnnexCommandContext is like AnnexCommand but includes a context.
+// The git-annex process is killed if the context is cancelled before the command completes.
+func AnnexCommandContext(ctx context.Context, args ...string) shell.Cmd {
 	config := config.Read()
 	// gitannexbin := config.Bin.GitAnnex
 	gitbin := config.Bin.Git
 	gitannexpath := config.Bin.GitAnnexPath
 	cmdargs := []string{"annex"}
 	cmdargs = append(cmdargs, args...)
-	cmd := shell.Command(gitbin, cmdargs...)
+	cmd := shell.CommandContext(ctx, gitbin, cmdargs...)
 	env := os.Environ()
 	cmd.Env = env
 	if gitannexpath != "" {
@@ -1070,15 +2390,45 @@ func AnnexCommand(args ...string) shell.Cmd {
 	}
 	cmd.Env = append(cmd.Env, sshEnv())
 	cmd.Env = append(cmd.Env, "GIT_ANNEX_USE_GIT_SSH=1")
+	cmd.RetryCheck = retryIndexLock
 	workingdir, _ := filepath.Abs(".")
-	log.Write("Running shell command (Dir: %s): %s", workingdir, strings.Join(cmd.Args, " "))
+	log.Write("Running shell command (Dir: %s): %s", workingdir, RedactURLs(strings.Join(cmd.Args, " ")))
 	return cmd
 }
 
+// unsafeDropErrMsg is the error message for files whose content git-annex refuses to drop because no other copy could be verified.
+const unsafeDropErrMsg = "cannot drop: no verified copy on any remote; upload the file or copy its content to a remote first, or use --force to remove it anyway"
+
+// isUnsafeDrop returns true if the output of git annex drop shows that the content was not dropped because not enough copies could be verified in other repositories.
+func isUnsafeDrop(messages string) bool {
+	for _, msg := range []string{"unsafe", "necessary cop", "verify the existence"} {
+		if strings.Contains(messages, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// quotaErrMsg is the error message for uploads that are rejected by the server because of a size limit or quota.
+const quotaErrMsg = "the server rejected the upload because the repository would exceed its size limit or storage quota; remove content that is no longer needed or ask the server administrators for more space"
+
+// isQuotaError returns true if the output of a git or git-annex command shows that the server rejected data because of a size limit or quota (e.g., HTTP status 413).
+func isQuotaError(messages string) bool {
+	lower := strings.ToLower(messages)
+	for _, msg := range []string{"http 413", "entity too large", "payload too large", "quota exceeded", "exceeds quota", "disk quota", "size limit"} {
+		if strings.Contains(lower, msg) {
+			return true
+		}
+	}
+	return false
+}
+
 // parseSyncErrors is used by all annex sync commands to check the
 // output for common error messages and return the appropriate gin message.
 func parseSyncErrors(messages string) error {
-	if strings.Contains(messages, "Permission denied") {
+	if isQuotaError(messages) {
+		return fmt.Errorf(quotaErrMsg)
+	} else if strings.Contains(messages, "Permission denied") {
 		return fmt.Errorf("permission denied")
 	} else if strings.Contains(messages, "Host key verification failed") {
 		// Bad host key configured
@@ -1089,6 +2439,36 @@ func parseSyncErrors(messages string) error {
 	return nil
 }
 
+// abortConflictedMerge aborts a merge left behind by a failed download or sync, so that the repository is not left in a half-merged state.
+// It returns an error listing the files with merge conflicts, along with guidance for resolving them.
+// If no conflicting files are found, the error detected from the command output ('cause') is returned with the same guidance.
+// Returns nil if 'cause' is nil and there was no failed merge.
+func abortConflictedMerge(cause error) error {
+	conflicts, _ := UnmergedFiles()
+	inmerge := InMerge()
+	if cause == nil && len(conflicts) == 0 && !inmerge {
+		return nil
+	}
+	if inmerge || len(conflicts) > 0 {
+		mergeAbort()
+	}
+
+	var msg string
+	if len(conflicts) > 0 {
+		msg = fmt.Sprintf("files changed locally and remotely and cannot be automatically merged (merge conflict):\n  %s", strings.Join(conflicts, "\n  "))
+	} else if cause != nil {
+		msg = cause.Error()
+	} else {
+		msg = "changes could not be merged"
+	}
+
+	if InMerge() {
+		// abort failed: the user needs to resolve the conflict
+		return fmt.Errorf("%s\nThe repository could not be restored to its state before the download and has unresolved conflicts.\nEdit the conflicting files to resolve the conflicts and record the result with 'gin commit', or run 'gin git merge --abort' to cancel the merge", msg)
+	}
+	return fmt.Errorf("%s\nThe merge was cancelled and your local files were not changed.\nTo resolve the conflict, rename or revert your local changes to the listed files and download again", msg)
+}
+
 func checkMergeErrors(stdout, stderr string) error {
 	messages := strings.ToLower(stdout + stderr)
 	if strings.Contains(messages, "would be overwritten by merge") {
@@ -0,0 +1,132 @@
+package git
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+	"github.com/G-Node/gin-cli/git/shell"
+)
+
+// UseAnnexBatch enables the batch mode of git-annex for the functions that support it (AnnexWhereis).
+// Instead of starting a new git-annex process for every call, one process is started per repository and command on first use and kept running, and the file names are passed to it on its standard input (git annex <command> --batch).
+// The processes are stopped by StopAnnexBatch.
+// Commands that have no batch interface (e.g., git annex status) are not affected.
+var UseAnnexBatch = false
+
+// batchProcess is a running command that reads one query per line on its standard input and answers each with one line on its standard output.
+type batchProcess struct {
+	sync.Mutex
+	cmd shell.Cmd
+	in  io.WriteCloser
+}
+
+// annexBatches holds the running git-annex batch processes, by repository and command.
+var annexBatches = struct {
+	sync.Mutex
+	procs map[string]*batchProcess
+}{procs: make(map[string]*batchProcess)}
+
+// startBatch starts the command and returns it as a batchProcess.
+// The standard error of the command is logged.
+func startBatch(cmd shell.Cmd) (*batchProcess, error) {
+	in, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	go func() {
+		for line, err := cmd.ErrReader.ReadString('\n'); err == nil; line, err = cmd.ErrReader.ReadString('\n') {
+			log.Write("[batch stderr] %s", strings.TrimSpace(line))
+		}
+	}()
+	return &batchProcess{cmd: cmd, in: in}, nil
+}
+
+// query sends one line to the process and returns the line it answers with, without the trailing newline.
+func (proc *batchProcess) query(line string) (string, error) {
+	proc.Lock()
+	defer proc.Unlock()
+	if _, err := io.WriteString(proc.in, line+"\n"); err != nil {
+		return "", err
+	}
+	answer, err := proc.cmd.OutReader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(answer, "\n"), nil
+}
+
+// stop closes the standard input of the process, which ends it, and waits for it to exit.
+func (proc *batchProcess) stop() error {
+	proc.Lock()
+	defer proc.Unlock()
+	proc.in.Close()
+	return proc.cmd.Wait()
+}
+
+// annexBatch returns the running batch process of the git-annex command with the given arguments for the repository in the working directory, starting it if necessary.
+// (git annex <args> --batch)
+func annexBatch(args ...string) (*batchProcess, error) {
+	workingdir, _ := filepath.Abs(".")
+	id := workingdir + "\000" + strings.Join(args, " ")
+	annexBatches.Lock()
+	defer annexBatches.Unlock()
+	if proc, ok := annexBatches.procs[id]; ok {
+		return proc, nil
+	}
+	proc, err := startBatch(AnnexCommand(append(args, "--batch")...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to start git-annex %s in batch mode: %s", args[0], err)
+	}
+	annexBatches.procs[id] = proc
+	return proc, nil
+}
+
+// dropAnnexBatch stops a batch process that failed and removes it, so that a new one is started on the next call.
+func dropAnnexBatch(proc *batchProcess) {
+	annexBatches.Lock()
+	defer annexBatches.Unlock()
+	for id, p := range annexBatches.procs {
+		if p == proc {
+			delete(annexBatches.procs, id)
+		}
+	}
+	if err := proc.stop(); err != nil {
+		log.Write("git-annex batch process exited with error: %v", err)
+	}
+}
+
+// StopAnnexBatch stops all running git-annex batch processes (see UseAnnexBatch).
+func StopAnnexBatch() {
+	annexBatches.Lock()
+	defer annexBatches.Unlock()
+	for id, proc := range annexBatches.procs {
+		if err := proc.stop(); err != nil {
+			log.Write("git-annex batch process exited with error: %v", err)
+		}
+		delete(annexBatches.procs, id)
+	}
+}
+
+// batchable returns true if all paths can be passed to a git-annex batch process: batch mode only accepts files (directories are not expanded) and reads one name per line.
+func batchable(paths []string) bool {
+	if len(paths) == 0 {
+		return false
+	}
+	for _, p := range paths {
+		if strings.Contains(p, "\n") {
+			return false
+		}
+		if info, err := os.Lstat(p); err != nil || info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
@@ -2,14 +2,17 @@ package git
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/G-Node/gin-cli/ginclient/config"
@@ -36,6 +39,18 @@ const (
 // giterror convenience alias to util.Error
 type giterror = shell.Error
 
+// AbbrevLength is the minimum number of digits of abbreviated commit hashes (GinCommit.AbbreviatedHash).
+// Git uses more digits when necessary to keep the abbreviations unique.
+// If 0, the length is determined by git (core.abbrev).
+var AbbrevLength = 0
+
+// SignCommits enables signing of the commits made by Commit, CommitPaths, and CommitEmpty with GPG (git commit --gpg-sign), in addition to the commit.sign option of the client configuration.
+var SignCommits = false
+
+// SignKey is the ID of the GPG key used to sign commits.
+// If empty, the commit.signkey option of the client configuration is used, or the default key of the user if that is not set either.
+var SignKey = ""
+
 // **************** //
 
 // Types
@@ -50,6 +65,10 @@ type RepoFileStatus struct {
 	Progress string `json:"progress"`
 	// The data rate, if available.
 	Rate string `json:"rate"`
+	// Size of the file content in bytes, if known.
+	Size int64 `json:"size"`
+	// Skipped is true if the operation was not necessary for the file (e.g., the content is already available).
+	Skipped bool `json:"skipped"`
 	// original cmd input
 	RawInput string `json:"rawinput"`
 	// original command output
@@ -124,20 +143,52 @@ func Init(bare bool) error {
 	return nil
 }
 
+// PackOptions holds the settings git uses for the data it receives when cloning a repository (see Clone).
+type PackOptions struct {
+	// Compression is the zlib compression level (0-9) of the objects written during the clone (core.compression).
+	// A negative value uses the default of git.
+	Compression int
+	// Threads is the number of threads used to process the received pack (pack.threads).
+	// Zero uses one thread per CPU.
+	Threads int
+}
+
+// configArgs returns the git configuration arguments (-c <key>=<value>) for the options that are not the defaults.
+func (pack *PackOptions) configArgs() []string {
+	var args []string
+	if pack == nil {
+		return args
+	}
+	if pack.Compression >= 0 {
+		args = append(args, "-c", fmt.Sprintf("core.compression=%d", pack.Compression))
+	}
+	if pack.Threads > 0 {
+		args = append(args, "-c", fmt.Sprintf("pack.threads=%d", pack.Threads))
+	}
+	return args
+}
+
 // Clone downloads a repository and sets the remote fetch and push urls.
+// The repository is cloned into 'destination', or into a directory named after the repository if destination is empty.
+// The pack options set the compression and number of threads for processing the received data; if pack is nil, the defaults of git are used.
 // The status channel 'clonechan' is closed when this function returns.
 // (git clone ...)
-func Clone(remotepath string, repopath string, clonechan chan<- RepoFileStatus) {
+func Clone(ctx context.Context, remotepath string, repopath string, destination string, pack *PackOptions, clonechan chan<- RepoFileStatus) {
 	// TODO: This function is crazy huge - simplify
-	fn := fmt.Sprintf("Clone(%s)", remotepath)
+	fn := fmt.Sprintf("Clone(%s)", RedactURLs(remotepath))
 	defer close(clonechan)
 	args := []string{"clone", "--progress", remotepath}
+	if destination != "" {
+		args = append(args, destination)
+	}
 	if runtime.GOOS == "windows" {
 		// force disable symlinks even if user can create them
 		// see https://git-annex.branchable.com/bugs/Symlink_support_on_Windows_10_Creators_Update_with_Developer_Mode/
 		args = append([]string{"-c", "core.symlinks=false"}, args...)
 	}
-	cmd := Command(args...)
+	args = append(pack.configArgs(), args...)
+	cmd := CommandContext(ctx, args...)
+	withCredentials(&cmd, remotepath)
 	err := cmd.Start()
 	if err != nil {
 		clonechan <- RepoFileStatus{Err: giterror{UError: err.Error(), Origin: fn}}
@@ -154,7 +205,7 @@ func Clone(remotepath string, repopath string, clonechan chan<- RepoFileStatus)
 	var nread, errhead int
 	var eob, eof bool
 	lineInput := cmd.Args
-	input := strings.Join(lineInput, " ")
+	input := RedactURLs(strings.Join(lineInput, " "))
 	status.RawInput = input
 	// git clone progress prints to stderr
 	for eof = false; !eof; nread, rerr = cmd.ErrReader.Read(readbuffer) {
@@ -168,26 +219,18 @@ func Clone(remotepath string, repopath string, clonechan chan<- RepoFileStatus)
 				break
 			}
 			errhead += len(line) + 1
-			words := strings.Fields(line)
 			status.FileName = repopath
-			if strings.HasPrefix(line, "Receiving objects") {
-				if len(words) > 2 {
-					status.Progress = words[2]
-				}
-				if len(words) > 8 {
-					rate := fmt.Sprintf("%s%s", words[7], words[8])
-					if strings.HasSuffix(rate, ",") {
-						rate = strings.TrimSuffix(rate, ",")
-					}
-					status.Rate = rate
-					status.RawOutput = line
-				}
+			status.RawOutput = line
+			if state, progress, rate, ok := parseCloneProgress(line); ok {
+				status.State = state
+				status.Progress = progress
+				status.Rate = rate
 			}
 			clonechan <- status
 		}
 	}
 
-	errstring := string(stderr)
+	errstring := RedactURLs(string(stderr))
 	if err = cmd.Wait(); err != nil {
 		log.Write("Error during clone command")
 		repoPathParts := strings.SplitN(repopath, "/", 2)
@@ -204,6 +247,9 @@ func Clone(remotepath string, repopath string, clonechan chan<- RepoFileStatus)
 				"'%s' already exists in the current directory and is not empty.", repoName)
 		} else if strings.Contains(errstring, "Host key verification failed") {
 			gerr.Description = "Server key does not match known/configured host key."
+		} else if strings.HasPrefix(remotepath, "ssh://") && sshUnavailable(errstring) {
+			gerr.Description = "Repository download failed: could not connect to the server over ssh.\n" +
+				"If your network blocks ssh connections, use the --https option to download the repository over HTTPS."
 		} else {
 			gerr.Description = fmt.Sprintf("Repository download failed. Internal git command returned: %s", errstring)
 		}
@@ -213,16 +259,55 @@ func Clone(remotepath string, repopath string, clonechan chan<- RepoFileStatus)
 		return
 	}
 	// Progress doesn't show 100% if cloning an empty repository, so let's force it
+	status.State = "Downloading repository"
 	status.Progress = progcomplete
+	status.Rate = ""
 	clonechan <- status
 	return
 }
 
+// cloneProgressRe matches the progress lines of git clone, e.g., "Receiving objects:  45% (450/1000), 1.20 MiB | 1.00 MiB/s".
+// The lines of the phases that run on the server are prefixed with "remote: ".
+var cloneProgressRe = regexp.MustCompile(`^(?:remote: )?(Enumerating objects|Counting objects|Compressing objects|Receiving objects|Resolving deltas|Updating files|Checking out files):\s+([0-9]{1,3})%(?:.*\|\s*([0-9.]+ [KMGT]?i?B/s))?`)
+
+// cloneStates maps the phases of git clone to the state reported in the status of Clone.
+var cloneStates = map[string]string{
+	"Enumerating objects": "Preparing repository download",
+	"Counting objects":    "Preparing repository download",
+	"Compressing objects": "Preparing repository download",
+	"Receiving objects":   "Downloading repository",
+	"Resolving deltas":    "Unpacking repository",
+	"Updating files":      "Checking out files",
+	"Checking out files":  "Checking out files",
+}
+
+// parseCloneProgress parses a progress line of git clone and returns the state of the clone, the percentage of the current phase, and the transfer rate (only while receiving objects).
+// ok is false if the line does not report progress.
+func parseCloneProgress(line string) (state, progress, rate string, ok bool) {
+	match := cloneProgressRe.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return "", "", "", false
+	}
+	rate = strings.Replace(match[3], " ", "", 1)
+	return cloneStates[match[1]], match[2] + "%", rate, true
+}
+
+// sshUnavailable returns true if the error output of a git command indicates that an ssh connection to the server could not be established.
+func sshUnavailable(errstring string) bool {
+	for _, msg := range []string{"Connection timed out", "Connection refused", "Network is unreachable", "Could not resolve hostname", "Connection closed by remote host", "kex_exchange_identification"} {
+		if strings.Contains(errstring, msg) {
+			return true
+		}
+	}
+	return false
+}
+
 // Pull downloads all small (git) files from the server.
 // (git pull --ff-only)
 func Pull(remote string) error {
 	// TODO: Common output handling with Push
 	cmd := Command("pull", "--ff-only", remote)
+	withCredentials(&cmd, remote)
 	stdout, stderr, err := cmd.OutputError()
 
 	if err != nil {
@@ -232,9 +317,27 @@ func Pull(remote string) error {
 	return nil
 }
 
+// Fetch downloads the branches and references of a remote without modifying local branches or files.
+// (git fetch)
+func Fetch(remote string) error {
+	fn := fmt.Sprintf("Fetch(%s)", remote)
+	cmd := Command("fetch", remote)
+	withCredentials(&cmd, remote)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during fetch")
+		logstd(stdout, stderr)
+		if serr := parseSyncErrors(string(stderr)); serr != nil {
+			return fmt.Errorf("fetch failed: %v", serr)
+		}
+		return giterror{UError: string(stderr), Origin: fn, Description: "fetch failed"}
+	}
+	return nil
+}
+
 // Push uploads all small (git) files to the server.
 // (git push)
-func Push(remote string, pushchan chan<- RepoFileStatus) {
+func Push(ctx context.Context, remote string, pushchan chan<- RepoFileStatus) {
 	defer close(pushchan)
 
 	if IsDirect() {
@@ -247,7 +350,8 @@ func Push(remote string, pushchan chan<- RepoFileStatus) {
 		defer setBare(true)
 	}
 
-	cmd := Command("push", "--progress", remote)
+	cmd := CommandContext(ctx, "push", "--progress", remote)
+	withCredentials(&cmd, remote)
 	err := cmd.Start()
 	if err != nil {
 		pushchan <- RepoFileStatus{Err: err}
@@ -279,6 +383,7 @@ func Push(remote string, pushchan chan<- RepoFileStatus) {
 // Add adds paths to git directly (not annex).
 // In direct mode, files that are already in the annex are explicitly ignored.
 // In indirect mode, adding annexed files to git has no effect.
+// The State of each status message includes the number of files processed so far and the total number of files to add (e.g., "Adding (git) [42/100]").
 // The status channel 'addchan' is closed when this function returns.
 // (git add)
 func Add(filepaths []string, addchan chan<- RepoFileStatus) {
@@ -300,6 +405,8 @@ func Add(filepaths []string, addchan chan<- RepoFileStatus) {
 		filepaths = gitAddDirect(filepaths)
 	}
 
+	total := countAdd(filepaths)
+
 	// exclargs := annexExclArgs()
 	cmdargs := []string{"add", "--verbose", "--"}
 	cmdargs = append(cmdargs, filepaths...)
@@ -315,6 +422,7 @@ func Add(filepaths []string, addchan chan<- RepoFileStatus) {
 	lineInput := cmd.Args
 	input := strings.Join(lineInput, " ")
 	status.RawInput = input
+	var nadded int
 	for rerr = nil; rerr == nil; line, rerr = cmd.OutReader.ReadString('\n') {
 		fname := strings.TrimSpace(line)
 		status.RawOutput = line
@@ -322,11 +430,17 @@ func Add(filepaths []string, addchan chan<- RepoFileStatus) {
 			// skip empty lines
 			continue
 		}
+		nadded++
+		if nadded > total {
+			// the count may be off if files changed since counting
+			total = nadded
+		}
+		counter := fmt.Sprintf("[%d/%d]", nadded, total)
 		if strings.HasPrefix(fname, "add") {
-			status.State = "Adding (git)  "
+			status.State = fmt.Sprintf("Adding (git) %s", counter)
 			fname = strings.TrimPrefix(fname, "add '")
 		} else if strings.HasPrefix(fname, "remove") {
-			status.State = "Removing"
+			status.State = fmt.Sprintf("Removing %s", counter)
 			fname = strings.TrimPrefix(fname, "remove '")
 		}
 		fname = strings.TrimSuffix(fname, "'")
@@ -347,6 +461,26 @@ func Add(filepaths []string, addchan chan<- RepoFileStatus) {
 	return
 }
 
+// countAdd returns the number of files that would be added or removed by running Add with the given paths.
+// (git add --dry-run)
+func countAdd(filepaths []string) int {
+	cmdargs := append([]string{"add", "--dry-run", "--"}, filepaths...)
+	cmd := Command(cmdargs...)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error counting files to add")
+		logstd(stdout, stderr)
+		return 0
+	}
+	var count int
+	for _, line := range strings.Split(string(stdout), "\n") {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+	return count
+}
+
 // SetGitUser sets the user.name and user.email configuration values for the local git repository.
 func SetGitUser(name, email string) error {
 	if Checkwd() == NotRepository {
@@ -457,6 +591,7 @@ func RemoteAdd(name, url string) error {
 	// Performing fetch after adding remote to retrieve references
 	// Any errors are logged and ignored
 	cmd = Command("fetch", name)
+	withCredentials(&cmd, url)
 	stdout, stderr, err = cmd.OutputError()
 	if err != nil {
 		logstd(stdout, stderr)
@@ -482,6 +617,25 @@ func RemoteRemove(name string) error {
 	return nil
 }
 
+// RemoteSetURL changes the URL of the remote named name.
+// (git remote set-url)
+func RemoteSetURL(name, url string) error {
+	fn := fmt.Sprintf("RemoteSetURL(%s, %s)", name, RedactURLs(url))
+	cmd := Command("remote", "set-url", name, url)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		sstderr := string(stderr)
+		gerr := giterror{UError: sstderr, Origin: fn}
+		log.Write("Error during remote set-url command")
+		logstd(stdout, stderr)
+		if strings.Contains(sstderr, "No such remote") {
+			gerr.Description = fmt.Sprintf("remote with name '%s' does not exist", name)
+		}
+		return gerr
+	}
+	return nil
+}
+
 // BranchSetUpstream sets the default upstream remote for the current branch.
 // (git branch --set-upstream-to=)
 func BranchSetUpstream(name string) error {
@@ -503,6 +657,7 @@ func BranchSetUpstream(name string) error {
 func LsRemote(remote string) (string, error) {
 	fn := fmt.Sprintf("LsRemote(%s)", remote)
 	cmd := Command("ls-remote", remote)
+	withCredentials(&cmd, remote)
 	stdout, stderr, err := cmd.OutputError()
 	if err != nil {
 		sstderr := string(stderr)
@@ -518,6 +673,25 @@ func LsRemote(remote string) (string, error) {
 	return string(stdout), nil
 }
 
+// ResolveCommit returns the full hash of the commit identified by a (possibly abbreviated) hash or any other revision name.
+// Abbreviated hashes are only matched against commits, so they are not ambiguous with other objects (files or directories) that have the same prefix.
+// (git rev-parse --verify <rev>^{commit})
+func ResolveCommit(rev string) (string, error) {
+	fn := fmt.Sprintf("ResolveCommit(%s)", rev)
+	cmd := Command("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during rev-parse command")
+		logstd(stdout, stderr)
+		gerr := giterror{UError: string(stderr), Origin: fn, Description: fmt.Sprintf("'%s' does not match a known version ID or name", rev)}
+		if strings.Contains(string(stderr), "ambiguous") {
+			gerr.Description = fmt.Sprintf("version ID '%s' is ambiguous; specify more characters of the ID", rev)
+		}
+		return "", gerr
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
 // RevParse parses an argument and returns the unambiguous, SHA1 representation.
 // (git rev-parse)
 func RevParse(rev string) (string, error) {
@@ -533,6 +707,98 @@ func RevParse(rev string) (string, error) {
 	return string(stdout), nil
 }
 
+// RefreshIndex updates the information that git keeps about the files in the working tree by reading the content of every tracked file, ignoring the recorded file sizes and modification times.
+// This detects changes that are missed when a file is modified without changing its modification time.
+// Staged changes are kept.
+// For unlocked annexed files, the content is hashed by git-annex, which may take a long time for large files.
+// (git update-index --index-info; git update-index -q --refresh)
+func RefreshIndex() error {
+	fn := "RefreshIndex()"
+	cmd := Command("ls-files", "-s", "-z")
+	entries, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during ls-files")
+		logstd(entries, stderr)
+		return giterror{UError: string(stderr), Origin: fn, Description: "failed to rescan working tree"}
+	}
+	// re-adding the same entries clears their recorded file information, so the refresh compares the content
+	cmd = Command("update-index", "-z", "--index-info")
+	cmd.Stdin = bytes.NewReader(entries)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during update-index --index-info")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: fn, Description: "failed to rescan working tree"}
+	}
+	cmd = Command("update-index", "-q", "--refresh")
+	stdout, stderr, err = cmd.OutputError()
+	// exit status 1 only reports that files were modified
+	if err != nil && (cmd.ProcessState.ExitCode() != 1 || len(bytes.TrimSpace(stderr)) > 0) {
+		log.Write("Error during update-index --refresh")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: fn, Description: "failed to rescan working tree"}
+	}
+	return nil
+}
+
+// GitDir returns the absolute path of the git directory of the repository (usually .git at the root of the working tree).
+// (git rev-parse --git-dir)
+func GitDir() (string, error) {
+	fn := "GitDir()"
+	cmd := Command("rev-parse", "--git-dir")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during rev-parse --git-dir")
+		logstd(stdout, stderr)
+		return "", giterror{UError: string(stderr), Origin: fn}
+	}
+	return filepath.Abs(strings.TrimSpace(string(stdout)))
+}
+
+// IndexBlobs returns the IDs of the objects recorded in the index for the files under the given paths, keyed by file name (relative to the working directory).
+// For annexed files, the object is the pointer file or symbolic link, which changes when the annex key of the file changes.
+// (git ls-files --stage)
+func IndexBlobs(paths []string) (map[string]string, error) {
+	fn := fmt.Sprintf("IndexBlobs(%v)", paths)
+	cmdargs := append([]string{"ls-files", "--stage", "-z", "--"}, paths...)
+	cmd := Command(cmdargs...)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during ls-files --stage")
+		logstd(stdout, stderr)
+		return nil, giterror{UError: string(stderr), Origin: fn}
+	}
+	blobs := make(map[string]string)
+	// entries are of the form "<mode> <object> <stage>\t<file>"
+	for _, entry := range strings.Split(string(stdout), "\000") {
+		parts := strings.SplitN(entry, "\t", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		fields := strings.Fields(parts[0])
+		if len(fields) != 3 {
+			continue
+		}
+		blobs[filepath.Clean(parts[1])] = fields[1]
+	}
+	return blobs, nil
+}
+
+// UpdateRef sets the reference 'ref' (e.g., refs/gin/name) to the commit 'rev'.
+// The reference is created if it does not exist.
+// (git update-ref)
+func UpdateRef(ref, rev string) error {
+	fn := fmt.Sprintf("UpdateRef(%s, %s)", ref, rev)
+	cmd := Command("update-ref", ref, rev)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during update-ref command")
+		logstd(stdout, stderr)
+		return giterror{UError: string(stderr), Origin: fn}
+	}
+	return nil
+}
+
 // Checkwd checks whether the current working directory is in a git repository.
 // Returns NotRepository if the working directory is not inside a repository.
 // Returns NotAnnex if the working directory is inside a repository but there is no annex.
@@ -547,6 +813,10 @@ func Checkwd() error {
 	annexver, err := ConfigGet("annex.version")
 	if err != nil {
 		// Annex version config key missing: Annex not initialised
+		if NoAnnex() {
+			// plain git repository
+			return nil
+		}
 		return NotAnnex
 	}
 
@@ -564,6 +834,38 @@ func Checkwd() error {
 	return nil
 }
 
+// noAnnexCache holds the result of NoAnnex for each working directory.
+var noAnnexCache = struct {
+	sync.Mutex
+	dirs map[string]bool
+}{dirs: make(map[string]bool)}
+
+// NoAnnex returns true if git-annex is disabled for the current repository by the repository's git configuration (see SetNoAnnex).
+// All files in such a repository are added to git.
+// The result is cached for each working directory.
+func NoAnnex() bool {
+	wd, _ := os.Getwd()
+	noAnnexCache.Lock()
+	defer noAnnexCache.Unlock()
+	if noannex, ok := noAnnexCache.dirs[wd]; ok {
+		return noannex
+	}
+	cmd := Command("config", "--local", "--bool", "gin.noannex")
+	stdout, _, err := cmd.OutputError()
+	noannex := err == nil && strings.TrimSpace(string(stdout)) == "true"
+	noAnnexCache.dirs[wd] = noannex
+	return noannex
+}
+
+// SetNoAnnex marks the current repository as a plain git repository (or removes the mark), so that git-annex is not used for it.
+// (git config --local gin.noannex)
+func SetNoAnnex(noannex bool) error {
+	noAnnexCache.Lock()
+	noAnnexCache.dirs = make(map[string]bool)
+	noAnnexCache.Unlock()
+	return ConfigSet("gin.noannex", strconv.FormatBool(noannex))
+}
+
 // FindRepoRoot returns the absolute path to the root of the repository.
 // For bare repositories, it returns an empty string, but no error.
 // (git rev-parse --show-toplevel)
@@ -581,6 +883,14 @@ func FindRepoRoot(path string) (string, error) {
 // Commit records changes that have been added to the repository with a given message.
 // (git commit)
 func Commit(commitmsg string) error {
+	return CommitPaths(commitmsg, nil)
+}
+
+// CommitPaths records the current state of the given paths with a given message.
+// Other changes that have been added to the repository are not recorded and remain added.
+// If no paths are given, it is equivalent to Commit.
+// (git commit --only -- <paths>)
+func CommitPaths(commitmsg string, paths []string) error {
 	if IsDirect() {
 		// Set bare false and revert at the end of the function
 		err := setBare(false)
@@ -590,7 +900,13 @@ func Commit(commitmsg string) error {
 		defer setBare(true)
 	}
 
-	cmd := Command("commit", fmt.Sprintf("--message=%s", commitmsg))
+	cmdargs := []string{"commit", fmt.Sprintf("--message=%s", commitmsg)}
+	cmdargs = append(cmdargs, signArgs()...)
+	if len(paths) > 0 {
+		cmdargs = append(cmdargs, "--only", "--")
+		cmdargs = append(cmdargs, paths...)
+	}
+	cmd := Command(cmdargs...)
 	stdout, stderr, err := cmd.OutputError()
 
 	if err != nil {
@@ -602,11 +918,39 @@ func Commit(commitmsg string) error {
 		}
 		log.Write("Error during GitCommit")
 		logstd(stdout, stderr)
-		return fmt.Errorf(string(stderr))
+		return signError(stderr)
 	}
 	return nil
 }
 
+// signArgs returns the git commit arguments that enable signing if it is enabled with SignCommits or in the client configuration.
+func signArgs() []string {
+	conf := config.Read().Commit
+	if !SignCommits && !conf.Sign {
+		return nil
+	}
+	key := SignKey
+	if key == "" {
+		key = conf.SignKey
+	}
+	if key == "" {
+		return []string{"--gpg-sign"}
+	}
+	return []string{fmt.Sprintf("--gpg-sign=%s", key)}
+}
+
+// signError returns the error for a failed commit with the given error output, with a description if the commit failed because it could not be signed.
+func signError(stderr []byte) error {
+	serr := string(stderr)
+	switch {
+	case strings.Contains(serr, "cannot run ") || strings.Contains(serr, "cannot exec "):
+		return giterror{UError: serr, Origin: "Commit", Description: "failed to sign commit: gpg could not be run (is GnuPG installed and in the PATH?)"}
+	case strings.Contains(serr, "gpg failed to sign"):
+		return giterror{UError: serr, Origin: "Commit", Description: "failed to sign commit: gpg failed to sign the data (check the key ID and that the key is available)"}
+	}
+	return fmt.Errorf(serr)
+}
+
 // CommitEmpty performs a commit even when there are no new changes added to the index.
 // This is useful for initialising new repositories with a usable HEAD.
 // In indirect mode (non-bare repositories) simply uses git commit with the '--allow-empty' flag.
@@ -616,15 +960,17 @@ func CommitEmpty(commitmsg string) error {
 	msgarg := fmt.Sprintf("--message=%s", commitmsg)
 	var cmd shell.Cmd
 	if !IsDirect() {
-		cmd = Command("commit", "--allow-empty", msgarg)
+		cmdargs := append([]string{"commit", "--allow-empty", msgarg}, signArgs()...)
+		cmd = Command(cmdargs...)
 	} else {
 		cmd = AnnexCommand("sync", "--commit", msgarg)
+		withCredentials(&cmd)
 	}
 	stdout, stderr, err := cmd.OutputError()
 	if err != nil {
 		log.Write("Error during CommitEmpty")
 		logstd(stdout, stderr)
-		return fmt.Errorf(string(stderr))
+		return signError(stderr)
 	}
 	return nil
 }
@@ -662,12 +1008,55 @@ func DiffUpstream(paths []string, upstream string, diffchan chan<- string) {
 	return
 }
 
+// DiffNameStatus returns the names of all files in the working tree that differ from the given revision along with a single letter describing the change (A: added, D: deleted, M: modified, T: type changed).
+// Renames are reported as a deletion and an addition.
+// File names are relative to the working directory.
+// (git diff --name-status --relative <rev>)
+func DiffNameStatus(rev string, paths []string) (map[string]string, error) {
+	fn := fmt.Sprintf("DiffNameStatus(%s)", rev)
+	return diffNameStatus(fn, []string{rev}, paths)
+}
+
+// DiffCachedNameStatus returns the names of all files in the index (the changes staged for the next commit) that differ from the given revision along with a single letter describing the change (A: added, D: deleted, M: modified, T: type changed).
+// Renames are reported as a deletion and an addition.
+// File names are relative to the working directory.
+// (git diff --cached --name-status --relative <rev>)
+func DiffCachedNameStatus(rev string, paths []string) (map[string]string, error) {
+	fn := fmt.Sprintf("DiffCachedNameStatus(%s)", rev)
+	return diffNameStatus(fn, []string{"--cached", rev}, paths)
+}
+
+func diffNameStatus(fn string, revargs []string, paths []string) (map[string]string, error) {
+	rev := revargs[len(revargs)-1]
+	diffargs := append([]string{"diff", "-z", "--name-status", "--no-renames", "--relative"}, revargs...)
+	diffargs = append(diffargs, "--")
+	diffargs = append(diffargs, paths...)
+	cmd := Command(diffargs...)
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during %s", fn)
+		logstd(stdout, stderr)
+		gerr := giterror{UError: string(stderr), Origin: fn}
+		if strings.Contains(string(stderr), "unknown revision") || strings.Contains(string(stderr), "bad revision") {
+			gerr.Description = fmt.Sprintf("unknown revision or commit '%s'", rev)
+		}
+		return nil, gerr
+	}
+	changes := make(map[string]string)
+	// output alternates between status letter and file name, separated by NUL
+	fields := strings.Split(strings.TrimSuffix(string(stdout), "\000"), "\000")
+	for idx := 0; idx+1 < len(fields); idx += 2 {
+		changes[fields[idx+1]] = fields[idx]
+	}
+	return changes, nil
+}
+
 // LsFiles lists all files known to git.
 // The output channel 'lschan' is closed when this function returns.
 // (git ls-files)
 func LsFiles(args []string, lschan chan<- string) {
 	defer close(lschan)
-	cmdargs := append([]string{"ls-files"}, args...)
+	cmdargs := append([]string{"ls-files", "-z"}, args...)
 	cmd := Command(cmdargs...)
 	err := cmd.Start()
 	if err != nil {
@@ -676,8 +1065,8 @@ func LsFiles(args []string, lschan chan<- string) {
 	}
 	var line string
 	var rerr error
-	for rerr = nil; rerr == nil; line, rerr = cmd.OutReader.ReadString('\n') {
-		line = strings.TrimSuffix(line, "\n")
+	for rerr = nil; rerr == nil; line, rerr = cmd.OutReader.ReadString('\000') {
+		line = strings.TrimSuffix(line, "\000")
 		if line != "" {
 			lschan <- line
 		}
@@ -755,6 +1144,9 @@ func DescribeIndex() (string, error) {
 func Log(count uint, revrange string, paths []string, showdeletes bool) ([]GinCommit, error) {
 	logformat := `{"hash":"%H","abbrevhash":"%h","authorname":"%an","authoremail":"%ae","date":"%aI","subject":"%s","body":"%b"}`
 	cmdargs := []string{"log", "-z", fmt.Sprintf("--format=%s", logformat)}
+	if AbbrevLength > 0 {
+		cmdargs = append(cmdargs, fmt.Sprintf("--abbrev=%d", AbbrevLength))
+	}
 	if count > 0 {
 		cmdargs = append(cmdargs, fmt.Sprintf("--max-count=%d", count))
 	}
@@ -1035,6 +1427,59 @@ func IsVersion6() bool {
 	return ver == "6"
 }
 
+// CurrentBranch returns the name of the currently checked out branch.
+// (git symbolic-ref --short HEAD)
+func CurrentBranch() (string, error) {
+	cmd := Command("symbolic-ref", "--short", "HEAD")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during CurrentBranch")
+		logstd(stdout, stderr)
+		return "", giterror{UError: string(stderr), Origin: "CurrentBranch()", Description: "could not determine current branch (detached HEAD?)"}
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// Upstream returns the name of the remote-tracking branch that the current branch is configured to track (e.g., origin/master).
+// An error is returned if no upstream is configured or the remote-tracking branch does not exist.
+// (git rev-parse --abbrev-ref --symbolic-full-name @{upstream})
+func Upstream() (string, error) {
+	cmd := Command("rev-parse", "--abbrev-ref", "--symbolic-full-name", "@{upstream}")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during Upstream")
+		logstd(stdout, stderr)
+		return "", giterror{UError: string(stderr), Origin: "Upstream()", Description: "current branch has no upstream branch"}
+	}
+	return strings.TrimSpace(string(stdout)), nil
+}
+
+// UnmergedFiles returns the paths of the files that have unresolved merge conflicts.
+// (git diff --name-only --diff-filter=U)
+func UnmergedFiles() ([]string, error) {
+	cmd := Command("diff", "--name-only", "-z", "--diff-filter=U")
+	stdout, stderr, err := cmd.OutputError()
+	if err != nil {
+		log.Write("Error during UnmergedFiles")
+		logstd(stdout, stderr)
+		return nil, giterror{UError: string(stderr), Origin: "UnmergedFiles()", Description: "failed to list unmerged files"}
+	}
+	var files []string
+	for _, fname := range strings.Split(string(stdout), "\000") {
+		if fname != "" {
+			files = append(files, fname)
+		}
+	}
+	return files, nil
+}
+
+// InMerge returns true if the repository is in the middle of a merge.
+// (git rev-parse --verify MERGE_HEAD)
+func InMerge() bool {
+	cmd := Command("rev-parse", "--quiet", "--verify", "MERGE_HEAD")
+	return cmd.Run() == nil
+}
+
 // mergeAbort aborts an unfinished git merge.
 func mergeAbort() {
 	// Here, we run a git status without checking any part of the result. It
@@ -1125,15 +1570,132 @@ func GetGitVersion() (string, error) {
 	return verstr, nil
 }
 
+// HTTPCredential holds the username and password (access token) that git uses for the HTTPS addresses that start with URL.
+type HTTPCredential struct {
+	URL      string
+	Username string
+	Password string
+}
+
+// HTTPCredentials are the credentials for the HTTPS addresses of the servers the user is logged in to.
+// They are only provided to git and git-annex commands that contact a remote on one of these servers (see withCredentials), so that repositories can be accessed over HTTPS addresses without credentials in the remote URLs.
+var HTTPCredentials []HTTPCredential
+
+// matches returns true if 'remoteurl' is an HTTP(S) address on the server of the credential, i.e., it has the same scheme, host, and port as the URL of the credential.
+func (cred HTTPCredential) matches(remoteurl string) bool {
+	u, err := url.Parse(remoteurl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	credurl, err := url.Parse(cred.URL)
+	if err != nil {
+		return false
+	}
+	return u.Scheme == credurl.Scheme && strings.EqualFold(u.Hostname(), credurl.Hostname()) && urlPort(u) == urlPort(credurl)
+}
+
+// urlPort returns the port of an HTTP(S) URL, or the default port of its scheme if it has none.
+func urlPort(u *url.URL) string {
+	if port := u.Port(); port != "" {
+		return port
+	}
+	if u.Scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
+// remoteURLs returns the URLs of the given remotes.
+// Names of configured remotes are replaced by their URL and all other values are assumed to be URLs.
+// If no remotes are specified, or one of them is empty, the URLs of all configured remotes are returned.
+func remoteURLs(remotes []string) []string {
+	all := len(remotes) == 0
+	var urls, names []string
+	for _, remote := range remotes {
+		if remote == "" {
+			all = true
+		} else if strings.Contains(remote, "://") {
+			urls = append(urls, remote)
+		} else {
+			names = append(names, remote)
+		}
+	}
+	if !all && len(names) == 0 {
+		return urls
+	}
+	configured, err := RemoteShow()
+	if err != nil {
+		return urls
+	}
+	if all {
+		for _, remoteurl := range configured {
+			urls = append(urls, remoteurl)
+		}
+		return urls
+	}
+	for _, name := range names {
+		if remoteurl, ok := configured[name]; ok {
+			urls = append(urls, remoteurl)
+		}
+	}
+	return urls
+}
+
+// withCredentials provides the HTTPCredentials of the servers of the given remotes to a git or git-annex command that has not been started yet.
+// Remotes can be specified by name or URL; if none are specified, the command may contact any remote of the repository.
+// Credentials of other servers are not provided, so that hooks and other programs run by the command cannot read them.
+// Each credential is returned by a credential helper that reads it from the environment of the command, so that it is neither stored in the repository configuration nor visible in the command line.
+// Other credential helpers configured by the user are not used for the URLs of the credentials.
+func withCredentials(cmd *shell.Cmd, remotes ...string) {
+	if len(HTTPCredentials) == 0 {
+		return
+	}
+	remoteurls := remoteURLs(remotes)
+	var args, env []string
+	for _, cred := range HTTPCredentials {
+		used := false
+		for _, remoteurl := range remoteurls {
+			if cred.matches(remoteurl) {
+				used = true
+				break
+			}
+		}
+		if !used {
+			continue
+		}
+		idx := len(env) / 2
+		userenv := fmt.Sprintf("GIN_CREDENTIAL_USERNAME_%d", idx)
+		passenv := fmt.Sprintf("GIN_CREDENTIAL_PASSWORD_%d", idx)
+		helper := fmt.Sprintf(`!f() { test "$1" = get && printf 'username=%%s\npassword=%%s\n' "$%s" "$%s"; }; f`, userenv, passenv)
+		key := fmt.Sprintf("credential.%s.helper", cred.URL)
+		args = append(args, "-c", key+"=", "-c", key+"="+helper)
+		env = append(env, userenv+"="+cred.Username, passenv+"="+cred.Password)
+		log.Write("Providing HTTP credentials for %s", cred.URL)
+	}
+	if len(args) == 0 {
+		return
+	}
+	// git options must precede the subcommand (or 'annex')
+	cmd.Args = append(append([]string{cmd.Args[0]}, args...), cmd.Args[1:]...)
+	cmd.Env = append(cmd.Env, env...)
+}
+
 // Command sets up an external git command with the provided arguments and returns a GinCmd struct.
 func Command(args ...string) shell.Cmd {
+	return CommandContext(context.Background(), args...)
+}
+
+// CommandContext is like Command but includes a context.
+// The git process is killed if the context is cancelled before the command completes.
+func CommandContext(ctx context.Context, args ...string) shell.Cmd {
 	config := config.Read()
 	gitbin := config.Bin.Git
-	cmd := shell.Command(gitbin)
+	cmd := shell.CommandContext(ctx, gitbin)
 	cmd.Args = append(cmd.Args, args...)
 	env := os.Environ()
 	cmd.Env = append(env, sshEnv())
+	cmd.RetryCheck = retryIndexLock
 	workingdir, _ := filepath.Abs(".")
-	log.Write("Running shell command (Dir: %s): %s", workingdir, strings.Join(cmd.Args, " "))
+	log.Write("Running shell command (Dir: %s): %s", workingdir, RedactURLs(strings.Join(cmd.Args, " ")))
 	return cmd
 }
@@ -0,0 +1,65 @@
+package git
+
+import (
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+)
+
+// indexLockRe matches the error message of git (and git-annex) when the index lock file of the repository exists and captures the path of the lock file.
+var indexLockRe = regexp.MustCompile(`Unable to create '(.*index\.lock)': File exists`)
+
+// StaleLockAge is the minimum time since an index lock file was last modified for it to be considered stale.
+// Lock files that are younger may belong to an operation that is still running and are never removed.
+var StaleLockAge = time.Minute
+
+// RemoveStaleLock is called when a git command fails because of an index lock file that appears to be stale: it is older than StaleLockAge and no git process is running.
+// If it returns true, the lock file is removed and the command is run once more.
+// If it is not set, lock files are never removed.
+var RemoveStaleLock func(lockpath string, age time.Duration) bool
+
+// indexLockMutex serialises the handling of lock errors, so that concurrent commands that fail because of the same lock file do not ask for its removal more than once.
+var indexLockMutex sync.Mutex
+
+// retryIndexLock checks if the standard error of a failed command shows that the index lock file exists and whether the command should be run again (see shell.Cmd.RetryCheck).
+// Stale lock files are removed if RemoveStaleLock allows it.
+func retryIndexLock(stderr []byte) bool {
+	match := indexLockRe.FindSubmatch(stderr)
+	if match == nil {
+		return false
+	}
+	lockpath := string(match[1])
+	indexLockMutex.Lock()
+	defer indexLockMutex.Unlock()
+	info, err := os.Stat(lockpath)
+	if os.IsNotExist(err) {
+		// the lock has been released (or removed after a previous failure) in the meantime
+		log.Write("Index lock file %s no longer exists; retrying", lockpath)
+		return true
+	} else if err != nil {
+		log.Write("Failed to check index lock file %s: %v", lockpath, err)
+		return false
+	}
+	age := time.Since(info.ModTime())
+	if age < StaleLockAge {
+		log.Write("Index lock file %s was modified %s ago; not removing", lockpath, age)
+		return false
+	}
+	// where processes cannot be listed, only lock files that are much older are considered stale
+	if running, known := gitProcessRunning(); running || !known && age < 10*StaleLockAge {
+		log.Write("Index lock file %s may be in use (git process running: %t, known: %t); not removing", lockpath, running, known)
+		return false
+	}
+	if RemoveStaleLock == nil || !RemoveStaleLock(lockpath, age) {
+		return false
+	}
+	if err := os.Remove(lockpath); err != nil && !os.IsNotExist(err) {
+		log.Write("Failed to remove index lock file %s: %v", lockpath, err)
+		return false
+	}
+	log.Write("Removed stale index lock file %s; retrying", lockpath)
+	return true
+}
@@ -6,6 +6,7 @@ import (
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
+	"io/ioutil"
 	"net"
 	"os"
 	"path/filepath"
@@ -57,18 +58,31 @@ func MakeKeyPair() (*KeyPair, error) {
 	return &KeyPair{privStr, pubStr}, nil
 }
 
-// PrivKeyPath returns a map with the full path for all the currently available private key files indexed by the server alias for each key.
-func PrivKeyPath() map[string]string {
+// SessionKeyPath returns the full path of the private key file that is generated on login for the server with the given alias.
+func SessionKeyPath(srvalias string) (string, error) {
 	configpath, err := config.Path(false)
 	if err != nil {
-		log.Write("Error getting user's config path. Can't load key file.")
-		log.Write(err.Error())
-		return nil
+		return "", err
 	}
+	return filepath.Join(configpath, fmt.Sprintf("%s.key", srvalias)), nil
+}
+
+// PrivKeyPath returns a map with the full path for all the currently available private key files indexed by the server alias for each key.
+// If a server is configured with a key file (servers.<alias>.git.keyfile), that file is used instead of the key generated on login.
+func PrivKeyPath() map[string]string {
 	servers := config.Read().Servers
 	keys := make(map[string]string)
-	for srvalias := range servers {
-		keyfilepath := filepath.Join(configpath, fmt.Sprintf("%s.key", srvalias))
+	for srvalias, srvcfg := range servers {
+		keyfilepath := srvcfg.Git.KeyFile
+		if keyfilepath == "" {
+			var err error
+			keyfilepath, err = SessionKeyPath(srvalias)
+			if err != nil {
+				log.Write("Error getting user's config path. Can't load key file.")
+				log.Write(err.Error())
+				return nil
+			}
+		}
 		if pathExists(keyfilepath) {
 			keys[srvalias] = keyfilepath
 		}
@@ -76,6 +90,22 @@ func PrivKeyPath() map[string]string {
 	return keys
 }
 
+// ReadPublicKeyFile reads the public key that matches the given private key file.
+// The public key is expected to be in a file with the same name as the private key and the extension '.pub' (as created by ssh-keygen).
+// The key is returned in the format of an authorized_keys line, without a comment.
+func ReadPublicKeyFile(privkeyfile string) (string, error) {
+	pubkeyfile := privkeyfile + ".pub"
+	pubkeybytes, err := ioutil.ReadFile(pubkeyfile)
+	if err != nil {
+		return "", fmt.Errorf("could not read public key file '%s': %s", pubkeyfile, err)
+	}
+	pubkey, _, _, _, err := ssh.ParseAuthorizedKey(pubkeybytes)
+	if err != nil {
+		return "", fmt.Errorf("could not parse public key file '%s': %s", pubkeyfile, err)
+	}
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pubkey))), nil
+}
+
 // GetHostKey takes a git server configuration, queries the server via SSH, and
 // returns the public key of the host (in the format required for the
 // known_hosts file) and the key fingerprint.
@@ -0,0 +1,55 @@
+package git
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// gitProcessRunning returns true if a git or git-annex process is running that was not started by this program.
+// The processes are read from /proc; the second return value is false if they could not be read.
+func gitProcessRunning() (bool, bool) {
+	procdirs, err := filepath.Glob("/proc/[0-9]*")
+	if err != nil || len(procdirs) == 0 {
+		return false, false
+	}
+	for _, procdir := range procdirs {
+		comm, err := ioutil.ReadFile(filepath.Join(procdir, "comm"))
+		if err != nil {
+			// process exited
+			continue
+		}
+		if name := strings.TrimSpace(string(comm)); name != "git" && !strings.HasPrefix(name, "git-") {
+			continue
+		}
+		pid, _ := strconv.Atoi(filepath.Base(procdir))
+		if !isDescendant(pid, os.Getpid()) {
+			return true, true
+		}
+	}
+	return false, true
+}
+
+// isDescendant returns true if the process with the given pid is a child (or a child of a child, etc.) of the process 'ancestor'.
+func isDescendant(pid, ancestor int) bool {
+	for pid > 1 {
+		stat, err := ioutil.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+		if err != nil {
+			return false
+		}
+		// the parent pid is the second field after the command name, which is in parentheses and may contain spaces
+		fields := strings.Fields(string(stat[strings.LastIndex(string(stat), ")")+1:]))
+		if len(fields) < 2 {
+			return false
+		}
+		if pid, err = strconv.Atoi(fields[1]); err != nil {
+			return false
+		}
+		if pid == ancestor {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package git
+
+// gitProcessRunning reports whether a git process that was not started by this program is running.
+// Processes cannot be listed on this system, so the second return value is always false.
+func gitProcessRunning() (bool, bool) {
+	return false, false
+}
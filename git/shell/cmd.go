@@ -5,9 +5,29 @@ package shell
 import (
 	"bufio"
 	"bytes"
+	"context"
+	"os"
 	"os/exec"
+	"sync"
+	"time"
 )
 
+// running holds the processes of all commands that have been started and not
+// yet waited on, so that they can be interrupted if the program is cancelled.
+var running = struct {
+	sync.Mutex
+	procs map[*os.Process]*runningProc
+}{procs: make(map[*os.Process]*runningProc)}
+
+// runningProc holds the start time of a running process, whether it runs in
+// its own process group, and a channel that is closed when the process has
+// been waited on.
+type runningProc struct {
+	start time.Time
+	group bool
+	done  chan struct{}
+}
+
 // Cmd extends the exec.Cmd struct with convenience functions for reading piped
 // output.
 type Cmd struct {
@@ -15,22 +35,122 @@ type Cmd struct {
 	OutReader *bufio.Reader
 	ErrReader *bufio.Reader
 	Err       error
+	// RetryCheck, if set, is called with the standard error of the command
+	// when it fails in OutputError.  If it returns true, the command is run
+	// once more.
+	RetryCheck func(stderr []byte) bool
+	ctx        context.Context
+	group      bool
 }
 
 // Command returns the GinCmd struct to execute the named program with the
 // given arguments.
 func Command(name string, args ...string) Cmd {
-	cmd := exec.Command(name, args...)
+	return CommandContext(context.Background(), name, args...)
+}
+
+// CommandContext is like Command but includes a context.  The process is
+// killed if the context is cancelled before the command completes, along with
+// all the processes it started if it runs in its own process group (see
+// setProcessGroup).
+func CommandContext(ctx context.Context, name string, args ...string) Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	group := setProcessGroup(cmd)
 	outpipe, _ := cmd.StdoutPipe()
 	errpipe, _ := cmd.StderrPipe()
 	outreader := bufio.NewReader(outpipe)
 	errreader := bufio.NewReader(errpipe)
-	return Cmd{cmd, outreader, errreader, nil}
+	return Cmd{Cmd: cmd, OutReader: outreader, ErrReader: errreader, ctx: ctx, group: group}
+}
+
+// Start starts the command and keeps track of its process until Wait is
+// called, so that it can be stopped by Interrupt.  If the command is started
+// in its own process group and its context is cancelled, the whole group is
+// killed.
+func (cmd Cmd) Start() error {
+	running.Lock()
+	defer running.Unlock()
+	if err := cmd.Cmd.Start(); err != nil {
+		return err
+	}
+	proc := &runningProc{start: time.Now(), group: cmd.group, done: make(chan struct{})}
+	running.procs[cmd.Process] = proc
+	if cmd.group && cmd.ctx != nil && cmd.ctx.Done() != nil {
+		go func(p *os.Process) {
+			select {
+			case <-cmd.ctx.Done():
+				signalGroup(p, os.Kill)
+			case <-proc.done:
+			}
+		}(cmd.Process)
+	}
+	return nil
+}
+
+// Wait waits for the command to exit and stops tracking its process.  The
+// duration of the command is logged and recorded (see Profile).
+func (cmd Cmd) Wait() error {
+	err := cmd.Cmd.Wait()
+	running.Lock()
+	proc, ok := running.procs[cmd.Process]
+	delete(running.procs, cmd.Process)
+	running.Unlock()
+	if ok {
+		close(proc.done)
+		record(cmd.Args, time.Since(proc.start))
+	}
+	return err
+}
+
+// Run starts the command and waits for it to complete.
+func (cmd Cmd) Run() error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	return cmd.Wait()
+}
+
+// Interrupt stops all commands that are currently running.  Each command (its
+// process group, if it has its own) is sent an interrupt signal, which allows
+// git to clean up lock files.  On systems where interrupt signals are not
+// supported (Windows), the process is killed.
+func Interrupt() {
+	running.Lock()
+	defer running.Unlock()
+	for proc, rp := range running.procs {
+		var err error
+		if rp.group {
+			err = signalGroup(proc, os.Interrupt)
+		} else {
+			err = proc.Signal(os.Interrupt)
+		}
+		if err != nil {
+			proc.Kill()
+		}
+	}
 }
 
 // OutputError runs the command and returns the standard output and standard
-// error as two byte slices.
+// error as two byte slices.  If the command fails and its RetryCheck returns
+// true, the command is run once more (unless it reads from a standard input
+// that cannot be replayed) and the output of the second run is returned.
 func (cmd *Cmd) OutputError() ([]byte, []byte, error) {
+	stdout, stderr, err := cmd.outputError()
+	if err == nil || cmd.RetryCheck == nil || cmd.Stdin != nil || !cmd.RetryCheck(stderr) {
+		return stdout, stderr, err
+	}
+	ctx := cmd.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	retry := CommandContext(ctx, cmd.Path, cmd.Args[1:]...)
+	retry.Env = cmd.Env
+	retry.Dir = cmd.Dir
+	*cmd = retry
+	return cmd.outputError()
+}
+
+func (cmd *Cmd) outputError() ([]byte, []byte, error) {
 	var bout, berr bytes.Buffer
 	cmd.Stdout = &bout
 	cmd.Stderr = &berr
@@ -40,8 +160,10 @@ func (cmd *Cmd) OutputError() ([]byte, []byte, error) {
 
 // Output runs the command and returns its standard output.
 func (cmd *Cmd) Output() ([]byte, error) {
-	cmd.Stdout = nil
-	return cmd.Cmd.Output()
+	var bout bytes.Buffer
+	cmd.Stdout = &bout
+	err := cmd.Run()
+	return bout.Bytes(), err
 }
 
 // Error is used to return errors caused by web requests, API calls, or system
@@ -0,0 +1,46 @@
+//go:build !windows
+// +build !windows
+
+package shell
+
+import (
+	"os"
+	"os/exec"
+	"sync"
+	"syscall"
+)
+
+// noTerminal is set if the program has no controlling terminal.
+var noTerminal struct {
+	sync.Once
+	value bool
+}
+
+// setProcessGroup makes the command start in a new process group, so that the
+// processes it starts (e.g., the git and ssh processes of git-annex) can be
+// signalled along with it.  This is only done if the program has no
+// controlling terminal: processes in a background process group are stopped
+// when they read from the terminal, e.g., for an ssh passphrase or a password
+// prompt.  With a terminal, the processes stay in the process group of the
+// program and receive the interrupt signal of the terminal (Ctrl-C) directly.
+// Returns true if a new process group is used.
+func setProcessGroup(cmd *exec.Cmd) bool {
+	noTerminal.Do(func() {
+		tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+		if err != nil {
+			noTerminal.value = true
+			return
+		}
+		tty.Close()
+	})
+	if !noTerminal.value {
+		return false
+	}
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return true
+}
+
+// signalGroup sends a signal to the process group of the given process.
+func signalGroup(proc *os.Process, sig os.Signal) error {
+	return syscall.Kill(-proc.Pid, sig.(syscall.Signal))
+}
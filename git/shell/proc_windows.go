@@ -0,0 +1,17 @@
+package shell
+
+import (
+	"os"
+	"os/exec"
+)
+
+// setProcessGroup does nothing on Windows, where processes can't be signalled
+// as a group.
+func setProcessGroup(cmd *exec.Cmd) bool {
+	return false
+}
+
+// signalGroup sends a signal to the given process only.
+func signalGroup(proc *os.Process, sig os.Signal) error {
+	return proc.Signal(sig)
+}
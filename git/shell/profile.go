@@ -0,0 +1,91 @@
+package shell
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/G-Node/gin-cli/ginclient/log"
+)
+
+// Timing holds the number of invocations and the total wall-clock duration of
+// the commands of one type.
+type Timing struct {
+	// Command is the program and subcommand, e.g., "git annex whereis".
+	Command  string
+	Count    int
+	Duration time.Duration
+}
+
+// timings holds the accumulated durations of all commands that have
+// completed, by command type.
+var timings = struct {
+	sync.Mutex
+	bytype map[string]*Timing
+}{bytype: make(map[string]*Timing)}
+
+// commandType returns the program name and subcommand of a command line,
+// e.g., "git annex whereis" for "/usr/bin/git annex whereis --json file".
+func commandType(args []string) string {
+	if len(args) == 0 {
+		return ""
+	}
+	prog := filepath.Base(args[0])
+	parts := []string{strings.TrimSuffix(prog, filepath.Ext(prog))}
+	skipvalue := false
+	for _, arg := range args[1:] {
+		if skipvalue {
+			// value of the preceding option
+			skipvalue = false
+			continue
+		}
+		if arg == "-c" || arg == "-C" {
+			skipvalue = true
+			continue
+		}
+		if strings.HasPrefix(arg, "-") {
+			continue
+		}
+		parts = append(parts, arg)
+		if arg != "annex" {
+			break
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// record logs the duration of a completed command and adds it to the
+// timings of its type.
+func record(args []string, duration time.Duration) {
+	ctype := commandType(args)
+	log.Write("Command finished in %s: %s", duration, ctype)
+	timings.Lock()
+	defer timings.Unlock()
+	timing, ok := timings.bytype[ctype]
+	if !ok {
+		timing = &Timing{Command: ctype}
+		timings.bytype[ctype] = timing
+	}
+	timing.Count++
+	timing.Duration += duration
+}
+
+// Profile returns the timings of all commands that have completed, by
+// command type, sorted by total duration (longest first).
+func Profile() []Timing {
+	timings.Lock()
+	defer timings.Unlock()
+	profile := make([]Timing, 0, len(timings.bytype))
+	for _, timing := range timings.bytype {
+		profile = append(profile, *timing)
+	}
+	sort.Slice(profile, func(i, j int) bool {
+		if profile[i].Duration == profile[j].Duration {
+			return profile[i].Command < profile[j].Command
+		}
+		return profile[i].Duration > profile[j].Duration
+	})
+	return profile
+}
@@ -62,7 +62,13 @@ func main() {
 	rootCmd.SetVersionTemplate("{{ .Version }}")
 
 	// Engage
-	rootCmd.Execute()
+	// Errors returned by Execute are usage errors (unknown commands or flags,
+	// wrong number of arguments); cobra has already printed them
+	if err := rootCmd.Execute(); err != nil {
+		log.Write("Exiting with usage error: %s", err)
+		log.Close()
+		os.Exit(gincmd.ExitUsage)
+	}
 
 	log.Write("EXIT OK")
 }
@@ -0,0 +1,120 @@
+package web
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// HTTPAuth holds the credentials for an HTTP basic authentication layer in front of a server (e.g., a reverse proxy).
+// They are independent of the user's GIN account and access token (see Client.HTTPAuth).
+type HTTPAuth struct {
+	Username string
+	Password string
+}
+
+// netrcPath returns the path of the user's netrc file: the value of the NETRC environment variable if it is set, otherwise .netrc in the home directory (_netrc on Windows, if .netrc does not exist).
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	path := filepath.Join(home, ".netrc")
+	if runtime.GOOS == "windows" {
+		if _, err := os.Stat(path); os.IsNotExist(err) {
+			path = filepath.Join(home, "_netrc")
+		}
+	}
+	return path
+}
+
+// NetrcAuth returns the credentials for the given host from the user's netrc file (see netrcPath).
+// The entry for the host ('machine') is used if it exists, otherwise the 'default' entry.
+// If the file does not exist or has no entry for the host, nil is returned without an error.
+func NetrcAuth(host string) (*HTTPAuth, error) {
+	path := netrcPath()
+	if path == "" {
+		return nil, nil
+	}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, weberror{UError: err.Error(), Origin: "NetrcAuth()", Description: "failed to read netrc file " + path}
+	}
+	return parseNetrc(string(data), host), nil
+}
+
+// parseNetrc returns the credentials for host from the content of a netrc file, or nil if there is no entry for the host and no default entry.
+func parseNetrc(content string, host string) *HTTPAuth {
+	var machine, defaultauth *HTTPAuth
+	var current *HTTPAuth
+	tokens := netrcTokens(content)
+	for idx := 0; idx < len(tokens); idx++ {
+		switch tokens[idx] {
+		case "machine":
+			current = nil
+			if idx+1 < len(tokens) {
+				idx++
+				if tokens[idx] == host && machine == nil {
+					machine = &HTTPAuth{}
+					current = machine
+				}
+			}
+		case "default":
+			current = nil
+			if defaultauth == nil {
+				defaultauth = &HTTPAuth{}
+				current = defaultauth
+			}
+		case "login", "password", "account":
+			if idx+1 >= len(tokens) {
+				break
+			}
+			idx++
+			if current == nil {
+				continue
+			}
+			if tokens[idx-1] == "login" {
+				current.Username = tokens[idx]
+			} else if tokens[idx-1] == "password" {
+				current.Password = tokens[idx]
+			}
+		}
+	}
+	if machine != nil {
+		return machine
+	}
+	return defaultauth
+}
+
+// netrcTokens splits the content of a netrc file into tokens, leaving out comments and macro definitions.
+// A macro definition ('macdef') runs until the next empty line.
+func netrcTokens(content string) []string {
+	var tokens []string
+	inmacro := false
+	for _, line := range strings.Split(content, "\n") {
+		fields := strings.Fields(line)
+		if inmacro {
+			inmacro = len(fields) > 0
+			continue
+		}
+		if len(fields) > 0 && strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		for idx, field := range fields {
+			if field == "macdef" {
+				fields = fields[:idx]
+				inmacro = true
+				break
+			}
+		}
+		tokens = append(tokens, fields...)
+	}
+	return tokens
+}
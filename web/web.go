@@ -11,12 +11,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/G-Node/gin-cli/ginclient/config"
 	"github.com/G-Node/gin-cli/ginclient/log"
@@ -37,7 +41,10 @@ type UserToken struct {
 type Client struct {
 	Host string
 	UserToken
-	web *http.Client
+	// HTTPAuth holds the credentials for an HTTP basic authentication layer in front of the server (e.g., a reverse proxy).
+	// If set, they are sent with every request in the Authorization header and the user token is sent as the 'token' query parameter instead.
+	HTTPAuth *HTTPAuth
+	web      *http.Client
 }
 
 func urlJoin(parts ...string) string {
@@ -49,6 +56,11 @@ func urlJoin(parts ...string) string {
 	}
 
 	for _, part := range parts[1:] {
+		// keep query strings out of the path
+		if idx := strings.Index(part, "?"); idx >= 0 {
+			u.RawQuery = part[idx+1:]
+			part = part[:idx]
+		}
 		u.Path = path.Join(u.Path, part)
 	}
 	return u.String()
@@ -79,11 +91,10 @@ func (cl *Client) Get(address string) (*http.Response, error) {
 	}
 	req.Header.Set("content-type", "application/jsonAuthorization")
 	log.Write("Performing GET: %s", req.URL)
-	if cl.Token != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", cl.Token))
-	}
+	cl.setAuth(req)
 	resp, err := cl.web.Do(req)
 	if err != nil {
+		err = redactRequestURL(err, requrl)
 		return nil, weberror{UError: err.Error(), Origin: fmt.Sprintf("Get(%s)", requrl), Description: parseServerError(err)}
 	}
 	return resp, nil
@@ -103,22 +114,58 @@ func (cl *Client) Post(address string, data interface{}) (*http.Response, error)
 		return nil, weberror{UError: err.Error(), Origin: fn}
 	}
 	req.Header.Set("content-type", "application/jsonAuthorization")
-	if cl.Token != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", cl.Token))
-		log.Write("Added token to POST")
-	}
 	log.Write("Performing POST: %s", req.URL)
+	cl.setAuth(req)
 	resp, err := cl.web.Do(req)
 	if err != nil {
+		err = redactRequestURL(err, requrl)
 		err = weberror{UError: err.Error(), Origin: fn, Description: parseServerError(err)}
 	}
 	return resp, err
 }
 
+// setAuth adds the user token to the request.
+// If the client has credentials for an HTTP authentication layer (HTTPAuth), these are sent in the Authorization header, which can only hold one set of credentials, and the token is sent as the 'token' query parameter instead.
+// The request URL should be logged before calling setAuth, so that the token is not written to the log.
+func (cl *Client) setAuth(req *http.Request) {
+	if cl.HTTPAuth != nil {
+		req.SetBasicAuth(cl.HTTPAuth.Username, cl.HTTPAuth.Password)
+		log.Write("Added HTTP authentication for %s", cl.HTTPAuth.Username)
+		if cl.Token != "" {
+			query := req.URL.Query()
+			query.Set("token", cl.Token)
+			req.URL.RawQuery = query.Encode()
+			log.Write("Added token to %s", req.Method)
+		}
+		return
+	}
+	if cl.Token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("token %s", cl.Token))
+		log.Write("Added token to %s", req.Method)
+	}
+}
+
+// redactRequestURL replaces the URL in the error of a failed request with 'requrl', the URL of the request before the token was added (see setAuth).
+func redactRequestURL(err error, requrl string) error {
+	if uerr, ok := err.(*url.Error); ok {
+		uerr.URL = requrl
+	}
+	return err
+}
+
+// basicAuthError returns the error for a request with the user's password through an HTTP authentication layer, whose credentials occupy the Authorization header.
+func basicAuthError(fn string) error {
+	return weberror{UError: "HTTP authentication layer configured", Origin: fn, Description: "a password cannot be sent through the HTTP authentication layer of the server; use an access token instead"}
+}
+
 // GetBasicAuth sends a GET request to address.
 // The username and password are used to perform Basic authentication.
+// This is not possible if the client has credentials for an HTTP authentication layer (HTTPAuth).
 func (cl *Client) GetBasicAuth(address, username, password string) (*http.Response, error) {
 	fn := fmt.Sprintf("GetBasicAuth(%s)", address)
+	if cl.HTTPAuth != nil {
+		return nil, basicAuthError(fn)
+	}
 	requrl := urlJoin(cl.Host, address)
 	req, err := http.NewRequest("GET", requrl, nil)
 	if err != nil {
@@ -136,8 +183,12 @@ func (cl *Client) GetBasicAuth(address, username, password string) (*http.Respon
 
 // PostBasicAuth sends a POST request to address with the provided data.
 // The username and password are used to perform Basic authentication.
+// This is not possible if the client has credentials for an HTTP authentication layer (HTTPAuth).
 func (cl *Client) PostBasicAuth(address, username, password string, data interface{}) (*http.Response, error) {
 	fn := fmt.Sprintf("PostBasicAuth(%s)", address)
+	if cl.HTTPAuth != nil {
+		return nil, basicAuthError(fn)
+	}
 	datajson, err := json.Marshal(data)
 	if err != nil {
 		return nil, weberror{UError: err.Error(), Origin: fn}
@@ -166,21 +217,60 @@ func (cl *Client) Delete(address string) (*http.Response, error) {
 		return nil, weberror{UError: err.Error(), Origin: fn}
 	}
 	req.Header.Set("content-type", "application/jsonAuthorization")
-	if cl.Token != "" {
-		req.Header.Set("Authorization", fmt.Sprintf("token %s", cl.Token))
-		log.Write("Added token to DELETE")
-	}
 	log.Write("Performing DELETE: %s", req.URL)
+	cl.setAuth(req)
 	resp, err := cl.web.Do(req)
 	if err != nil {
+		err = redactRequestURL(err, requrl)
 		err = weberror{UError: err.Error(), Origin: fn, Description: parseServerError(err)}
 	}
 	return resp, err
 }
 
+// MaxIdleConnsPerHost is the number of idle connections to each server that are kept open for reuse by subsequent requests.
+// Commands that make several requests to the same server (e.g., listing repositories and then querying each one) reuse these connections instead of opening a new connection (and performing a new TLS handshake) for each request.
+var MaxIdleConnsPerHost = 8
+
+// IdleConnTimeout is the time after which an idle connection is closed.
+var IdleConnTimeout = 90 * time.Second
+
+// sharedTransport is the transport used by all clients, so that connections are reused across clients for the same server.
+var sharedTransport struct {
+	sync.Once
+	*http.Transport
+}
+
+// transport returns the shared transport, creating it on first use with the current values of MaxIdleConnsPerHost and IdleConnTimeout.
+// The remaining settings are the same as those of http.DefaultTransport, with keep-alives enabled.
+func transport() *http.Transport {
+	sharedTransport.Do(func() {
+		sharedTransport.Transport = &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			MaxIdleConns:          100,
+			MaxIdleConnsPerHost:   MaxIdleConnsPerHost,
+			IdleConnTimeout:       IdleConnTimeout,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ExpectContinueTimeout: 1 * time.Second,
+		}
+	})
+	return sharedTransport.Transport
+}
+
 // New creates a new client for a given host.
 func New(host string) *Client {
-	return &Client{Host: host, web: &http.Client{}}
+	return &Client{Host: host, web: &http.Client{Transport: transport()}}
+}
+
+// tokenPath returns the path of the token file for the server with the given alias.
+// Each configured server has its own token file, so users can be logged in to multiple servers at the same time.
+func tokenPath(srvalias string) string {
+	path, _ := config.Path(false) // Error can only occur when create=True
+	filename := fmt.Sprintf("%s.token", srvalias)
+	return filepath.Join(path, filename)
 }
 
 // LoadToken reads the username and auth token from the token file and sets the
@@ -190,9 +280,7 @@ func (ut *UserToken) LoadToken(srvalias string) error {
 	if ut.Username != "" && ut.Token != "" {
 		return nil
 	}
-	path, _ := config.Path(false) // Error can only occur when create=True
-	filename := fmt.Sprintf("%s.token", srvalias)
-	filepath := filepath.Join(path, filename)
+	filepath := tokenPath(srvalias)
 	log.Write("Loading token [server %s] %s", srvalias, filepath)
 	file, err := os.Open(filepath)
 	if err != nil {
@@ -213,12 +301,10 @@ func (ut *UserToken) LoadToken(srvalias string) error {
 // StoreToken saves the username and auth token to the token file.
 func (ut *UserToken) StoreToken(srvalias string) error {
 	fn := fmt.Sprintf("StoreToken(%s)", srvalias)
-	path, err := config.Path(true)
-	if err != nil {
+	if _, err := config.Path(true); err != nil {
 		return weberror{UError: err.Error(), Origin: fn}
 	}
-	filename := fmt.Sprintf("%s.token", srvalias)
-	filepath := filepath.Join(path, filename)
+	filepath := tokenPath(srvalias)
 	log.Write("Saving token [server %s] %s", srvalias, filepath)
 	file, err := os.Create(filepath)
 	if err != nil {
@@ -237,12 +323,10 @@ func (ut *UserToken) StoreToken(srvalias string) error {
 	return nil
 }
 
-// DeleteToken deletes the token file if it exists (for finalising a logout).
+// DeleteToken deletes the token file of the server with the given alias if it exists (for finalising a logout).
+// Tokens for other servers are not affected.
 func DeleteToken(srvalias string) error {
-	path, _ := config.Path(false) // Error can only occur when create=True
-	filename := fmt.Sprintf("%s.token", srvalias)
-	tokenpath := filepath.Join(path, filename)
-	err := os.Remove(tokenpath)
+	err := os.Remove(tokenPath(srvalias))
 	if err != nil {
 		return weberror{UError: err.Error(), Origin: "DeleteToken()", Description: "could not delete token"}
 	}
@@ -250,8 +334,14 @@ func DeleteToken(srvalias string) error {
 	return nil
 }
 
+// maxDrainBytes is the maximum number of unread bytes that are discarded from a response body before it is closed.
+// Larger remainders are not read and the connection is closed instead of being reused.
+const maxDrainBytes = 64 << 10
+
 // CloseRes closes a given result buffer (for use with defer).
+// Any unread data (up to maxDrainBytes) is discarded first, so that the connection can be reused for the next request.
 func CloseRes(b io.ReadCloser) {
+	_, _ = io.CopyN(ioutil.Discard, b, maxDrainBytes)
 	b.Close()
 }
 